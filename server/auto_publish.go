@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	trdlGit "github.com/werf/trdl/server/pkg/git"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+const (
+	storageKeyAutoPublishState = "auto_publish_state"
+
+	defaultAutoPublishInterval = 5 * time.Minute
+	autoPublishMaxInterval     = 6 * time.Hour
+)
+
+// getRemoteBranchHead is replaced in tests to avoid the network access.
+var getRemoteBranchHead = func(url, branchName, username, password string) (string, error) {
+	var auth transport.AuthMethod
+	if username != "" && password != "" {
+		auth = &http.BasicAuth{
+			Username: username,
+			Password: password,
+		}
+	}
+
+	return trdlGit.GetRemoteBranchHead(url, branchName, auth)
+}
+
+type autoPublishState struct {
+	LastCheck time.Time `json:"last_check"`
+	Failures  int       `json:"failures"`
+	LastError string    `json:"last_error,omitempty"`
+	// TaskUUID is the enqueued publish task, the next check is delayed until the task is completed
+	TaskUUID string `json:"task_uuid,omitempty"`
+}
+
+// checkInterval doubles the interval after each failure up to autoPublishMaxInterval.
+func (s *autoPublishState) checkInterval(interval time.Duration) time.Duration {
+	for i := 0; i < s.Failures && interval < autoPublishMaxInterval; i++ {
+		interval *= 2
+	}
+
+	if interval > autoPublishMaxInterval {
+		return autoPublishMaxInterval
+	}
+
+	return interval
+}
+
+func (s *autoPublishState) setResult(err error) {
+	if err != nil {
+		s.Failures++
+		s.LastError = err.Error()
+	} else {
+		s.Failures = 0
+		s.LastError = ""
+	}
+}
+
+// autoPublish enqueues the publish task if the head of the trdl channels branch is changed since the last publication.
+// The publish task performs the usual ancestor and signature checks, the failed task delays the next check.
+func (b *Backend) autoPublish(ctx context.Context, storage logical.Storage) error {
+	cfg, err := getConfiguration(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get configuration: %w", err)
+	}
+
+	if cfg == nil || !cfg.AutoPublish {
+		return nil
+	}
+
+	state, err := getAutoPublishState(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	if state.TaskUUID != "" {
+		task, err := tasks_manager.GetTask(ctx, storage, state.TaskUUID)
+		if err != nil {
+			return fmt.Errorf("unable to get publish task %q: %w", state.TaskUUID, err)
+		}
+
+		if task != nil && !task.Completed() {
+			return nil
+		}
+
+		// the task removed from the task history is considered succeeded
+		if task != nil && !task.Succeeded() {
+			state.setResult(fmt.Errorf("publish task %q %s: %s", task.UUID, strings.ToLower(task.Status), task.Reason))
+		} else {
+			state.setResult(nil)
+		}
+		state.TaskUUID = ""
+
+		if err := putAutoPublishState(ctx, storage, state); err != nil {
+			return err
+		}
+	}
+
+	interval := cfg.AutoPublishInterval
+	if interval <= 0 {
+		interval = defaultAutoPublishInterval
+	}
+
+	if SystemClock.Since(state.LastCheck) < state.checkInterval(interval) {
+		return nil
+	}
+
+	state.LastCheck = SystemClock.Now()
+	taskUUID, err := b.enqueueAutoPublishTask(ctx, storage, cfg)
+	state.setResult(err)
+	state.TaskUUID = taskUUID
+
+	if err := putAutoPublishState(ctx, storage, state); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// enqueueAutoPublishTask returns empty UUID if the head of the trdl channels branch is already published.
+func (b *Backend) enqueueAutoPublishTask(ctx context.Context, storage logical.Storage, cfg *configuration) (string, error) {
+	gitUsername, gitPassword, err := getGitCredential(ctx, storage, "", "")
+	if err != nil {
+		return "", err
+	}
+
+	headCommit, err := getRemoteBranchHead(cfg.GitRepoUrl, cfg.GitTrdlChannelsBranch, gitUsername, gitPassword)
+	if err != nil {
+		return "", fmt.Errorf("unable to get trdl channels branch head: %w", err)
+	}
+
+	lastPublishedGitCommit, err := getLastPublishedGitCommit(ctx, storage, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	if headCommit == lastPublishedGitCommit {
+		b.Logger().Debug(fmt.Sprintf("Auto publish: head commit %q not changed", headCommit))
+		return "", nil
+	}
+
+	taskFunc, err := b.newPublishTaskFunc(ctx, storage, cfg, gitUsername, gitPassword)
+	if err != nil {
+		return "", err
+	}
+
+	taskUUID, err := b.TasksManager.AddTask(context.Background(), storage, publishTaskOptions(), taskFunc)
+	if err != nil {
+		return "", fmt.Errorf("unable to add publish task: %w", err)
+	}
+
+	b.Logger().Info(fmt.Sprintf("Auto publish: head commit %q of the trdl channels branch is not published: added publish task %q", headCommit, taskUUID))
+
+	return taskUUID, nil
+}
+
+func getAutoPublishState(ctx context.Context, storage logical.Storage) (*autoPublishState, error) {
+	entry, err := storage.Get(ctx, storageKeyAutoPublishState)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %q from storage: %w", storageKeyAutoPublishState, err)
+	}
+
+	state := &autoPublishState{}
+	if entry == nil {
+		return state, nil
+	}
+
+	if err := json.Unmarshal(entry.Value, state); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %q: %w", storageKeyAutoPublishState, err)
+	}
+
+	return state, nil
+}
+
+func putAutoPublishState(ctx context.Context, storage logical.Storage, state *autoPublishState) error {
+	entry, err := logical.StorageEntryJSON(storageKeyAutoPublishState, state)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put %q into storage: %w", storageKeyAutoPublishState, err)
+	}
+
+	return nil
+}
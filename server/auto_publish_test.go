@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+type AutoPublishSuite struct {
+	CommonSuite
+	clock                   *util.FixedClock
+	remoteHead              string
+	remoteHeadErr           error
+	origSystemClock         util.Clock
+	origGetRemoteBranchHead func(url, branchName, username, password string) (string, error)
+}
+
+func (suite *AutoPublishSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+
+	suite.clock = util.NewFixedClock(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+	suite.remoteHead = "3d1a0e7b7d8b2f5f2f0a8c1d7b64f1e3a8c0d1e2"
+	suite.remoteHeadErr = nil
+
+	suite.origSystemClock = SystemClock
+	suite.origGetRemoteBranchHead = getRemoteBranchHead
+	SystemClock = suite.clock
+	getRemoteBranchHead = func(_, _, _, _ string) (string, error) {
+		return suite.remoteHead, suite.remoteHeadErr
+	}
+
+	cfg := completeConfiguration()
+	cfg.AutoPublish = true
+	err := putConfiguration(suite.ctx, suite.storage, cfg)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *AutoPublishSuite) TearDownTest() {
+	SystemClock = suite.origSystemClock
+	getRemoteBranchHead = suite.origGetRemoteBranchHead
+}
+
+func (suite *AutoPublishSuite) TestDisabled() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	assert.Nil(suite.T(), suite.backend.autoPublish(suite.ctx, suite.storage))
+
+	state, err := getAutoPublishState(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), &autoPublishState{}, state)
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func (suite *AutoPublishSuite) TestHeadNotChanged() {
+	suite.remoteHead = completeConfiguration().InitialLastPublishedGitCommit
+
+	assert.Nil(suite.T(), suite.backend.autoPublish(suite.ctx, suite.storage))
+
+	state, err := getAutoPublishState(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), &autoPublishState{LastCheck: suite.clock.Now()}, state)
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func (suite *AutoPublishSuite) TestHeadChanged() {
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", publishTaskOptions()).Return("UUID", nil)
+
+	assert.Nil(suite.T(), suite.backend.autoPublish(suite.ctx, suite.storage))
+
+	state, err := getAutoPublishState(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), &autoPublishState{LastCheck: suite.clock.Now(), TaskUUID: "UUID"}, state)
+
+	// the head is not checked again until the interval is passed
+	suite.clock.NowTime = suite.clock.NowTime.Add(defaultAutoPublishInterval - time.Second)
+	assert.Nil(suite.T(), suite.backend.autoPublish(suite.ctx, suite.storage))
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertNumberOfCalls(suite.T(), "AddTask", 1)
+}
+
+func (suite *AutoPublishSuite) TestBackoff() {
+	suite.remoteHeadErr = errors.New("connection refused")
+
+	startTime := suite.clock.Now()
+	for _, test := range []struct {
+		after            time.Duration
+		expectedFailures int
+	}{
+		{after: 0, expectedFailures: 1},
+		{after: defaultAutoPublishInterval, expectedFailures: 1},
+		{after: 2 * defaultAutoPublishInterval, expectedFailures: 2},
+		{after: 5 * defaultAutoPublishInterval, expectedFailures: 2},
+		{after: 6 * defaultAutoPublishInterval, expectedFailures: 3},
+	} {
+		suite.clock.NowTime = startTime.Add(test.after)
+		_ = suite.backend.autoPublish(suite.ctx, suite.storage)
+
+		state, err := getAutoPublishState(suite.ctx, suite.storage)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), test.expectedFailures, state.Failures, "after %s", test.after)
+		assert.Contains(suite.T(), state.LastError, "connection refused")
+	}
+
+	// the successful check resets the failures
+	suite.remoteHeadErr = nil
+	suite.remoteHead = completeConfiguration().InitialLastPublishedGitCommit
+	suite.clock.NowTime = startTime.Add(14 * defaultAutoPublishInterval)
+	assert.Nil(suite.T(), suite.backend.autoPublish(suite.ctx, suite.storage))
+
+	state, err := getAutoPublishState(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, state.Failures)
+	assert.Empty(suite.T(), state.LastError)
+}
+
+func (suite *AutoPublishSuite) TestCheckInterval() {
+	state := &autoPublishState{Failures: 100}
+	assert.Equal(suite.T(), autoPublishMaxInterval, state.checkInterval(defaultAutoPublishInterval))
+}
+
+func TestAutoPublish(t *testing.T) {
+	suite.Run(t, new(AutoPublishSuite))
+}
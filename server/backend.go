@@ -8,6 +8,8 @@ import (
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/werf/trdl/server/pkg/migrations"
+	"github.com/werf/trdl/server/pkg/notifications"
 	"github.com/werf/trdl/server/pkg/publisher"
 	"github.com/werf/trdl/server/pkg/tasks_manager"
 )
@@ -26,6 +28,7 @@ type Backend struct {
 	TasksManager    tasks_manager.ActionsInterface
 	Publisher       publisher.Interface
 	BackendPeriodic BackendPeriodicInterface
+	StorageMigrator *migrations.Migrator
 }
 
 var _ logical.Factory = Factory
@@ -51,17 +54,29 @@ func NewBackend(logger hclog.Logger) (*Backend, error) {
 	tasksManager := tasks_manager.NewManager(logger)
 	publisher := publisher.NewPublisher(logger)
 
+	storageMigrator, err := NewStorageMigrator(logger)
+	if err != nil {
+		return nil, err
+	}
+
 	b := &Backend{
-		TasksManager: tasksManager,
-		Publisher:    publisher,
+		TasksManager:    tasksManager,
+		Publisher:       publisher,
+		StorageMigrator: storageMigrator,
 	}
 	b.BackendPeriodic = b
 
 	b.Backend = &framework.Backend{
 		BackendType: logical.TypeLogical,
 		Help:        backendHelp,
+		PathsSpecial: &logical.Paths{
+			// the webhook request is verified by the payload signature or the GitLab token instead of the Vault token
+			Unauthenticated: []string{pathPatternGitWebhook},
+		},
 	}
 
+	b.RegisterTaskFactories(tasksManager)
+	tasksManager.AddTaskCompletedHook(b.notifyTaskCompletedHook(notifications.NewNotifier(logger)))
 	b.InitPaths(tasksManager, publisher)
 	b.InitPeriodicFunc(tasksManager, publisher)
 	return b, nil
@@ -73,7 +88,14 @@ func (b *Backend) InitPaths(modules ...BackendModuleInterface) {
 		[]*framework.Path{
 			releasePath(b),
 			publishPath(b),
+			gitWebhookPath(b),
+			statusPath(b),
+			verifyPath(b),
+			consistentSnapshotPath(b),
+			mirrorResyncPath(b),
 		},
+		rootPaths(b),
+		backupPaths(b),
 	)
 
 	for _, module := range modules {
@@ -83,6 +105,10 @@ func (b *Backend) InitPaths(modules ...BackendModuleInterface) {
 
 func (b *Backend) InitPeriodicFunc(modules ...BackendModuleInterface) {
 	b.PeriodicFunc = func(ctx context.Context, request *logical.Request) error {
+		if err := b.migrateStorage(context.Background(), request.Storage); err != nil {
+			return err
+		}
+
 		for _, module := range modules {
 			if err := module.PeriodicFunc(context.Background(), request); err != nil {
 				return fmt.Errorf("backend module periodic task failed: %w", err)
@@ -35,7 +35,7 @@ func (m *MockedTasksManager) PeriodicFunc(_ context.Context, _ *logical.Request)
 	return nil
 }
 
-func (m *MockedTasksManager) RunTask(_ context.Context, _ logical.Storage, _ func(ctx context.Context, storage logical.Storage) error) (string, error) {
+func (m *MockedTasksManager) RunTask(_ context.Context, _ logical.Storage, _ tasks_manager.TaskOptions, _ func(ctx context.Context, storage logical.Storage) error) (string, error) {
 	m.Called()
 
 	if !m.IsBusy {
@@ -45,6 +45,16 @@ func (m *MockedTasksManager) RunTask(_ context.Context, _ logical.Storage, _ fun
 	}
 }
 
+func (m *MockedTasksManager) RunExclusive(_ context.Context, _ logical.Storage, f func() error) error {
+	m.Called()
+	return f()
+}
+
+func (m *MockedTasksManager) AddTask(_ context.Context, _ logical.Storage, opts tasks_manager.TaskOptions, _ func(ctx context.Context, storage logical.Storage) error) (string, error) {
+	m.Called(opts)
+	return "UUID", nil
+}
+
 type MockedPublisher struct {
 	mock.Mock
 	publisher.Interface
@@ -61,8 +71,66 @@ func (m *MockedPublisher) PeriodicFunc(_ context.Context, _ *logical.Request) er
 }
 
 func (m *MockedPublisher) GetRepository(_ context.Context, _ logical.Storage, _ publisher.RepositoryOptions) (publisher.RepositoryInterface, error) {
-	m.Called()
-	return nil, nil
+	args := m.Called()
+
+	var err error
+	if len(args) > 1 {
+		err = args.Error(1)
+	}
+
+	repository, _ := args.Get(0).(publisher.RepositoryInterface)
+	return repository, err
+}
+
+func (m *MockedPublisher) GetPGPSigningKeyFingerprint(_ context.Context, _ logical.Storage) (string, error) {
+	args := m.Called()
+	return args.String(0), nil
+}
+
+func (m *MockedPublisher) PrepareRoot(_ context.Context, _ logical.Storage, _ publisher.RepositoryInterface, offlineRoot *publisher.OfflineRootOptions) (*publisher.PendingRoot, error) {
+	args := m.Called(offlineRoot)
+	return args.Get(0).(*publisher.PendingRoot), nil
+}
+
+func (m *MockedPublisher) PublishRoot(_ context.Context, _ logical.Storage, _ publisher.RepositoryInterface) error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockedRepository struct {
+	mock.Mock
+	publisher.RepositoryInterface
+}
+
+func (m *MockedRepository) GetRolesMetadata(_ context.Context) (map[string]publisher.RoleMetadata, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]publisher.RoleMetadata), nil
+}
+
+func (m *MockedRepository) GetVersions(_ context.Context) (map[string]int64, error) {
+	args := m.Called()
+	return args.Get(0).(map[string]int64), nil
+}
+
+func (m *MockedRepository) ResyncMirror(_ context.Context, name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *MockedRepository) TakeMirrorReplications() []publisher.MirrorReplication {
+	args := m.Called()
+	replications, _ := args.Get(0).([]publisher.MirrorReplication)
+	return replications
+}
+
+func (m *MockedRepository) Verify(_ context.Context) (*publisher.VerificationReport, error) {
+	args := m.Called()
+	return args.Get(0).(*publisher.VerificationReport), nil
+}
+
+func (m *MockedRepository) EnableConsistentSnapshot(_ context.Context) (bool, error) {
+	args := m.Called()
+	return args.Bool(0), args.Error(1)
 }
 
 type MockedBackendPeriodic struct {
@@ -138,7 +206,7 @@ func (suite *BackendSuite) TestInitPeriodicFunc() {
 
 	suite.backend.InitPeriodicFunc(suite.mockedTasksManager, suite.mockedPublisher)
 	if assert.NotNil(suite.T(), suite.backend.PeriodicFunc) {
-		_ = suite.backend.PeriodicFunc(context.Background(), nil)
+		_ = suite.backend.PeriodicFunc(context.Background(), suite.req)
 	}
 
 	suite.mockedTasksManager.AssertExpectations(suite.T())
@@ -162,5 +230,6 @@ func TestFactory(t *testing.T) {
 		assert.NotNil(t, impl.TasksManager)
 		assert.NotEmpty(t, impl.Paths)
 		assert.NotNil(t, impl.PeriodicFunc)
+		assert.Equal(t, []string{pathPatternGitWebhook}, impl.SpecialPaths().Unauthenticated)
 	}
 }
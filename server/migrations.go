@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/trdl/server/pkg/migrations"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+// storageMigrations are applied in order, a new migration is appended with the next version
+// and must keep working for the storage dumps in testdata/storage.
+func storageMigrations() []migrations.Migration {
+	return []migrations.Migration{
+		{
+			Version:     1,
+			Description: "compress and chunk the task logs",
+			Migrate:     tasks_manager.MigrateUncompressedTaskLogs,
+		},
+	}
+}
+
+func NewStorageMigrator(logger hclog.Logger) (*migrations.Migrator, error) {
+	return migrations.NewMigrator(logger, storageMigrations()...)
+}
+
+// HandleRequest migrates the storage on the first request after the plugin upgrade.
+func (b *Backend) HandleRequest(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+	if err := b.migrateStorage(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	return b.Backend.HandleRequest(ctx, req)
+}
+
+func (b *Backend) migrateStorage(ctx context.Context, storage logical.Storage) error {
+	if b.StorageMigrator == nil || storage == nil {
+		return nil
+	}
+
+	if err := b.StorageMigrator.Migrate(ctx, storage); err != nil {
+		return fmt.Errorf("unable to migrate storage: %w", err)
+	}
+
+	return nil
+}
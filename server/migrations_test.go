@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/migrations"
+)
+
+type StorageMigrationsSuite struct {
+	CommonSuite
+}
+
+func (suite *StorageMigrationsSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+
+	storageMigrator, err := NewStorageMigrator(hclog.NewNullLogger())
+	assert.Nil(suite.T(), err)
+	suite.backend.StorageMigrator = storageMigrator
+}
+
+// loadStorageDump puts the storage dump of the previous plugin version into the storage.
+func (suite *StorageMigrationsSuite) loadStorageDump(path string) map[string]string {
+	data, err := os.ReadFile(path)
+	assert.Nil(suite.T(), err)
+
+	dump := map[string]string{}
+	assert.Nil(suite.T(), json.Unmarshal(data, &dump))
+
+	for key, value := range dump {
+		err := suite.storage.Put(suite.ctx, &logical.StorageEntry{Key: key, Value: []byte(value)})
+		assert.Nil(suite.T(), err)
+	}
+
+	return dump
+}
+
+func (suite *StorageMigrationsSuite) readConfiguration() *logical.Response {
+	suite.req.Path = "configure"
+	suite.req.Operation = logical.ReadOperation
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+
+	return resp
+}
+
+func (suite *StorageMigrationsSuite) schemaVersion() int {
+	version, found, err := migrations.GetSchemaVersion(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), found)
+
+	return version
+}
+
+func (suite *StorageMigrationsSuite) TestNewMount() {
+	resp := suite.readConfiguration()
+	assert.Equal(suite.T(), errorResponseConfigurationNotFound, resp)
+	assert.Equal(suite.T(), len(storageMigrations()), suite.schemaVersion())
+}
+
+func (suite *StorageMigrationsSuite) TestUpgrade() {
+	dumps, err := filepath.Glob("testdata/storage/*.json")
+	assert.Nil(suite.T(), err)
+	assert.NotEmpty(suite.T(), dumps)
+
+	for _, dump := range dumps {
+		suite.Run(filepath.Base(dump), func() {
+			suite.SetupTest()
+			suite.loadStorageDump(dump)
+
+			resp := suite.readConfiguration()
+			if assert.NotNil(suite.T(), resp) {
+				assert.False(suite.T(), resp.IsError())
+			}
+
+			assert.Equal(suite.T(), len(storageMigrations()), suite.schemaVersion())
+
+			// the task logs are chunked
+			keys, err := suite.storage.List(suite.ctx, "task_log/")
+			assert.Nil(suite.T(), err)
+			for _, key := range keys {
+				assert.True(suite.T(), strings.HasSuffix(key, "/"), key)
+			}
+		})
+	}
+}
+
+func (suite *StorageMigrationsSuite) TestUpgrade_V0() {
+	dump := suite.loadStorageDump("testdata/storage/v0.json")
+
+	resp := suite.readConfiguration()
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), "https://github.com/werf/trdl-test-project.git", resp.Data[fieldNameGitRepoUrl])
+	}
+
+	uuid := "3f1b7a5e-8a6d-4d54-9a2e-1d6b0e0b6b11"
+	entry, err := suite.storage.Get(suite.ctx, "task_log/"+uuid+"/0")
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), entry) {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(entry.Value))
+		assert.Nil(suite.T(), err)
+		log, err := io.ReadAll(gzipReader)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), dump["task_log/"+uuid], string(log))
+	}
+
+	for _, key := range []string{"trusted_pgp_public_key/developer", "last_published_git_commit", "completed_task/" + uuid} {
+		entry, err := suite.storage.Get(suite.ctx, key)
+		assert.Nil(suite.T(), err)
+		if assert.NotNil(suite.T(), entry) {
+			assert.Equal(suite.T(), dump[key], string(entry.Value))
+		}
+	}
+}
+
+func (suite *StorageMigrationsSuite) TestDowngrade() {
+	err := migrations.PutSchemaVersion(suite.ctx, suite.storage, len(storageMigrations())+1)
+	assert.Nil(suite.T(), err)
+
+	suite.req.Path = "configure"
+	suite.req.Operation = logical.ReadOperation
+
+	_, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	if assert.NotNil(suite.T(), err) {
+		assert.Contains(suite.T(), err.Error(), "the plugin downgrade is not supported")
+	}
+}
+
+func TestBackendStorageMigrations(t *testing.T) {
+	suite.Run(t, new(StorageMigrationsSuite))
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/trdl/server/pkg/backup"
+	"github.com/werf/trdl/server/pkg/migrations"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+const (
+	fieldNameBackupPassphrase          = "passphrase"
+	fieldNameBackupRecipientPublicKeys = "recipient_public_keys"
+	fieldNameBackupIncludeTaskHistory  = "include_task_history"
+	fieldNameBackupBundle              = "bundle"
+	fieldNameBackupPrivateKey          = "private_key"
+	fieldNameBackupOverwrite           = "overwrite"
+)
+
+func backupPaths(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: `backup$`,
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameBackupPassphrase: {
+					Type:         framework.TypeString,
+					Description:  "The passphrase the bundle is encrypted with. Required if the recipient public keys are not set",
+					DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+				},
+				fieldNameBackupRecipientPublicKeys: {
+					Type:        framework.TypeStringSlice,
+					Description: "The armored PGP public keys the bundle is encrypted for, any of the private keys decrypts the bundle",
+				},
+				fieldNameBackupIncludeTaskHistory: {
+					Type:        framework.TypeBool,
+					Description: "Include the completed tasks and their logs. The queued and running tasks are never included",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathBackup,
+					Summary:  pathBackupHelpSyn,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathBackup,
+					Summary:  pathBackupHelpSyn,
+				},
+			},
+
+			HelpSynopsis:    pathBackupHelpSyn,
+			HelpDescription: pathBackupHelpDesc,
+		},
+		{
+			Pattern: `restore$`,
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameBackupBundle: {
+					Type:        framework.TypeString,
+					Description: "The armored bundle returned by the backup endpoint",
+					Required:    true,
+				},
+				fieldNameBackupPassphrase: {
+					Type:         framework.TypeString,
+					Description:  "The passphrase the bundle is encrypted with or the passphrase of the private key",
+					DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+				},
+				fieldNameBackupPrivateKey: {
+					Type:         framework.TypeString,
+					Description:  "The armored PGP private key of the recipient the bundle is encrypted for",
+					DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+				},
+				fieldNameBackupOverwrite: {
+					Type:        framework.TypeBool,
+					Description: "Delete the current plugin state before restoring the configured plugin",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathRestore,
+					Summary:  pathRestoreHelpSyn,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRestore,
+					Summary:  pathRestoreHelpSyn,
+				},
+			},
+
+			HelpSynopsis:    pathRestoreHelpSyn,
+			HelpDescription: pathRestoreHelpDesc,
+		},
+	}
+}
+
+func (b *Backend) pathBackup(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	opts := backup.EncryptOptions{
+		Passphrase:          fields.Get(fieldNameBackupPassphrase).(string),
+		RecipientPublicKeys: fields.Get(fieldNameBackupRecipientPublicKeys).([]string),
+	}
+
+	if opts.Passphrase != "" && len(opts.RecipientPublicKeys) > 0 {
+		return logical.ErrorResponse("fields %q and %q are mutually exclusive", fieldNameBackupPassphrase, fieldNameBackupRecipientPublicKeys), nil
+	}
+
+	excludePrefixes := tasks_manager.ActiveTaskStorageKeyPrefixes
+	if !fields.Get(fieldNameBackupIncludeTaskHistory).(bool) {
+		excludePrefixes = append(append([]string{}, excludePrefixes...), tasks_manager.TaskHistoryStorageKeyPrefixes...)
+	}
+
+	bundle, err := backup.Export(ctx, req.Storage, excludePrefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	armored, err := backup.Encrypt(bundle, opts)
+	if err == backup.ErrNoEncryptionKey {
+		return logical.ErrorResponse("field %q or %q must be set", fieldNameBackupPassphrase, fieldNameBackupRecipientPublicKeys), nil
+	} else if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"bundle":         armored,
+			"schema_version": bundle.SchemaVersion,
+			"created":        bundle.Created.Format(time.RFC3339),
+			"entries":        len(bundle.Entries),
+		},
+	}, nil
+}
+
+func (b *Backend) pathRestore(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if errResp := util.CheckRequiredFields(req, fields); errResp != nil {
+		return errResp, nil
+	}
+
+	bundle, err := backup.Decrypt(fields.Get(fieldNameBackupBundle).(string), backup.DecryptOptions{
+		Passphrase: fields.Get(fieldNameBackupPassphrase).(string),
+		PrivateKey: fields.Get(fieldNameBackupPrivateKey).(string),
+	})
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if bundle.SchemaVersion > backup.BundleSchemaVersion {
+		return logical.ErrorResponse("bundle schema version %d is not supported: the bundle is created by the newer plugin version", bundle.SchemaVersion), nil
+	}
+
+	hasSchemaVersion := false
+	for _, entry := range bundle.Entries {
+		if entry.Key != migrations.StorageKeySchemaVersion {
+			continue
+		}
+		hasSchemaVersion = true
+
+		if b.StorageMigrator == nil {
+			continue
+		}
+
+		if version, err := strconv.Atoi(string(entry.Value)); err != nil || version > b.StorageMigrator.SchemaVersion() {
+			return logical.ErrorResponse("bundle storage schema version %q is not supported: the bundle is created by the newer plugin version", string(entry.Value)), nil
+		}
+	}
+
+	overwrite := fields.Get(fieldNameBackupOverwrite).(bool)
+
+	// the tasks cannot be added or started while the storage is checked, restored and migrated
+	var errResp *logical.Response
+	if err := b.TasksManager.RunExclusive(ctx, req.Storage, func() error {
+		for _, prefix := range tasks_manager.ActiveTaskStorageKeyPrefixes {
+			list, err := req.Storage.List(ctx, prefix)
+			if err != nil {
+				return fmt.Errorf("unable to list %q in storage: %w", prefix, err)
+			}
+
+			if len(list) > 0 {
+				errResp = logical.ErrorResponse("busy: unable to restore while the tasks are queued or running")
+				return nil
+			}
+		}
+
+		cfg, err := getConfiguration(ctx, req.Storage)
+		if err != nil {
+			return fmt.Errorf("unable to get configuration from storage: %w", err)
+		}
+
+		if cfg != nil && !overwrite {
+			errResp = logical.ErrorResponse("the plugin is already configured: set %q to replace the plugin state", fieldNameBackupOverwrite)
+			return nil
+		}
+
+		// the current entries are deleted only after all bundle entries are put
+		restore := backup.Restore
+		if overwrite {
+			restore = backup.Replace
+		}
+
+		if err := restore(ctx, req.Storage, bundle); err != nil {
+			return err
+		}
+
+		// the bundle of the plugin without the storage schema version is migrated from the start
+		if !hasSchemaVersion {
+			if err := migrations.DeleteSchemaVersion(ctx, req.Storage); err != nil {
+				return err
+			}
+		}
+
+		if b.StorageMigrator != nil {
+			b.StorageMigrator.Reset()
+			if err := b.migrateStorage(ctx, req.Storage); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if errResp != nil {
+		return errResp, nil
+	}
+
+	b.Logger().Info(fmt.Sprintf("Restored %d storage entries from the bundle created at %s", len(bundle.Entries), bundle.Created.Format(time.RFC3339)))
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"schema_version": bundle.SchemaVersion,
+			"created":        bundle.Created.Format(time.RFC3339),
+			"entries":        len(bundle.Entries),
+		},
+	}, nil
+}
+
+const (
+	pathBackupHelpSyn  = "Export the plugin state as the encrypted bundle"
+	pathBackupHelpDesc = "The bundle contains all plugin storage: the configuration, the TUF repository keys, the PGP signing key, the trusted PGP public keys, the build secrets, the last published Git commit and the other state. The bundle is the armored OpenPGP message encrypted with the passphrase or for the recipient PGP public keys. The keys kept in Vault Transit are referenced only and must be backed up with Transit"
+
+	pathRestoreHelpSyn  = "Restore the plugin state from the encrypted bundle"
+	pathRestoreHelpDesc = "The bundle is restored into the new mount or, with the overwrite option, replaces the state of the configured plugin. The bundle of the older plugin version is restored and migrated to the current storage schema version, the bundle of the newer plugin version is rejected. The restoring is rejected while the tasks are queued or running, no tasks are added until the restoring is finished. The state is replaced only after all bundle entries are restored"
+)
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/backup"
+	"github.com/werf/trdl/server/pkg/migrations"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+type PathBackupCallbacksSuite struct {
+	CommonSuite
+}
+
+func (suite *PathBackupCallbacksSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+	suite.mockedTasksManager.On("RunExclusive")
+
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	for key, value := range map[string]string{
+		"tuf_repository_keys":          "keys",
+		"queued_task/uuid1":            "queued",
+		"completed_task/uuid2":         "completed",
+		"task_log/uuid2":               "log",
+		"trusted_pgp_public_key/name1": "public key",
+	} {
+		err := suite.storage.Put(suite.ctx, &logical.StorageEntry{Key: key, Value: []byte(value)})
+		assert.Nil(suite.T(), err)
+	}
+}
+
+func (suite *PathBackupCallbacksSuite) backup(data map[string]interface{}) *logical.Response {
+	suite.req.Path = "backup"
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Storage = suite.storage
+	suite.req.Data = data
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+
+	return resp
+}
+
+func (suite *PathBackupCallbacksSuite) restore(storage logical.Storage, data map[string]interface{}) *logical.Response {
+	suite.req.Path = "restore"
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Storage = storage
+	suite.req.Data = data
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+
+	return resp
+}
+
+func (suite *PathBackupCallbacksSuite) TestBackup_NoEncryptionKey() {
+	resp := suite.backup(map[string]interface{}{})
+	assert.Equal(suite.T(), logical.ErrorResponse("field %q or %q must be set", fieldNameBackupPassphrase, fieldNameBackupRecipientPublicKeys), resp)
+}
+
+func (suite *PathBackupCallbacksSuite) TestBackupAndRestore() {
+	suite.Run("without task history", func() {
+		resp := suite.backup(map[string]interface{}{fieldNameBackupPassphrase: "secret"})
+		if !assert.NotNil(suite.T(), resp) || !assert.False(suite.T(), resp.IsError()) {
+			return
+		}
+		assert.Equal(suite.T(), 3, resp.Data["entries"])
+
+		storage := &logical.InmemStorage{}
+		resp = suite.restore(storage, map[string]interface{}{
+			fieldNameBackupBundle:     resp.Data["bundle"],
+			fieldNameBackupPassphrase: "secret",
+		})
+		if assert.NotNil(suite.T(), resp) {
+			assert.False(suite.T(), resp.IsError())
+			assert.Equal(suite.T(), 3, resp.Data["entries"])
+		}
+
+		keys, err := logical.CollectKeys(suite.ctx, storage)
+		assert.Nil(suite.T(), err)
+		assert.ElementsMatch(suite.T(), []string{storageKeyConfiguration, "tuf_repository_keys", "trusted_pgp_public_key/name1"}, keys)
+
+		cfg, err := getConfiguration(suite.ctx, storage)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), completeConfiguration(), cfg)
+	})
+
+	suite.Run("with task history", func() {
+		resp := suite.backup(map[string]interface{}{fieldNameBackupPassphrase: "secret", fieldNameBackupIncludeTaskHistory: true})
+		if assert.NotNil(suite.T(), resp) {
+			assert.Equal(suite.T(), 5, resp.Data["entries"])
+		}
+	})
+}
+
+func (suite *PathBackupCallbacksSuite) TestRestore_WrongPassphrase() {
+	resp := suite.backup(map[string]interface{}{fieldNameBackupPassphrase: "secret"})
+	if !assert.NotNil(suite.T(), resp) {
+		return
+	}
+
+	resp = suite.restore(&logical.InmemStorage{}, map[string]interface{}{
+		fieldNameBackupBundle:     resp.Data["bundle"],
+		fieldNameBackupPassphrase: "wrong",
+	})
+	if assert.NotNil(suite.T(), resp) {
+		assert.True(suite.T(), resp.IsError())
+	}
+}
+
+func (suite *PathBackupCallbacksSuite) TestRestore_Configured() {
+	resp := suite.backup(map[string]interface{}{fieldNameBackupPassphrase: "secret"})
+	if !assert.NotNil(suite.T(), resp) {
+		return
+	}
+	bundle := resp.Data["bundle"]
+
+	storage := &logical.InmemStorage{}
+	err := putConfiguration(suite.ctx, storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+	err = storage.Put(suite.ctx, &logical.StorageEntry{Key: "stale", Value: []byte("stale")})
+	assert.Nil(suite.T(), err)
+
+	suite.Run("without overwrite", func() {
+		resp := suite.restore(storage, map[string]interface{}{
+			fieldNameBackupBundle:     bundle,
+			fieldNameBackupPassphrase: "secret",
+		})
+		assert.Equal(suite.T(), logical.ErrorResponse("the plugin is already configured: set %q to replace the plugin state", fieldNameBackupOverwrite), resp)
+	})
+
+	suite.Run("with overwrite", func() {
+		resp := suite.restore(storage, map[string]interface{}{
+			fieldNameBackupBundle:     bundle,
+			fieldNameBackupPassphrase: "secret",
+			fieldNameBackupOverwrite:  true,
+		})
+		if assert.NotNil(suite.T(), resp) {
+			assert.False(suite.T(), resp.IsError())
+		}
+
+		e, err := storage.Get(suite.ctx, "stale")
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), e)
+
+		suite.mockedTasksManager.AssertCalled(suite.T(), "RunExclusive")
+	})
+}
+
+func (suite *PathBackupCallbacksSuite) TestRestore_Busy() {
+	resp := suite.backup(map[string]interface{}{fieldNameBackupPassphrase: "secret"})
+	if !assert.NotNil(suite.T(), resp) {
+		return
+	}
+
+	resp = suite.restore(suite.storage, map[string]interface{}{
+		fieldNameBackupBundle:     resp.Data["bundle"],
+		fieldNameBackupPassphrase: "secret",
+		fieldNameBackupOverwrite:  true,
+	})
+	assert.Equal(suite.T(), logical.ErrorResponse("busy: unable to restore while the tasks are queued or running"), resp)
+}
+
+func (suite *PathBackupCallbacksSuite) TestRestore_Migrated() {
+	storageMigrator, err := NewStorageMigrator(hclog.NewNullLogger())
+	assert.Nil(suite.T(), err)
+	suite.backend.StorageMigrator = storageMigrator
+
+	// the bundle of the plugin version without the storage schema version
+	err = suite.storage.Put(suite.ctx, &logical.StorageEntry{Key: "task_log/uuid2", Value: []byte("log")})
+	assert.Nil(suite.T(), err)
+	bundle, err := backup.Export(suite.ctx, suite.storage, tasks_manager.ActiveTaskStorageKeyPrefixes)
+	assert.Nil(suite.T(), err)
+	armored, err := backup.Encrypt(bundle, backup.EncryptOptions{Passphrase: "secret"})
+	assert.Nil(suite.T(), err)
+
+	// the new mount is migrated by the restore request itself
+	storage := &logical.InmemStorage{}
+	resp := suite.restore(storage, map[string]interface{}{
+		fieldNameBackupBundle:     armored,
+		fieldNameBackupPassphrase: "secret",
+	})
+	if assert.NotNil(suite.T(), resp) {
+		assert.False(suite.T(), resp.IsError())
+	}
+
+	version, found, err := migrations.GetSchemaVersion(suite.ctx, storage)
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), found)
+	assert.Equal(suite.T(), storageMigrator.SchemaVersion(), version)
+
+	entry, err := storage.Get(suite.ctx, "task_log/uuid2")
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), entry)
+
+	suite.Run("newer storage schema version", func() {
+		bundle.Entries = append(bundle.Entries, backup.Entry{Key: migrations.StorageKeySchemaVersion, Value: []byte("100")})
+		armored, err := backup.Encrypt(bundle, backup.EncryptOptions{Passphrase: "secret"})
+		assert.Nil(suite.T(), err)
+
+		resp := suite.restore(&logical.InmemStorage{}, map[string]interface{}{
+			fieldNameBackupBundle:     armored,
+			fieldNameBackupPassphrase: "secret",
+		})
+		if assert.NotNil(suite.T(), resp) {
+			assert.True(suite.T(), resp.IsError())
+		}
+	})
+}
+
+func TestBackendPathBackupCallbacks(t *testing.T) {
+	suite.Run(t, new(PathBackupCallbacksSuite))
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/fatih/structs"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/werf/trdl/server/pkg/builder"
 	"github.com/werf/trdl/server/pkg/git"
+	"github.com/werf/trdl/server/pkg/notifications"
 	"github.com/werf/trdl/server/pkg/pgp"
 	"github.com/werf/trdl/server/pkg/publisher"
 	"github.com/werf/trdl/server/pkg/secrets"
@@ -27,29 +33,53 @@ const (
 	fieldNameS3Region                                   = "s3_region"
 	fieldNameS3AccessKeyID                              = "s3_access_key_id"
 	fieldNameS3SecretAccessKey                          = "s3_secret_access_key"
+	fieldNameS3CredentialsSource                        = "s3_credentials_source"
+	fieldNameS3VaultAddress                             = "s3_vault_address"
+	fieldNameS3VaultToken                               = "s3_vault_token"
+	fieldNameS3VaultCredentialsPath                     = "s3_vault_credentials_path"
+	fieldNameS3CredentialProcess                        = "s3_credential_process"
 	fieldNameS3BucketName                               = "s3_bucket_name"
+	fieldNameS3ForcePathStyle                           = "s3_force_path_style"
+	fieldNameS3CACertificate                            = "s3_ca_certificate"
+	fieldNameS3ServerSideEncryption                     = "s3_server_side_encryption"
+	fieldNameS3SSEKMSKeyID                              = "s3_sse_kms_key_id"
+	fieldNameS3StorageClass                             = "s3_storage_class"
+	fieldNameConsistentSnapshot                         = "consistent_snapshot"
+	fieldNameOfflineRootPublicKeys                      = "offline_root_public_keys"
+	fieldNameOfflineRootThreshold                       = "offline_root_threshold"
+	fieldNameBuildBackend                               = "build_backend"
+	fieldNameAutoPublish                                = "auto_publish"
+	fieldNameAutoPublishInterval                        = "auto_publish_interval"
+	fieldNameReleaseStagingConcurrency                  = "release_staging_concurrency"
+	fieldNameValidate                                   = "validate"
 
 	storageKeyConfiguration = "configuration"
 )
 
 var errorResponseConfigurationNotFound = logical.ErrorResponse("Configuration not found")
 
+// configurationSensitiveFields are not returned by the read, the <field>_set flag is returned instead.
+var configurationSensitiveFields = []string{fieldNameS3SecretAccessKey, fieldNameS3VaultToken}
+
 func configurePaths(b *Backend) []*framework.Path {
 	return framework.PathAppend(
 		[]*framework.Path{
 			configurePath(b),
 			configureLastPublishedGitCommitPath(b),
+			configureGitWebhookPath(b),
 		},
 		git.CredentialsPaths(),
 		pgp.Paths(),
 		secrets.Paths(),
+		notifications.Paths(),
 	)
 }
 
 func configurePath(b *Backend) *framework.Path {
 	return &framework.Path{
-		Pattern:      "configure/?",
-		HelpSynopsis: "Configure the plugin",
+		Pattern:         "configure/?",
+		HelpSynopsis:    "Configure the plugin",
+		HelpDescription: "The configuration is replaced by the POST request, the PATCH request updates only the specified fields and keeps the stored ones (the field set to null is reset to the default).",
 		Fields: map[string]*framework.FieldSchema{
 			fieldNameGitRepoUrl: {
 				Type:        framework.TypeString,
@@ -98,13 +128,113 @@ func configurePath(b *Backend) *framework.Path {
 			},
 			fieldNameS3AccessKeyID: {
 				Type:        framework.TypeString,
-				Description: "The S3 storage access key id",
-				Required:    true,
+				Description: "The S3 storage access key id. Required for the static credentials",
+				Required:    false,
 			},
 			fieldNameS3SecretAccessKey: {
+				Type:         framework.TypeString,
+				Description:  "The S3 storage secret access key. Required for the static credentials. The field is write-only",
+				Required:     false,
+				DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+			},
+			fieldNameS3CredentialsSource: {
+				Type:          framework.TypeString,
+				Description:   "The source of the S3 storage credentials: static (the access key id and secret access key of the configuration), vault (the short-lived credentials of the Vault AWS secrets engine) or process (the command printing the credentials in the AWS credential_process format)",
+				Required:      false,
+				Default:       publisher.S3CredentialsSourceStatic,
+				AllowedValues: []interface{}{publisher.S3CredentialsSourceStatic, publisher.S3CredentialsSourceVault, publisher.S3CredentialsSourceProcess},
+			},
+			fieldNameS3VaultAddress: {
 				Type:        framework.TypeString,
-				Description: "The S3 storage access key id",
-				Required:    true,
+				Description: "The address of the Vault the S3 storage credentials are read from, e.g. https://127.0.0.1:8200. Required for the vault credentials source",
+				Required:    false,
+			},
+			fieldNameS3VaultToken: {
+				Type:         framework.TypeString,
+				Description:  "The token the S3 storage credentials are read with, the token is not renewed by the plugin (use the periodic token). Required for the vault credentials source. The field is write-only",
+				Required:     false,
+				DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+			},
+			fieldNameS3VaultCredentialsPath: {
+				Type:        framework.TypeString,
+				Description: "The path of the Vault AWS secrets engine credentials, e.g. aws/sts/trdl or aws/creds/trdl. Required for the vault credentials source",
+				Required:    false,
+			},
+			fieldNameS3CredentialProcess: {
+				Type:        framework.TypeString,
+				Description: "The command run on the plugin host to get the S3 storage credentials in the AWS credential_process format. Required for the process credentials source",
+				Required:    false,
+			},
+			fieldNameS3ForcePathStyle: {
+				Type:        framework.TypeBool,
+				Description: "Use path-style addressing of the S3 storage bucket. Path-style addressing is used by default for the endpoints other than AWS",
+				Required:    false,
+			},
+			fieldNameS3CACertificate: {
+				Type:        framework.TypeString,
+				Description: "The PEM-encoded CA bundle the S3 storage endpoint certificate is verified with instead of the system certificates",
+				Required:    false,
+			},
+			fieldNameS3ServerSideEncryption: {
+				Type:          framework.TypeString,
+				Description:   "The server-side encryption of the uploaded objects: AES256 (SSE-S3) or aws:kms (SSE-KMS). The bucket default is used if not set",
+				Required:      false,
+				AllowedValues: []interface{}{s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms},
+			},
+			fieldNameS3SSEKMSKeyID: {
+				Type:        framework.TypeString,
+				Description: "The KMS key id for the aws:kms server-side encryption. The AWS managed key is used if not set",
+				Required:    false,
+			},
+			fieldNameS3StorageClass: {
+				Type:        framework.TypeString,
+				Description: "The storage class of the uploaded objects, e.g. STANDARD or STANDARD_IA. The bucket default is used if not set",
+				Required:    false,
+			},
+			fieldNameConsistentSnapshot: {
+				Type:        framework.TypeBool,
+				Description: "Initialize the new TUF repository with consistent snapshots: the targets are stored under the hash-prefixed names and the metadata under the version-prefixed names. The existing repository is migrated with the consistent_snapshot endpoint",
+				Required:    false,
+			},
+			fieldNameOfflineRootPublicKeys: {
+				Type:        framework.TypeStringSlice,
+				Description: `The TUF root role public keys kept offline, e.g. {"keytype": "ed25519", "scheme": "ed25519", "keyval": {"public": "<hex>"}}. The new TUF repository is initialized without the root key and published once root.json is signed by the offline keys. The existing repository is switched to the offline keys with the root/pending endpoint`,
+				Required:    false,
+			},
+			fieldNameOfflineRootThreshold: {
+				Type:        framework.TypeInt,
+				Description: "The number of the offline root keys root.json must be signed by",
+				Required:    false,
+				Default:     1,
+			},
+			fieldNameBuildBackend: {
+				Type:          framework.TypeString,
+				Description:   "The backend to build release artifacts with: docker (docker buildx), podman, buildah or local (runs commands on the plugin host without containers)",
+				Required:      false,
+				Default:       builder.DefaultBackend,
+				AllowedValues: []interface{}{builder.BackendDocker, builder.BackendPodman, builder.BackendBuildah, builder.BackendLocal},
+			},
+			fieldNameAutoPublish: {
+				Type:        framework.TypeBool,
+				Description: "Enqueue the publish task automatically when the head of the trdl channels branch is changed",
+				Required:    false,
+			},
+			fieldNameAutoPublishInterval: {
+				Type:        framework.TypeDurationSecond,
+				Description: "The interval of polling the trdl channels branch for automatic publishing. The interval is doubled after each failure",
+				Required:    false,
+				Default:     defaultAutoPublishInterval.String(),
+			},
+			fieldNameReleaseStagingConcurrency: {
+				Type:        framework.TypeInt,
+				Description: "The number of the release artifacts signed and uploaded at the same time. The artifacts are spooled to the temporary files of the plugin host while waiting for the upload",
+				Required:    false,
+				Default:     publisher.DefaultReleaseStagingConcurrency,
+			},
+			fieldNameValidate: {
+				Type:        framework.TypeBool,
+				Description: "Test-clone the Git repository and probe the S3 storage bucket by writing, reading and deleting an object before saving the configuration",
+				Required:    false,
 			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -116,6 +246,10 @@ func configurePath(b *Backend) *framework.Path {
 				Description: "Configure plugin",
 				Callback:    b.pathConfigureCreateOrUpdate,
 			},
+			logical.PatchOperation: &framework.PathOperation{
+				Description: "Update the specified fields of the plugin configuration, the other fields are kept. The field set to null is reset to the default",
+				Callback:    b.pathConfigurePatch,
+			},
 			logical.ReadOperation: &framework.PathOperation{
 				Description: "Read the plugin configuration",
 				Callback:    b.pathConfigureRead,
@@ -133,6 +267,42 @@ func (b *Backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.
 		return errResp, nil
 	}
 
+	return b.configure(ctx, req.Storage, fields)
+}
+
+// pathConfigurePatch merges the request fields into the stored configuration (JSON merge patch semantics).
+func (b *Backend) pathConfigurePatch(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	storedCfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if storedCfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	data := storedCfg.fieldsData()
+	for fieldName, value := range req.Data {
+		if _, ok := fields.Schema[fieldName]; !ok {
+			continue
+		}
+
+		if value == nil {
+			delete(data, fieldName)
+		} else {
+			data[fieldName] = value
+		}
+	}
+
+	patchedFields := &framework.FieldData{Raw: data, Schema: fields.Schema}
+	if errResp := util.CheckRequiredFieldData(patchedFields); errResp != nil {
+		return errResp, nil
+	}
+
+	return b.configure(ctx, req.Storage, patchedFields)
+}
+
+func (b *Backend) configure(ctx context.Context, storage logical.Storage, fields *framework.FieldData) (*logical.Response, error) {
 	cfg := &configuration{
 		GitRepoUrl:                    fields.Get(fieldNameGitRepoUrl).(string),
 		GitTrdlPath:                   fields.Get(fieldNameGitTrdlPath).(string),
@@ -140,20 +310,114 @@ func (b *Backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.
 		GitTrdlChannelsBranch:         fields.Get(fieldNameGitTrdlChannelsBranch).(string),
 		InitialLastPublishedGitCommit: fields.Get(fieldNameInitialLastPublishedGitCommit).(string),
 		RequiredNumberOfVerifiedSignaturesOnCommit: fields.Get(fieldNameRequiredNumberOfVerifiedSignaturesOnCommit).(int),
-		S3Endpoint:        fields.Get(fieldNameS3Endpoint).(string),
-		S3Region:          fields.Get(fieldNameS3Region).(string),
-		S3AccessKeyID:     fields.Get(fieldNameS3AccessKeyID).(string),
-		S3SecretAccessKey: fields.Get(fieldNameS3SecretAccessKey).(string),
-		S3BucketName:      fields.Get(fieldNameS3BucketName).(string),
+		S3Endpoint:                fields.Get(fieldNameS3Endpoint).(string),
+		S3Region:                  fields.Get(fieldNameS3Region).(string),
+		S3AccessKeyID:             fields.Get(fieldNameS3AccessKeyID).(string),
+		S3SecretAccessKey:         fields.Get(fieldNameS3SecretAccessKey).(string),
+		S3CredentialsSource:       fields.Get(fieldNameS3CredentialsSource).(string),
+		S3VaultAddress:            fields.Get(fieldNameS3VaultAddress).(string),
+		S3VaultToken:              fields.Get(fieldNameS3VaultToken).(string),
+		S3VaultCredentialsPath:    fields.Get(fieldNameS3VaultCredentialsPath).(string),
+		S3CredentialProcess:       fields.Get(fieldNameS3CredentialProcess).(string),
+		S3BucketName:              fields.Get(fieldNameS3BucketName).(string),
+		S3ForcePathStyle:          fields.Get(fieldNameS3ForcePathStyle).(bool),
+		S3CACertificate:           fields.Get(fieldNameS3CACertificate).(string),
+		S3ServerSideEncryption:    fields.Get(fieldNameS3ServerSideEncryption).(string),
+		S3SSEKMSKeyID:             fields.Get(fieldNameS3SSEKMSKeyID).(string),
+		S3StorageClass:            fields.Get(fieldNameS3StorageClass).(string),
+		ConsistentSnapshot:        fields.Get(fieldNameConsistentSnapshot).(bool),
+		OfflineRootPublicKeys:     fields.Get(fieldNameOfflineRootPublicKeys).([]string),
+		OfflineRootThreshold:      fields.Get(fieldNameOfflineRootThreshold).(int),
+		BuildBackend:              fields.Get(fieldNameBuildBackend).(string),
+		AutoPublish:               fields.Get(fieldNameAutoPublish).(bool),
+		AutoPublishInterval:       time.Duration(fields.Get(fieldNameAutoPublishInterval).(int)) * time.Second,
+		ReleaseStagingConcurrency: fields.Get(fieldNameReleaseStagingConcurrency).(int),
+	}
+
+	cfg.normalize()
+
+	if cfg.AutoPublishInterval <= 0 {
+		return logical.ErrorResponse("Field %q must be positive", fieldNameAutoPublishInterval), nil
+	}
+
+	if cfg.ReleaseStagingConcurrency <= 0 {
+		return logical.ErrorResponse("Field %q must be positive", fieldNameReleaseStagingConcurrency), nil
+	}
+
+	if err := builder.ValidateBackend(cfg.BuildBackend); err != nil {
+		return logical.ErrorResponse("%s validation failed: %s", fieldNameBuildBackend, err), nil
+	}
+
+	if cfg.S3CredentialsSource == publisher.S3CredentialsSourceStatic {
+		for fieldName, value := range map[string]string{
+			fieldNameS3AccessKeyID:     cfg.S3AccessKeyID,
+			fieldNameS3SecretAccessKey: cfg.S3SecretAccessKey,
+		} {
+			if value == "" {
+				return logical.ErrorResponse("Required field %q must be set", fieldName), nil
+			}
+		}
+	}
+
+	if err := cfg.RepositoryOptions().ValidateS3(); err != nil {
+		return logical.ErrorResponse("S3 options validation failed: %s", err), nil
+	}
+
+	if _, err := cfg.OfflineRootOptions(); err != nil {
+		return logical.ErrorResponse("Offline root options validation failed: %s", err), nil
+	}
+
+	if fields.Get(fieldNameValidate).(bool) {
+		if err := b.validateConfiguration(ctx, storage, cfg); err != nil {
+			return logical.ErrorResponse("Configuration validation failed: %s", err), nil
+		}
 	}
 
-	if err := putConfiguration(ctx, req.Storage, cfg); err != nil {
+	if err := putConfiguration(ctx, storage, cfg); err != nil {
 		return nil, fmt.Errorf("unable to put configuration into storage: %w", err)
 	}
 
 	return nil, nil
 }
 
+// validateConfiguration checks the access to the Git repository and the S3 storage bucket.
+func (b *Backend) validateConfiguration(ctx context.Context, storage logical.Storage, cfg *configuration) error {
+	gitUsername, gitPassword, err := getGitCredential(ctx, storage, "", "")
+	if err != nil {
+		return err
+	}
+
+	if err := testCloneGitRepository(cfg.GitRepoUrl, gitUsername, gitPassword); err != nil {
+		return fmt.Errorf("unable to clone git repository %q: %w", cfg.GitRepoUrl, err)
+	}
+
+	if err := probeS3(ctx, cfg.RepositoryOptions(), b.Logger()); err != nil {
+		return fmt.Errorf("unable to access S3 storage bucket %q: %w", cfg.S3BucketName, err)
+	}
+
+	return nil
+}
+
+// testCloneGitRepository and probeS3 are replaced in tests to avoid the network access.
+var (
+	testCloneGitRepository = func(url, username, password string) error {
+		cloneOptions := git.CloneOptions{Depth: 1}
+		if username != "" && password != "" {
+			cloneOptions.Auth = &http.BasicAuth{
+				Username: username,
+				Password: password,
+			}
+		}
+
+		_, err := git.CloneInMemory(url, cloneOptions)
+		return err
+	}
+
+	probeS3 = func(ctx context.Context, options publisher.RepositoryOptions, logger hclog.Logger) error {
+		return options.ProbeS3(ctx, logger)
+	}
+)
+
 func (b *Backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
 	cfg, err := getConfiguration(ctx, req.Storage)
 	if err != nil {
@@ -164,7 +428,14 @@ func (b *Backend) pathConfigureRead(ctx context.Context, req *logical.Request, _
 		return errorResponseConfigurationNotFound, nil
 	}
 
-	return &logical.Response{Data: structs.Map(cfg)}, nil
+	// the sensitive fields are write-only
+	data := cfg.fieldsData()
+	for _, fieldName := range configurationSensitiveFields {
+		data[fieldName+"_set"] = data[fieldName] != ""
+		delete(data, fieldName)
+	}
+
+	return &logical.Response{Data: data}, nil
 }
 
 func (b *Backend) pathConfigureDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
@@ -176,29 +447,92 @@ func (b *Backend) pathConfigureDelete(ctx context.Context, req *logical.Request,
 }
 
 type configuration struct {
-	GitRepoUrl                                 string `structs:"git_repo_url" json:"git_repo_url"`
-	GitTrdlPath                                string `structs:"git_trdl_path" json:"git_trdl_path"`
-	GitTrdlChannelsPath                        string `structs:"git_trdl_channels_path" json:"git_trdl_channels_path"`
-	GitTrdlChannelsBranch                      string `structs:"git_trdl_channels_branch" json:"git_trdl_channels_branch"`
-	InitialLastPublishedGitCommit              string `structs:"initial_last_published_git_commit" json:"initial_last_published_git_commit"`
-	RequiredNumberOfVerifiedSignaturesOnCommit int    `structs:"required_number_of_verified_signatures_on_commit" json:"required_number_of_verified_signatures_on_commit"`
-	S3Endpoint                                 string `structs:"s3_endpoint" json:"s3_endpoint"`
-	S3Region                                   string `structs:"s3_region" json:"s3_region"`
-	S3AccessKeyID                              string `structs:"s3_access_key_id" json:"s3_access_key_id"`
-	S3SecretAccessKey                          string `structs:"s3_secret_access_key" json:"s3_secret_access_key"`
-	S3BucketName                               string `structs:"s3_bucket_name" json:"s3_bucket_name"`
+	GitRepoUrl                                 string        `structs:"git_repo_url" json:"git_repo_url"`
+	GitTrdlPath                                string        `structs:"git_trdl_path" json:"git_trdl_path"`
+	GitTrdlChannelsPath                        string        `structs:"git_trdl_channels_path" json:"git_trdl_channels_path"`
+	GitTrdlChannelsBranch                      string        `structs:"git_trdl_channels_branch" json:"git_trdl_channels_branch"`
+	InitialLastPublishedGitCommit              string        `structs:"initial_last_published_git_commit" json:"initial_last_published_git_commit"`
+	RequiredNumberOfVerifiedSignaturesOnCommit int           `structs:"required_number_of_verified_signatures_on_commit" json:"required_number_of_verified_signatures_on_commit"`
+	S3Endpoint                                 string        `structs:"s3_endpoint" json:"s3_endpoint"`
+	S3Region                                   string        `structs:"s3_region" json:"s3_region"`
+	S3AccessKeyID                              string        `structs:"s3_access_key_id" json:"s3_access_key_id"`
+	S3SecretAccessKey                          string        `structs:"s3_secret_access_key" json:"s3_secret_access_key"`
+	S3CredentialsSource                        string        `structs:"s3_credentials_source" json:"s3_credentials_source"`
+	S3VaultAddress                             string        `structs:"s3_vault_address" json:"s3_vault_address"`
+	S3VaultToken                               string        `structs:"s3_vault_token" json:"s3_vault_token"`
+	S3VaultCredentialsPath                     string        `structs:"s3_vault_credentials_path" json:"s3_vault_credentials_path"`
+	S3CredentialProcess                        string        `structs:"s3_credential_process" json:"s3_credential_process"`
+	S3BucketName                               string        `structs:"s3_bucket_name" json:"s3_bucket_name"`
+	S3ForcePathStyle                           bool          `structs:"s3_force_path_style" json:"s3_force_path_style"`
+	S3CACertificate                            string        `structs:"s3_ca_certificate" json:"s3_ca_certificate"`
+	S3ServerSideEncryption                     string        `structs:"s3_server_side_encryption" json:"s3_server_side_encryption"`
+	S3SSEKMSKeyID                              string        `structs:"s3_sse_kms_key_id" json:"s3_sse_kms_key_id"`
+	S3StorageClass                             string        `structs:"s3_storage_class" json:"s3_storage_class"`
+	ConsistentSnapshot                         bool          `structs:"consistent_snapshot" json:"consistent_snapshot"`
+	OfflineRootPublicKeys                      []string      `structs:"offline_root_public_keys" json:"offline_root_public_keys"`
+	OfflineRootThreshold                       int           `structs:"offline_root_threshold" json:"offline_root_threshold"`
+	BuildBackend                               string        `structs:"build_backend" json:"build_backend"`
+	AutoPublish                                bool          `structs:"auto_publish" json:"auto_publish"`
+	AutoPublishInterval                        time.Duration `structs:"auto_publish_interval" json:"auto_publish_interval"`
+	ReleaseStagingConcurrency                  int           `structs:"release_staging_concurrency" json:"release_staging_concurrency"`
+}
+
+// fieldsData returns the configuration in the format of the configure path fields.
+func (cfg *configuration) fieldsData() map[string]interface{} {
+	data := structs.Map(cfg)
+	data[fieldNameAutoPublishInterval] = int(cfg.AutoPublishInterval / time.Second)
+
+	return data
 }
 
 func (cfg *configuration) RepositoryOptions() publisher.RepositoryOptions {
+	// the offline root options are validated on configure
+	offlineRoot, _ := cfg.OfflineRootOptions()
+
 	return publisher.RepositoryOptions{
 		S3Endpoint:        cfg.S3Endpoint,
 		S3Region:          cfg.S3Region,
 		S3AccessKeyID:     cfg.S3AccessKeyID,
 		S3SecretAccessKey: cfg.S3SecretAccessKey,
 		S3BucketName:      cfg.S3BucketName,
+		S3ForcePathStyle:  cfg.S3ForcePathStyle,
+		S3CACertificate:   cfg.S3CACertificate,
+		S3UploadOptions: publisher.S3UploadOptions{
+			ServerSideEncryption: cfg.S3ServerSideEncryption,
+			SSEKMSKeyID:          cfg.S3SSEKMSKeyID,
+			StorageClass:         cfg.S3StorageClass,
+		},
+		S3CredentialsOptions: publisher.S3CredentialsOptions{
+			Source:       cfg.S3CredentialsSource,
+			VaultAddress: cfg.S3VaultAddress,
+			VaultToken:   cfg.S3VaultToken,
+			VaultPath:    cfg.S3VaultCredentialsPath,
+			Process:      cfg.S3CredentialProcess,
+		},
+		ConsistentSnapshot: cfg.ConsistentSnapshot,
+		OfflineRoot:        offlineRoot,
 	}
 }
 
+// OfflineRootOptions returns nil if the offline root keys are not configured.
+func (cfg *configuration) OfflineRootOptions() (*publisher.OfflineRootOptions, error) {
+	if len(cfg.OfflineRootPublicKeys) == 0 {
+		return nil, nil
+	}
+
+	publicKeys, err := publisher.ParseOfflineRootPublicKeys(cfg.OfflineRootPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &publisher.OfflineRootOptions{PublicKeys: publicKeys, Threshold: cfg.OfflineRootThreshold}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
 func getConfiguration(ctx context.Context, storage logical.Storage) (*configuration, error) {
 	raw, err := storage.Get(ctx, storageKeyConfiguration)
 	if err != nil {
@@ -212,10 +546,18 @@ func getConfiguration(ctx context.Context, storage logical.Storage) (*configurat
 	if err := raw.DecodeJSON(config); err != nil {
 		return nil, err
 	}
+	config.normalize()
 
 	return config, nil
 }
 
+// normalize sets the defaults of the fields which are empty in the configurations stored by the previous versions.
+func (cfg *configuration) normalize() {
+	if cfg.S3CredentialsSource == "" {
+		cfg.S3CredentialsSource = publisher.S3CredentialsSourceStatic
+	}
+}
+
 func putConfiguration(ctx context.Context, storage logical.Storage, config *configuration) error {
 	entry, err := logical.StorageEntryJSON(storageKeyConfiguration, config)
 	if err != nil {
@@ -244,7 +586,7 @@ func (c *configuration) maskConfigSensitiveDataForDebug() (string, error) {
 		return "", err
 	}
 
-	sensitiveKeys := []string{"s3_secret_access_key", "s3_access_key_id"}
+	sensitiveKeys := append([]string{fieldNameS3AccessKeyID}, configurationSensitiveFields...)
 	for _, key := range sensitiveKeys {
 		if _, exists := configMap[key]; exists {
 			configMap[key] = "******"
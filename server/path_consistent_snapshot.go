@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+func consistentSnapshotPath(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `consistent_snapshot$`,
+		Fields: map[string]*framework.FieldSchema{
+			fieldNameQueue: {
+				Type:        framework.TypeBool,
+				Description: "Add the task to the queue instead of returning the busy error. The queued or running migration is returned instead of adding a duplicate",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.pathConsistentSnapshot,
+				Summary:  pathConsistentSnapshotHelpSyn,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConsistentSnapshot,
+				Summary:  pathConsistentSnapshotHelpSyn,
+			},
+		},
+
+		HelpSynopsis:    pathConsistentSnapshotHelpSyn,
+		HelpDescription: pathConsistentSnapshotHelpDesc,
+	}
+}
+
+func (b *Backend) pathConsistentSnapshot(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if errResp := util.CheckRequiredFields(req, fields); errResp != nil {
+		return errResp, nil
+	}
+
+	cfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	taskFunc, err := b.newConsistentSnapshotTaskFunc(ctx, req.Storage, cfg)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return logical.ErrorResponse("repository is not initialized: set %q in the configuration to initialize it with consistent snapshots", fieldNameConsistentSnapshot), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	taskUUID, err := b.runOrQueueTask(req.Storage, fields.Get(fieldNameQueue).(bool), consistentSnapshotTaskOptions(), taskFunc)
+	if err != nil {
+		if err == tasks_manager.ErrBusy {
+			return logical.ErrorResponse("busy"), nil
+		}
+
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"task_uuid": taskUUID,
+		},
+	}, nil
+}
+
+// newConsistentSnapshotTaskFunc returns publisher.ErrUninitializedRepositoryKeys if nothing is published yet.
+func (b *Backend) newConsistentSnapshotTaskFunc(ctx context.Context, storage logical.Storage, cfg *configuration) (func(context.Context, logical.Storage) error, error) {
+	opts := cfg.RepositoryOptions()
+	opts.InitializeTUFKeys = false
+	opts.InitializePGPSigningKey = false
+	publisherRepository, err := b.Publisher.GetRepository(ctx, storage, opts)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting publisher repository: %w", err)
+	}
+
+	return func(ctx context.Context, storage logical.Storage) error {
+		logboek.Context(ctx).Default().LogF("Started task\n")
+		b.Logger().Debug("Started task")
+
+		var migrated bool
+		finishStage := tasks_manager.StartTaskStage(ctx, taskStageEnableConsistentSnapshot)
+		err := tasks_manager.WithResourceLock(ctx, tasks_manager.LockTufRepoCommit, func() error {
+			var err error
+			migrated, err = publisherRepository.EnableConsistentSnapshot(ctx)
+			return err
+		})
+		b.saveMirrorReplications(ctx, storage, publisherRepository)
+		if err != nil {
+			return fmt.Errorf("unable to enable consistent snapshots: %w", err)
+		}
+		finishStage()
+
+		if migrated {
+			logboek.Context(ctx).Default().LogF("Repository is migrated to consistent snapshots\n")
+			b.Logger().Info("Repository is migrated to consistent snapshots")
+			b.setTaskTufVersions(ctx, publisherRepository)
+		} else {
+			logboek.Context(ctx).Default().LogF("Repository already uses consistent snapshots: nothing to do\n")
+		}
+
+		logboek.Context(ctx).Default().LogF("Task finished\n")
+		b.Logger().Debug("Task finished")
+
+		return nil
+	}, nil
+}
+
+const (
+	pathConsistentSnapshotHelpSyn  = "Migrate the repository to TUF consistent snapshots"
+	pathConsistentSnapshotHelpDesc = "Copy the targets of the existing repository under the hash-prefixed names, then enable consistent snapshots in root.json and commit the metadata with the version-prefixed copies. The objects under the plain target names are kept for the clients which have not updated root.json yet and are reported as unreferenced by the verification. The new repository is initialized with consistent snapshots by the consistent_snapshot configuration option"
+)
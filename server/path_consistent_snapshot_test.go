@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+type PathConsistentSnapshotCallbackSuite struct {
+	CommonSuite
+}
+
+func (suite *PathConsistentSnapshotCallbackSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+	suite.req.Path = "consistent_snapshot"
+	suite.req.Operation = logical.CreateOperation
+}
+
+func (suite *PathConsistentSnapshotCallbackSuite) TestConfigurationNotFound() {
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), errorResponseConfigurationNotFound, resp)
+}
+
+func (suite *PathConsistentSnapshotCallbackSuite) TestUninitializedRepository() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.mockedPublisher.On("GetRepository").Return(nil, publisher.ErrUninitializedRepositoryKeys)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.True(suite.T(), resp.IsError())
+		assert.Contains(suite.T(), resp.Error().Error(), "repository is not initialized")
+	}
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "RunTask")
+}
+
+func (suite *PathConsistentSnapshotCallbackSuite) TestBasic() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("RunTask").Return("UUID", nil)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathConsistentSnapshotCallbackSuite) TestBusy() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	// tasks manager is busy
+	suite.mockedTasksManager.IsBusy = true
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("RunTask").Return("", tasks_manager.ErrBusy)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("busy"), resp)
+}
+
+func (suite *PathConsistentSnapshotCallbackSuite) TestQueue() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.req.Data = map[string]interface{}{fieldNameQueue: true}
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", consistentSnapshotTaskOptions()).Return("UUID", nil)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathConsistentSnapshotCallbackSuite) TestTaskFunc() {
+	cfg := completeConfiguration()
+
+	suite.Run("migrated", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("EnableConsistentSnapshot").Return(true, nil)
+		mockedRepository.On("TakeMirrorReplications").Return(nil)
+		mockedRepository.On("GetVersions").Return(map[string]int64{"root": 2})
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+
+		taskFunc, err := suite.backend.newConsistentSnapshotTaskFunc(suite.ctx, suite.storage, cfg)
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), taskFunc(context.Background(), suite.storage))
+
+		mockedRepository.AssertExpectations(suite.T())
+	})
+
+	suite.Run("already consistent", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("EnableConsistentSnapshot").Return(false, nil)
+		mockedRepository.On("TakeMirrorReplications").Return(nil)
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+
+		taskFunc, err := suite.backend.newConsistentSnapshotTaskFunc(suite.ctx, suite.storage, cfg)
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), taskFunc(context.Background(), suite.storage))
+
+		mockedRepository.AssertNotCalled(suite.T(), "GetVersions")
+	})
+
+	suite.Run("failed", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("EnableConsistentSnapshot").Return(false, errors.New("access denied"))
+		mockedRepository.On("TakeMirrorReplications").Return(nil)
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+
+		taskFunc, err := suite.backend.newConsistentSnapshotTaskFunc(suite.ctx, suite.storage, cfg)
+		assert.Nil(suite.T(), err)
+		err = taskFunc(context.Background(), suite.storage)
+		if assert.Error(suite.T(), err) {
+			assert.Contains(suite.T(), err.Error(), "access denied")
+		}
+	})
+}
+
+func TestBackendPathConsistentSnapshotCallback(t *testing.T) {
+	suite.Run(t, new(PathConsistentSnapshotCallbackSuite))
+}
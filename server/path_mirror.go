@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+const (
+	fieldNameMirrorName = "name"
+
+	taskParamMirror = "mirror"
+)
+
+func mirrorResyncPath(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "mirrors/" + framework.GenericNameRegex(fieldNameMirrorName) + "/resync$",
+		Fields: map[string]*framework.FieldSchema{
+			fieldNameMirrorName: {
+				Type:        framework.TypeNameString,
+				Description: "Mirror name",
+				Required:    true,
+			},
+			fieldNameQueue: {
+				Type:        framework.TypeBool,
+				Description: "Add the task to the queue instead of returning the busy error. The queued or running resync of the same mirror is returned instead of adding a duplicate",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.pathMirrorResync,
+				Summary:  pathMirrorResyncHelpSyn,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathMirrorResync,
+				Summary:  pathMirrorResyncHelpSyn,
+			},
+		},
+
+		HelpSynopsis:    pathMirrorResyncHelpSyn,
+		HelpDescription: pathMirrorResyncHelpDesc,
+	}
+}
+
+func (b *Backend) pathMirrorResync(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	cfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	name := fields.Get(fieldNameMirrorName).(string)
+	mirror, err := publisher.GetMirror(ctx, req.Storage, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get mirror: %w", err)
+	}
+
+	if mirror == nil {
+		return logical.ErrorResponse("mirror %q not found", name), nil
+	}
+
+	taskFunc, err := b.newMirrorResyncTaskFunc(ctx, req.Storage, cfg, name)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return logical.ErrorResponse("repository is not initialized: nothing to resync"), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	taskUUID, err := b.runOrQueueTask(req.Storage, fields.Get(fieldNameQueue).(bool), mirrorResyncTaskOptions(name), taskFunc)
+	if err != nil {
+		if err == tasks_manager.ErrBusy {
+			return logical.ErrorResponse("busy"), nil
+		}
+
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"task_uuid": taskUUID,
+		},
+	}, nil
+}
+
+// newMirrorResyncTaskFunc returns publisher.ErrUninitializedRepositoryKeys if nothing is published yet.
+func (b *Backend) newMirrorResyncTaskFunc(ctx context.Context, storage logical.Storage, cfg *configuration, name string) (func(context.Context, logical.Storage) error, error) {
+	opts := cfg.RepositoryOptions()
+	opts.InitializeTUFKeys = false
+	opts.InitializePGPSigningKey = false
+	publisherRepository, err := b.Publisher.GetRepository(ctx, storage, opts)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting publisher repository: %w", err)
+	}
+
+	return func(ctx context.Context, storage logical.Storage) error {
+		logboek.Context(ctx).Default().LogF("Started task\n")
+		b.Logger().Debug("Started task")
+
+		logboek.Context(ctx).Default().LogF("Replicating all repository objects to the mirror %q\n", name)
+		b.Logger().Debug(fmt.Sprintf("Replicating all repository objects to the mirror %q", name))
+
+		// the commits are not replicated concurrently, so the mirror metadata is not replaced by the stale one
+		finishStage := tasks_manager.StartTaskStage(ctx, taskStageResyncMirror)
+		err := tasks_manager.WithResourceLock(ctx, tasks_manager.LockTufRepoCommit, func() error {
+			return publisherRepository.ResyncMirror(ctx, name)
+		})
+		b.saveMirrorReplications(ctx, storage, publisherRepository)
+		if err != nil {
+			return err
+		}
+		finishStage()
+
+		logboek.Context(ctx).Default().LogF("Task finished\n")
+		b.Logger().Debug("Task finished")
+
+		return nil
+	}, nil
+}
+
+const (
+	pathMirrorResyncHelpSyn  = "Resync the repository mirror"
+	pathMirrorResyncHelpDesc = "Replicate all objects of the repository to the mirror configured with configure/mirrors: the targets and then the metadata. The mirror which failed to receive the changes is not synced until the resync succeeds"
+)
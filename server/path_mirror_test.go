@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/publisher"
+)
+
+type PathMirrorResyncCallbackSuite struct {
+	CommonSuite
+}
+
+func (suite *PathMirrorResyncCallbackSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+	suite.req.Path = "mirrors/eu/resync"
+	suite.req.Operation = logical.CreateOperation
+}
+
+func (suite *PathMirrorResyncCallbackSuite) TestConfigurationNotFound() {
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), errorResponseConfigurationNotFound, resp)
+}
+
+func (suite *PathMirrorResyncCallbackSuite) TestMirrorNotFound() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("mirror %q not found", "eu"), resp)
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "RunTask")
+}
+
+func (suite *PathMirrorResyncCallbackSuite) TestQueue() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	err = publisher.PutMirror(suite.ctx, suite.storage, publisher.MirrorOptions{Name: "eu", LocalPath: "/srv/trdl"})
+	assert.Nil(suite.T(), err)
+
+	suite.req.Data = map[string]interface{}{fieldNameQueue: true}
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", mirrorResyncTaskOptions("eu")).Return("UUID", nil)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathMirrorResyncCallbackSuite) TestTaskFunc() {
+	err := publisher.PutMirror(suite.ctx, suite.storage, publisher.MirrorOptions{Name: "eu", LocalPath: "/srv/trdl"})
+	assert.Nil(suite.T(), err)
+
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	suite.Run("failed", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("ResyncMirror", "eu").Return(errors.New("access denied"))
+		mockedRepository.On("TakeMirrorReplications").Return([]publisher.MirrorReplication{{Mirror: "eu", Time: now, Resync: true, Err: errors.New("access denied")}})
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+
+		taskFunc, err := suite.backend.newMirrorResyncTaskFunc(suite.ctx, suite.storage, completeConfiguration(), "eu")
+		assert.Nil(suite.T(), err)
+		assert.EqualError(suite.T(), taskFunc(context.Background(), suite.storage), "access denied")
+
+		status, err := publisher.GetMirrorStatus(suite.ctx, suite.storage, "eu")
+		assert.Nil(suite.T(), err)
+		assert.False(suite.T(), status.Synced)
+		assert.Equal(suite.T(), "access denied", status.LastError)
+	})
+
+	suite.Run("succeeded", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("ResyncMirror", "eu").Return(nil)
+		mockedRepository.On("TakeMirrorReplications").Return([]publisher.MirrorReplication{{Mirror: "eu", Time: now.Add(time.Hour), Resync: true}})
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+
+		taskFunc, err := suite.backend.newMirrorResyncTaskFunc(suite.ctx, suite.storage, completeConfiguration(), "eu")
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), taskFunc(context.Background(), suite.storage))
+
+		status, err := publisher.GetMirrorStatus(suite.ctx, suite.storage, "eu")
+		assert.Nil(suite.T(), err)
+		assert.True(suite.T(), status.Synced)
+		assert.Empty(suite.T(), status.LastError)
+	})
+}
+
+func TestBackendPathMirrorResyncCallback(t *testing.T) {
+	suite.Run(t, new(PathMirrorResyncCallbackSuite))
+}
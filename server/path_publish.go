@@ -42,6 +42,10 @@ func publishPath(b *Backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Git password",
 			},
+			fieldNameQueue: {
+				Type:        framework.TypeBool,
+				Description: "Add the task to the queue instead of returning the busy error. The already queued publish task is returned instead of adding a duplicate",
+			},
 		},
 
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -74,45 +78,59 @@ func (b *Backend) pathPublish(ctx context.Context, req *logical.Request, fields
 		return errorResponseConfigurationNotFound, nil
 	}
 
-	gitCredentialFromStorage, err := trdlGit.GetGitCredential(ctx, req.Storage)
+	taskFunc, err := b.newPublishTaskFunc(ctx, req.Storage, cfg, fields.Get(fieldNameGitUsername).(string), fields.Get(fieldNameGitPassword).(string))
 	if err != nil {
-		return nil, fmt.Errorf("unable to get git credential from storage: %w", err)
-	}
-
-	gitUsername := fields.Get(fieldNameGitUsername).(string)
-	gitPassword := fields.Get(fieldNameGitPassword).(string)
-	if gitCredentialFromStorage != nil && gitUsername == "" && gitPassword == "" {
-		gitUsername = gitCredentialFromStorage.Username
-		gitPassword = gitCredentialFromStorage.Password
+		return nil, err
 	}
 
-	lastPublishedGitCommit := cfg.InitialLastPublishedGitCommit
-	{
-		entry, err := req.Storage.Get(ctx, storageKeyLastPublishedGitCommit)
-		if err != nil {
-			return nil, fmt.Errorf("unable to get %q from storage: %w", storageKeyLastPublishedGitCommit, err)
+	taskUUID, err := b.runOrQueueTask(req.Storage, fields.Get(fieldNameQueue).(bool), publishTaskOptions(), taskFunc)
+	if err != nil {
+		if err == tasks_manager.ErrBusy {
+			return logical.ErrorResponse("busy"), nil
 		}
 
-		if entry != nil {
-			lastPublishedGitCommit = string(entry.Value)
+		if _, match := err.(util.LogicalError); match {
+			return logical.ErrorResponse(err.Error()), nil
 		}
+
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"task_uuid": taskUUID,
+		},
+	}, nil
+}
+
+func (b *Backend) newPublishTaskFunc(ctx context.Context, storage logical.Storage, cfg *configuration, gitUsername, gitPassword string) (func(context.Context, logical.Storage) error, error) {
+	gitUsername, gitPassword, err := getGitCredential(ctx, storage, gitUsername, gitPassword)
+	if err != nil {
+		return nil, err
 	}
 
 	opts := cfg.RepositoryOptions()
 	opts.InitializeTUFKeys = true
 	opts.InitializePGPSigningKey = true
-	publisherRepository, err := b.Publisher.GetRepository(ctx, req.Storage, opts)
+	publisherRepository, err := b.Publisher.GetRepository(ctx, storage, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error getting publisher repository: %w", err)
 	}
 
-	taskUUID, err := b.TasksManager.RunTask(context.Background(), req.Storage, func(ctx context.Context, storage logical.Storage) error {
+	// the last published commit is read when the task is started, because the queued task can be run after another publish
+	return func(ctx context.Context, storage logical.Storage) error {
 		logboek.Context(ctx).Default().LogF("Started task\n")
 		b.Logger().Debug("Started task")
 
+		lastPublishedGitCommit, err := getLastPublishedGitCommit(ctx, storage, cfg)
+		if err != nil {
+			return err
+		}
+
 		logboek.Context(ctx).Default().LogF("Cloning git repo\n")
 		b.Logger().Debug("Cloning git repo")
 
+		finishStage := tasks_manager.StartTaskStage(ctx, taskStageClone)
 		gitBranch := cfg.GitTrdlChannelsBranch
 		gitRepo, err := cloneGitRepositoryBranch(cfg.GitRepoUrl, gitBranch, gitUsername, gitPassword)
 		if err != nil {
@@ -124,6 +142,8 @@ func (b *Backend) pathPublish(ctx context.Context, req *logical.Request, fields
 			return fmt.Errorf("error getting git repo branch %q head reference: %w", gitBranch, err)
 		}
 		headCommit := headRef.Hash().String()
+		tasks_manager.SetTaskCommit(ctx, headCommit)
+		finishStage()
 
 		if lastPublishedGitCommit == headCommit {
 			logboek.Context(ctx).Default().LogF("Head commit %q not changed: skipping publish task\n", headCommit)
@@ -132,6 +152,8 @@ func (b *Backend) pathPublish(ctx context.Context, req *logical.Request, fields
 			return nil
 		}
 
+		finishStage = tasks_manager.StartTaskStage(ctx, taskStageVerifySignatures)
+
 		if lastPublishedGitCommit != "" {
 			logboek.Context(ctx).Default().LogF("Checking previously published commit %q is ancestor to the current head commit %q\n", lastPublishedGitCommit, headCommit)
 			b.Logger().Debug(fmt.Sprintf("Checking previously published commit %q is ancestor to the current head commit %q", lastPublishedGitCommit, headCommit))
@@ -149,7 +171,7 @@ func (b *Backend) pathPublish(ctx context.Context, req *logical.Request, fields
 		logboek.Context(ctx).Default().LogF("Verifying tag PGP signatures of the commit %q\n", headCommit)
 		b.Logger().Debug(fmt.Sprintf("Verifying tag PGP signatures of the commit %q", headCommit))
 
-		trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(ctx, req.Storage)
+		trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(ctx, storage)
 		if err != nil {
 			return fmt.Errorf("unable to get trusted PGP public keys: %w", err)
 		}
@@ -160,6 +182,7 @@ func (b *Backend) pathPublish(ctx context.Context, req *logical.Request, fields
 
 		logboek.Context(ctx).Default().LogF("Verified commit signatures\n")
 		b.Logger().Debug("Verified commit signatures")
+		finishStage()
 
 		logboek.Context(ctx).Default().LogF("Getting trdl_channels.yaml configuration from the commit %q\n", headCommit)
 		b.Logger().Debug(fmt.Sprintf("Getting trdl_channels.yaml configuration from the commit %q\n", headCommit))
@@ -173,52 +196,62 @@ func (b *Backend) pathPublish(ctx context.Context, req *logical.Request, fields
 		logboek.Context(ctx).Default().LogF("Got trdl channels config:\n%s\n---\n", cfgDump)
 		b.Logger().Debug(fmt.Sprintf("Got trdl channels config:\n%s\n---", cfgDump))
 
-		if err := ValidatePublishConfig(ctx, b.Publisher, publisherRepository, cfg, b.Logger()); err != nil {
-			return fmt.Errorf("unable to publish bad config: %w", err)
-		}
+		if err := tasks_manager.WithResourceLock(ctx, tasks_manager.LockTufRepoCommit, func() error {
+			finishStage := tasks_manager.StartTaskStage(ctx, taskStageStageTargets)
+			if err := ValidatePublishConfig(ctx, b.Publisher, publisherRepository, cfg, b.Logger()); err != nil {
+				return fmt.Errorf("unable to publish bad config: %w", err)
+			}
 
-		logboek.Context(ctx).Default().LogF("Publishing trdl channels config into the TUF repository\n")
-		b.Logger().Debug("Publishing trdl channels config into the TUF repository")
-		if err := b.Publisher.StageChannelsConfig(ctx, publisherRepository, cfg); err != nil {
-			return fmt.Errorf("error publishing trdl channels into the repository: %w", err)
-		}
+			logboek.Context(ctx).Default().LogF("Publishing trdl channels config into the TUF repository\n")
+			b.Logger().Debug("Publishing trdl channels config into the TUF repository")
+			if err := b.Publisher.StageChannelsConfig(ctx, publisherRepository, cfg); err != nil {
+				return fmt.Errorf("error publishing trdl channels into the repository: %w", err)
+			}
+			finishStage()
 
-		logboek.Context(ctx).Default().LogF("Committing TUF repository state\n")
-		b.Logger().Debug("Committing TUF repository state")
+			logboek.Context(ctx).Default().LogF("Committing TUF repository state\n")
+			b.Logger().Debug("Committing TUF repository state")
 
-		if err := publisherRepository.CommitStaged(ctx); err != nil {
-			return fmt.Errorf("unable to commit new tuf repository state: %w", err)
-		}
+			finishStage = tasks_manager.StartTaskStage(ctx, taskStageCommit)
+			if err := publisherRepository.CommitStaged(ctx); err != nil {
+				return fmt.Errorf("unable to commit new tuf repository state: %w", err)
+			}
+
+			b.setTaskTufVersions(ctx, publisherRepository)
+			b.saveMirrorReplications(ctx, storage, publisherRepository)
 
-		logboek.Context(ctx).Default().LogF("Storing published commit record %q into the storage\n", headCommit)
-		b.Logger().Debug(fmt.Sprintf("Storing published commit record %q into the storage", headCommit))
+			logboek.Context(ctx).Default().LogF("Storing published commit record %q into the storage\n", headCommit)
+			b.Logger().Debug(fmt.Sprintf("Storing published commit record %q into the storage", headCommit))
 
-		if err := storage.Put(ctx, &logical.StorageEntry{Key: storageKeyLastPublishedGitCommit, Value: []byte(headCommit)}); err != nil {
-			return fmt.Errorf("unable to put %q into storage: %w", storageKeyLastPublishedGitCommit, err)
+			if err := storage.Put(ctx, &logical.StorageEntry{Key: storageKeyLastPublishedGitCommit, Value: []byte(headCommit)}); err != nil {
+				return fmt.Errorf("unable to put %q into storage: %w", storageKeyLastPublishedGitCommit, err)
+			}
+			finishStage()
+
+			return nil
+		}); err != nil {
+			return err
 		}
 
 		logboek.Context(ctx).Default().LogF("Task finished\n")
 		b.Logger().Debug("Task finished")
 
 		return nil
-	})
-	if err != nil {
-		if err == tasks_manager.ErrBusy {
-			return logical.ErrorResponse("busy"), nil
-		}
+	}, nil
+}
 
-		if _, match := err.(util.LogicalError); match {
-			return logical.ErrorResponse(err.Error()), nil
-		}
+// getLastPublishedGitCommit returns the initial commit from the configuration if nothing is published yet.
+func getLastPublishedGitCommit(ctx context.Context, storage logical.Storage, cfg *configuration) (string, error) {
+	entry, err := storage.Get(ctx, storageKeyLastPublishedGitCommit)
+	if err != nil {
+		return "", fmt.Errorf("unable to get %q from storage: %w", storageKeyLastPublishedGitCommit, err)
+	}
 
-		return nil, err
+	if entry == nil {
+		return cfg.InitialLastPublishedGitCommit, nil
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"task_uuid": taskUUID,
-		},
-	}, nil
+	return string(entry.Value), nil
 }
 
 func ValidatePublishConfig(ctx context.Context, publisher publisher.Interface, publisherRepository publisher.RepositoryInterface, config *config.TrdlChannels, logger hclog.Logger) error {
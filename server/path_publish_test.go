@@ -67,6 +67,25 @@ func (suite *PathPublishCallbackSuite) TestBusy() {
 	suite.mockedTasksManager.AssertExpectations(suite.T())
 }
 
+func (suite *PathPublishCallbackSuite) TestQueue() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.req.Data = map[string]interface{}{fieldNameQueue: true}
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", publishTaskOptions()).Return("UUID", nil)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
 func TestBackendPathPublishCallback(t *testing.T) {
 	suite.Run(t, new(PathPublishCallbackSuite))
 }
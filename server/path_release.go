@@ -16,10 +16,11 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 
 	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/builder"
 	"github.com/werf/trdl/server/pkg/config"
-	"github.com/werf/trdl/server/pkg/docker"
 	trdlGit "github.com/werf/trdl/server/pkg/git"
 	"github.com/werf/trdl/server/pkg/pgp"
+	"github.com/werf/trdl/server/pkg/publisher"
 	"github.com/werf/trdl/server/pkg/tasks_manager"
 	"github.com/werf/trdl/server/pkg/util"
 )
@@ -28,6 +29,7 @@ const (
 	fieldNameGitTag      = "git_tag"
 	fieldNameGitUsername = "git_username"
 	fieldNameGitPassword = "git_password"
+	fieldNameQueue       = "queue"
 )
 
 func releasePath(b *Backend) *framework.Path {
@@ -47,6 +49,10 @@ func releasePath(b *Backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Git password",
 			},
+			fieldNameQueue: {
+				Type:        framework.TypeBool,
+				Description: "Add the task to the queue instead of returning the busy error. The queued or running task for the same git tag is returned instead of adding a duplicate",
+			},
 		},
 
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -87,48 +93,75 @@ func (b *Backend) pathRelease(ctx context.Context, req *logical.Request, fields
 		return errorResponseConfigurationNotFound, nil
 	}
 
-	gitCredentialFromStorage, err := trdlGit.GetGitCredential(ctx, req.Storage)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get git credential from storage: %w", err)
-	}
-
 	gitTag := fields.Get(fieldNameGitTag).(string)
 	if err := ValidateReleaseVersion(gitTag); err != nil {
 		return logical.ErrorResponse("%s validation failed: %s", fieldNameGitTag, err), nil
 	}
-	releaseName := strings.TrimPrefix(gitTag, "v")
 
-	gitUsername := fields.Get(fieldNameGitUsername).(string)
-	gitPassword := fields.Get(fieldNameGitPassword).(string)
-	if gitCredentialFromStorage != nil && gitUsername == "" && gitPassword == "" {
-		gitUsername = gitCredentialFromStorage.Username
-		gitPassword = gitCredentialFromStorage.Password
+	taskFunc, err := b.newReleaseTaskFunc(ctx, req.Storage, cfg, gitTag, fields.Get(fieldNameGitUsername).(string), fields.Get(fieldNameGitPassword).(string))
+	if err != nil {
+		return nil, err
+	}
+
+	taskUUID, err := b.runOrQueueTask(req.Storage, fields.Get(fieldNameQueue).(bool), releaseTaskOptions(gitTag), taskFunc)
+	if err != nil {
+		if err == tasks_manager.ErrBusy {
+			return logical.ErrorResponse("busy"), nil
+		}
+
+		return nil, err
 	}
 
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"task_uuid": taskUUID,
+		},
+	}, nil
+}
+
+func (b *Backend) newReleaseTaskFunc(ctx context.Context, storage logical.Storage, cfg *configuration, gitTag, gitUsername, gitPassword string) (func(context.Context, logical.Storage) error, error) {
+	gitUsername, gitPassword, err := getGitCredential(ctx, storage, gitUsername, gitPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseName := strings.TrimPrefix(gitTag, "v")
+
 	opts := cfg.RepositoryOptions()
 	opts.InitializeTUFKeys = true
 	opts.InitializePGPSigningKey = true
-	publisherRepository, err := b.Publisher.GetRepository(ctx, req.Storage, opts)
+	publisherRepository, err := b.Publisher.GetRepository(ctx, storage, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error getting publisher repository: %w", err)
 	}
 
-	taskUUID, err := b.TasksManager.RunTask(context.Background(), req.Storage, func(ctx context.Context, storage logical.Storage) error {
+	return func(ctx context.Context, storage logical.Storage) error {
 		logboek.Context(ctx).Default().LogF("Started task\n")
 		b.Logger().Debug("Started task")
 
 		logboek.Context(ctx).Default().LogF("Cloning git repo\n")
 		b.Logger().Debug("Cloning git repo")
 
+		finishStage := tasks_manager.StartTaskStage(ctx, taskStageClone)
 		gitRepo, err := cloneGitRepositoryTag(cfg.GitRepoUrl, gitTag, gitUsername, gitPassword)
 		if err != nil {
 			return fmt.Errorf("unable to clone git repository: %w", err)
 		}
 
+		headRef, err := gitRepo.Head()
+		if err != nil {
+			return fmt.Errorf("error getting git tag %q head reference: %w", gitTag, err)
+		}
+		headCommit := headRef.Hash().String()
+		tasks_manager.SetTaskCommit(ctx, headCommit)
+		finishStage()
+
 		logboek.Context(ctx).Default().LogF("Verifying tag PGP signatures of the git tag %q\n", gitTag)
 		b.Logger().Debug(fmt.Sprintf("Verifying tag PGP signatures of the git tag %q", gitTag))
 
-		trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(ctx, req.Storage)
+		finishStage = tasks_manager.StartTaskStage(ctx, taskStageVerifySignatures)
+
+		trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(ctx, storage)
 		if err != nil {
 			return fmt.Errorf("unable to get trusted PGP public keys: %w", err)
 		}
@@ -137,6 +170,9 @@ func (b *Backend) pathRelease(ctx context.Context, req *logical.Request, fields
 		if err := trdlGit.VerifyTagSignatures(gitRepo, gitTag, trustedPGPPublicKeys, cfg.RequiredNumberOfVerifiedSignaturesOnCommit, b.Logger()); err != nil {
 			return fmt.Errorf("signature verification failed: %w", err)
 		}
+		finishStage()
+
+		finishBuildStage := tasks_manager.StartTaskStage(ctx, taskStageBuild)
 
 		logboek.Context(ctx).Default().LogF("Getting trdl.yaml configuration from the git tag %q\n", gitTag)
 		b.Logger().Debug(fmt.Sprintf("Getting trdl.yaml configuration from the git tag %q\n", gitTag))
@@ -146,6 +182,11 @@ func (b *Backend) pathRelease(ctx context.Context, req *logical.Request, fields
 			return fmt.Errorf("unable to get trdl configuration: %w", err)
 		}
 
+		artifactsBuilder, err := builder.NewBuilder(cfg.BuildBackend)
+		if err != nil {
+			return fmt.Errorf("unable to create release artifacts builder: %w", err)
+		}
+
 		logboek.Context(ctx).Default().LogF("Starting release artifacts tar archive build\n")
 		b.Logger().Debug("Starting release artifacts tar archive build")
 
@@ -154,47 +195,32 @@ func (b *Backend) pathRelease(ctx context.Context, req *logical.Request, fields
 
 		errCh := make(chan error, 1)
 		go func() {
-			err := docker.BuildReleaseArtifacts(ctx,
-				docker.BuildReleaseArtifactsOpts{
+			err := artifactsBuilder.BuildReleaseArtifacts(ctx,
+				builder.BuildReleaseArtifactsOpts{
 					TarWriter:   tarWriter,
 					GitRepo:     gitRepo,
 					FromImage:   trdlCfg.GetDockerImage(),
 					RunCommands: trdlCfg.Commands,
-					Storage:     req.Storage,
+					Storage:     storage,
 				}, b.Logger())
 			if err != nil {
 				errCh <- err
 				tarWriter.CloseWithError(err)
 				return
 			}
+			finishBuildStage()
 			errCh <- nil
 		}()
 
 		{
 			logboek.Context(ctx).Default().LogF("Starting to read tar artifacts...\n")
 			b.Logger().Debug("Starting to read tar artifacts...")
-			twArtifacts := tar.NewReader(tarReader)
-			for {
-				hdr, err := twArtifacts.Next()
-
-				if err == io.EOF {
-					break
-				}
-
-				if err != nil {
-					return fmt.Errorf("error reading next tar artifact header: %w", err)
-				}
-
-				if strings.HasPrefix(hdr.Name, docker.ContainerArtifactsDir+"/") && hdr.Typeflag != tar.TypeDir {
-					name := strings.TrimPrefix(hdr.Name, docker.ContainerArtifactsDir+"/")
-					logboek.Context(ctx).Default().LogF("Publishing %q into the tuf repo ...\n", name)
-					b.Logger().Debug(fmt.Sprintf("Publishing %q into the tuf repo ...", name))
-
-					if err := b.Publisher.StageReleaseTarget(ctx, publisherRepository, releaseName, name, twArtifacts); err != nil {
-						return fmt.Errorf("unable to publish release target %q: %w", name, err)
-					}
-				}
+
+			finishStage = tasks_manager.StartTaskStage(ctx, taskStageStageTargets)
+			if err := b.stageReleaseArtifacts(ctx, publisherRepository, releaseName, tar.NewReader(tarReader), cfg.ReleaseStagingConcurrency); err != nil {
+				return err
 			}
+			finishStage()
 
 			if err := <-errCh; err != nil {
 				return fmt.Errorf("unable to build release artifacts: %w", err)
@@ -204,28 +230,63 @@ func (b *Backend) pathRelease(ctx context.Context, req *logical.Request, fields
 		logboek.Context(ctx).Default().LogF("Committing TUF repository state\n")
 		b.Logger().Debug("Committing TUF repository state")
 
-		if err := publisherRepository.CommitStaged(ctx); err != nil {
+		finishStage = tasks_manager.StartTaskStage(ctx, taskStageCommit)
+		if err := tasks_manager.WithResourceLock(ctx, tasks_manager.LockTufRepoCommit, func() error {
+			if err := publisherRepository.CommitStaged(ctx); err != nil {
+				return err
+			}
+
+			b.setTaskTufVersions(ctx, publisherRepository)
+			b.saveMirrorReplications(ctx, storage, publisherRepository)
+			return nil
+		}); err != nil {
 			return fmt.Errorf("unable to commit new tuf repository state: %w", err)
 		}
 
+		if err := putStatusEntry(ctx, storage, storageKeyLastRelease, lastRelease{GitTag: gitTag, Commit: headCommit, Time: SystemClock.Now()}); err != nil {
+			return err
+		}
+		finishStage()
+
 		logboek.Context(ctx).Default().LogF("Task finished\n")
 		b.Logger().Debug("Task finished")
 
 		return nil
-	})
+	}, nil
+}
+
+// stageReleaseArtifacts reads the artifacts from the tar stream one by one and signs and stages them concurrently.
+func (b *Backend) stageReleaseArtifacts(ctx context.Context, publisherRepository publisher.RepositoryInterface, releaseName string, twArtifacts *tar.Reader, concurrency int) error {
+	stager, err := publisher.NewReleaseTargetsStager(ctx, b.Publisher, publisherRepository, releaseName, concurrency)
 	if err != nil {
-		if err == tasks_manager.ErrBusy {
-			return logical.ErrorResponse("busy"), nil
+		return fmt.Errorf("unable to start release targets staging: %w", err)
+	}
+
+	for {
+		hdr, err := twArtifacts.Next()
+
+		if err == io.EOF {
+			break
 		}
 
-		return nil, err
+		if err != nil {
+			_ = stager.Wait()
+			return fmt.Errorf("error reading next tar artifact header: %w", err)
+		}
+
+		if strings.HasPrefix(hdr.Name, builder.ArtifactsDir+"/") && hdr.Typeflag != tar.TypeDir {
+			name := strings.TrimPrefix(hdr.Name, builder.ArtifactsDir+"/")
+			logboek.Context(ctx).Default().LogF("Publishing %q into the tuf repo ...\n", name)
+			b.Logger().Debug(fmt.Sprintf("Publishing %q into the tuf repo ...", name))
+
+			if err := stager.Stage(name, twArtifacts); err != nil {
+				_ = stager.Wait()
+				return err
+			}
+		}
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"task_uuid": taskUUID,
-		},
-	}, nil
+	return stager.Wait()
 }
 
 func cloneGitRepositoryTag(url, gitTag, username, password string) (*git.Repository, error) {
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/theupdateframework/go-tuf/data"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+const (
+	fieldNameRootKeyID     = "key_id"
+	fieldNameRootSignature = "signature"
+)
+
+func rootPaths(b *Backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: `root/pending$`,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathPendingRootPrepare,
+					Summary:  "Prepare the pending root.json replacing the previous one",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathPendingRootPrepare,
+					Summary:  "Prepare the pending root.json replacing the previous one",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathPendingRootRead,
+					Summary:  "Read the pending root.json and the collected signatures",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathPendingRootDelete,
+					Summary:  "Discard the pending root.json",
+				},
+			},
+
+			HelpSynopsis:    pathPendingRootHelpSyn,
+			HelpDescription: pathPendingRootHelpDesc,
+		},
+		{
+			Pattern: `root/pending/signatures$`,
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameRootKeyID: {
+					Type:        framework.TypeString,
+					Description: "The id of the root key",
+					Required:    true,
+				},
+				fieldNameRootSignature: {
+					Type:        framework.TypeString,
+					Description: "The hex-encoded signature of the signed field of the pending root.json",
+					Required:    true,
+				},
+				fieldNameQueue: {
+					Type:        framework.TypeBool,
+					Description: "Add the publication task to the queue instead of returning the busy error",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathPendingRootSign,
+					Summary:  pathPendingRootSignaturesHelpSyn,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathPendingRootSign,
+					Summary:  pathPendingRootSignaturesHelpSyn,
+				},
+			},
+
+			HelpSynopsis:    pathPendingRootSignaturesHelpSyn,
+			HelpDescription: pathPendingRootSignaturesHelpDesc,
+		},
+	}
+}
+
+func (b *Backend) pathPendingRootPrepare(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	cfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	opts := cfg.RepositoryOptions()
+	opts.InitializeTUFKeys = false
+	opts.InitializePGPSigningKey = false
+	publisherRepository, err := b.Publisher.GetRepository(ctx, req.Storage, opts)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return logical.ErrorResponse("repository is not initialized"), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting publisher repository: %w", err)
+	}
+
+	pendingRoot, err := b.Publisher.PrepareRoot(ctx, req.Storage, publisherRepository, opts.OfflineRoot)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare root.json: %w", err)
+	}
+
+	return pendingRootResponse(pendingRoot)
+}
+
+func (b *Backend) pathPendingRootRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	pendingRoot, err := publisher.GetPendingRoot(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get pending root.json: %w", err)
+	}
+
+	if pendingRoot == nil {
+		return logical.ErrorResponse("pending root.json not found"), nil
+	}
+
+	return pendingRootResponse(pendingRoot)
+}
+
+func (b *Backend) pathPendingRootDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if err := publisher.DeletePendingRoot(ctx, req.Storage); err != nil {
+		return nil, fmt.Errorf("unable to delete pending root.json: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathPendingRootSign(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if errResp := util.CheckRequiredFields(req, fields); errResp != nil {
+		return errResp, nil
+	}
+
+	cfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	pendingRoot, err := publisher.GetPendingRoot(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get pending root.json: %w", err)
+	}
+
+	if pendingRoot == nil {
+		return logical.ErrorResponse("pending root.json not found"), nil
+	}
+
+	signature, err := hex.DecodeString(fields.Get(fieldNameRootSignature).(string))
+	if err != nil {
+		return logical.ErrorResponse("unable to decode %q: %s", fieldNameRootSignature, err), nil
+	}
+
+	if err := pendingRoot.AddSignature(data.Signature{KeyID: fields.Get(fieldNameRootKeyID).(string), Signature: signature}); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := publisher.PutPendingRoot(ctx, req.Storage, pendingRoot); err != nil {
+		return nil, fmt.Errorf("unable to put pending root.json: %w", err)
+	}
+
+	resp, err := pendingRootResponse(pendingRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Data["missing_signatures"].(int) > 0 || resp.Data["missing_previous_signatures"].(int) > 0 {
+		return resp, nil
+	}
+
+	taskFunc, err := b.newPublishRootTaskFunc(ctx, req.Storage, cfg)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return logical.ErrorResponse("repository is not initialized"), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	taskUUID, err := b.runOrQueueTask(req.Storage, fields.Get(fieldNameQueue).(bool), publishRootTaskOptions(), taskFunc)
+	if err != nil {
+		if err == tasks_manager.ErrBusy {
+			return logical.ErrorResponse("busy: the signature is saved, sign again to retry the publication"), nil
+		}
+
+		return nil, err
+	}
+
+	resp.Data["task_uuid"] = taskUUID
+
+	return resp, nil
+}
+
+// newPublishRootTaskFunc returns publisher.ErrUninitializedRepositoryKeys if the repository keys are not generated yet.
+func (b *Backend) newPublishRootTaskFunc(ctx context.Context, storage logical.Storage, cfg *configuration) (func(context.Context, logical.Storage) error, error) {
+	opts := cfg.RepositoryOptions()
+	opts.InitializeTUFKeys = false
+	opts.InitializePGPSigningKey = false
+	publisherRepository, err := b.Publisher.GetRepository(ctx, storage, opts)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting publisher repository: %w", err)
+	}
+
+	return func(ctx context.Context, storage logical.Storage) error {
+		logboek.Context(ctx).Default().LogF("Started task\n")
+		b.Logger().Debug("Started task")
+
+		finishStage := tasks_manager.StartTaskStage(ctx, taskStagePublishRoot)
+		err := tasks_manager.WithResourceLock(ctx, tasks_manager.LockTufRepoCommit, func() error {
+			return b.Publisher.PublishRoot(ctx, storage, publisherRepository)
+		})
+		b.saveMirrorReplications(ctx, storage, publisherRepository)
+		if err != nil {
+			return fmt.Errorf("unable to publish root.json: %w", err)
+		}
+		finishStage()
+
+		logboek.Context(ctx).Default().LogF("Root.json is published\n")
+		b.Logger().Info("Root.json is published")
+		b.setTaskTufVersions(ctx, publisherRepository)
+
+		logboek.Context(ctx).Default().LogF("Task finished\n")
+		b.Logger().Debug("Task finished")
+
+		return nil
+	}, nil
+}
+
+func pendingRootResponse(pendingRoot *publisher.PendingRoot) (*logical.Response, error) {
+	root, err := pendingRoot.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	rootKeys, threshold, err := pendingRoot.RootKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	missing, previousMissing, err := pendingRoot.MissingSignatures()
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := map[string]string{}
+	for _, signature := range pendingRoot.Signatures {
+		signatures[signature.KeyID] = signature.Signature.String()
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"signed":                      string(pendingRoot.Signed),
+			"version":                     root.Version,
+			"expires":                     root.Expires,
+			"root_key_ids":                publicKeyIDs(rootKeys),
+			"threshold":                   threshold,
+			"previous_root_key_ids":       publicKeyIDs(pendingRoot.PreviousKeys),
+			"previous_threshold":          pendingRoot.PreviousThreshold,
+			"signatures":                  signatures,
+			"missing_signatures":          missing,
+			"missing_previous_signatures": previousMissing,
+		},
+	}, nil
+}
+
+func publicKeyIDs(publicKeys []*data.PublicKey) []string {
+	ids := []string{}
+	for _, publicKey := range publicKeys {
+		ids = append(ids, publicKey.IDs()[0])
+	}
+
+	return ids
+}
+
+const (
+	pathPendingRootHelpSyn  = "Manage root.json waiting for the signatures of the offline root keys"
+	pathPendingRootHelpDesc = "The pending root.json is prepared automatically for the new repository initialized with the offline root keys and before the expiration of root.json signed by the offline keys. The POST request prepares the pending root.json by hand, e.g. to switch the existing repository to the offline root keys of the configuration. The signed field is the canonical JSON to be signed by the root keys"
+
+	pathPendingRootSignaturesHelpSyn  = "Add the signature of the pending root.json"
+	pathPendingRootSignaturesHelpDesc = "The signature is verified against the root keys of the pending and the published root.json. Root.json is published by the task once it is signed by the threshold of both the new and the previous root keys"
+)
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/secure-systems-lab/go-securesystemslib/cjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/pkg/keys"
+
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+type PathRootCallbacksSuite struct {
+	CommonSuite
+	signers     []keys.Signer
+	pendingRoot *publisher.PendingRoot
+}
+
+func (suite *PathRootCallbacksSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+	suite.req.Path = "root/pending"
+
+	suite.signers = nil
+	root := data.NewRoot()
+	root.Version = 2
+	root.Expires = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	root.Roles["root"] = &data.Role{KeyIDs: []string{}, Threshold: 2}
+	for i := 0; i < 3; i++ {
+		signer, err := keys.GenerateEd25519Key()
+		assert.Nil(suite.T(), err)
+
+		suite.signers = append(suite.signers, signer)
+		root.AddKey(signer.PublicData())
+		root.Roles["root"].AddKeyIDs(signer.PublicData().IDs())
+	}
+
+	signed, err := cjson.EncodeCanonical(root)
+	assert.Nil(suite.T(), err)
+	suite.pendingRoot = &publisher.PendingRoot{Signed: signed}
+}
+
+func (suite *PathRootCallbacksSuite) signatureData(signer keys.Signer) map[string]interface{} {
+	signature, err := signer.SignMessage(suite.pendingRoot.Signed)
+	assert.Nil(suite.T(), err)
+
+	return map[string]interface{}{
+		fieldNameRootKeyID:     signer.PublicData().IDs()[0],
+		fieldNameRootSignature: hex.EncodeToString(signature),
+	}
+}
+
+func (suite *PathRootCallbacksSuite) TestPrepare_ConfigurationNotFound() {
+	suite.req.Operation = logical.CreateOperation
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), errorResponseConfigurationNotFound, resp)
+}
+
+func (suite *PathRootCallbacksSuite) TestPrepare_UninitializedRepository() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.req.Operation = logical.CreateOperation
+	suite.mockedPublisher.On("GetRepository").Return(nil, publisher.ErrUninitializedRepositoryKeys)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("repository is not initialized"), resp)
+
+	suite.mockedPublisher.AssertNotCalled(suite.T(), "PrepareRoot")
+}
+
+func (suite *PathRootCallbacksSuite) TestPrepare() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.req.Operation = logical.CreateOperation
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedPublisher.On("PrepareRoot", (*publisher.OfflineRootOptions)(nil)).Return(suite.pendingRoot)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), string(suite.pendingRoot.Signed), resp.Data["signed"])
+		assert.Equal(suite.T(), int64(2), resp.Data["version"])
+		assert.Len(suite.T(), resp.Data["root_key_ids"], 3)
+		assert.Equal(suite.T(), 2, resp.Data["threshold"])
+		assert.Equal(suite.T(), 2, resp.Data["missing_signatures"])
+		assert.Equal(suite.T(), 0, resp.Data["missing_previous_signatures"])
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+}
+
+func (suite *PathRootCallbacksSuite) TestReadAndDelete() {
+	suite.req.Operation = logical.ReadOperation
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("pending root.json not found"), resp)
+
+	err = publisher.PutPendingRoot(suite.ctx, suite.storage, suite.pendingRoot)
+	assert.Nil(suite.T(), err)
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), string(suite.pendingRoot.Signed), resp.Data["signed"])
+	}
+
+	suite.req.Operation = logical.DeleteOperation
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	pendingRoot, err := publisher.GetPendingRoot(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), pendingRoot)
+}
+
+func (suite *PathRootCallbacksSuite) TestSign() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+	err = publisher.PutPendingRoot(suite.ctx, suite.storage, suite.pendingRoot)
+	assert.Nil(suite.T(), err)
+
+	suite.req.Path = "root/pending/signatures"
+	suite.req.Operation = logical.CreateOperation
+
+	suite.Run("invalid signature", func() {
+		suite.req.Data = suite.signatureData(suite.signers[0])
+		suite.req.Data[fieldNameRootKeyID] = suite.signers[1].PublicData().IDs()[0]
+
+		resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+		assert.Nil(suite.T(), err)
+		if assert.NotNil(suite.T(), resp) {
+			assert.True(suite.T(), resp.IsError())
+			assert.Contains(suite.T(), resp.Error().Error(), "invalid signature")
+		}
+	})
+
+	suite.Run("threshold is not met", func() {
+		suite.req.Data = suite.signatureData(suite.signers[0])
+
+		resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+		assert.Nil(suite.T(), err)
+		if assert.NotNil(suite.T(), resp) {
+			assert.Equal(suite.T(), 1, resp.Data["missing_signatures"])
+			assert.NotContains(suite.T(), resp.Data, "task_uuid")
+		}
+
+		suite.mockedTasksManager.AssertNotCalled(suite.T(), "RunTask")
+	})
+
+	suite.Run("threshold is met", func() {
+		suite.req.Data = suite.signatureData(suite.signers[2])
+
+		suite.mockedPublisher.On("GetRepository").Return(nil)
+		suite.mockedTasksManager.On("RunTask").Return("UUID", nil)
+
+		resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+		assert.Nil(suite.T(), err)
+		if assert.NotNil(suite.T(), resp) {
+			assert.Equal(suite.T(), 0, resp.Data["missing_signatures"])
+			assert.Equal(suite.T(), "UUID", resp.Data["task_uuid"])
+		}
+
+		pendingRoot, err := publisher.GetPendingRoot(suite.ctx, suite.storage)
+		assert.Nil(suite.T(), err)
+		if assert.NotNil(suite.T(), pendingRoot) {
+			assert.Len(suite.T(), pendingRoot.Signatures, 2)
+		}
+
+		suite.mockedTasksManager.AssertExpectations(suite.T())
+	})
+}
+
+func (suite *PathRootCallbacksSuite) TestSign_Busy() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	signature, err := suite.signers[0].SignMessage(suite.pendingRoot.Signed)
+	assert.Nil(suite.T(), err)
+	err = suite.pendingRoot.AddSignature(data.Signature{KeyID: suite.signers[0].PublicData().IDs()[0], Signature: signature})
+	assert.Nil(suite.T(), err)
+	err = publisher.PutPendingRoot(suite.ctx, suite.storage, suite.pendingRoot)
+	assert.Nil(suite.T(), err)
+
+	suite.req.Path = "root/pending/signatures"
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Data = suite.signatureData(suite.signers[1])
+
+	// tasks manager is busy
+	suite.mockedTasksManager.IsBusy = true
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("RunTask").Return("", tasks_manager.ErrBusy)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.True(suite.T(), resp.IsError())
+		assert.Contains(suite.T(), resp.Error().Error(), "busy")
+	}
+}
+
+func (suite *PathRootCallbacksSuite) TestTaskFunc() {
+	cfg := completeConfiguration()
+
+	suite.Run("published", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("TakeMirrorReplications").Return(nil)
+		mockedRepository.On("GetVersions").Return(map[string]int64{"root": 2})
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+		suite.mockedPublisher.On("PublishRoot").Return(nil).Once()
+
+		taskFunc, err := suite.backend.newPublishRootTaskFunc(suite.ctx, suite.storage, cfg)
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), taskFunc(context.Background(), suite.storage))
+
+		mockedRepository.AssertExpectations(suite.T())
+	})
+
+	suite.Run("stale", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("TakeMirrorReplications").Return(nil)
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+		suite.mockedPublisher.On("PublishRoot").Return(publisher.ErrStalePendingRoot).Once()
+
+		taskFunc, err := suite.backend.newPublishRootTaskFunc(suite.ctx, suite.storage, cfg)
+		assert.Nil(suite.T(), err)
+		err = taskFunc(context.Background(), suite.storage)
+		assert.True(suite.T(), errors.Is(err, publisher.ErrStalePendingRoot))
+
+		mockedRepository.AssertNotCalled(suite.T(), "GetVersions")
+	})
+}
+
+func TestBackendPathRootCallbacks(t *testing.T) {
+	suite.Run(t, new(PathRootCallbacksSuite))
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/trdl/server/pkg/publisher"
+)
+
+const (
+	storageKeyLastRelease        = "last_release"
+	storageKeyPeriodicTaskResult = "periodic_task_result"
+)
+
+type lastRelease struct {
+	GitTag string    `json:"git_tag" structs:"git_tag"`
+	Commit string    `json:"commit" structs:"commit"`
+	Time   time.Time `json:"time" structs:"time"`
+}
+
+type periodicTaskResult struct {
+	LastRun       time.Time  `json:"last_run" structs:"last_run"`
+	LastSucceeded *time.Time `json:"last_succeeded,omitempty" structs:"last_succeeded"`
+	Status        string     `json:"status" structs:"status"`
+	Error         string     `json:"error,omitempty" structs:"error"`
+}
+
+func statusPath(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `status$`,
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathStatus,
+				Summary:  pathStatusHelpSyn,
+			},
+		},
+
+		HelpSynopsis:    pathStatusHelpSyn,
+		HelpDescription: pathStatusHelpDesc,
+	}
+}
+
+func (b *Backend) pathStatus(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	cfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	data := map[string]interface{}{}
+
+	// the status must not initialize the repository keys
+	{
+		opts := cfg.RepositoryOptions()
+		opts.InitializeTUFKeys = false
+		opts.InitializePGPSigningKey = false
+		publisherRepository, err := b.Publisher.GetRepository(ctx, req.Storage, opts)
+		switch {
+		case err == publisher.ErrUninitializedRepositoryKeys:
+			data["repository_initialized"] = false
+		case err != nil:
+			return nil, fmt.Errorf("error getting publisher repository: %w", err)
+		default:
+			rolesMetadata, err := publisherRepository.GetRolesMetadata(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get TUF repository metadata: %w", err)
+			}
+
+			now := SystemClock.Now()
+			roles := map[string]interface{}{}
+			for role, metadata := range rolesMetadata {
+				if !metadata.Published {
+					roles[role] = map[string]interface{}{"published": false}
+					continue
+				}
+
+				roles[role] = map[string]interface{}{
+					"published":  true,
+					"version":    metadata.Version,
+					"expires":    metadata.Expires,
+					"expires_in": int64(metadata.Expires.Sub(now) / time.Second),
+				}
+			}
+
+			data["repository_initialized"] = true
+			data["tuf_roles"] = roles
+		}
+	}
+
+	lastPublishedGitCommit, err := getLastPublishedGitCommit(ctx, req.Storage, cfg)
+	if err != nil {
+		return nil, err
+	}
+	data[storageKeyLastPublishedGitCommit] = lastPublishedGitCommit
+
+	release := &lastRelease{}
+	if ok, err := getStatusEntry(ctx, req.Storage, storageKeyLastRelease, release); err != nil {
+		return nil, err
+	} else if ok {
+		data[storageKeyLastRelease] = structs.Map(release)
+	} else {
+		data[storageKeyLastRelease] = nil
+	}
+
+	periodicResult := &periodicTaskResult{}
+	if ok, err := getStatusEntry(ctx, req.Storage, storageKeyPeriodicTaskResult, periodicResult); err != nil {
+		return nil, err
+	} else if ok {
+		data["periodic_task"] = structs.Map(periodicResult)
+	} else {
+		data["periodic_task"] = nil
+	}
+
+	mirrors, err := publisher.GetMirrors(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get mirrors: %w", err)
+	}
+
+	mirrorsStatus := map[string]interface{}{}
+	for _, mirror := range mirrors {
+		status, err := publisher.GetMirrorStatus(ctx, req.Storage, mirror.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		mirrorsStatus[mirror.Name] = structs.Map(status)
+	}
+	data["mirrors"] = mirrorsStatus
+
+	fingerprint, err := b.Publisher.GetPGPSigningKeyFingerprint(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get PGP signing key: %w", err)
+	}
+	data["pgp_signing_key_fingerprint"] = fingerprint
+
+	return &logical.Response{Data: data}, nil
+}
+
+func putStatusEntry(ctx context.Context, storage logical.Storage, key string, value interface{}) error {
+	entry, err := logical.StorageEntryJSON(key, value)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put %q into storage: %w", key, err)
+	}
+
+	return nil
+}
+
+// getStatusEntry returns false if the entry is not found.
+func getStatusEntry(ctx context.Context, storage logical.Storage, key string, value interface{}) (bool, error) {
+	entry, err := storage.Get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("unable to get %q from storage: %w", key, err)
+	}
+
+	if entry == nil {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(entry.Value, value); err != nil {
+		return false, fmt.Errorf("unable to unmarshal %q: %w", key, err)
+	}
+
+	return true, nil
+}
+
+const (
+	pathStatusHelpSyn  = "Get the repository health status"
+	pathStatusHelpDesc = "Get the versions and expiration times of the TUF repository metadata, the last published commit, the last release, the PGP signing key fingerprint, the replication status of the mirrors and the result of the last periodic task"
+)
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+type PathStatusCallbackSuite struct {
+	CommonSuite
+	origSystemClock util.Clock
+	now             time.Time
+}
+
+func (suite *PathStatusCallbackSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+	suite.req.Path = "status"
+	suite.req.Operation = logical.ReadOperation
+
+	suite.now = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	suite.origSystemClock = SystemClock
+	SystemClock = util.NewFixedClock(suite.now)
+}
+
+func (suite *PathStatusCallbackSuite) TearDownTest() {
+	SystemClock = suite.origSystemClock
+}
+
+func (suite *PathStatusCallbackSuite) TestConfigurationNotFound() {
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), errorResponseConfigurationNotFound, resp)
+}
+
+func (suite *PathStatusCallbackSuite) TestUninitializedRepository() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.mockedPublisher.On("GetRepository").Return(nil, publisher.ErrUninitializedRepositoryKeys)
+	suite.mockedPublisher.On("GetPGPSigningKeyFingerprint").Return("")
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{
+			"repository_initialized":         false,
+			storageKeyLastPublishedGitCommit: completeConfiguration().InitialLastPublishedGitCommit,
+			storageKeyLastRelease:            nil,
+			"periodic_task":                  nil,
+			"mirrors":                        map[string]interface{}{},
+			"pgp_signing_key_fingerprint":    "",
+		}, resp.Data)
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+}
+
+func (suite *PathStatusCallbackSuite) TestStatus() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	err = putStatusEntry(suite.ctx, suite.storage, storageKeyLastRelease, lastRelease{GitTag: "v1.0.0", Commit: "0123456789abcdef", Time: suite.now.Add(-time.Hour)})
+	assert.Nil(suite.T(), err)
+
+	assert.Nil(suite.T(), putPeriodicTaskResult(suite.ctx, suite.storage, nil))
+	SystemClock = util.NewFixedClock(suite.now.Add(time.Hour))
+	assert.Nil(suite.T(), putPeriodicTaskResult(suite.ctx, suite.storage, errors.New("rotation failed")))
+	SystemClock = util.NewFixedClock(suite.now)
+
+	err = publisher.PutMirror(suite.ctx, suite.storage, publisher.MirrorOptions{Name: "eu", LocalPath: "/srv/trdl"})
+	assert.Nil(suite.T(), err)
+
+	err = publisher.SaveMirrorReplications(suite.ctx, suite.storage, []publisher.MirrorReplication{{Mirror: "eu", Time: suite.now, Err: errors.New("access denied")}})
+	assert.Nil(suite.T(), err)
+
+	timestampExpires := suite.now.Add(6 * time.Hour)
+	mockedRepository := &MockedRepository{}
+	mockedRepository.On("GetRolesMetadata").Return(map[string]publisher.RoleMetadata{
+		"timestamp": {Published: true, Version: 42, Expires: timestampExpires},
+		"root":      {},
+	})
+
+	suite.mockedPublisher.On("GetRepository").Return(mockedRepository)
+	suite.mockedPublisher.On("GetPGPSigningKeyFingerprint").Return("FINGERPRINT")
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), true, resp.Data["repository_initialized"])
+		assert.Equal(suite.T(), map[string]interface{}{
+			"root": map[string]interface{}{"published": false},
+			"timestamp": map[string]interface{}{
+				"published":  true,
+				"version":    int64(42),
+				"expires":    timestampExpires,
+				"expires_in": int64(6 * 60 * 60),
+			},
+		}, resp.Data["tuf_roles"])
+		assert.Equal(suite.T(), "FINGERPRINT", resp.Data["pgp_signing_key_fingerprint"])
+		assert.Equal(suite.T(), map[string]interface{}{
+			"git_tag": "v1.0.0",
+			"commit":  "0123456789abcdef",
+			"time":    suite.now.Add(-time.Hour),
+		}, resp.Data[storageKeyLastRelease])
+
+		assert.Equal(suite.T(), map[string]interface{}{
+			"eu": map[string]interface{}{
+				"synced":       false,
+				"last_attempt": suite.now,
+				"last_success": (*time.Time)(nil),
+				"last_error":   "access denied",
+			},
+		}, resp.Data["mirrors"])
+
+		periodicTask := resp.Data["periodic_task"].(map[string]interface{})
+		assert.Equal(suite.T(), "FAILED", periodicTask["status"])
+		assert.Equal(suite.T(), "rotation failed", periodicTask["error"])
+		assert.Equal(suite.T(), suite.now.Add(time.Hour), periodicTask["last_run"])
+		if lastSucceeded, ok := periodicTask["last_succeeded"].(*time.Time); assert.True(suite.T(), ok) {
+			assert.Equal(suite.T(), suite.now, *lastSucceeded)
+		}
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	mockedRepository.AssertExpectations(suite.T())
+}
+
+func TestBackendPathStatusCallback(t *testing.T) {
+	suite.Run(t, new(PathStatusCallbackSuite))
+}
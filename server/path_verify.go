@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+func verifyPath(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `verify$`,
+		Fields: map[string]*framework.FieldSchema{
+			fieldNameQueue: {
+				Type:        framework.TypeBool,
+				Description: "Add the task to the queue instead of returning the busy error. The queued or running verify task is returned instead of adding a duplicate",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.pathVerify,
+				Summary:  pathVerifyHelpSyn,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathVerify,
+				Summary:  pathVerifyHelpSyn,
+			},
+		},
+
+		HelpSynopsis:    pathVerifyHelpSyn,
+		HelpDescription: pathVerifyHelpDesc,
+	}
+}
+
+func (b *Backend) pathVerify(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if errResp := util.CheckRequiredFields(req, fields); errResp != nil {
+		return errResp, nil
+	}
+
+	cfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	taskFunc, err := b.newVerifyTaskFunc(ctx, req.Storage, cfg)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return logical.ErrorResponse("repository is not initialized: nothing to verify"), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	taskUUID, err := b.runOrQueueTask(req.Storage, fields.Get(fieldNameQueue).(bool), verifyTaskOptions(), taskFunc)
+	if err != nil {
+		if err == tasks_manager.ErrBusy {
+			return logical.ErrorResponse("busy"), nil
+		}
+
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"task_uuid": taskUUID,
+		},
+	}, nil
+}
+
+// newVerifyTaskFunc returns publisher.ErrUninitializedRepositoryKeys if nothing is published yet.
+func (b *Backend) newVerifyTaskFunc(ctx context.Context, storage logical.Storage, cfg *configuration) (func(context.Context, logical.Storage) error, error) {
+	opts := cfg.RepositoryOptions()
+	opts.InitializeTUFKeys = false
+	opts.InitializePGPSigningKey = false
+	publisherRepository, err := b.Publisher.GetRepository(ctx, storage, opts)
+	if err == publisher.ErrUninitializedRepositoryKeys {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting publisher repository: %w", err)
+	}
+
+	return func(ctx context.Context, storage logical.Storage) error {
+		logboek.Context(ctx).Default().LogF("Started task\n")
+		b.Logger().Debug("Started task")
+
+		// the repository is verified under the commit lock, so that the metadata and the targets are not changed during the verification
+		var report *publisher.VerificationReport
+		if err := tasks_manager.WithResourceLock(ctx, tasks_manager.LockTufRepoCommit, func() error {
+			finishStage := tasks_manager.StartTaskStage(ctx, taskStageVerifyRepository)
+			var err error
+			report, err = publisherRepository.Verify(ctx)
+			if err != nil {
+				return fmt.Errorf("unable to verify repository: %w", err)
+			}
+			finishStage()
+
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for name, reason := range report.InvalidMetadata {
+			logboek.Context(ctx).Warn().LogF("Invalid metadata %q: %s\n", name, reason)
+		}
+
+		for _, target := range report.MissingTargets {
+			logboek.Context(ctx).Warn().LogF("Missing target %q\n", target)
+		}
+
+		for target, reason := range report.ModifiedTargets {
+			logboek.Context(ctx).Warn().LogF("Modified target %q: %s\n", target, reason)
+		}
+
+		for _, object := range report.UnreferencedObjects {
+			logboek.Context(ctx).Default().LogF("Unreferenced object %q\n", object)
+		}
+
+		logboek.Context(ctx).Default().LogF("Verification result: %s\n", report)
+		b.Logger().Info(fmt.Sprintf("Repository verification result: %s", report))
+
+		if report.Failed() {
+			return fmt.Errorf("repository verification failed: %s", report)
+		}
+
+		b.setTaskTufVersions(ctx, publisherRepository)
+
+		logboek.Context(ctx).Default().LogF("Task finished\n")
+		b.Logger().Debug("Task finished")
+
+		return nil
+	}, nil
+}
+
+const (
+	pathVerifyHelpSyn  = "Verify the repository integrity"
+	pathVerifyHelpDesc = "Check the signatures of the TUF repository metadata and the length and hashes of every target in the bucket. The task fails if the metadata is invalid or a target is missing or modified, the objects not registered in the metadata are only reported, because the targets of the running release are uploaded before they are registered"
+)
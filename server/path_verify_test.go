@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+type PathVerifyCallbackSuite struct {
+	CommonSuite
+}
+
+func (suite *PathVerifyCallbackSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+	suite.req.Path = "verify"
+	suite.req.Operation = logical.CreateOperation
+}
+
+func (suite *PathVerifyCallbackSuite) TestConfigurationNotFound() {
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), errorResponseConfigurationNotFound, resp)
+}
+
+func (suite *PathVerifyCallbackSuite) TestUninitializedRepository() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.mockedPublisher.On("GetRepository").Return(nil, publisher.ErrUninitializedRepositoryKeys)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("repository is not initialized: nothing to verify"), resp)
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "RunTask")
+}
+
+func (suite *PathVerifyCallbackSuite) TestBasic() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("RunTask").Return("UUID", nil)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathVerifyCallbackSuite) TestBusy() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	// tasks manager is busy
+	suite.mockedTasksManager.IsBusy = true
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("RunTask").Return("", tasks_manager.ErrBusy)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("busy"), resp)
+}
+
+func (suite *PathVerifyCallbackSuite) TestQueue() {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.req.Data = map[string]interface{}{fieldNameQueue: true}
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", verifyTaskOptions()).Return("UUID", nil)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathVerifyCallbackSuite) TestTaskFunc() {
+	cfg := completeConfiguration()
+
+	suite.Run("verified", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("Verify").Return(&publisher.VerificationReport{
+			VerifiedTargets:     2,
+			UnreferencedObjects: []string{"targets/releases/1.0.1/linux-amd64/bin/app"},
+		})
+		mockedRepository.On("GetVersions").Return(map[string]int64{"root": 1})
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+
+		taskFunc, err := suite.backend.newVerifyTaskFunc(suite.ctx, suite.storage, cfg)
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), taskFunc(context.Background(), suite.storage))
+
+		mockedRepository.AssertExpectations(suite.T())
+	})
+
+	suite.Run("failed", func() {
+		mockedRepository := &MockedRepository{}
+		mockedRepository.On("Verify").Return(&publisher.VerificationReport{
+			MissingTargets: []string{"channels/1/stable"},
+		})
+
+		suite.mockedPublisher.On("GetRepository").Return(mockedRepository).Once()
+
+		taskFunc, err := suite.backend.newVerifyTaskFunc(suite.ctx, suite.storage, cfg)
+		assert.Nil(suite.T(), err)
+		err = taskFunc(context.Background(), suite.storage)
+		if assert.Error(suite.T(), err) {
+			assert.Contains(suite.T(), err.Error(), "1 missing targets")
+		}
+
+		mockedRepository.AssertNotCalled(suite.T(), "GetVersions")
+	})
+}
+
+func TestBackendPathVerifyCallback(t *testing.T) {
+	suite.Run(t, new(PathVerifyCallbackSuite))
+}
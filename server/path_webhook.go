@@ -0,0 +1,459 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/trdl/server/pkg/notifications"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+const (
+	fieldNameWebhookSecret  = "secret"
+	fieldNameWebhookMode    = "mode"
+	fieldNameWebhookPayload = "payload"
+	fieldNameWebhookRef     = "ref"
+	fieldNameWebhookAfter   = "after"
+
+	storageKeyGitWebhook           = "git_webhook"
+	storageKeyGitWebhookDeliveries = "git_webhook_deliveries"
+
+	pathPatternGitWebhook = "webhook/git"
+
+	// gitWebhookModeSignature verifies the HMAC-SHA256 signature of the payload field passed in the X-Trdl-Signature header.
+	gitWebhookModeSignature = "signature"
+	// gitWebhookModeGitlab compares the X-Gitlab-Token header with the secret for compatibility with the GitLab webhooks.
+	gitWebhookModeGitlab = "gitlab"
+
+	// The headers of the GitLab webhook request, must be passed to the plugin with passthrough_request_headers.
+	gitlabHeaderToken     = "X-Gitlab-Token"
+	gitlabHeaderEvent     = "X-Gitlab-Event"
+	gitlabHeaderEventUUID = "X-Gitlab-Event-UUID"
+
+	gitlabEventPush    = "Push Hook"
+	gitlabEventTagPush = "Tag Push Hook"
+
+	// gitWebhookDeliveryTTL is the period the delivery ids are kept to reject the replayed requests.
+	gitWebhookDeliveryTTL = 7 * 24 * time.Hour
+)
+
+// gitWebhookDeliveriesMu serializes the handling of the deliveries, so that the same delivery cannot add the task twice.
+var gitWebhookDeliveriesMu sync.Mutex
+
+type gitWebhookConfiguration struct {
+	Secret string `json:"secret"`
+	Mode   string `json:"mode,omitempty"`
+}
+
+// gitWebhookEvent is the push event of the signed payload or of the GitLab webhook request.
+type gitWebhookEvent struct {
+	Delivery string `json:"delivery"`
+	Ref      string `json:"ref"`
+	After    string `json:"after"`
+
+	// ignored is the reason the event is not handled
+	ignored string
+}
+
+func configureGitWebhookPath(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern:         "configure/git_webhook/?",
+		HelpSynopsis:    "Configure the git webhook",
+		HelpDescription: "Configure the secret and the verification mode of the unauthenticated webhook/git endpoint. The webhook is disabled until the secret is set. The secret is never returned",
+		Fields: map[string]*framework.FieldSchema{
+			fieldNameWebhookSecret: {
+				Type:         framework.TypeString,
+				Description:  "Secret to verify the webhook requests: the key of the payload signature or the X-Gitlab-Token header value in the gitlab mode",
+				Required:     true,
+				DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+			},
+			fieldNameWebhookMode: {
+				Type:          framework.TypeString,
+				Description:   "Verification mode of the webhook requests: signature verifies the HMAC-SHA256 signature of the payload field passed in the X-Trdl-Signature header, gitlab compares the X-Gitlab-Token header with the secret for compatibility with the GitLab webhooks",
+				AllowedValues: []interface{}{gitWebhookModeSignature, gitWebhookModeGitlab},
+				Default:       gitWebhookModeSignature,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Description: "Configure the git webhook",
+				Callback:    b.pathConfigureGitWebhookCreateOrUpdate,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Description: "Configure the git webhook",
+				Callback:    b.pathConfigureGitWebhookCreateOrUpdate,
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Description: "Get the git webhook configuration",
+				Callback:    b.pathConfigureGitWebhookRead,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Description: "Disable the git webhook",
+				Callback:    b.pathConfigureGitWebhookDelete,
+			},
+		},
+	}
+}
+
+func gitWebhookPath(b *Backend) *framework.Path {
+	return &framework.Path{
+		Pattern: pathPatternGitWebhook + "$",
+		// the other fields of the GitLab push event are ignored
+		TakesArbitraryInput: true,
+		Fields: map[string]*framework.FieldSchema{
+			fieldNameWebhookPayload: {
+				Type:        framework.TypeString,
+				Description: "Push event JSON with the delivery, ref and after fields signed by the X-Trdl-Signature header (signature mode)",
+			},
+			fieldNameWebhookRef: {
+				Type:        framework.TypeString,
+				Description: "Git reference of the push event: refs/tags/<tag> or refs/heads/<branch> (gitlab mode)",
+			},
+			fieldNameWebhookAfter: {
+				Type:        framework.TypeString,
+				Description: "Commit the reference points to after the push, the zero commit for the deleted reference (gitlab mode)",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.pathGitWebhook,
+				Summary:  pathGitWebhookHelpSyn,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathGitWebhook,
+				Summary:  pathGitWebhookHelpSyn,
+			},
+		},
+
+		HelpSynopsis:    pathGitWebhookHelpSyn,
+		HelpDescription: pathGitWebhookHelpDesc,
+	}
+}
+
+func (b *Backend) pathConfigureGitWebhookCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if errResp := util.CheckRequiredFields(req, fields); errResp != nil {
+		return errResp, nil
+	}
+
+	secret := fields.Get(fieldNameWebhookSecret).(string)
+	if secret == "" {
+		return logical.ErrorResponse("Field %q cannot be empty", fieldNameWebhookSecret), nil
+	}
+
+	mode := fields.Get(fieldNameWebhookMode).(string)
+	if mode != gitWebhookModeSignature && mode != gitWebhookModeGitlab {
+		return logical.ErrorResponse("Field %q must be %q or %q", fieldNameWebhookMode, gitWebhookModeSignature, gitWebhookModeGitlab), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(storageKeyGitWebhook, gitWebhookConfiguration{Secret: secret, Mode: mode})
+	if err != nil {
+		return nil, fmt.Errorf("error creating storage json entry: %w", err)
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, fmt.Errorf("unable to put %q into storage: %w", storageKeyGitWebhook, err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathConfigureGitWebhookRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	cfg, err := getGitWebhookConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg == nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"enabled": false,
+			},
+		}, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":            true,
+			fieldNameWebhookMode: cfg.mode(),
+		},
+	}, nil
+}
+
+func (b *Backend) pathConfigureGitWebhookDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, storageKeyGitWebhook); err != nil {
+		return nil, fmt.Errorf("unable to delete %q from storage: %w", storageKeyGitWebhook, err)
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) pathGitWebhook(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	webhookCfg, err := getGitWebhookConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhookCfg == nil {
+		return logical.ErrorResponse("git webhook is not configured"), nil
+	}
+
+	var event *gitWebhookEvent
+	var errResp *logical.Response
+	switch webhookCfg.mode() {
+	case gitWebhookModeGitlab:
+		event, errResp, err = b.gitlabWebhookEvent(req, fields, webhookCfg)
+	default:
+		event, errResp, err = b.signedGitWebhookEvent(req, fields, webhookCfg)
+	}
+
+	if err != nil || errResp != nil {
+		return errResp, err
+	}
+
+	gitWebhookDeliveriesMu.Lock()
+	defer gitWebhookDeliveriesMu.Unlock()
+
+	deliveries, err := getGitWebhookDeliveries(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := deliveries[event.Delivery]; ok {
+		b.Logger().Warn(fmt.Sprintf("Git webhook: duplicate delivery %q", event.Delivery))
+		return logical.ErrorResponse("duplicate delivery %q", event.Delivery), nil
+	}
+
+	resp, err := b.handleGitWebhookEvent(ctx, req, event)
+	if err != nil || resp.IsError() {
+		return resp, err
+	}
+
+	// the delivery is registered only after the task is added or the event is ignored, so that the failed delivery can be retried
+	if err := putGitWebhookDelivery(ctx, req.Storage, deliveries, event.Delivery); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// signedGitWebhookEvent verifies the X-Trdl-Signature header of the payload and decodes the push event from it.
+// The delivery id is a part of the signed payload, so that the replayed request cannot be passed off as a new delivery.
+func (b *Backend) signedGitWebhookEvent(req *logical.Request, fields *framework.FieldData, webhookCfg *gitWebhookConfiguration) (*gitWebhookEvent, *logical.Response, error) {
+	payload := fields.Get(fieldNameWebhookPayload).(string)
+	if payload == "" {
+		return nil, logical.ErrorResponse("Required field %q must be set", fieldNameWebhookPayload), nil
+	}
+
+	signature := http.Header(req.Headers).Get(notifications.HeaderSignature)
+	if !hmac.Equal([]byte(signature), []byte(notifications.Sign(webhookCfg.Secret, []byte(payload)))) {
+		b.Logger().Warn("Git webhook: invalid signature")
+		return nil, nil, logical.ErrPermissionDenied
+	}
+
+	event := &gitWebhookEvent{}
+	if err := json.Unmarshal([]byte(payload), event); err != nil {
+		return nil, logical.ErrorResponse("unable to decode field %q: %s", fieldNameWebhookPayload, err), nil
+	}
+
+	for name, value := range map[string]string{"delivery": event.Delivery, "ref": event.Ref} {
+		if value == "" {
+			return nil, logical.ErrorResponse("field %q of the payload must be set", name), nil
+		}
+	}
+
+	return event, nil, nil
+}
+
+// gitlabWebhookEvent verifies the X-Gitlab-Token header and gets the push event from the GitLab webhook request.
+func (b *Backend) gitlabWebhookEvent(req *logical.Request, fields *framework.FieldData, webhookCfg *gitWebhookConfiguration) (*gitWebhookEvent, *logical.Response, error) {
+	headers := http.Header(req.Headers)
+	if subtle.ConstantTimeCompare([]byte(headers.Get(gitlabHeaderToken)), []byte(webhookCfg.Secret)) != 1 {
+		b.Logger().Warn("Git webhook: invalid token")
+		return nil, nil, logical.ErrPermissionDenied
+	}
+
+	event := &gitWebhookEvent{Delivery: headers.Get(gitlabHeaderEventUUID)}
+	if event.Delivery == "" {
+		return nil, logical.ErrorResponse("header %q must be set", gitlabHeaderEventUUID), nil
+	}
+
+	switch eventType := headers.Get(gitlabHeaderEvent); eventType {
+	case gitlabEventPush, gitlabEventTagPush:
+	default:
+		event.ignored = fmt.Sprintf("event %q is not a push event", eventType)
+		return event, nil, nil
+	}
+
+	event.Ref = fields.Get(fieldNameWebhookRef).(string)
+	if event.Ref == "" {
+		return nil, logical.ErrorResponse("Required field %q must be set", fieldNameWebhookRef), nil
+	}
+	event.After = fields.Get(fieldNameWebhookAfter).(string)
+
+	return event, nil, nil
+}
+
+func (b *Backend) handleGitWebhookEvent(ctx context.Context, req *logical.Request, event *gitWebhookEvent) (*logical.Response, error) {
+	if event.ignored != "" {
+		return gitWebhookIgnoredResponse(event.ignored), nil
+	}
+
+	ref := event.Ref
+	if isZeroCommit(event.After) {
+		return gitWebhookIgnoredResponse(fmt.Sprintf("reference %q is deleted", ref)), nil
+	}
+
+	cfg, err := getConfiguration(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return errorResponseConfigurationNotFound, nil
+	}
+
+	switch {
+	case strings.HasPrefix(ref, "refs/tags/"):
+		gitTag := strings.TrimPrefix(ref, "refs/tags/")
+		if err := ValidateReleaseVersion(gitTag); err != nil {
+			return gitWebhookIgnoredResponse(fmt.Sprintf("tag %q is not a release version", gitTag)), nil
+		}
+
+		// the tag signatures are verified by the release task
+		taskFunc, err := b.newReleaseTaskFunc(ctx, req.Storage, cfg, gitTag, "", "")
+		if err != nil {
+			return nil, err
+		}
+
+		taskUUID, err := b.TasksManager.AddTask(context.Background(), req.Storage, releaseTaskOptions(gitTag), taskFunc)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Logger().Info(fmt.Sprintf("Git webhook: added release task %q for the tag %q", taskUUID, gitTag))
+		return gitWebhookTaskResponse(taskUUID), nil
+
+	case cfg.GitTrdlChannelsBranch != "" && ref == "refs/heads/"+cfg.GitTrdlChannelsBranch:
+		// the commit signatures are verified by the publish task
+		taskFunc, err := b.newPublishTaskFunc(ctx, req.Storage, cfg, "", "")
+		if err != nil {
+			return nil, err
+		}
+
+		taskUUID, err := b.TasksManager.AddTask(context.Background(), req.Storage, publishTaskOptions(), taskFunc)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Logger().Info(fmt.Sprintf("Git webhook: added publish task %q for the branch %q", taskUUID, cfg.GitTrdlChannelsBranch))
+		return gitWebhookTaskResponse(taskUUID), nil
+	}
+
+	return gitWebhookIgnoredResponse(fmt.Sprintf("reference %q is neither a release tag nor the trdl channels branch", ref)), nil
+}
+
+func gitWebhookTaskResponse(taskUUID string) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"task_uuid": taskUUID,
+		},
+	}
+}
+
+// gitWebhookIgnoredResponse is not an error, so that the webhook sender does not retry the delivery.
+func gitWebhookIgnoredResponse(reason string) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"ignored": reason,
+		},
+	}
+}
+
+// isZeroCommit returns true for the commit sent by GitLab for the deleted reference.
+func isZeroCommit(commit string) bool {
+	return commit != "" && strings.Trim(commit, "0") == ""
+}
+
+// getGitWebhookDeliveries returns the registered delivery ids with their registration time.
+func getGitWebhookDeliveries(ctx context.Context, storage logical.Storage) (map[string]int64, error) {
+	deliveries := map[string]int64{}
+
+	entry, err := storage.Get(ctx, storageKeyGitWebhookDeliveries)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %q from storage: %w", storageKeyGitWebhookDeliveries, err)
+	}
+
+	if entry != nil {
+		if err := entry.DecodeJSON(&deliveries); err != nil {
+			return nil, fmt.Errorf("unable to decode %q: %w", storageKeyGitWebhookDeliveries, err)
+		}
+	}
+
+	return deliveries, nil
+}
+
+// putGitWebhookDelivery registers the delivery id, the ids older than gitWebhookDeliveryTTL are dropped.
+func putGitWebhookDelivery(ctx context.Context, storage logical.Storage, deliveries map[string]int64, deliveryID string) error {
+	now := SystemClock.Now()
+	for id, timestamp := range deliveries {
+		if now.Sub(time.Unix(timestamp, 0)) > gitWebhookDeliveryTTL {
+			delete(deliveries, id)
+		}
+	}
+	deliveries[deliveryID] = now.Unix()
+
+	entry, err := logical.StorageEntryJSON(storageKeyGitWebhookDeliveries, deliveries)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put %q into storage: %w", storageKeyGitWebhookDeliveries, err)
+	}
+
+	return nil
+}
+
+// getGitWebhookConfiguration returns nil if the webhook is not configured.
+func getGitWebhookConfiguration(ctx context.Context, storage logical.Storage) (*gitWebhookConfiguration, error) {
+	entry, err := storage.Get(ctx, storageKeyGitWebhook)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %q from storage: %w", storageKeyGitWebhook, err)
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	cfg := &gitWebhookConfiguration{}
+	if err := json.Unmarshal(entry.Value, cfg); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %q: %w", storageKeyGitWebhook, err)
+	}
+
+	return cfg, nil
+}
+
+// mode returns the signature mode for the configuration stored without the mode.
+func (cfg *gitWebhookConfiguration) mode() string {
+	if cfg.Mode == "" {
+		return gitWebhookModeSignature
+	}
+
+	return cfg.Mode
+}
+
+const (
+	pathGitWebhookHelpSyn  = "Trigger the release or publish task by the git push event"
+	pathGitWebhookHelpDesc = "The endpoint receives the git push events and does not require the Vault token. In the signature mode (default) the payload field must contain the push event JSON with the unique delivery id, the git reference and the commit after the push: {\"delivery\": \"<id>\", \"ref\": \"refs/tags/v1.0.0\", \"after\": \"<commit>\"}. The payload is signed with the secret configured with configure/git_webhook using HMAC-SHA256, the signature is passed in the X-Trdl-Signature header as sha256=<hex>. In the gitlab mode the endpoint receives the GitLab push events verified by the X-Gitlab-Token header, the delivery id is taken from the X-Gitlab-Event-UUID header. The used headers must be allowed with passthrough_request_headers of the plugin mount. The repeated delivery id is rejected, the delivery is registered only after the task is added or the event is ignored. The release task is added for the release tag and the publish task is added for the trdl channels branch, other references are ignored. The git signatures are verified by the tasks as usual"
+)
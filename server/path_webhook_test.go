@@ -0,0 +1,383 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/werf/trdl/server/pkg/notifications"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+const testGitWebhookSecret = "s3cr3t"
+
+type PathGitWebhookCallbackSuite struct {
+	CommonSuite
+	origSystemClock util.Clock
+	now             time.Time
+	deliveries      int
+}
+
+func (suite *PathGitWebhookCallbackSuite) SetupTest() {
+	suite.CommonSuite.SetupTest()
+	suite.req.Path = "webhook/git"
+	suite.req.Operation = logical.CreateOperation
+
+	suite.deliveries = 0
+	suite.now = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	suite.origSystemClock = SystemClock
+	SystemClock = util.NewFixedClock(suite.now)
+}
+
+func (suite *PathGitWebhookCallbackSuite) TearDownTest() {
+	SystemClock = suite.origSystemClock
+}
+
+func (suite *PathGitWebhookCallbackSuite) configure(mode string) {
+	err := putConfiguration(suite.ctx, suite.storage, completeConfiguration())
+	assert.Nil(suite.T(), err)
+
+	suite.configureWebhook(mode)
+}
+
+func (suite *PathGitWebhookCallbackSuite) configureWebhook(mode string) {
+	data := map[string]interface{}{fieldNameWebhookSecret: testGitWebhookSecret}
+	if mode != "" {
+		data[fieldNameWebhookMode] = mode
+	}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "configure/git_webhook",
+		Data:      data,
+		Storage:   suite.storage,
+	})
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+}
+
+// signedPushEvent prepares the request with the push event payload signed by the secret and with the unique delivery id.
+func (suite *PathGitWebhookCallbackSuite) signedPushEvent(secret, ref string) {
+	suite.deliveries++
+	suite.signedPayload(secret, fmt.Sprintf(`{"delivery": "delivery-%d", "ref": %q, "after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"}`, suite.deliveries, ref))
+}
+
+func (suite *PathGitWebhookCallbackSuite) signedPayload(secret, payload string) {
+	headers := http.Header{}
+	headers.Set(notifications.HeaderSignature, notifications.Sign(secret, []byte(payload)))
+
+	suite.req.Headers = headers
+	suite.req.Data = map[string]interface{}{fieldNameWebhookPayload: payload}
+}
+
+// gitlabPushEvent prepares the request of the GitLab push event with the unique delivery id.
+func (suite *PathGitWebhookCallbackSuite) gitlabPushEvent(token, ref string) {
+	event := gitlabEventPush
+	if strings.HasPrefix(ref, "refs/tags/") {
+		event = gitlabEventTagPush
+	}
+
+	suite.deliveries++
+	headers := http.Header{}
+	headers.Set(gitlabHeaderToken, token)
+	headers.Set(gitlabHeaderEvent, event)
+	headers.Set(gitlabHeaderEventUUID, fmt.Sprintf("delivery-%d", suite.deliveries))
+
+	suite.req.Headers = headers
+	suite.req.Data = map[string]interface{}{
+		"object_kind":         "push",
+		fieldNameWebhookRef:   ref,
+		"before":              "95790bf891e76fee5e1747ab589903a6a1f80f22",
+		fieldNameWebhookAfter: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+	}
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestNotConfigured() {
+	suite.signedPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("git webhook is not configured"), resp)
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestConfigure() {
+	read := func() map[string]interface{} {
+		resp, err := suite.backend.HandleRequest(suite.ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "configure/git_webhook",
+			Storage:   suite.storage,
+		})
+		assert.Nil(suite.T(), err)
+		if assert.NotNil(suite.T(), resp) {
+			return resp.Data
+		}
+		return nil
+	}
+
+	assert.Equal(suite.T(), map[string]interface{}{"enabled": false}, read())
+
+	suite.configureWebhook("")
+	assert.Equal(suite.T(), map[string]interface{}{"enabled": true, fieldNameWebhookMode: gitWebhookModeSignature}, read())
+
+	suite.configureWebhook(gitWebhookModeGitlab)
+	assert.Equal(suite.T(), map[string]interface{}{"enabled": true, fieldNameWebhookMode: gitWebhookModeGitlab}, read())
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "configure/git_webhook",
+		Data:      map[string]interface{}{fieldNameWebhookSecret: testGitWebhookSecret, fieldNameWebhookMode: "github"},
+		Storage:   suite.storage,
+	})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("Field %q must be %q or %q", fieldNameWebhookMode, gitWebhookModeSignature, gitWebhookModeGitlab), resp)
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestInvalidSignature() {
+	suite.configure("")
+
+	for name, prepare := range map[string]func(){
+		"wrong secret": func() { suite.signedPushEvent("wrong", "refs/tags/v1.0.0") },
+		"no signature": func() {
+			suite.signedPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+			http.Header(suite.req.Headers).Del(notifications.HeaderSignature)
+		},
+		"tampered payload": func() {
+			suite.signedPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+			suite.req.Data[fieldNameWebhookPayload] = `{"delivery": "delivery-0", "ref": "refs/tags/v1.0.0"}`
+		},
+	} {
+		suite.Run(name, func() {
+			prepare()
+
+			_, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+			assert.Equal(suite.T(), logical.ErrPermissionDenied, err)
+		})
+	}
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestInvalidPayload() {
+	suite.configure("")
+
+	for name, test := range map[string]struct {
+		payload      string
+		expectedResp *logical.Response
+	}{
+		"no payload":  {payload: "", expectedResp: logical.ErrorResponse("Required field %q must be set", fieldNameWebhookPayload)},
+		"no delivery": {payload: `{"ref": "refs/tags/v1.0.0"}`, expectedResp: logical.ErrorResponse("field %q of the payload must be set", "delivery")},
+		"no ref":      {payload: `{"delivery": "delivery-1"}`, expectedResp: logical.ErrorResponse("field %q of the payload must be set", "ref")},
+	} {
+		suite.Run(name, func() {
+			suite.signedPayload(testGitWebhookSecret, test.payload)
+
+			resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+			assert.Nil(suite.T(), err)
+			assert.Equal(suite.T(), test.expectedResp, resp)
+		})
+	}
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestDuplicateDelivery() {
+	suite.configure("")
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", releaseTaskOptions("v1.0.0")).Return("UUID", nil).Once()
+
+	suite.signedPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), resp.IsError())
+
+	// the replayed request is rejected
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("duplicate delivery %q", "delivery-1"), resp)
+
+	// the expired delivery ids are dropped with the next delivery
+	SystemClock = util.NewFixedClock(suite.now.Add(gitWebhookDeliveryTTL + time.Second))
+	suite.signedPushEvent(testGitWebhookSecret, "refs/heads/feature")
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), resp.IsError())
+
+	deliveries, err := getGitWebhookDeliveries(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), deliveries, 1)
+	assert.Contains(suite.T(), deliveries, "delivery-2")
+
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestFailedDeliveryRetry() {
+	suite.configureWebhook("")
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", releaseTaskOptions("v1.0.0")).Return("UUID", nil).Once()
+
+	suite.signedPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+
+	// the failed delivery is not registered
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), errorResponseConfigurationNotFound, resp)
+
+	deliveries, err := getGitWebhookDeliveries(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), deliveries)
+
+	// the retried delivery adds the task
+	assert.Nil(suite.T(), putConfiguration(suite.ctx, suite.storage, completeConfiguration()))
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestReleaseTag() {
+	suite.configure("")
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", releaseTaskOptions("v1.0.0")).Return("UUID", nil)
+
+	suite.signedPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestChannelsBranch() {
+	suite.configure("")
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", publishTaskOptions()).Return("UUID", nil)
+
+	suite.signedPushEvent(testGitWebhookSecret, "refs/heads/"+completeConfiguration().GitTrdlChannelsBranch)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestIgnored() {
+	suite.configure("")
+
+	for name, prepare := range map[string]func(){
+		"not release tag": func() { suite.signedPushEvent(testGitWebhookSecret, "refs/tags/nightly") },
+		"other branch":    func() { suite.signedPushEvent(testGitWebhookSecret, "refs/heads/feature") },
+		"deleted tag": func() {
+			suite.signedPayload(testGitWebhookSecret, `{"delivery": "deleted", "ref": "refs/tags/v1.0.0", "after": "0000000000000000000000000000000000000000"}`)
+		},
+	} {
+		suite.Run(name, func() {
+			prepare()
+
+			resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+			assert.Nil(suite.T(), err)
+			if assert.NotNil(suite.T(), resp) {
+				assert.False(suite.T(), resp.IsError())
+				assert.Contains(suite.T(), resp.Data, "ignored")
+			}
+		})
+	}
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestGitlabInvalidToken() {
+	suite.configure(gitWebhookModeGitlab)
+
+	for _, token := range []string{"", "wrong"} {
+		suite.gitlabPushEvent(token, "refs/tags/v1.0.0")
+
+		_, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+		assert.Equal(suite.T(), logical.ErrPermissionDenied, err)
+	}
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestGitlabMissingDeliveryID() {
+	suite.configure(gitWebhookModeGitlab)
+
+	suite.gitlabPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+	http.Header(suite.req.Headers).Del(gitlabHeaderEventUUID)
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("header %q must be set", gitlabHeaderEventUUID), resp)
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestGitlabReleaseTag() {
+	suite.configure(gitWebhookModeGitlab)
+
+	suite.mockedPublisher.On("GetRepository").Return(nil)
+	suite.mockedTasksManager.On("AddTask", releaseTaskOptions("v1.0.0")).Return("UUID", nil).Once()
+
+	suite.gitlabPushEvent(testGitWebhookSecret, "refs/tags/v1.0.0")
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{"task_uuid": "UUID"}, resp.Data)
+	}
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("duplicate delivery %q", "delivery-1"), resp)
+
+	suite.mockedPublisher.AssertExpectations(suite.T())
+	suite.mockedTasksManager.AssertExpectations(suite.T())
+}
+
+func (suite *PathGitWebhookCallbackSuite) TestGitlabNotPushEvent() {
+	suite.configure(gitWebhookModeGitlab)
+
+	suite.gitlabPushEvent(testGitWebhookSecret, "")
+	http.Header(suite.req.Headers).Set(gitlabHeaderEvent, "Merge Request Hook")
+	suite.req.Data = map[string]interface{}{"object_kind": "merge_request"}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.False(suite.T(), resp.IsError())
+		assert.Contains(suite.T(), resp.Data, "ignored")
+	}
+
+	// the deliberately ignored delivery is registered
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("duplicate delivery %q", "delivery-1"), resp)
+
+	suite.mockedTasksManager.AssertNotCalled(suite.T(), "AddTask")
+}
+
+func TestBackendPathGitWebhookCallback(t *testing.T) {
+	suite.Run(t, new(PathGitWebhookCallbackSuite))
+}
@@ -31,6 +31,11 @@ const (
 )
 
 func (b *Backend) Periodic(ctx context.Context, req *logical.Request) error {
+	// the trdl channels branch is polled with its own interval, the failure does not prevent the periodic task
+	if err := b.autoPublish(ctx, req.Storage); err != nil {
+		b.Logger().Error(fmt.Sprintf("Auto publish failed: %s", err))
+	}
+
 	entry, err := req.Storage.Get(ctx, lastPeriodicRunTimestampKey)
 	if err != nil {
 		return fmt.Errorf("unable to get key %q from storage: %w", lastPeriodicRunTimestampKey, err)
@@ -71,18 +76,31 @@ func (b *Backend) Periodic(ctx context.Context, req *logical.Request) error {
 	}
 
 	now := SystemClock.Now()
-	uuid, err := b.TasksManager.RunTask(ctx, req.Storage, func(ctx context.Context, storage logical.Storage) error {
-		err := b.periodicTask(ctx, storage, config, publisherRepository)
+	uuid, err := b.TasksManager.RunTask(ctx, req.Storage, tasks_manager.TaskOptions{Type: taskTypePeriodic}, func(ctx context.Context, storage logical.Storage) error {
+		err := tasks_manager.WithResourceLock(ctx, tasks_manager.LockTufRepoCommit, func() error {
+			if err := b.periodicTask(ctx, storage, config, publisherRepository); err != nil {
+				return err
+			}
+
+			b.setTaskTufVersions(ctx, publisherRepository)
+			b.saveMirrorReplications(ctx, storage, publisherRepository)
+			return nil
+		})
 		if err != nil {
 			b.Logger().Error(fmt.Sprintf("Periodic task failed: %s", err))
 		} else {
 			b.Logger().Info("Periodic task succeeded")
 		}
+
+		if err := putPeriodicTaskResult(ctx, storage, err); err != nil {
+			b.Logger().Error(fmt.Sprintf("Unable to save periodic task result: %s", err))
+		}
+
 		return err
 	})
 
 	if err == tasks_manager.ErrBusy {
-		b.Logger().Debug(fmt.Sprintf("Will not add new periodic task: there is currently running task which took more than %s", periodicRunPeriod))
+		b.Logger().Debug(fmt.Sprintf("Will not add new periodic task: all task workers are busy for more than %s", periodicRunPeriod))
 		return nil
 	}
 
@@ -103,16 +121,53 @@ func (b *Backend) periodicTask(ctx context.Context, storage logical.Storage, _ *
 	logboek.Context(ctx).Default().LogF("Started TUF repository keys rotation\n")
 	b.Logger().Debug("Started TUF repository keys rotation")
 
+	finishStage := tasks_manager.StartTaskStage(ctx, taskStageRotateKeys)
+
 	if err := b.Publisher.RotateRepositoryKeys(ctx, storage, publisherRepository, SystemClock); err != nil {
 		return fmt.Errorf("unable to rotate TUF repository private keys: %w", err)
 	}
+	finishStage()
 
 	logboek.Context(ctx).Default().LogF("Started TUF repository timestamps update\n")
 	b.Logger().Debug("Started TUF repository timestamps update")
 
+	finishStage = tasks_manager.StartTaskStage(ctx, taskStageUpdateTimestamps)
+
 	if err := b.Publisher.UpdateTimestamps(ctx, storage, publisherRepository, SystemClock); err != nil {
 		return fmt.Errorf("unable to update TUF repository timestamps: %w", err)
 	}
+	finishStage()
+
+	logboek.Context(ctx).Default().LogF("Started PGP signing key rotation\n")
+	b.Logger().Debug("Started PGP signing key rotation")
+
+	finishStage = tasks_manager.StartTaskStage(ctx, taskStageRotatePGPKey)
+
+	if err := b.Publisher.RotatePGPSigningKey(ctx, storage, publisherRepository, SystemClock); err != nil {
+		return fmt.Errorf("unable to rotate PGP signing key: %w", err)
+	}
+	finishStage()
 
 	return nil
 }
+
+// putPeriodicTaskResult saves the outcome of the periodic task for the status endpoint.
+func putPeriodicTaskResult(ctx context.Context, storage logical.Storage, taskErr error) error {
+	result := &periodicTaskResult{}
+	if _, err := getStatusEntry(ctx, storage, storageKeyPeriodicTaskResult, result); err != nil {
+		return err
+	}
+
+	now := SystemClock.Now()
+	result.LastRun = now
+	if taskErr != nil {
+		result.Status = "FAILED"
+		result.Error = taskErr.Error()
+	} else {
+		result.Status = "SUCCEEDED"
+		result.Error = ""
+		result.LastSucceeded = &now
+	}
+
+	return putStatusEntry(ctx, storage, storageKeyPeriodicTaskResult, result)
+}
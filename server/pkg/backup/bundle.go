@@ -0,0 +1,241 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// BundleSchemaVersion is the version of the bundle format, the bundle of the newer version is not restored.
+const BundleSchemaVersion = 1
+
+var (
+	ErrNoEncryptionKey = errors.New("passphrase or recipient public keys required")
+	ErrDecryption      = errors.New("unable to decrypt bundle: wrong passphrase or private key")
+)
+
+var encryptionConfig = &packet.Config{
+	DefaultCipher:          packet.CipherAES256,
+	DefaultCompressionAlgo: packet.CompressionZLIB,
+}
+
+// Bundle is the plugin storage snapshot.
+type Bundle struct {
+	SchemaVersion int       `json:"schema_version"`
+	Created       time.Time `json:"created"`
+	Entries       []Entry   `json:"entries"`
+}
+
+type Entry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Export collects all storage entries except the ones under the excluded prefixes.
+func Export(ctx context.Context, storage logical.Storage, excludePrefixes []string) (*Bundle, error) {
+	keys, err := logical.CollectKeys(ctx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list storage keys: %w", err)
+	}
+
+	bundle := &Bundle{SchemaVersion: BundleSchemaVersion, Created: time.Now().UTC(), Entries: []Entry{}}
+	for _, key := range keys {
+		if hasPrefix(key, excludePrefixes) {
+			continue
+		}
+
+		e, err := storage.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get %q from storage: %w", key, err)
+		}
+		if e == nil {
+			continue
+		}
+
+		bundle.Entries = append(bundle.Entries, Entry{Key: e.Key, Value: e.Value})
+	}
+
+	return bundle, nil
+}
+
+// Restore puts the bundle entries into the storage, the other entries are kept.
+func Restore(ctx context.Context, storage logical.Storage, bundle *Bundle) error {
+	if bundle.SchemaVersion > BundleSchemaVersion {
+		return fmt.Errorf("bundle schema version %d is not supported: the bundle is created by the newer plugin version", bundle.SchemaVersion)
+	}
+
+	for _, entry := range bundle.Entries {
+		if err := storage.Put(ctx, &logical.StorageEntry{Key: entry.Key, Value: entry.Value}); err != nil {
+			return fmt.Errorf("unable to put %q into storage: %w", entry.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// Replace puts the bundle entries into the storage and only then deletes the other entries,
+// so that the failed replacing keeps the current entries missing in the bundle.
+func Replace(ctx context.Context, storage logical.Storage, bundle *Bundle) error {
+	if err := Restore(ctx, storage, bundle); err != nil {
+		return err
+	}
+
+	keys, err := logical.CollectKeys(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to list storage keys: %w", err)
+	}
+
+	bundleKeys := map[string]bool{}
+	for _, entry := range bundle.Entries {
+		bundleKeys[entry.Key] = true
+	}
+
+	for _, key := range keys {
+		if bundleKeys[key] {
+			continue
+		}
+
+		if err := storage.Delete(ctx, key); err != nil {
+			return fmt.Errorf("unable to delete %q from storage: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// EncryptOptions require either the passphrase or the armored recipient public keys.
+type EncryptOptions struct {
+	Passphrase          string
+	RecipientPublicKeys []string
+}
+
+// Encrypt returns the bundle as the armored OpenPGP message.
+func Encrypt(bundle *Bundle, opts EncryptOptions) (string, error) {
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal bundle: %w", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	armorWriter, err := armor.Encode(buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var plaintextWriter io.WriteCloser
+	switch {
+	case len(opts.RecipientPublicKeys) > 0:
+		var recipients openpgp.EntityList
+		for _, publicKey := range opts.RecipientPublicKeys {
+			entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+			if err != nil {
+				return "", fmt.Errorf("unable to read recipient public key: %w", err)
+			}
+			recipients = append(recipients, entities...)
+		}
+
+		plaintextWriter, err = openpgp.Encrypt(armorWriter, recipients, nil, &openpgp.FileHints{IsBinary: true}, encryptionConfig)
+	case opts.Passphrase != "":
+		plaintextWriter, err = openpgp.SymmetricallyEncrypt(armorWriter, []byte(opts.Passphrase), &openpgp.FileHints{IsBinary: true}, encryptionConfig)
+	default:
+		return "", ErrNoEncryptionKey
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to encrypt bundle: %w", err)
+	}
+
+	if _, err := plaintextWriter.Write(data); err != nil {
+		return "", fmt.Errorf("unable to encrypt bundle: %w", err)
+	}
+	if err := plaintextWriter.Close(); err != nil {
+		return "", fmt.Errorf("unable to encrypt bundle: %w", err)
+	}
+	if err := armorWriter.Close(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// DecryptOptions are the passphrase of the symmetrically encrypted bundle or the armored recipient private key.
+// The passphrase unlocks the private key if the key is encrypted.
+type DecryptOptions struct {
+	Passphrase string
+	PrivateKey string
+}
+
+func Decrypt(armored string, opts DecryptOptions) (*Bundle, error) {
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode armored bundle: %w", err)
+	}
+
+	var keyRing openpgp.EntityList
+	if opts.PrivateKey != "" {
+		keyRing, err = openpgp.ReadArmoredKeyRing(strings.NewReader(opts.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read private key: %w", err)
+		}
+	}
+
+	// the prompt is called again on failure, so the passphrase is tried once
+	prompted := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if prompted || opts.Passphrase == "" {
+			return nil, ErrDecryption
+		}
+		prompted = true
+
+		if symmetric {
+			return []byte(opts.Passphrase), nil
+		}
+
+		for _, key := range keys {
+			if key.PrivateKey != nil && key.PrivateKey.Encrypted {
+				_ = key.PrivateKey.Decrypt([]byte(opts.Passphrase))
+			}
+		}
+
+		return nil, nil
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, keyRing, prompt, encryptionConfig)
+	if err != nil {
+		if err == ErrDecryption {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to decrypt bundle: %w", err)
+	}
+
+	data, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt bundle: %w", err)
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal bundle: %w", err)
+	}
+
+	return &bundle, nil
+}
+
+func hasPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
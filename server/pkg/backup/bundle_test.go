@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type bundleSuite struct {
+	suite.Suite
+	ctx     context.Context
+	storage logical.Storage
+}
+
+func (suite *bundleSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.storage = &logical.InmemStorage{}
+
+	for key, value := range map[string]string{
+		"configuration":            "cfg",
+		"tuf_repository_keys":      "keys",
+		"tasks/queued/uuid":        "queued",
+		"tasks/completed/uuid":     "completed",
+		"trusted_pgp_public_key/a": "public key",
+	} {
+		err := suite.storage.Put(suite.ctx, &logical.StorageEntry{Key: key, Value: []byte(value)})
+		assert.Nil(suite.T(), err)
+	}
+}
+
+func (suite *bundleSuite) restored(bundle *Bundle) map[string]string {
+	storage := &logical.InmemStorage{}
+	err := Restore(suite.ctx, storage, bundle)
+	assert.Nil(suite.T(), err)
+
+	keys, err := logical.CollectKeys(suite.ctx, storage)
+	assert.Nil(suite.T(), err)
+
+	res := map[string]string{}
+	for _, key := range keys {
+		e, err := storage.Get(suite.ctx, key)
+		assert.Nil(suite.T(), err)
+		res[key] = string(e.Value)
+	}
+
+	return res
+}
+
+func (suite *bundleSuite) TestExport() {
+	bundle, err := Export(suite.ctx, suite.storage, []string{"tasks/queued/", "tasks/completed/"})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), BundleSchemaVersion, bundle.SchemaVersion)
+	assert.Equal(suite.T(), map[string]string{
+		"configuration":            "cfg",
+		"tuf_repository_keys":      "keys",
+		"trusted_pgp_public_key/a": "public key",
+	}, suite.restored(bundle))
+}
+
+func (suite *bundleSuite) TestPassphrase() {
+	bundle, err := Export(suite.ctx, suite.storage, nil)
+	assert.Nil(suite.T(), err)
+
+	_, err = Encrypt(bundle, EncryptOptions{})
+	assert.Equal(suite.T(), ErrNoEncryptionKey, err)
+
+	armored, err := Encrypt(bundle, EncryptOptions{Passphrase: "secret"})
+	assert.Nil(suite.T(), err)
+	assert.NotContains(suite.T(), armored, "cfg")
+
+	_, err = Decrypt(armored, DecryptOptions{Passphrase: "wrong"})
+	assert.Equal(suite.T(), ErrDecryption, err)
+
+	_, err = Decrypt(armored, DecryptOptions{})
+	assert.Equal(suite.T(), ErrDecryption, err)
+
+	decrypted, err := Decrypt(armored, DecryptOptions{Passphrase: "secret"})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), suite.restored(bundle), suite.restored(decrypted))
+}
+
+func (suite *bundleSuite) TestRecipientPublicKeys() {
+	bundle, err := Export(suite.ctx, suite.storage, nil)
+	assert.Nil(suite.T(), err)
+
+	entity, err := openpgp.NewEntity("backup", "", "backup@example.com", nil)
+	assert.Nil(suite.T(), err)
+	otherEntity, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	assert.Nil(suite.T(), err)
+
+	armored, err := Encrypt(bundle, EncryptOptions{RecipientPublicKeys: []string{suite.armoredPublicKey(entity)}})
+	assert.Nil(suite.T(), err)
+
+	_, err = Decrypt(armored, DecryptOptions{PrivateKey: suite.armoredPrivateKey(otherEntity)})
+	assert.NotNil(suite.T(), err)
+
+	decrypted, err := Decrypt(armored, DecryptOptions{PrivateKey: suite.armoredPrivateKey(entity)})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), suite.restored(bundle), suite.restored(decrypted))
+}
+
+func (suite *bundleSuite) TestRestore_NewerSchemaVersion() {
+	err := Restore(suite.ctx, &logical.InmemStorage{}, &Bundle{SchemaVersion: BundleSchemaVersion + 1})
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *bundleSuite) TestReplace() {
+	err := Replace(suite.ctx, suite.storage, &Bundle{
+		SchemaVersion: BundleSchemaVersion,
+		Entries: []Entry{
+			{Key: "configuration", Value: []byte("restored cfg")},
+			{Key: "trusted_pgp_public_key/b", Value: []byte("restored public key")},
+		},
+	})
+	assert.Nil(suite.T(), err)
+
+	keys, err := logical.CollectKeys(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.ElementsMatch(suite.T(), []string{"configuration", "trusted_pgp_public_key/b"}, keys)
+
+	e, err := suite.storage.Get(suite.ctx, "configuration")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "restored cfg", string(e.Value))
+}
+
+func (suite *bundleSuite) TestReplace_NewerSchemaVersion() {
+	err := Replace(suite.ctx, suite.storage, &Bundle{SchemaVersion: BundleSchemaVersion + 1})
+	assert.NotNil(suite.T(), err)
+
+	// the current entries are kept
+	keys, err := logical.CollectKeys(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), keys, 5)
+}
+
+func (suite *bundleSuite) armoredPublicKey(entity *openpgp.Entity) string {
+	buf := bytes.NewBuffer(nil)
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), entity.Serialize(w))
+	assert.Nil(suite.T(), w.Close())
+
+	return buf.String()
+}
+
+func (suite *bundleSuite) armoredPrivateKey(entity *openpgp.Entity) string {
+	buf := bytes.NewBuffer(nil)
+	w, err := armor.Encode(buf, openpgp.PrivateKeyType, nil)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), entity.SerializePrivate(w, nil))
+	assert.Nil(suite.T(), w.Close())
+
+	return buf.String()
+}
+
+func TestBundle(t *testing.T) {
+	suite.Run(t, new(bundleSuite))
+}
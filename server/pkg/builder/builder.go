@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/djherbis/nio/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/trdl/server/pkg/docker"
+)
+
+const (
+	BackendDocker  = "docker"
+	BackendPodman  = "podman"
+	BackendBuildah = "buildah"
+	BackendLocal   = "local"
+
+	DefaultBackend = BackendDocker
+
+	// ArtifactsDir is the directory the build commands must save release artifacts into.
+	// Every builder writes the artifacts into the resulting tar stream under this directory.
+	ArtifactsDir = docker.ContainerArtifactsDir
+
+	// EnvNameArtifactsDir is the environment variable with the absolute path to ArtifactsDir.
+	// It allows writing build commands which do not depend on the builder backend.
+	EnvNameArtifactsDir = "TRDL_RESULT_DIR"
+
+	defaultTimeOut = 30
+)
+
+var Backends = []string{BackendDocker, BackendPodman, BackendBuildah, BackendLocal}
+
+type Builder interface {
+	// BuildReleaseArtifacts runs the build commands against the git worktree
+	// and writes the tar stream with the ArtifactsDir into the opts.TarWriter.
+	BuildReleaseArtifacts(ctx context.Context, opts BuildReleaseArtifactsOpts, logger hclog.Logger) error
+}
+
+type BuildReleaseArtifactsOpts struct {
+	FromImage   string
+	RunCommands []string
+	GitRepo     *git.Repository
+	TarWriter   *nio.PipeWriter
+	Storage     logical.Storage
+}
+
+func NewBuilder(backend string) (Builder, error) {
+	switch backend {
+	case "", BackendDocker:
+		return NewBuildxBuilder(), nil
+	case BackendPodman, BackendBuildah:
+		return NewContainerCLIBuilder(backend), nil
+	case BackendLocal:
+		return NewLocalBuilder(), nil
+	default:
+		return nil, NewErrUnknownBackend(backend)
+	}
+}
+
+func ValidateBackend(backend string) error {
+	_, err := NewBuilder(backend)
+	return err
+}
+
+func NewErrUnknownBackend(backend string) error {
+	return fmt.Errorf("unknown builder backend %q: expected %q, %q, %q or %q", backend, BackendDocker, BackendPodman, BackendBuildah, BackendLocal)
+}
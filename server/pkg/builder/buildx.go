@@ -0,0 +1,133 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/djherbis/nio/v3"
+	"github.com/hashicorp/go-hclog"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+// BuildxBuilder builds release artifacts with a one-time docker buildx builder.
+type BuildxBuilder struct{}
+
+func NewBuildxBuilder() *BuildxBuilder {
+	return &BuildxBuilder{}
+}
+
+func (b *BuildxBuilder) BuildReleaseArtifacts(ctx context.Context, opts BuildReleaseArtifactsOpts, logger hclog.Logger) error {
+	buildId := uuid.NewV4().String()
+	serviceLabels := map[string]string{
+		"vault-trdl-release-uuid": buildId,
+	}
+
+	secrets, err := secrets.GetSecrets(ctx, opts.Storage)
+	if err != nil {
+		return fmt.Errorf("unable to get build secrets: %w", err)
+	}
+
+	contextReader := runDockerfileContextWriter(ctx, opts, serviceLabels, secrets, logger)
+
+	logboek.Context(ctx).Default().LogLn("Building docker image with artifacts")
+	logger.Info("Building docker image with artifacts")
+
+	builder, err := newBuildxCLI(ctx, &newBuildxCLIOpts{
+		BuildId:     buildId,
+		ContextPath: serviceDockerfilePathInContext,
+		Secrets:     secrets,
+		Logger:      logger,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create docker builder: %w", err)
+	}
+
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), defaultTimeOut*time.Second)
+		defer cancel()
+
+		if err := builder.Remove(cleanupCtx); err != nil {
+			errStr := fmt.Sprintf("Unable to remove builder `%s`: %s", builder.builderName, err.Error())
+			logboek.Context(ctx).Default().LogLn(errStr)
+			logger.Info(errStr)
+		}
+	}()
+
+	if err := builder.Build(ctx, contextReader, opts.TarWriter); err != nil {
+		return fmt.Errorf("can't build artifacts: %w", err)
+	}
+
+	logboek.Context(ctx).Default().LogLn("Build is successful")
+	logger.Info("Build is successful")
+
+	return nil
+}
+
+type buildxCLI struct {
+	builderName string
+	buildArgs   []string
+	buildEnv    []string
+	logger      Logger
+}
+
+type newBuildxCLIOpts struct {
+	BuildId     string
+	ContextPath string
+	Secrets     []secrets.Secret
+	Logger      Logger
+}
+
+func newBuildxCLI(ctx context.Context, opts *newBuildxCLIOpts) (*buildxCLI, error) {
+	builderName := fmt.Sprintf("trdl-builder-%s", opts.BuildId)
+	builderArgs := []string{
+		"buildx",
+		"create",
+		"--name", builderName,
+		"--driver=docker-container",
+	}
+
+	if err := runCmd(ctx, "docker", builderArgs); err != nil {
+		return nil, fmt.Errorf("builder setup failed: %w", err)
+	}
+
+	args, env := setBuildxCliArgs(builderName, opts.ContextPath, opts.Secrets)
+
+	return &buildxCLI{
+		builderName: builderName,
+		buildArgs:   args,
+		buildEnv:    env,
+		logger:      opts.Logger,
+	}, nil
+}
+
+func (b *buildxCLI) Build(ctx context.Context, contextReader *nio.PipeReader, tarWriter *nio.PipeWriter) error {
+	finalArgs := append([]string{"buildx", "build"}, b.buildArgs...)
+	return runBuildCmd(ctx, "docker", finalArgs, "", b.buildEnv, contextReader, tarWriter, b.logger)
+}
+
+func (b *buildxCLI) Remove(ctx context.Context) error {
+	if err := runCmd(ctx, "docker", []string{"buildx", "rm", b.builderName}); err != nil {
+		return fmt.Errorf("unable to cleanup: %w", err)
+	}
+
+	return nil
+}
+
+func setBuildxCliArgs(builder, serviceDockerfilePathInContext string, secrets []secrets.Secret) ([]string, []string) {
+	args := []string{
+		"--file", serviceDockerfilePathInContext,
+		"--pull",
+		"--no-cache",
+		"--builder", builder,
+	}
+
+	secretArgs, env := secretsCLIArgsAndEnv(secrets)
+	args = append(args, secretArgs...)
+
+	args = append(args, "-o", "-", "-")
+	return args, env
+}
@@ -0,0 +1,107 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/werf/logboek"
+)
+
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// runBuildCmd runs the build command and streams its stderr into the task log and the plugin log.
+// The tar writer is closed on success, on failure the caller is responsible for closing it with an error.
+func runBuildCmd(ctx context.Context, name string, args []string, dir string, env []string, stdin io.Reader, tarWriter io.WriteCloser, logger Logger) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = tarWriter
+	cmd.Stdin = stdin
+
+	logOut, closeLogOut := logWriter(logger)
+	defer closeLogOut()
+
+	cmd.Stderr = io.MultiWriter(logboek.Context(ctx).OutStream(), logOut)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("build failed: %w", err)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("unable to close tar writer: %w", err)
+	}
+
+	return nil
+}
+
+// logWriter streams the written lines into the logger.
+// The returned close function must be called on every return path: it closes the pipe and waits until the lines are logged.
+func logWriter(logger Logger) (io.Writer, func()) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			logger.Info(line)
+		}
+		if err := scanner.Err(); err != nil {
+			logger.Error("error reading stderr", "err", err)
+		}
+
+		// the rest of the output is dropped not to block the command on the failed scanner
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+
+	return pw, func() {
+		_ = pw.Close()
+		<-done
+	}
+}
+
+func runCmd(ctx context.Context, name string, args []string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s command failed: %s %w", name, stderr.String(), err)
+	}
+
+	return nil
+}
+
+func runCmdOutput(ctx context.Context, name string, args []string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s command failed: %s %w", name, stderr.String(), err)
+	}
+
+	return stdout.String(), nil
+}
+
+func runLocalCmd(ctx context.Context, shell, command, dir string, env []string, output io.Writer) error {
+	cmd := exec.CommandContext(ctx, shell, "-c", command)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	return cmd.Run()
+}
@@ -0,0 +1,109 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+// ContainerCLIBuilder builds release artifacts with a daemonless docker-compatible CLI (podman or buildah).
+type ContainerCLIBuilder struct {
+	Binary string
+}
+
+func NewContainerCLIBuilder(binary string) *ContainerCLIBuilder {
+	return &ContainerCLIBuilder{Binary: binary}
+}
+
+func (b *ContainerCLIBuilder) BuildReleaseArtifacts(ctx context.Context, opts BuildReleaseArtifactsOpts, logger hclog.Logger) error {
+	buildId := uuid.NewV4().String()
+	serviceLabelName := "vault-trdl-release-uuid"
+	serviceLabels := map[string]string{
+		serviceLabelName: buildId,
+	}
+
+	secrets, err := secrets.GetSecrets(ctx, opts.Storage)
+	if err != nil {
+		return fmt.Errorf("unable to get build secrets: %w", err)
+	}
+
+	// unlike docker buildx, podman and buildah cannot read the build context from stdin
+	contextDir, err := os.MkdirTemp("", "trdl-build-context-")
+	if err != nil {
+		return fmt.Errorf("unable to create build context dir: %w", err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	contextReader := runDockerfileContextWriter(ctx, opts, serviceLabels, secrets, logger)
+	if err := extractTar(contextReader, contextDir); err != nil {
+		return fmt.Errorf("unable to prepare build context: %w", err)
+	}
+
+	logboek.Context(ctx).Default().LogF("Building image with artifacts using %s\n", b.Binary)
+	logger.Info(fmt.Sprintf("Building image with artifacts using %s", b.Binary))
+
+	defer func() {
+		if err := b.removeImages(fmt.Sprintf("%s=%s", serviceLabelName, buildId)); err != nil {
+			errStr := fmt.Sprintf("Unable to remove images of build `%s`: %s", buildId, err.Error())
+			logboek.Context(ctx).Default().LogLn(errStr)
+			logger.Info(errStr)
+		}
+	}()
+
+	args, env := b.buildArgsAndEnv(contextDir, secrets)
+	if err := runBuildCmd(ctx, b.Binary, args, "", env, nil, opts.TarWriter, logger); err != nil {
+		return fmt.Errorf("can't build artifacts: %w", err)
+	}
+
+	logboek.Context(ctx).Default().LogLn("Build is successful")
+	logger.Info("Build is successful")
+
+	return nil
+}
+
+// buildArgsAndEnv returns the build command arguments which write the tar stream with the artifacts into stdout.
+func (b *ContainerCLIBuilder) buildArgsAndEnv(contextDir string, buildSecrets []secrets.Secret) ([]string, []string) {
+	args := []string{
+		"build",
+		"--file", filepath.Join(contextDir, serviceDockerfilePathInContext),
+		"--pull=always",
+		"--no-cache",
+		"--output", "type=tar,dest=-",
+	}
+
+	secretArgs, env := secretsCLIArgsAndEnv(buildSecrets)
+	args = append(args, secretArgs...)
+	args = append(args, contextDir)
+
+	return args, env
+}
+
+func (b *ContainerCLIBuilder) removeImages(labelFilter string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeOut*time.Second)
+	defer cancel()
+
+	out, err := runCmdOutput(ctx, b.Binary, []string{"images", "--quiet", "--filter", "label=" + labelFilter})
+	if err != nil {
+		return fmt.Errorf("unable to list images: %w", err)
+	}
+
+	ids := strings.Fields(out)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := runCmd(ctx, b.Binary, append([]string{"rmi", "--force"}, ids...)); err != nil {
+		return fmt.Errorf("unable to remove images: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+func TestNewBuilder(t *testing.T) {
+	for _, backend := range []string{BackendPodman, BackendBuildah} {
+		b, err := NewBuilder(backend)
+		if assert.NoError(t, err) {
+			assert.Equal(t, &ContainerCLIBuilder{Binary: backend}, b)
+		}
+	}
+
+	b, err := NewBuilder("")
+	if assert.NoError(t, err) {
+		assert.IsType(t, &BuildxBuilder{}, b)
+	}
+
+	b, err = NewBuilder(BackendLocal)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &LocalBuilder{}, b)
+	}
+
+	_, err = NewBuilder("kaniko")
+	assert.EqualError(t, err, NewErrUnknownBackend("kaniko").Error())
+}
+
+func TestContainerCLIBuilder_BuildArgsAndEnv(t *testing.T) {
+	args, env := NewContainerCLIBuilder(BackendPodman).buildArgsAndEnv("/tmp/context", []secrets.Secret{{Id: "token", Data: []byte("data")}})
+
+	assert.Equal(t, []string{
+		"build",
+		"--file", "/tmp/context/.trdl/Dockerfile",
+		"--pull=always",
+		"--no-cache",
+		"--output", "type=tar,dest=-",
+		"--secret", "id=token,env=TRDL_BUILD_SECRET_token",
+		"/tmp/context",
+	}, args)
+	assert.Contains(t, env, "TRDL_BUILD_SECRET_token=data")
+}
+
+func TestContainerCLIBuilder_BuildReleaseArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+
+	// the fake CLI writes the tar stream with the artifact the same way as the build --output type=tar,dest=-
+	binary := writeFakeContainerCLI(t, dir, `
+case "$1" in
+build)
+	echo "$@" > `+argsFile+`
+	context_dir=$(eval echo \${$#})
+	test -f "$context_dir/.trdl/Dockerfile" || exit 1
+	mkdir -p `+dir+`/out/result/linux-amd64 && cp "$context_dir/main.go" `+dir+`/out/result/linux-amd64/app
+	tar -cf - -C `+dir+`/out result/linux-amd64/app
+	;;
+esac
+`)
+
+	gitRepo := initTestGitRepo(t, map[string]string{"main.go": "package main"})
+	artifacts, err := buildWithBuilder(NewContainerCLIBuilder(binary), gitRepo, &logical.InmemStorage{}, []string{"go build"}, hclog.NewNullLogger())
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"result/linux-amd64/app": "package main"}, artifacts)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if assert.NoError(t, err) {
+		assert.True(t, strings.HasPrefix(string(args), "build --file "), string(args))
+		assert.Contains(t, string(args), "--output type=tar,dest=-")
+	}
+}
+
+func TestContainerCLIBuilder_BuildReleaseArtifactsFailed(t *testing.T) {
+	binary := writeFakeContainerCLI(t, t.TempDir(), `
+if [ "$1" = build ]; then
+	echo "step 1 failed" >&2
+	exit 3
+fi
+`)
+
+	// the build output is logged completely before the build returns
+	logs := bytes.NewBuffer(nil)
+	logger := hclog.New(&hclog.LoggerOptions{Output: logs})
+
+	gitRepo := initTestGitRepo(t, map[string]string{"main.go": "package main"})
+	_, err := buildWithBuilder(NewContainerCLIBuilder(binary), gitRepo, &logical.InmemStorage{}, []string{"go build"}, logger)
+	assert.ErrorContains(t, err, "exit status 3")
+	assert.Contains(t, logs.String(), "step 1 failed")
+}
+
+func writeFakeContainerCLI(t *testing.T, dir, script string) string {
+	binary := filepath.Join(dir, "podman")
+	assert.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\n"+script), 0o755))
+	return binary
+}
@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/djherbis/buffer"
+	"github.com/djherbis/nio/v3"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/docker"
+	trdlGit "github.com/werf/trdl/server/pkg/git"
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+var (
+	serviceDirInContext            = ".trdl"
+	serviceDockerfilePathInContext = path.Join(serviceDirInContext, "Dockerfile")
+)
+
+// runDockerfileContextWriter writes the build context with the git worktree files and the service Dockerfile in the background.
+func runDockerfileContextWriter(ctx context.Context, opts BuildReleaseArtifactsOpts, labels map[string]string, secrets []secrets.Secret, logger hclog.Logger) *nio.PipeReader {
+	contextBuf := buffer.New(64 * 1024 * 1024)
+	contextReader, contextWriter := nio.Pipe(contextBuf)
+
+	go func() {
+		if err := func() error {
+			tw := tar.NewWriter(contextWriter)
+
+			logboek.Context(ctx).Default().LogF("Adding git worktree files to the build context\n")
+			logger.Debug("Adding git worktree files to the build context")
+
+			if err := trdlGit.AddWorktreeFilesToTar(tw, opts.GitRepo); err != nil {
+				return fmt.Errorf("unable to add git worktree files to tar: %w", err)
+			}
+
+			dockerfileOpts := docker.DockerfileOpts{
+				EnvVars: map[string]string{EnvNameArtifactsDir: "/" + ArtifactsDir},
+				Labels:  labels,
+				Secrets: secrets,
+			}
+			if err := docker.GenerateAndAddDockerfileToTar(tw, serviceDockerfilePathInContext, opts.FromImage, opts.RunCommands, dockerfileOpts); err != nil {
+				return fmt.Errorf("unable to add service dockerfile to tar: %w", err)
+			}
+
+			if err := tw.Close(); err != nil {
+				return fmt.Errorf("unable to close tar writer: %w", err)
+			}
+
+			return nil
+		}(); err != nil {
+			if closeErr := contextWriter.CloseWithError(err); closeErr != nil {
+				panic(closeErr)
+			}
+			return
+		}
+
+		if err := contextWriter.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	return contextReader
+}
@@ -0,0 +1,186 @@
+package builder
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/werf/logboek"
+	"github.com/werf/trdl/server/pkg/docker"
+	trdlGit "github.com/werf/trdl/server/pkg/git"
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+// EnvNameSecretsDir is the environment variable with the path to the directory with build secret files.
+// It is set only by the local builder, container builders mount secrets into /run/secrets.
+const EnvNameSecretsDir = "TRDL_SECRETS_DIR"
+
+// LocalBuilder runs the build commands as a local process in a temporary checkout of the git worktree.
+// The docker image is ignored, so the host must provide all the tools the build commands require.
+// The artifacts must be saved into the directory from the TRDL_RESULT_DIR environment variable.
+type LocalBuilder struct {
+	Shell string
+}
+
+func NewLocalBuilder() *LocalBuilder {
+	return &LocalBuilder{Shell: "sh"}
+}
+
+func (b *LocalBuilder) BuildReleaseArtifacts(ctx context.Context, opts BuildReleaseArtifactsOpts, logger hclog.Logger) error {
+	if err := b.buildReleaseArtifacts(ctx, opts, logger); err != nil {
+		return err
+	}
+
+	if err := opts.TarWriter.Close(); err != nil {
+		return fmt.Errorf("unable to close tar writer: %w", err)
+	}
+
+	return nil
+}
+
+func (b *LocalBuilder) buildReleaseArtifacts(ctx context.Context, opts BuildReleaseArtifactsOpts, logger hclog.Logger) error {
+	workDir, err := os.MkdirTemp("", "trdl-local-build-")
+	if err != nil {
+		return fmt.Errorf("unable to create build dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	sourceDir := filepath.Join(workDir, docker.ContainerSourceDir)
+	artifactsDir := filepath.Join(workDir, ArtifactsDir)
+
+	for _, dir := range []string{sourceDir, artifactsDir} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("unable to create dir %q: %w", dir, err)
+		}
+	}
+
+	logboek.Context(ctx).Default().LogF("Checking out git worktree files into the build dir\n")
+	logger.Debug("Checking out git worktree files into the build dir")
+
+	if err := checkoutWorktree(opts, sourceDir); err != nil {
+		return fmt.Errorf("unable to checkout git worktree: %w", err)
+	}
+
+	buildSecrets, err := secrets.GetSecrets(ctx, opts.Storage)
+	if err != nil {
+		return fmt.Errorf("unable to get build secrets: %w", err)
+	}
+
+	logboek.Context(ctx).Default().LogLn("Running build commands locally")
+	logger.Info("Running build commands locally")
+
+	// the commands are run the same way as the RUN instructions of the container builders
+	if err := b.runCommands(ctx, workDir, sourceDir, artifactsDir, opts.RunCommands, buildSecrets, logger); err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(opts.TarWriter)
+	if err := addDirToTar(tw, artifactsDir, ArtifactsDir); err != nil {
+		return fmt.Errorf("unable to add artifacts to tar: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to close tar writer: %w", err)
+	}
+
+	logboek.Context(ctx).Default().LogLn("Build is successful")
+	logger.Info("Build is successful")
+
+	return nil
+}
+
+func checkoutWorktree(opts BuildReleaseArtifactsOpts, dir string) error {
+	return trdlGit.ForEachWorktreeFile(opts.GitRepo, func(path, link string, fileReader io.Reader, info os.FileInfo) error {
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			return fmt.Errorf("unexpected worktree file path %q", path)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+
+		if link != "" {
+			return os.Symlink(link, target)
+		}
+
+		return writeFile(target, fileReader, info.Mode().Perm())
+	})
+}
+
+// runCommands runs the commands of each step group in a separate shell, the same way as the RUN instructions of the container builders.
+// The secrets scoped to the commands of the group are delivered only for the group shell.
+func (b *LocalBuilder) runCommands(ctx context.Context, workDir, sourceDir, artifactsDir string, commands []string, buildSecrets []secrets.Secret, logger hclog.Logger) error {
+	secretsDir := filepath.Join(workDir, "secrets")
+	if err := os.MkdirAll(secretsDir, 0o700); err != nil {
+		return fmt.Errorf("unable to create dir %q: %w", secretsDir, err)
+	}
+	defer os.RemoveAll(secretsDir)
+
+	env := append(os.Environ(),
+		fmt.Sprintf("%s=%s", EnvNameArtifactsDir, artifactsDir),
+		fmt.Sprintf("%s=%s", EnvNameSecretsDir, secretsDir),
+	)
+
+	var unscopedSecrets []secrets.Secret
+	for _, s := range buildSecrets {
+		if !s.IsScoped() {
+			unscopedSecrets = append(unscopedSecrets, s)
+		}
+	}
+
+	env, err := deliverSecrets(secretsDir, env, unscopedSecrets)
+	if err != nil {
+		return err
+	}
+
+	logOut, closeLogOut := logWriter(logger)
+	defer closeLogOut()
+
+	output := io.MultiWriter(logboek.Context(ctx).OutStream(), logOut)
+	for _, group := range docker.GroupSteps(commands, buildSecrets) {
+		if err := b.runStepGroup(ctx, group, sourceDir, secretsDir, env, output); err != nil {
+			return fmt.Errorf("can't build artifacts: build failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (b *LocalBuilder) runStepGroup(ctx context.Context, group docker.StepGroup, sourceDir, secretsDir string, env []string, output io.Writer) error {
+	for _, s := range group.ScopedSecrets {
+		if s.AsFile() {
+			defer os.Remove(filepath.Join(secretsDir, s.Id))
+		}
+	}
+
+	groupEnv, err := deliverSecrets(secretsDir, append([]string{}, env...), group.ScopedSecrets)
+	if err != nil {
+		return err
+	}
+
+	return runLocalCmd(ctx, b.Shell, docker.JoinCommands(group.Commands), sourceDir, groupEnv, output)
+}
+
+// deliverSecrets writes the file secrets into the secretsDir and returns the env with the env secrets.
+func deliverSecrets(secretsDir string, env []string, buildSecrets []secrets.Secret) ([]string, error) {
+	for _, s := range buildSecrets {
+		if s.AsFile() {
+			if err := os.WriteFile(filepath.Join(secretsDir, s.Id), s.Data, 0o600); err != nil {
+				return nil, fmt.Errorf("unable to write secret %q: %w", s.Id, err)
+			}
+		}
+
+		if s.AsEnv() {
+			env = append(env, fmt.Sprintf("%s=%s", s.Id, s.Data))
+		}
+	}
+
+	return env, nil
+}
@@ -0,0 +1,179 @@
+package builder
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/djherbis/buffer"
+	"github.com/djherbis/nio/v3"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+func TestLocalBuilder_BuildReleaseArtifacts(t *testing.T) {
+	gitRepo := initTestGitRepo(t, map[string]string{
+		"main.go":        "package main",
+		"hack/build.sh":  "mkdir -p $TRDL_RESULT_DIR/linux-amd64/bin && cp $1 $TRDL_RESULT_DIR/linux-amd64/bin/app",
+		"docs/README.md": "# app",
+	})
+
+	artifacts, err := buildWithLocalBuilder(gitRepo, &logical.InmemStorage{}, []string{
+		"sh hack/build.sh main.go",
+		"mkdir -p $TRDL_RESULT_DIR/any-any",
+		"cp docs/README.md $TRDL_RESULT_DIR/any-any/",
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			"result/linux-amd64/bin/app": "package main",
+			"result/any-any/README.md":   "# app",
+		}, artifacts)
+	}
+}
+
+func TestLocalBuilder_BuildReleaseArtifactsFailedCommand(t *testing.T) {
+	gitRepo := initTestGitRepo(t, map[string]string{"main.go": "package main"})
+
+	_, err := buildWithLocalBuilder(gitRepo, &logical.InmemStorage{}, []string{"true", "exit 3", "touch $TRDL_RESULT_DIR/unreachable"})
+	assert.ErrorContains(t, err, "exit status 3")
+}
+
+func TestLocalBuilder_BuildReleaseArtifactsScopedSecrets(t *testing.T) {
+	ctx := context.Background()
+	gitRepo := initTestGitRepo(t, map[string]string{"main.go": "package main"})
+
+	storage := &logical.InmemStorage{}
+	assert.NoError(t, secrets.PutSecret(ctx, storage, secrets.Secret{Id: "file", Data: []byte("file data")}))
+	assert.NoError(t, secrets.PutSecret(ctx, storage, secrets.Secret{
+		Id:            "TOKEN",
+		Data:          []byte("token data"),
+		SecretOptions: secrets.SecretOptions{Steps: []int{2}, Delivery: []string{secrets.DeliveryEnv}},
+	}))
+
+	artifacts, err := buildWithLocalBuilder(gitRepo, storage, []string{
+		`echo "$TOKEN" > $TRDL_RESULT_DIR/step1-token && ls $TRDL_SECRETS_DIR > $TRDL_RESULT_DIR/step1-files`,
+		`echo "$TOKEN" > $TRDL_RESULT_DIR/step2-token && cat $TRDL_SECRETS_DIR/file > $TRDL_RESULT_DIR/step2-file`,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			"result/step1-token": "\n",
+			"result/step1-files": "file\n",
+			"result/step2-token": "token data\n",
+			"result/step2-file":  "file data",
+		}, artifacts)
+	}
+}
+
+func TestLocalBuilder_BuildReleaseArtifactsScopedSecretsStepGroups(t *testing.T) {
+	ctx := context.Background()
+	gitRepo := initTestGitRepo(t, map[string]string{"hack/build.sh": "echo build"})
+
+	storage := &logical.InmemStorage{}
+	assert.NoError(t, secrets.PutSecret(ctx, storage, secrets.Secret{
+		Id:            "TOKEN",
+		Data:          []byte("token data"),
+		SecretOptions: secrets.SecretOptions{Steps: []int{3}, Delivery: []string{secrets.DeliveryFile, secrets.DeliveryEnv}},
+	}))
+
+	// the working directory and the exported variables are kept only between the commands of the same step group
+	artifacts, err := buildWithLocalBuilder(gitRepo, storage, []string{
+		"cd hack && export VERSION=1.0.0",
+		`echo "$VERSION $(ls) $TOKEN $(ls $TRDL_SECRETS_DIR)" > $TRDL_RESULT_DIR/step2`,
+		`echo "$VERSION $(ls) $TOKEN $(cat $TRDL_SECRETS_DIR/TOKEN)" > $TRDL_RESULT_DIR/step3`,
+		`echo "$TOKEN $(ls $TRDL_SECRETS_DIR)" > $TRDL_RESULT_DIR/step4`,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			"result/step2": "1.0.0 build.sh  \n",
+			"result/step3": " hack token data token data\n",
+			"result/step4": " \n",
+		}, artifacts)
+	}
+}
+
+func TestLocalBuilder_BuildReleaseArtifactsGroupedCommands(t *testing.T) {
+	gitRepo := initTestGitRepo(t, map[string]string{"main.go": "package main"})
+
+	_, err := buildWithLocalBuilder(gitRepo, &logical.InmemStorage{}, []string{"exit 3", "false || true", "touch $TRDL_RESULT_DIR/unreachable"})
+	assert.ErrorContains(t, err, "exit status 3")
+}
+
+func buildWithLocalBuilder(gitRepo *git.Repository, storage logical.Storage, commands []string) (map[string]string, error) {
+	return buildWithBuilder(NewLocalBuilder(), gitRepo, storage, commands, hclog.NewNullLogger())
+}
+
+// buildWithBuilder returns the files of the resulting tar stream by their names.
+func buildWithBuilder(builder Builder, gitRepo *git.Repository, storage logical.Storage, commands []string, logger hclog.Logger) (map[string]string, error) {
+	tarReader, tarWriter := nio.Pipe(buffer.New(1024 * 1024))
+
+	errCh := make(chan error, 1)
+	go func() {
+		err := builder.BuildReleaseArtifacts(context.Background(), BuildReleaseArtifactsOpts{
+			FromImage:   "alpine:3.18",
+			RunCommands: commands,
+			GitRepo:     gitRepo,
+			TarWriter:   tarWriter,
+			Storage:     storage,
+		}, logger)
+		if err != nil {
+			tarWriter.CloseWithError(err)
+		}
+		errCh <- err
+	}()
+
+	artifacts := map[string]string{}
+	tr := tar.NewReader(tarReader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		artifacts[hdr.Name] = string(data)
+	}
+
+	return artifacts, <-errCh
+}
+
+func initTestGitRepo(t *testing.T, files map[string]string) *git.Repository {
+	fs := memfs.New()
+	gitRepo, err := git.Init(memory.NewStorage(), fs)
+	assert.NoError(t, err)
+
+	w, err := gitRepo.Worktree()
+	assert.NoError(t, err)
+
+	for name, data := range files {
+		assert.NoError(t, util.WriteFile(fs, name, []byte(data), 0o644))
+		_, err := w.Add(name)
+		assert.NoError(t, err)
+	}
+
+	_, err = w.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "trdl", Email: "trdl@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	return gitRepo
+}
@@ -0,0 +1,27 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+// secretsCLIArgsAndEnv returns the --secret build arguments and the build command environment with the secret values.
+// The values are passed only to the build command, the plugin process environment is left untouched.
+func secretsCLIArgsAndEnv(buildSecrets []secrets.Secret) ([]string, []string) {
+	args := make([]string, 0, len(buildSecrets)*2)
+	env := os.Environ()
+	for _, s := range buildSecrets {
+		envName := secretEnvName(s.Id)
+		args = append(args, "--secret", fmt.Sprintf("id=%s,env=%s", s.Id, envName))
+		env = append(env, fmt.Sprintf("%s=%s", envName, s.Data))
+	}
+
+	return args, env
+}
+
+// secretEnvName is prefixed to avoid overriding the build command environment (e.g. PATH or HOME).
+func secretEnvName(id string) string {
+	return "TRDL_BUILD_SECRET_" + id
+}
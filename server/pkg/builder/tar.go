@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// extractTar extracts the tar stream into the dir, entries pointing outside the dir are rejected.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading next tar header: %w", err)
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return fmt.Errorf("unable to create dir for %q: %w", hdr.Name, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return fmt.Errorf("unable to create dir %q: %w", hdr.Name, err)
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return fmt.Errorf("unable to create symlink %q: %w", hdr.Name, err)
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return fmt.Errorf("unable to write file %q: %w", hdr.Name, err)
+			}
+		}
+	}
+}
+
+func writeFile(target string, r io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// addDirToTar adds the dir files to the tar stream under the prefix.
+func addDirToTar(tw *tar.Writer, dir, prefix string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(prefix, filepath.ToSlash(rel))
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return fmt.Errorf("unable to read link %q: %w", p, err)
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("unable to create tar header for %q: %w", p, err)
+		}
+		hdr.Format = tar.FormatGNU
+		hdr.Name = name
+		hdr.ModTime = time.Now()
+		if info.IsDir() {
+			hdr.Name = strings.TrimSuffix(name, "/") + "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("unable to write tar entry %q header: %w", name, err)
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("unable to write tar entry %q data: %w", name, err)
+		}
+
+		return nil
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"archive/tar"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/werf/trdl/server/pkg/secrets"
@@ -68,13 +67,8 @@ func generateDockerfile(fromImage string, runCommands []string, opts DockerfileO
 	addLineFunc(fmt.Sprintf("RUN %s", fmt.Sprintf("mkdir -p /%s", ContainerArtifactsDir)))
 
 	// run user's build commands
-	if len(runCommands) != 0 {
-		if len(opts.Secrets) > 0 {
-			mounts := GetSecretsRunMounts(opts.Secrets)
-			addLineFunc(fmt.Sprintf("%s %s", mounts, strings.Join(runCommands, " && ")))
-		} else {
-			addLineFunc(fmt.Sprintf("RUN %s", strings.Join(runCommands, " && ")))
-		}
+	for _, instruction := range GetRunInstructions(runCommands, opts.Secrets) {
+		addLineFunc(instruction)
 	}
 
 	// since we need only the artifacts from the build stage
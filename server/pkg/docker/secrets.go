@@ -2,43 +2,107 @@ package docker
 
 import (
 	"fmt"
-	"os"
+	"path"
 	"strings"
 
 	"github.com/werf/trdl/server/pkg/secrets"
 )
 
-func GetSecretsRunMounts(secrets []secrets.Secret) string {
-	mounts := buildStringInstruction(secrets)
-	return fmt.Sprintf("RUN %s", mounts)
+const (
+	ContainerSecretsDir = "/run/secrets"
+	// containerEnvSecretsDir is used for the secrets delivered only as environment variables,
+	// so that they are not available as /run/secrets/<id> files.
+	containerEnvSecretsDir = "/run/trdl-env-secrets"
+)
+
+// StepGroup is the consecutive build commands with the same scoped secrets.
+type StepGroup struct {
+	Commands      []string
+	ScopedSecrets []secrets.Secret
+}
+
+// GroupSteps splits the commands into the groups of the consecutive commands the same scoped secrets are available for.
+// Without the scoped secrets all commands are in a single group.
+func GroupSteps(commands []string, buildSecrets []secrets.Secret) []StepGroup {
+	var scopedSecrets []secrets.Secret
+	for _, s := range buildSecrets {
+		if s.IsScoped() {
+			scopedSecrets = append(scopedSecrets, s)
+		}
+	}
+
+	var groups []StepGroup
+	var lastKey string
+	for ind, command := range commands {
+		stepSecrets := secrets.ForStep(scopedSecrets, ind+1)
+
+		var ids []string
+		for _, s := range stepSecrets {
+			ids = append(ids, s.Id)
+		}
+		key := strings.Join(ids, ",")
+
+		if len(groups) == 0 || key != lastKey {
+			groups = append(groups, StepGroup{ScopedSecrets: stepSecrets})
+			lastKey = key
+		}
+
+		group := &groups[len(groups)-1]
+		group.Commands = append(group.Commands, command)
+	}
+
+	return groups
 }
 
-func GetSecretsCommandMounts(secrets []secrets.Secret) []string {
-	args := make([]string, 0, len(secrets))
-	for _, s := range secrets {
-		args = append(args, "--secret", fmt.Sprintf("id=%s", s.Id))
+// JoinCommands joins the commands into a single shell script.
+// Each command is grouped, so the operators in the command cannot break the chain and skip the failure of the previous command.
+func JoinCommands(commands []string) string {
+	var parts []string
+	for _, command := range commands {
+		parts = append(parts, fmt.Sprintf("{ %s; }", command))
 	}
-	return args
+
+	return strings.Join(parts, " && ")
 }
 
-func SetTempEnvVars(secrets []secrets.Secret) error {
-	for _, s := range secrets {
-		err := os.Setenv(s.Id, string(s.Data))
-		if err != nil {
-			return fmt.Errorf("unable to use secret data")
+// GetRunInstructions returns the RUN instructions for the build commands.
+// The commands of each step group are joined into a single instruction, so the working directory and the exported variables are kept between them.
+// Each instruction mounts only the unscoped secrets and the secrets scoped to the commands of the group.
+func GetRunInstructions(runCommands []string, buildSecrets []secrets.Secret) []string {
+	var unscopedSecrets []secrets.Secret
+	for _, s := range buildSecrets {
+		if !s.IsScoped() {
+			unscopedSecrets = append(unscopedSecrets, s)
 		}
 	}
-	return nil
+
+	var instructions []string
+	for _, group := range GroupSteps(runCommands, buildSecrets) {
+		groupSecrets := append(append([]secrets.Secret{}, unscopedSecrets...), group.ScopedSecrets...)
+		instructions = append(instructions, getRunInstruction(group.Commands, groupSecrets))
+	}
+
+	return instructions
 }
 
-func buildStringInstruction(secrets []secrets.Secret) string {
-	var builder strings.Builder
-	for _, secret := range secrets {
-		if builder.Len() > 0 {
-			builder.WriteString(" ")
+func getRunInstruction(runCommands []string, buildSecrets []secrets.Secret) string {
+	parts := []string{"RUN"}
+	var exports []string
+	for _, s := range buildSecrets {
+		if s.AsFile() {
+			parts = append(parts, fmt.Sprintf("--mount=type=secret,id=%s", s.Id))
+		}
+
+		if s.AsEnv() {
+			target := path.Join(ContainerSecretsDir, s.Id)
+			if !s.AsFile() {
+				target = path.Join(containerEnvSecretsDir, s.Id)
+				parts = append(parts, fmt.Sprintf("--mount=type=secret,id=%s,target=%s", s.Id, target))
+			}
+			exports = append(exports, fmt.Sprintf("export %s=\"$(cat %s)\"", s.Id, target))
 		}
-		builder.WriteString(fmt.Sprintf("--mount=type=secret,id=%s", secret.Id))
 	}
 
-	return builder.String()
+	parts = append(parts, strings.Join(append(exports, JoinCommands(runCommands)), " && "))
+	return strings.Join(parts, " ")
 }
@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/trdl/server/pkg/secrets"
+)
+
+func TestGetRunInstructions(t *testing.T) {
+	commands := []string{"./build.sh", "./upload.sh"}
+
+	t.Run("no secrets", func(t *testing.T) {
+		assert.Equal(t, []string{"RUN { ./build.sh; } && { ./upload.sh; }"}, GetRunInstructions(commands, nil))
+	})
+
+	t.Run("unscoped secrets", func(t *testing.T) {
+		assert.Equal(t, []string{
+			`RUN --mount=type=secret,id=aws --mount=type=secret,id=TOKEN,target=/run/trdl-env-secrets/TOKEN export TOKEN="$(cat /run/trdl-env-secrets/TOKEN)" && { ./build.sh; } && { ./upload.sh; }`,
+		}, GetRunInstructions(commands, []secrets.Secret{
+			{Id: "aws"},
+			{Id: "TOKEN", SecretOptions: secrets.SecretOptions{Delivery: []string{secrets.DeliveryEnv}}},
+		}))
+	})
+
+	t.Run("scoped secrets", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"RUN --mount=type=secret,id=aws { ./build.sh; }",
+			`RUN --mount=type=secret,id=aws --mount=type=secret,id=TOKEN export TOKEN="$(cat /run/secrets/TOKEN)" && { ./upload.sh; } && { ./notify.sh; }`,
+			"RUN --mount=type=secret,id=aws { ./cleanup.sh; }",
+		}, GetRunInstructions([]string{"./build.sh", "./upload.sh", "./notify.sh", "./cleanup.sh"}, []secrets.Secret{
+			{Id: "aws"},
+			{Id: "TOKEN", SecretOptions: secrets.SecretOptions{Steps: []int{2, 3}, Delivery: []string{secrets.DeliveryFile, secrets.DeliveryEnv}}},
+		}))
+	})
+
+	t.Run("commands are grouped", func(t *testing.T) {
+		assert.Equal(t, []string{"RUN { false || true; } && { ./build.sh; echo done; }"}, GetRunInstructions([]string{"false || true", "./build.sh; echo done"}, nil))
+	})
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/go-git/go-billy/v5/memfs"
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/storage/memory"
@@ -22,6 +23,8 @@ type CloneOptions struct {
 	ReferenceName     string
 	RecurseSubmodules git.SubmoduleRescursivity
 	Auth              transport.AuthMethod
+	// Depth limits the cloned history, the whole history is cloned if not set
+	Depth int
 }
 
 func CloneInMemory(url string, opts CloneOptions) (*git.Repository, error) {
@@ -48,11 +51,52 @@ func CloneInMemory(url string, opts CloneOptions) (*git.Repository, error) {
 		if opts.Auth != nil {
 			cloneOptions.Auth = opts.Auth
 		}
+
+		cloneOptions.Depth = opts.Depth
 	}
 
 	return git.Clone(storage, fs, cloneOptions)
 }
 
+// GetRemoteBranchHead returns the head commit of the remote branch (or the remote HEAD if the branch is not set) without cloning the repository.
+func GetRemoteBranchHead(url, branchName string, auth transport.AuthMethod) (string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", fmt.Errorf("unable to list remote references: %w", err)
+	}
+
+	refsByName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, ref := range refs {
+		refsByName[ref.Name()] = ref
+	}
+
+	refName := plumbing.HEAD
+	if branchName != "" {
+		refName = plumbing.NewBranchReferenceName(branchName)
+	}
+
+	// resolve the symbolic HEAD reference
+	for i := 0; i < 10; i++ {
+		ref, ok := refsByName[refName]
+		if !ok {
+			return "", fmt.Errorf("remote reference %q not found", refName)
+		}
+
+		if ref.Type() != plumbing.SymbolicReference {
+			return ref.Hash().String(), nil
+		}
+
+		refName = ref.Target()
+	}
+
+	return "", fmt.Errorf("unable to resolve remote reference %q", refName)
+}
+
 func AddWorktreeFilesToTar(tw *tar.Writer, gitRepo *git.Repository) error {
 	return ForEachWorktreeFile(gitRepo, func(path, link string, fileReader io.Reader, info os.FileInfo) error {
 		size := info.Size()
@@ -0,0 +1,146 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// StorageKeySchemaVersion is the key of the storage schema version, the storage of the previous versions of the plugin has no version.
+const StorageKeySchemaVersion = "storage_schema_version"
+
+// Migration brings the storage of the previous schema version to the Version.
+// The Migrate func must be safe to rerun, since the interrupted migration is started again.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(ctx context.Context, storage logical.Storage) error
+}
+
+// Migrator runs the pending migrations once per plugin process.
+type Migrator struct {
+	migrations []Migration
+	logger     hclog.Logger
+
+	mu       sync.Mutex
+	migrated bool
+}
+
+// NewMigrator requires the migration versions to go in order starting from 1.
+func NewMigrator(logger hclog.Logger, migrations ...Migration) (*Migrator, error) {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("unexpected version %d of migration %q: expected %d", migration.Version, migration.Description, i+1)
+		}
+	}
+
+	return &Migrator{migrations: migrations, logger: logger}, nil
+}
+
+// SchemaVersion is the storage schema version of the current plugin version.
+func (m *Migrator) SchemaVersion() int {
+	return len(m.migrations)
+}
+
+// Migrate runs the pending migrations unless they have already been run by the plugin process.
+// The failed migration is retried on the next call.
+func (m *Migrator) Migrate(ctx context.Context, storage logical.Storage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.migrated {
+		return nil
+	}
+
+	if err := m.migrate(ctx, storage); err != nil {
+		return err
+	}
+
+	m.migrated = true
+
+	return nil
+}
+
+// Reset makes the next Migrate call check the storage again, e.g. after the storage is replaced.
+func (m *Migrator) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.migrated = false
+}
+
+func (m *Migrator) migrate(ctx context.Context, storage logical.Storage) error {
+	version, found, err := GetSchemaVersion(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		keys, err := storage.List(ctx, "")
+		if err != nil {
+			return fmt.Errorf("unable to list storage: %w", err)
+		}
+
+		// the new mount has nothing to migrate
+		if len(keys) == 0 {
+			return PutSchemaVersion(ctx, storage, m.SchemaVersion())
+		}
+	}
+
+	if version > m.SchemaVersion() {
+		return fmt.Errorf("storage schema version %d is not supported by the plugin (supported %d): the plugin downgrade is not supported", version, m.SchemaVersion())
+	}
+
+	for _, migration := range m.migrations[version:] {
+		m.logger.Info(fmt.Sprintf("Migrating storage to schema version %d: %s", migration.Version, migration.Description))
+
+		if err := migration.Migrate(ctx, storage); err != nil {
+			return fmt.Errorf("storage migration to schema version %d failed: %w", migration.Version, err)
+		}
+
+		if err := PutSchemaVersion(ctx, storage, migration.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetSchemaVersion returns the stored schema version or 0 if the version is not found.
+func GetSchemaVersion(ctx context.Context, storage logical.Storage) (int, bool, error) {
+	entry, err := storage.Get(ctx, StorageKeySchemaVersion)
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to get %q from storage: %w", StorageKeySchemaVersion, err)
+	}
+
+	if entry == nil {
+		return 0, false, nil
+	}
+
+	version, err := strconv.Atoi(string(entry.Value))
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to parse storage schema version %q: %w", string(entry.Value), err)
+	}
+
+	return version, true, nil
+}
+
+func PutSchemaVersion(ctx context.Context, storage logical.Storage, version int) error {
+	if err := storage.Put(ctx, &logical.StorageEntry{Key: StorageKeySchemaVersion, Value: []byte(strconv.Itoa(version))}); err != nil {
+		return fmt.Errorf("unable to put %q into storage: %w", StorageKeySchemaVersion, err)
+	}
+
+	return nil
+}
+
+func DeleteSchemaVersion(ctx context.Context, storage logical.Storage) error {
+	if err := storage.Delete(ctx, StorageKeySchemaVersion); err != nil {
+		return fmt.Errorf("unable to delete %q from storage: %w", StorageKeySchemaVersion, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type migratorSuite struct {
+	suite.Suite
+	ctx     context.Context
+	storage logical.Storage
+	applied []int
+	failing map[int]bool
+}
+
+func (suite *migratorSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.storage = &logical.InmemStorage{}
+	suite.applied = nil
+	suite.failing = map[int]bool{}
+}
+
+func (suite *migratorSuite) newMigrator(count int) *Migrator {
+	var list []Migration
+	for i := 1; i <= count; i++ {
+		version := i
+		list = append(list, Migration{
+			Version:     version,
+			Description: "test",
+			Migrate: func(_ context.Context, _ logical.Storage) error {
+				if suite.failing[version] {
+					return errors.New("error")
+				}
+
+				suite.applied = append(suite.applied, version)
+				return nil
+			},
+		})
+	}
+
+	m, err := NewMigrator(hclog.NewNullLogger(), list...)
+	assert.Nil(suite.T(), err)
+
+	return m
+}
+
+func (suite *migratorSuite) putLegacyEntry() {
+	err := suite.storage.Put(suite.ctx, &logical.StorageEntry{Key: "configuration", Value: []byte("{}")})
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *migratorSuite) assertSchemaVersion(expected int) {
+	version, found, err := GetSchemaVersion(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), found)
+	assert.Equal(suite.T(), expected, version)
+}
+
+func (suite *migratorSuite) TestNewMigrator_InvalidVersions() {
+	_, err := NewMigrator(hclog.NewNullLogger(), Migration{Version: 2})
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *migratorSuite) TestNewMount() {
+	err := suite.newMigrator(2).Migrate(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), suite.applied)
+	suite.assertSchemaVersion(2)
+}
+
+func (suite *migratorSuite) TestUnversionedStorage() {
+	suite.putLegacyEntry()
+
+	err := suite.newMigrator(2).Migrate(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []int{1, 2}, suite.applied)
+	suite.assertSchemaVersion(2)
+}
+
+func (suite *migratorSuite) TestPendingMigrations() {
+	suite.putLegacyEntry()
+	assert.Nil(suite.T(), PutSchemaVersion(suite.ctx, suite.storage, 1))
+
+	err := suite.newMigrator(3).Migrate(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []int{2, 3}, suite.applied)
+	suite.assertSchemaVersion(3)
+}
+
+func (suite *migratorSuite) TestNewerSchemaVersion() {
+	assert.Nil(suite.T(), PutSchemaVersion(suite.ctx, suite.storage, 3))
+
+	err := suite.newMigrator(2).Migrate(suite.ctx, suite.storage)
+	assert.NotNil(suite.T(), err)
+	assert.Empty(suite.T(), suite.applied)
+}
+
+func (suite *migratorSuite) TestFailedMigration() {
+	suite.putLegacyEntry()
+	suite.failing[2] = true
+	m := suite.newMigrator(3)
+
+	err := m.Migrate(suite.ctx, suite.storage)
+	assert.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), []int{1}, suite.applied)
+	suite.assertSchemaVersion(1)
+
+	// retried on the next call from the failed migration
+	suite.failing[2] = false
+	err = m.Migrate(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []int{1, 2, 3}, suite.applied)
+	suite.assertSchemaVersion(3)
+}
+
+func (suite *migratorSuite) TestMigrateOnce() {
+	suite.putLegacyEntry()
+	m := suite.newMigrator(1)
+
+	assert.Nil(suite.T(), m.Migrate(suite.ctx, suite.storage))
+	assert.Nil(suite.T(), DeleteSchemaVersion(suite.ctx, suite.storage))
+	assert.Nil(suite.T(), m.Migrate(suite.ctx, suite.storage))
+	assert.Equal(suite.T(), []int{1}, suite.applied)
+
+	m.Reset()
+	assert.Nil(suite.T(), m.Migrate(suite.ctx, suite.storage))
+	assert.Equal(suite.T(), []int{1, 1}, suite.applied)
+}
+
+func TestMigrator(t *testing.T) {
+	suite.Run(t, new(migratorSuite))
+}
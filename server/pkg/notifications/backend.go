@@ -0,0 +1,185 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	fieldNameWebhookName      = "name"
+	fieldNameWebhookURL       = "url"
+	fieldNameWebhookSecret    = "secret"
+	fieldNameWebhookEvents    = "events"
+	fieldNameWebhookTaskTypes = "task_types"
+)
+
+func Paths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern:         "configure/notifications/?",
+			HelpSynopsis:    "List notification webhooks",
+			HelpDescription: "List notification webhooks",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Description: "Get the list of notification webhooks",
+					Callback:    pathWebhookList,
+				},
+			},
+		},
+		{
+			Pattern:         "configure/notifications/" + framework.GenericNameRegex(fieldNameWebhookName) + "$",
+			HelpSynopsis:    "Configure a notification webhook",
+			HelpDescription: "Configure the HTTP endpoint the task events are sent to with POST requests. The request body is signed with the secret using HMAC-SHA256, the signature is passed in the X-Trdl-Signature header as sha256=<hex>. The secret is never returned",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameWebhookName: {
+					Type:        framework.TypeNameString,
+					Description: "Webhook name",
+					Required:    true,
+				},
+				fieldNameWebhookURL: {
+					Type:        framework.TypeString,
+					Description: "Webhook URL with the http or https scheme (required if the webhook does not exist)",
+				},
+				fieldNameWebhookSecret: {
+					Type:         framework.TypeString,
+					Description:  "HMAC secret to sign the requests (required if the webhook does not exist)",
+					DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+				},
+				fieldNameWebhookEvents: {
+					Type:          framework.TypeCommaStringSlice,
+					Description:   "Events to send",
+					Default:       DefaultEvents,
+					AllowedValues: []interface{}{EventTaskSucceeded, EventTaskFailed},
+				},
+				fieldNameWebhookTaskTypes: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Types of the tasks to send the events for (release, publish, periodic), all tasks by default",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Description: "Add or update a notification webhook",
+					Callback:    pathWebhookCreateOrUpdate,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Description: "Add or update a notification webhook",
+					Callback:    pathWebhookCreateOrUpdate,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Description: "Get the notification webhook",
+					Callback:    pathWebhookRead,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Description: "Delete the notification webhook",
+					Callback:    pathWebhookDelete,
+				},
+			},
+		},
+	}
+}
+
+func pathWebhookCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(fieldNameWebhookName).(string)
+
+	existing, err := GetWebhook(ctx, req.Storage, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get webhook: %w", err)
+	}
+
+	// fields which are not passed are kept as is
+	w := Webhook{Name: name}
+	if existing != nil {
+		w = *existing
+	}
+
+	if v, ok := fields.GetOk(fieldNameWebhookURL); ok {
+		w.URL = v.(string)
+	}
+	if v, ok := fields.GetOk(fieldNameWebhookSecret); ok {
+		w.Secret = v.(string)
+	}
+	if v, ok := fields.GetOk(fieldNameWebhookEvents); ok {
+		w.Events = v.([]string)
+	} else if existing == nil {
+		w.Events = fields.Get(fieldNameWebhookEvents).([]string)
+	}
+	if v, ok := fields.GetOk(fieldNameWebhookTaskTypes); ok {
+		w.TaskTypes = v.([]string)
+	}
+
+	if existing == nil && w.URL == "" {
+		return logical.ErrorResponse("Required field %q must be set", fieldNameWebhookURL), nil
+	}
+
+	if err := w.Validate(); err != nil {
+		return logical.ErrorResponse("webhook validation failed: %s", err), nil
+	}
+
+	if err := PutWebhook(ctx, req.Storage, w); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func pathWebhookList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	list, err := GetWebhooks(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list webhooks: %w", err)
+	}
+
+	keys := make([]string, 0, len(list))
+	keysInfo := make(map[string]interface{}, len(list))
+	for _, w := range list {
+		keys = append(keys, w.Name)
+		keysInfo[w.Name] = webhookData(w)
+	}
+
+	return logical.ListResponseWithInfo(keys, keysInfo), nil
+}
+
+func pathWebhookRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(fieldNameWebhookName).(string)
+
+	w, err := GetWebhook(ctx, req.Storage, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get webhook: %w", err)
+	}
+	if w == nil {
+		return logical.ErrorResponse("webhook %q not found", name), nil
+	}
+
+	data := webhookData(*w)
+	data[fieldNameWebhookName] = w.Name
+
+	return &logical.Response{Data: data}, nil
+}
+
+func pathWebhookDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if err := DeleteWebhook(ctx, req.Storage, fields.Get(fieldNameWebhookName).(string)); err != nil {
+		return nil, fmt.Errorf("error delete webhook: %w", err)
+	}
+	return nil, nil
+}
+
+func webhookData(w Webhook) map[string]interface{} {
+	events := w.Events
+	if len(events) == 0 {
+		events = DefaultEvents
+	}
+
+	taskTypes := w.TaskTypes
+	if taskTypes == nil {
+		taskTypes = []string{}
+	}
+
+	return map[string]interface{}{
+		fieldNameWebhookURL:       w.URL,
+		fieldNameWebhookEvents:    events,
+		fieldNameWebhookTaskTypes: taskTypes,
+		"secret_set":              w.Secret != "",
+	}
+}
@@ -0,0 +1,151 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type pathNotificationsCallbacksSuite struct {
+	suite.Suite
+	ctx     context.Context
+	backend logical.Backend
+	req     *logical.Request
+	storage logical.Storage
+}
+
+func (suite *pathNotificationsCallbacksSuite) SetupTest() {
+	ctx := context.Background()
+	b := &framework.Backend{}
+	b.Paths = Paths()
+	storage := &logical.InmemStorage{}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	err := b.Setup(ctx, config)
+	assert.Nil(suite.T(), err)
+
+	suite.ctx = ctx
+	suite.backend = b
+	suite.req = &logical.Request{Storage: storage}
+	suite.storage = storage
+}
+
+func (suite *pathNotificationsCallbacksSuite) TestCreateAndUpdate() {
+	suite.req.Path = "configure/notifications/ci"
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Data = map[string]interface{}{}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("Required field %q must be set", fieldNameWebhookURL), resp)
+
+	suite.req.Data = map[string]interface{}{
+		fieldNameWebhookURL:    "http://localhost/hook",
+		fieldNameWebhookSecret: "secret",
+	}
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	// the fields which are not passed are kept
+	suite.req.Operation = logical.UpdateOperation
+	suite.req.Data = map[string]interface{}{
+		fieldNameWebhookEvents:    EventTaskFailed,
+		fieldNameWebhookTaskTypes: "release,publish",
+	}
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	w, err := GetWebhook(suite.ctx, suite.storage, "ci")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), &Webhook{
+		Name:      "ci",
+		URL:       "http://localhost/hook",
+		Secret:    "secret",
+		Events:    []string{EventTaskFailed},
+		TaskTypes: []string{"release", "publish"},
+	}, w)
+}
+
+func (suite *pathNotificationsCallbacksSuite) TestReadListDelete() {
+	assert.Nil(suite.T(), PutWebhook(suite.ctx, suite.storage, Webhook{Name: "ci", URL: "http://localhost/hook", Secret: "secret"}))
+
+	suite.req.Path = "configure/notifications/ci"
+	suite.req.Operation = logical.ReadOperation
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), map[string]interface{}{
+			fieldNameWebhookName:      "ci",
+			fieldNameWebhookURL:       "http://localhost/hook",
+			fieldNameWebhookEvents:    DefaultEvents,
+			fieldNameWebhookTaskTypes: []string{},
+			"secret_set":              true,
+		}, resp.Data)
+	}
+
+	suite.req.Path = "configure/notifications"
+	suite.req.Operation = logical.ListOperation
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	if assert.NotNil(suite.T(), resp) {
+		assert.Equal(suite.T(), []string{"ci"}, resp.Data["keys"])
+	}
+
+	suite.req.Path = "configure/notifications/ci"
+	suite.req.Operation = logical.DeleteOperation
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	w, err := GetWebhook(suite.ctx, suite.storage, "ci")
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), w)
+}
+
+func (suite *pathNotificationsCallbacksSuite) TestCreateInvalid() {
+	suite.req.Path = "configure/notifications/ci"
+	suite.req.Operation = logical.CreateOperation
+
+	for _, c := range []struct {
+		data          map[string]interface{}
+		expectedError string
+	}{
+		{
+			data:          map[string]interface{}{fieldNameWebhookURL: "http://localhost/hook"},
+			expectedError: "webhook validation failed: secret must be set",
+		},
+		{
+			data:          map[string]interface{}{fieldNameWebhookURL: "file:///etc/passwd", fieldNameWebhookSecret: "secret"},
+			expectedError: `webhook validation failed: unsupported url scheme "file": expected http or https`,
+		},
+		{
+			data:          map[string]interface{}{fieldNameWebhookURL: "localhost/hook", fieldNameWebhookSecret: "secret"},
+			expectedError: `webhook validation failed: unsupported url scheme "": expected http or https`,
+		},
+	} {
+		suite.req.Data = c.data
+
+		resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), logical.ErrorResponse(c.expectedError), resp)
+	}
+
+	w, err := GetWebhook(suite.ctx, suite.storage, "ci")
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), w)
+}
+
+func TestPathNotificationsCallbacks(t *testing.T) {
+	suite.Run(t, new(pathNotificationsCallbacksSuite))
+}
@@ -0,0 +1,161 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	HeaderEvent     = "X-Trdl-Event"
+	HeaderSignature = "X-Trdl-Signature"
+
+	deliveryAttempts = 5
+	requestTimeout   = 30 * time.Second
+
+	// LogTailSize is the maximum size of the task log tail sent with the event.
+	LogTailSize = 4 * 1024
+)
+
+// deliveryRetryInterval is doubled after each failed delivery attempt.
+var deliveryRetryInterval = 2 * time.Second
+
+// TaskEvent is sent as the JSON body of the webhook request.
+type TaskEvent struct {
+	Event       string            `json:"event"`
+	UUID        string            `json:"uuid"`
+	Type        string            `json:"type,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+	Status      string            `json:"status"`
+	Reason      string            `json:"reason,omitempty"`
+	Commit      string            `json:"commit,omitempty"`
+	TufVersions map[string]int64  `json:"tuf_versions,omitempty"`
+	Created     time.Time         `json:"created"`
+	Modified    time.Time         `json:"modified"`
+	LogTail     string            `json:"log_tail,omitempty"`
+}
+
+// LogTail returns the last LogTailSize bytes of the log.
+func LogTail(log []byte) string {
+	if len(log) > LogTailSize {
+		log = log[len(log)-LogTailSize:]
+	}
+
+	return string(log)
+}
+
+type Notifier struct {
+	client *http.Client
+	logger hclog.Logger
+}
+
+func NewNotifier(logger hclog.Logger) *Notifier {
+	return &Notifier{
+		client: &http.Client{Timeout: requestTimeout},
+		logger: logger,
+	}
+}
+
+// NotifyTaskEvent sends the event to the subscribed webhooks in the background.
+// The delivery is retried with backoff, the failures are only logged.
+func (n *Notifier) NotifyTaskEvent(ctx context.Context, storage logical.Storage, event TaskEvent) error {
+	webhooks, err := GetWebhooks(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get webhooks: %w", err)
+	}
+
+	var body []byte
+	for _, w := range webhooks {
+		if !w.Match(event.Event, event.Type) {
+			continue
+		}
+
+		// the webhooks stored before the validation was tightened are not used until reconfigured
+		if err := w.Validate(); err != nil {
+			n.logger.Error(fmt.Sprintf("Skipping invalid webhook %q: %s", w.Name, err))
+			continue
+		}
+
+		if body == nil {
+			body, err = json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("unable to marshal event: %w", err)
+			}
+		}
+
+		go func(w Webhook) {
+			if err := n.deliver(context.Background(), w, event.Event, body); err != nil {
+				n.logger.Error(fmt.Sprintf("Unable to deliver %s event of the task %q to the webhook %q: %s", event.Event, event.UUID, w.Name, err))
+			}
+		}(w)
+	}
+
+	return nil
+}
+
+func (n *Notifier) deliver(ctx context.Context, w Webhook, event string, body []byte) error {
+	interval := deliveryRetryInterval
+
+	var err error
+	for attempt := 1; attempt <= deliveryAttempts; attempt++ {
+		if err = n.send(ctx, w, event, body); err == nil {
+			return nil
+		}
+
+		if attempt == deliveryAttempts {
+			break
+		}
+
+		n.logger.Debug(fmt.Sprintf("Webhook %q delivery attempt %d failed: %s: retrying in %s", w.Name, attempt, err, interval))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+
+	return fmt.Errorf("%d delivery attempts failed: %w", deliveryAttempts, err)
+}
+
+func (n *Notifier) send(ctx context.Context, w Webhook, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderSignature, Sign(w.Secret, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the HMAC-SHA256 signature of the body in the X-Trdl-Signature header format.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
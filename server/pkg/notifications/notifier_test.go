@@ -0,0 +1,91 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedRequest struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+func TestNotifier_NotifyTaskEvent(t *testing.T) {
+	deliveryRetryInterval = time.Millisecond
+
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	// the receiver fails the first request to check the retry
+	requestsCh := make(chan receivedRequest, 10)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		requestsCh <- receivedRequest{event: r.Header.Get(HeaderEvent), signature: r.Header.Get(HeaderSignature), body: body}
+	}))
+	defer server.Close()
+
+	assert.Nil(t, PutWebhook(ctx, storage, Webhook{Name: "all", URL: server.URL, Secret: "secret"}))
+	assert.Nil(t, PutWebhook(ctx, storage, Webhook{Name: "publish", URL: server.URL, Secret: "secret", TaskTypes: []string{"publish"}}))
+
+	// the webhook stored without the secret is skipped
+	assert.Nil(t, PutWebhook(ctx, storage, Webhook{Name: "unsigned", URL: server.URL}))
+
+	n := NewNotifier(hclog.NewNullLogger())
+	event := TaskEvent{
+		Event:   EventTaskFailed,
+		UUID:    "bfc441c7-a143-4ab2-9aac-4d109cef5018",
+		Type:    "release",
+		Params:  map[string]string{"git_tag": "v1.0.0"},
+		Status:  "FAILED",
+		Reason:  "build failed",
+		LogTail: "error",
+	}
+	assert.Nil(t, n.NotifyTaskEvent(ctx, storage, event))
+
+	select {
+	case req := <-requestsCh:
+		assert.Equal(t, EventTaskFailed, req.event)
+		assert.Equal(t, Sign("secret", req.body), req.signature)
+
+		var receivedEvent TaskEvent
+		assert.Nil(t, json.Unmarshal(req.body, &receivedEvent))
+		assert.Equal(t, event, receivedEvent)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event is not delivered")
+	}
+
+	// the publish webhook is not subscribed to the release task events
+	select {
+	case req := <-requestsCh:
+		t.Fatalf("unexpected request %s", req.body)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 2, attempts)
+}
+
+func TestLogTail(t *testing.T) {
+	assert.Equal(t, "log", LogTail([]byte("log")))
+
+	log := make([]byte, LogTailSize+10)
+	for i := range log {
+		log[i] = byte('a' + i%26)
+	}
+	assert.Equal(t, string(log[10:]), LogTail(log))
+}
@@ -0,0 +1,163 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const storageKeyPrefixWebhook = "notifications_webhook/"
+
+const (
+	// EventTaskSucceeded is sent when the task is completed successfully.
+	EventTaskSucceeded = "task_succeeded"
+	// EventTaskFailed is sent when the task is failed or canceled.
+	EventTaskFailed = "task_failed"
+)
+
+var (
+	Events        = []string{EventTaskSucceeded, EventTaskFailed}
+	DefaultEvents = []string{EventTaskSucceeded, EventTaskFailed}
+)
+
+type Webhook struct {
+	Name string `json:"-"`
+	URL  string `json:"url"`
+	// Secret is used to sign the request body with HMAC-SHA256.
+	Secret string `json:"secret,omitempty"`
+	// Events are the names of the events the webhook is subscribed to.
+	Events []string `json:"events,omitempty"`
+	// TaskTypes filter the task events by the task type, the events of all tasks are sent if empty.
+	TaskTypes []string `json:"task_types,omitempty"`
+}
+
+func (w Webhook) Validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("url must be set")
+	}
+
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q: expected http or https", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("url host must be set")
+	}
+
+	if w.Secret == "" {
+		return fmt.Errorf("secret must be set")
+	}
+
+	for _, e := range w.Events {
+		if !contains(Events, e) {
+			return fmt.Errorf("unknown event %q: expected one of %v", e, Events)
+		}
+	}
+
+	return nil
+}
+
+// Match returns true if the webhook is subscribed to the event of the task with the type.
+func (w Webhook) Match(event, taskType string) bool {
+	events := w.Events
+	if len(events) == 0 {
+		events = DefaultEvents
+	}
+
+	if !contains(events, event) {
+		return false
+	}
+
+	return len(w.TaskTypes) == 0 || contains(w.TaskTypes, taskType)
+}
+
+func webhookStorageKey(name string) string {
+	return storageKeyPrefixWebhook + name
+}
+
+func PutWebhook(ctx context.Context, storage logical.Storage, w Webhook) error {
+	entry, err := logical.StorageEntryJSON(webhookStorageKey(w.Name), w)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhook returns nil if the webhook does not exist.
+func GetWebhook(ctx context.Context, storage logical.Storage, name string) (*Webhook, error) {
+	e, err := storage.Get(ctx, webhookStorageKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, nil
+	}
+
+	var w Webhook
+	if err := json.Unmarshal(e.Value, &w); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal webhook %q: %w", name, err)
+	}
+	w.Name = name
+
+	return &w, nil
+}
+
+func ListWebhookNames(ctx context.Context, storage logical.Storage) ([]string, error) {
+	list, err := storage.List(ctx, storageKeyPrefixWebhook)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(list)
+	return list, nil
+}
+
+func GetWebhooks(ctx context.Context, storage logical.Storage) ([]Webhook, error) {
+	list, err := ListWebhookNames(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhooks []Webhook
+	for _, name := range list {
+		w, err := GetWebhook(ctx, storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if w == nil {
+			continue
+		}
+
+		webhooks = append(webhooks, *w)
+	}
+
+	return webhooks, nil
+}
+
+func DeleteWebhook(ctx context.Context, storage logical.Storage, name string) error {
+	return storage.Delete(ctx, webhookStorageKey(name))
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,199 @@
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const (
+	SigningKeyAlgorithmRSA4096 = "rsa-4096"
+	SigningKeyAlgorithmEd25519 = "ed25519"
+
+	DefaultSigningKeyAlgorithm = SigningKeyAlgorithmRSA4096
+)
+
+func ValidateSigningKeyAlgorithm(algorithm string) error {
+	switch algorithm {
+	case SigningKeyAlgorithmRSA4096, SigningKeyAlgorithmEd25519:
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q: expected %q or %q", algorithm, SigningKeyAlgorithmRSA4096, SigningKeyAlgorithmEd25519)
+	}
+}
+
+type SigningKey struct {
+	Entity *openpgp.Entity
+}
+
+// Fingerprint returns the upper-case hex fingerprint of the primary key.
+func (key *SigningKey) Fingerprint() string {
+	return strings.ToUpper(hex.EncodeToString(key.Entity.PrimaryKey.Fingerprint))
+}
+
+func (key *SigningKey) CreationTime() time.Time {
+	return key.Entity.PrimaryKey.CreationTime
+}
+
+func (key *SigningKey) SerializePublicKey(out io.Writer) error {
+	armoredOut, err := armor.Encode(out, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return fmt.Errorf("unable to prepare armored writer: %w", err)
+	}
+
+	if err := key.Entity.Serialize(armoredOut); err != nil {
+		return err
+	}
+
+	if err := armoredOut.Close(); err != nil {
+		return fmt.Errorf("unable to close armored writer: %w", err)
+	}
+
+	return nil
+}
+
+func (key *SigningKey) SerializeFull(out io.Writer) error {
+	return key.SerializePrivateKey(out)
+}
+
+func (key *SigningKey) SerializePrivateKey(out io.Writer) error {
+	armoredOut, err := armor.Encode(out, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return fmt.Errorf("unable to prepare armored writer: %w", err)
+	}
+
+	if err := key.Entity.SerializePrivate(armoredOut, nil); err != nil {
+		return err
+	}
+
+	if err := armoredOut.Close(); err != nil {
+		return fmt.Errorf("unable to close armored writer: %w", err)
+	}
+
+	return nil
+}
+
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	config := &packet.Config{
+		Time:          time.Now,
+		Rand:          rand.Reader,
+		DefaultHash:   crypto.SHA256,
+		DefaultCipher: packet.CipherAES128,
+	}
+
+	switch algorithm {
+	case SigningKeyAlgorithmRSA4096:
+		config.Algorithm = packet.PubKeyAlgoRSA
+		config.RSABits = 4096
+	case SigningKeyAlgorithmEd25519:
+		config.Algorithm = packet.PubKeyAlgoEdDSA
+	default:
+		return nil, ValidateSigningKeyAlgorithm(algorithm)
+	}
+
+	entity, err := openpgp.NewEntity("trdl", "trdl server auto signer", "", config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate openpgp entity: %w", err)
+	}
+
+	return &SigningKey{Entity: entity}, nil
+}
+
+// NewRSASigningKeyFromSigner creates the key which signs by the signer, so the private key is never kept in memory.
+// The creation time is a part of the fingerprint, so the same time must be passed for the same signer.
+func NewRSASigningKeyFromSigner(signer crypto.Signer, creationTime time.Time) (*SigningKey, error) {
+	publicKey, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signer public key %T: expected rsa public key", signer.Public())
+	}
+
+	privateKey := &packet.PrivateKey{PublicKey: *packet.NewRSAPublicKey(creationTime, publicKey), PrivateKey: signer}
+	entity := &openpgp.Entity{
+		PrimaryKey: &privateKey.PublicKey,
+		PrivateKey: privateKey,
+		Identities: make(map[string]*openpgp.Identity),
+	}
+
+	uid := packet.NewUserId("trdl", "trdl server auto signer", "")
+	isPrimaryID := true
+	selfSignature := &packet.Signature{
+		CreationTime: creationTime,
+		SigType:      packet.SigTypePositiveCert,
+		PubKeyAlgo:   packet.PubKeyAlgoRSA,
+		Hash:         crypto.SHA256,
+		IsPrimaryId:  &isPrimaryID,
+		FlagsValid:   true,
+		FlagSign:     true,
+		FlagCertify:  true,
+		IssuerKeyId:  &entity.PrimaryKey.KeyId,
+	}
+
+	config := &packet.Config{Time: func() time.Time { return creationTime }, DefaultHash: crypto.SHA256}
+	if err := selfSignature.SignUserId(uid.Id, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+		return nil, fmt.Errorf("unable to sign openpgp user id: %w", err)
+	}
+
+	entity.Identities[uid.Id] = &openpgp.Identity{
+		Name:          uid.Id,
+		UserId:        uid,
+		SelfSignature: selfSignature,
+		Signatures:    []*packet.Signature{selfSignature},
+	}
+
+	return &SigningKey{Entity: entity}, nil
+}
+
+func ParseSigningKey(in io.Reader) (*SigningKey, error) {
+	el, err := openpgp.ReadArmoredKeyRing(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(el) == 0 {
+		return nil, fmt.Errorf("no private PGP signing key entities found")
+	}
+
+	return &SigningKey{Entity: el[0]}, nil
+}
+
+func SignDataStream(detachedSignatureOut io.Writer, dataStream io.Reader, key *SigningKey) error {
+	return openpgp.DetachSign(detachedSignatureOut, key.Entity, dataStream, nil)
+}
+
+// MergeArmoredPublicKeys returns the armored key ring of the public keys in the given order.
+func MergeArmoredPublicKeys(publicKeys []string) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	armoredOut, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to prepare armored writer: %w", err)
+	}
+
+	for _, publicKey := range publicKeys {
+		el, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+		if err != nil {
+			return "", fmt.Errorf("unable to read public key: %w", err)
+		}
+
+		for _, entity := range el {
+			if err := entity.Serialize(armoredOut); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := armoredOut.Close(); err != nil {
+		return "", fmt.Errorf("unable to close armored writer: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,183 @@
+package pgp
+
+import (
+	"bytes"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPGPSigningKey(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "PGP signing key suite")
+}
+
+var _ = Describe("PGP signing key", func() {
+	BeforeEach(func() {
+		rand.Seed(time.Now().Unix())
+	})
+
+	DescribeTable("Should create detached signature of data stream then decode signature using GPG tool", func(algorithm string) {
+		key, err := GenerateSigningKey(algorithm)
+		Expect(err).NotTo(HaveOccurred())
+
+		fileContent := make([]byte, rand.Uint32()%104857600)
+		rand.Read(fileContent)
+
+		pgpSignBuf := bytes.NewBuffer(nil)
+
+		err = SignDataStream(pgpSignBuf, bytes.NewReader(fileContent), key)
+		Expect(err).NotTo(HaveOccurred())
+
+		pgpSign := pgpSignBuf.Bytes()
+
+		Expect(len(pgpSignBuf.String()) > 0).To(BeTrue())
+
+		tmpFile, err := ioutil.TempFile("", "pgp-signing-key-test-file-")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tmpFile.Name())
+		_, err = io.Copy(tmpFile, bytes.NewReader(fileContent))
+		Expect(err).NotTo(HaveOccurred())
+		err = tmpFile.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpSigFile, err := ioutil.TempFile("", "pgp-signing-key-test-file-*.sig")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tmpSigFile.Name())
+		_, err = io.Copy(tmpSigFile, bytes.NewReader(pgpSign))
+		Expect(err).NotTo(HaveOccurred())
+		err = tmpSigFile.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpPubkeyFile, err := ioutil.TempFile("", "pgp-signing-key-test-pubkey-*.gpg")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tmpPubkeyFile.Name())
+		pubkeyData := bytes.NewBuffer(nil)
+		err = key.SerializePublicKey(pubkeyData)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = io.Copy(tmpPubkeyFile, bytes.NewReader(pubkeyData.Bytes()))
+		Expect(err).NotTo(HaveOccurred())
+		err = tmpPubkeyFile.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		fmt.Printf("Importing gpg key:\n%s\n", pubkeyData)
+
+		cmd := exec.Command("gpg", "--import", tmpPubkeyFile.Name())
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		cmd = exec.Command("gpg", "--verify", tmpSigFile.Name(), tmpFile.Name())
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		Expect(err).NotTo(HaveOccurred())
+	},
+		Entry("rsa-4096", SigningKeyAlgorithmRSA4096),
+		Entry("ed25519", SigningKeyAlgorithmEd25519),
+	)
+
+	DescribeTable("Should serialize and deserialize PGP signing key into text", func(algorithm string) {
+		key, err := GenerateSigningKey(algorithm)
+		Expect(err).NotTo(HaveOccurred())
+
+		data := bytes.NewBuffer(nil)
+		err = key.SerializeFull(data)
+		Expect(err).NotTo(HaveOccurred())
+		fmt.Printf("Serialized key:\n%s\n", data.String())
+
+		newKey, err := ParseSigningKey(bytes.NewReader(data.Bytes()))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(key.Entity.PrimaryKey.KeyId).To(Equal(newKey.Entity.PrimaryKey.KeyId))
+		Expect(key.Entity.PrimaryKey.Fingerprint).To(Equal(newKey.Entity.PrimaryKey.Fingerprint))
+
+		Expect(key.Entity.PrivateKey.KeyId).To(Equal(newKey.Entity.PrivateKey.KeyId))
+		Expect(key.Entity.PrivateKey.Fingerprint).To(Equal(newKey.Entity.PrimaryKey.Fingerprint))
+
+		for k, ident := range key.Entity.Identities {
+			newIdent := newKey.Entity.Identities[k]
+			Expect(ident.UserId).To(Equal(newIdent.UserId))
+			Expect(ident.Name).To(Equal(newIdent.Name))
+		}
+	},
+		Entry("rsa-4096", SigningKeyAlgorithmRSA4096),
+		Entry("ed25519", SigningKeyAlgorithmEd25519),
+	)
+
+	It("Should reject the unsupported algorithm", func() {
+		_, err := GenerateSigningKey("dsa")
+		Expect(err).To(MatchError(ContainSubstring("unsupported algorithm")))
+	})
+
+	It("Should sign by the crypto signer keeping the same fingerprint for the same creation time", func() {
+		rsaKey, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		creationTime := time.Date(2023, 3, 15, 8, 29, 15, 0, time.UTC)
+
+		key, err := NewRSASigningKeyFromSigner(rsaKey, creationTime)
+		Expect(err).NotTo(HaveOccurred())
+
+		sameKey, err := NewRSASigningKeyFromSigner(rsaKey, creationTime)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sameKey.Entity.PrimaryKey.Fingerprint).To(Equal(key.Entity.PrimaryKey.Fingerprint))
+
+		pubkeyData := bytes.NewBuffer(nil)
+		Expect(key.SerializePublicKey(pubkeyData)).To(Succeed())
+
+		keyRing, err := openpgp.ReadArmoredKeyRing(pubkeyData)
+		Expect(err).NotTo(HaveOccurred())
+
+		content := []byte("binary")
+		signature := bytes.NewBuffer(nil)
+		Expect(SignDataStream(signature, bytes.NewReader(content), key)).To(Succeed())
+
+		signer, err := openpgp.CheckDetachedSignature(keyRing, bytes.NewReader(content), signature, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signer.PrimaryKey.Fingerprint).To(Equal(key.Entity.PrimaryKey.Fingerprint))
+	})
+
+	It("Should merge the public keys into the key ring", func() {
+		var publicKeys []string
+		var keys []*SigningKey
+		for _, algorithm := range []string{SigningKeyAlgorithmEd25519, SigningKeyAlgorithmEd25519} {
+			key, err := GenerateSigningKey(algorithm)
+			Expect(err).NotTo(HaveOccurred())
+
+			publicKey := bytes.NewBuffer(nil)
+			Expect(key.SerializePublicKey(publicKey)).To(Succeed())
+
+			keys = append(keys, key)
+			publicKeys = append(publicKeys, publicKey.String())
+		}
+
+		keyRingData, err := MergeArmoredPublicKeys(publicKeys)
+		Expect(err).NotTo(HaveOccurred())
+
+		keyRing, err := openpgp.ReadArmoredKeyRing(strings.NewReader(keyRingData))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keyRing).To(HaveLen(2))
+
+		for _, key := range keys {
+			signature := bytes.NewBuffer(nil)
+			Expect(SignDataStream(signature, strings.NewReader("binary"), key)).To(Succeed())
+
+			signer, err := openpgp.CheckDetachedSignature(keyRing, strings.NewReader("binary"), signature, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signer.PrimaryKey.Fingerprint).To(Equal(key.Entity.PrimaryKey.Fingerprint))
+		}
+	})
+})
@@ -4,25 +4,208 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/trdl/server/pkg/pgp"
+)
+
+const (
+	fieldNameMirrorName                   = "name"
+	fieldNameMirrorS3Endpoint             = "s3_endpoint"
+	fieldNameMirrorS3Region               = "s3_region"
+	fieldNameMirrorS3AccessKeyID          = "s3_access_key_id"
+	fieldNameMirrorS3SecretAccessKey      = "s3_secret_access_key"
+	fieldNameMirrorS3BucketName           = "s3_bucket_name"
+	fieldNameMirrorS3ForcePathStyle       = "s3_force_path_style"
+	fieldNameMirrorS3CACertificate        = "s3_ca_certificate"
+	fieldNameMirrorS3ServerSideEncryption = "s3_server_side_encryption"
+	fieldNameMirrorS3SSEKMSKeyID          = "s3_sse_kms_key_id"
+	fieldNameMirrorS3StorageClass         = "s3_storage_class"
+	fieldNameMirrorLocalPath              = "local_path"
+
+	fieldNameTransitVaultAddress = "vault_address"
+	fieldNameTransitVaultToken   = "vault_token"
+	fieldNameTransitMount        = "mount"
+	fieldNameTransitKeyPrefix    = "key_prefix"
+
+	fieldNamePGPSigningKeyAlgorithm       = "algorithm"
+	fieldNamePGPSigningKeyRotationPeriod  = "rotation_period"
+	fieldNamePGPSigningKeyRotationOverlap = "rotation_overlap"
 )
 
 func (publisher *Publisher) Paths() []*framework.Path {
 	return []*framework.Path{
 		{
-			Pattern:      "configure/pgp_signing_key",
-			HelpSynopsis: "Configure a PGP key for signing release artifacts",
-			Fields:       map[string]*framework.FieldSchema{},
+			Pattern:         "configure/mirrors/?",
+			HelpSynopsis:    "List repository mirrors",
+			HelpDescription: "List repository mirrors",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Description: "Get the list of repository mirrors",
+					Callback:    publisher.pathMirrorList,
+				},
+			},
+		},
+		{
+			Pattern:         "configure/mirrors/" + framework.GenericNameRegex(fieldNameMirrorName) + "$",
+			HelpSynopsis:    "Configure a repository mirror",
+			HelpDescription: "Configure the additional S3 bucket or local directory the repository is replicated to. The committed targets are replicated before the metadata referencing them. The new mirror must be filled with the mirrors/<name>/resync task. The secret access key is never returned",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameMirrorName: {
+					Type:        framework.TypeNameString,
+					Description: "Mirror name",
+					Required:    true,
+				},
+				fieldNameMirrorS3Endpoint: {
+					Type:        framework.TypeString,
+					Description: "S3 storage endpoint",
+				},
+				fieldNameMirrorS3Region: {
+					Type:        framework.TypeString,
+					Description: "S3 storage region",
+				},
+				fieldNameMirrorS3AccessKeyID: {
+					Type:        framework.TypeString,
+					Description: "S3 storage access key id",
+				},
+				fieldNameMirrorS3SecretAccessKey: {
+					Type:        framework.TypeString,
+					Description: "S3 storage secret access key",
+				},
+				fieldNameMirrorS3BucketName: {
+					Type:        framework.TypeString,
+					Description: "S3 storage bucket name (either the bucket or the local path must be set)",
+				},
+				fieldNameMirrorS3ForcePathStyle: {
+					Type:        framework.TypeBool,
+					Description: "Use path-style addressing of the S3 storage bucket. Path-style addressing is used by default for the endpoints other than AWS",
+				},
+				fieldNameMirrorS3CACertificate: {
+					Type:        framework.TypeString,
+					Description: "The PEM-encoded CA bundle the S3 storage endpoint certificate is verified with instead of the system certificates",
+				},
+				fieldNameMirrorS3ServerSideEncryption: {
+					Type:          framework.TypeString,
+					Description:   "The server-side encryption of the uploaded objects: AES256 (SSE-S3) or aws:kms (SSE-KMS). The bucket default is used if not set",
+					AllowedValues: []interface{}{s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms},
+				},
+				fieldNameMirrorS3SSEKMSKeyID: {
+					Type:        framework.TypeString,
+					Description: "The KMS key id for the aws:kms server-side encryption. The AWS managed key is used if not set",
+				},
+				fieldNameMirrorS3StorageClass: {
+					Type:        framework.TypeString,
+					Description: "The storage class of the uploaded objects, e.g. STANDARD or STANDARD_IA. The bucket default is used if not set",
+				},
+				fieldNameMirrorLocalPath: {
+					Type:        framework.TypeString,
+					Description: "Absolute path of the local directory (either the bucket or the local path must be set)",
+				},
+			},
 			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Description: "Add or update a repository mirror",
+					Callback:    publisher.pathMirrorCreateOrUpdate,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Description: "Add or update a repository mirror",
+					Callback:    publisher.pathMirrorCreateOrUpdate,
+				},
 				logical.ReadOperation: &framework.PathOperation{
-					Description: "Get the public part of the current PGP signing key",
+					Description: "Get the repository mirror and its replication status",
+					Callback:    publisher.pathMirrorRead,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Description: "Delete the repository mirror, the replicated objects are kept",
+					Callback:    publisher.pathMirrorDelete,
+				},
+			},
+		},
+		{
+			Pattern:         "configure/transit",
+			HelpSynopsis:    "Configure Vault Transit for the signing keys",
+			HelpDescription: "Configure the Vault Transit mount the new TUF repository keys and PGP signing key are created and kept in, so the private keys never leave Transit. The keys which are already stored in the plugin storage are kept. The Transit keys are pinned by their versions: to take the rotated version of the PGP key, delete the current PGP signing key or configure its rotation. The vault token is never returned",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameTransitVaultAddress: {
+					Type:        framework.TypeString,
+					Description: "Address of the Vault server with the Transit mount",
+				},
+				fieldNameTransitVaultToken: {
+					Type:         framework.TypeString,
+					Description:  "Vault token allowed to create and read <mount>/keys/<key_prefix>* and to update <mount>/sign/<key_prefix>* and, if the PGP signing key is rotated, <mount>/keys/<key_prefix>pgp/rotate",
+					DisplayAttrs: &framework.DisplayAttributes{Sensitive: true},
+				},
+				fieldNameTransitMount: {
+					Type:        framework.TypeString,
+					Description: "Path of the Transit mount",
+					Default:     DefaultTransitMount,
+				},
+				fieldNameTransitKeyPrefix: {
+					Type:        framework.TypeString,
+					Description: "Prefix of the Transit key names: <key_prefix>tuf-<role> for the TUF repository keys and <key_prefix>pgp for the PGP signing key",
+					Default:     DefaultTransitKeyPrefix,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Description: "Configure Vault Transit",
+					Callback:    publisher.pathTransitCreateOrUpdate,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Description: "Configure Vault Transit",
+					Callback:    publisher.pathTransitCreateOrUpdate,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Description: "Get the Vault Transit configuration",
+					Callback:    publisher.pathTransitRead,
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Description: "Delete the Vault Transit configuration unless the signing keys are kept in Transit",
+					Callback:    publisher.pathTransitDelete,
+				},
+			},
+		},
+		{
+			Pattern:         "configure/pgp_signing_key",
+			HelpSynopsis:    "Configure a PGP key for signing release artifacts",
+			HelpDescription: "Configure the algorithm and the rotation of the PGP signing key. The next key is created at the start of the rotation overlap and replaces the current key at the end of the rotation period. The next, current and previous public keys are published as the trdl/signing-keys.asc target, so the signatures are verified across the rotations",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNamePGPSigningKeyAlgorithm: {
+					Type:          framework.TypeString,
+					Description:   "Algorithm of the generated keys: rsa-4096 or ed25519. The keys created in Vault Transit are always rsa-4096. The current key is kept until it is rotated or deleted",
+					Default:       pgp.DefaultSigningKeyAlgorithm,
+					AllowedValues: []interface{}{pgp.SigningKeyAlgorithmRSA4096, pgp.SigningKeyAlgorithmEd25519},
+				},
+				fieldNamePGPSigningKeyRotationPeriod: {
+					Type:        framework.TypeDurationSecond,
+					Description: "Lifetime of the key after which it is replaced by the next key. The rotation is disabled if it is zero",
+				},
+				fieldNamePGPSigningKeyRotationOverlap: {
+					Type:        framework.TypeDurationSecond,
+					Description: "Time the next key is published before it replaces the current key, must be less than the rotation period",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Description: "Configure the algorithm and the rotation of the PGP signing key",
+					Callback:    publisher.pathConfigurePGPSigningKeyCreateOrUpdate,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Description: "Configure the algorithm and the rotation of the PGP signing key",
+					Callback:    publisher.pathConfigurePGPSigningKeyCreateOrUpdate,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Description: "Get the public part of the current PGP signing key and the key options",
 					Callback:    publisher.pathConfigurePGPSigningKeyRead,
 				},
 				logical.DeleteOperation: &framework.PathOperation{
 					Summary:     "Delete the current PGP signing key",
-					Description: "Delete the current PGP signing key (new key will be generated automatically on demand)",
+					Description: "Delete the current PGP signing key (new key will be generated automatically on demand, or created by the latest version of the Transit key if Vault Transit is configured)",
 					Callback:    publisher.pathConfigurePGPSigningKeyDelete,
 				},
 			},
@@ -41,11 +224,62 @@ func (publisher *Publisher) pathConfigurePGPSigningKeyRead(ctx context.Context,
 		return nil, fmt.Errorf("unable to get public key text: %w", err)
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"public_key": pk.String(),
-		},
-	}, nil
+	opts, err := GetPGPSigningKeyOptions(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	ring, err := getPGPSigningKeyRing(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"public_key":                          pk.String(),
+		"fingerprint":                         key.Fingerprint(),
+		fieldNamePGPSigningKeyAlgorithm:       opts.Algorithm,
+		fieldNamePGPSigningKeyRotationPeriod:  int64(opts.RotationPeriod.Seconds()),
+		fieldNamePGPSigningKeyRotationOverlap: int64(opts.RotationOverlap.Seconds()),
+		"next_key_created":                    ring.hasNext(),
+	}
+
+	if opts.RotationPeriod > 0 {
+		currentSince := ring.CurrentSince
+		if currentSince.IsZero() {
+			currentSince = key.CreationTime()
+		}
+		data["rotate_at"] = currentSince.Add(opts.RotationPeriod).Format(time.RFC3339)
+	}
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (publisher *Publisher) pathConfigurePGPSigningKeyCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	opts, err := GetPGPSigningKeyOptions(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// fields which are not passed are kept as is
+	if v, ok := fields.GetOk(fieldNamePGPSigningKeyAlgorithm); ok {
+		opts.Algorithm = v.(string)
+	}
+	if v, ok := fields.GetOk(fieldNamePGPSigningKeyRotationPeriod); ok {
+		opts.RotationPeriod = time.Duration(v.(int)) * time.Second
+	}
+	if v, ok := fields.GetOk(fieldNamePGPSigningKeyRotationOverlap); ok {
+		opts.RotationOverlap = time.Duration(v.(int)) * time.Second
+	}
+
+	if err := opts.Validate(); err != nil {
+		return logical.ErrorResponse("pgp signing key validation failed: %s", err), nil
+	}
+
+	if err := PutPGPSigningKeyOptions(ctx, req.Storage, opts); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
 }
 
 func (publisher *Publisher) pathConfigurePGPSigningKeyDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
@@ -54,3 +288,178 @@ func (publisher *Publisher) pathConfigurePGPSigningKeyDelete(ctx context.Context
 	}
 	return &logical.Response{Data: map[string]interface{}{}}, nil
 }
+
+func (publisher *Publisher) pathTransitCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	existing, err := GetTransitOptions(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get transit options: %w", err)
+	}
+
+	// fields which are not passed are kept as is
+	opts := TransitOptions{Mount: DefaultTransitMount, KeyPrefix: DefaultTransitKeyPrefix}
+	if existing != nil {
+		opts = *existing
+	}
+
+	for fieldName, value := range map[string]*string{
+		fieldNameTransitVaultAddress: &opts.VaultAddress,
+		fieldNameTransitVaultToken:   &opts.VaultToken,
+		fieldNameTransitMount:        &opts.Mount,
+		fieldNameTransitKeyPrefix:    &opts.KeyPrefix,
+	} {
+		if v, ok := fields.GetOk(fieldName); ok {
+			*value = v.(string)
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return logical.ErrorResponse("transit validation failed: %s", err), nil
+	}
+
+	if err := PutTransitOptions(ctx, req.Storage, opts); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (publisher *Publisher) pathTransitRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	opts, err := GetTransitOptions(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get transit options: %w", err)
+	}
+	if opts == nil {
+		return logical.ErrorResponse("transit not configured"), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			fieldNameTransitVaultAddress: opts.VaultAddress,
+			fieldNameTransitMount:        opts.Mount,
+			fieldNameTransitKeyPrefix:    opts.KeyPrefix,
+			"vault_token_set":            opts.VaultToken != "",
+		},
+	}, nil
+}
+
+func (publisher *Publisher) pathTransitDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	inUse, err := transitKeysInUse(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return logical.ErrorResponse("transit is in use: the signing keys are kept in Vault Transit"), nil
+	}
+
+	if err := DeleteTransitOptions(ctx, req.Storage); err != nil {
+		return nil, fmt.Errorf("error delete transit options: %w", err)
+	}
+	return nil, nil
+}
+
+func (publisher *Publisher) pathMirrorCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(fieldNameMirrorName).(string)
+
+	existing, err := GetMirror(ctx, req.Storage, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get mirror: %w", err)
+	}
+
+	// fields which are not passed are kept as is
+	opts := MirrorOptions{Name: name}
+	if existing != nil {
+		opts = *existing
+	}
+
+	for fieldName, value := range map[string]*string{
+		fieldNameMirrorS3Endpoint:             &opts.S3Endpoint,
+		fieldNameMirrorS3Region:               &opts.S3Region,
+		fieldNameMirrorS3AccessKeyID:          &opts.S3AccessKeyID,
+		fieldNameMirrorS3SecretAccessKey:      &opts.S3SecretAccessKey,
+		fieldNameMirrorS3BucketName:           &opts.S3BucketName,
+		fieldNameMirrorS3CACertificate:        &opts.S3CACertificate,
+		fieldNameMirrorS3ServerSideEncryption: &opts.S3ServerSideEncryption,
+		fieldNameMirrorS3SSEKMSKeyID:          &opts.S3SSEKMSKeyID,
+		fieldNameMirrorS3StorageClass:         &opts.S3StorageClass,
+		fieldNameMirrorLocalPath:              &opts.LocalPath,
+	} {
+		if v, ok := fields.GetOk(fieldName); ok {
+			*value = v.(string)
+		}
+	}
+	if v, ok := fields.GetOk(fieldNameMirrorS3ForcePathStyle); ok {
+		opts.S3ForcePathStyle = v.(bool)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return logical.ErrorResponse("mirror validation failed: %s", err), nil
+	}
+
+	if err := PutMirror(ctx, req.Storage, opts); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (publisher *Publisher) pathMirrorList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	list, err := GetMirrors(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list mirrors: %w", err)
+	}
+
+	keys := make([]string, 0, len(list))
+	keysInfo := make(map[string]interface{}, len(list))
+	for _, opts := range list {
+		keys = append(keys, opts.Name)
+		keysInfo[opts.Name] = mirrorData(opts)
+	}
+
+	return logical.ListResponseWithInfo(keys, keysInfo), nil
+}
+
+func (publisher *Publisher) pathMirrorRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(fieldNameMirrorName).(string)
+
+	opts, err := GetMirror(ctx, req.Storage, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get mirror: %w", err)
+	}
+	if opts == nil {
+		return logical.ErrorResponse("mirror %q not found", name), nil
+	}
+
+	status, err := GetMirrorStatus(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data := mirrorData(*opts)
+	data[fieldNameMirrorName] = opts.Name
+	data["status"] = structs.Map(status)
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (publisher *Publisher) pathMirrorDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if err := DeleteMirror(ctx, req.Storage, fields.Get(fieldNameMirrorName).(string)); err != nil {
+		return nil, fmt.Errorf("error delete mirror: %w", err)
+	}
+	return nil, nil
+}
+
+func mirrorData(opts MirrorOptions) map[string]interface{} {
+	return map[string]interface{}{
+		fieldNameMirrorS3Endpoint:             opts.S3Endpoint,
+		fieldNameMirrorS3Region:               opts.S3Region,
+		fieldNameMirrorS3AccessKeyID:          opts.S3AccessKeyID,
+		fieldNameMirrorS3BucketName:           opts.S3BucketName,
+		fieldNameMirrorS3ForcePathStyle:       opts.S3ForcePathStyle,
+		fieldNameMirrorS3CACertificate:        opts.S3CACertificate,
+		fieldNameMirrorS3ServerSideEncryption: opts.S3ServerSideEncryption,
+		fieldNameMirrorS3SSEKMSKeyID:          opts.S3SSEKMSKeyID,
+		fieldNameMirrorS3StorageClass:         opts.S3StorageClass,
+		fieldNameMirrorLocalPath:              opts.LocalPath,
+		"s3_secret_access_key_set":            opts.S3SecretAccessKey != "",
+	}
+}
@@ -0,0 +1,107 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/theupdateframework/go-tuf/sign"
+)
+
+// EnableConsistentSnapshot migrates the existing repository to consistent snapshots: the targets are copied
+// under the hash-prefixed names, then root.json is updated and the metadata is committed with the version-prefixed copies.
+// The objects stored under the plain target names are kept for the clients which have not received the updated root.json yet.
+// Returns false if the repository already uses consistent snapshots.
+func (repository *S3Repository) EnableConsistentSnapshot(ctx context.Context) (bool, error) {
+	if err := repository.reloadTufRepo(); err != nil {
+		return false, err
+	}
+
+	rootSigned, err := repository.TufRepo.SignedMeta("root.json")
+	if err != nil {
+		return false, fmt.Errorf("unable to get root.json: %w", err)
+	}
+
+	rootJSON, err := json.Marshal(rootSigned)
+	if err != nil {
+		return false, fmt.Errorf("unable to marshal root.json: %w", err)
+	}
+
+	root, err := decodeRoot(rootJSON)
+	if err != nil {
+		return false, err
+	}
+
+	if root.ConsistentSnapshot {
+		return false, nil
+	}
+
+	signers, err := repository.TufStore.GetSigners("root")
+	if err != nil {
+		return false, fmt.Errorf("unable to get root signers: %w", err)
+	}
+	if len(signers) == 0 {
+		return false, fmt.Errorf("unable to update root.json: the root keys are kept offline")
+	}
+
+	// the clients use the hash-prefixed names right after receiving the updated root.json, so the targets are copied first
+	targets, err := repository.TufRepo.Targets()
+	if err != nil {
+		return false, fmt.Errorf("unable to get targets: %w", err)
+	}
+
+	var objectPaths []string
+	for _, targetPath := range sortedTargetPaths(targets) {
+		for _, objectPath := range targetObjectPaths(true, targetPath, targets[targetPath].Hashes) {
+			if err := copyObject(ctx, repository.TufStore.Filesystem, path.Join("targets", targetPath), repository.TufStore.Filesystem, objectPath); err != nil {
+				return false, fmt.Errorf("unable to copy target %q to %q: %w", targetPath, objectPath, err)
+			}
+
+			objectPaths = append(objectPaths, objectPath)
+		}
+	}
+
+	root.ConsistentSnapshot = true
+	root.Version++
+	updatedRootSigned, err := sign.Marshal(root, signers...)
+	if err != nil {
+		return false, fmt.Errorf("unable to sign root.json: %w", err)
+	}
+
+	updatedRootJSON, err := json.Marshal(updatedRootSigned)
+	if err != nil {
+		return false, fmt.Errorf("unable to marshal root.json: %w", err)
+	}
+
+	if err := repository.TufStore.SetMeta("root.json", updatedRootJSON); err != nil {
+		return false, err
+	}
+
+	// the targets metadata is staged again to be committed under the version-prefixed name referenced by the snapshot
+	if err := repository.reloadTufRepo(); err != nil {
+		return false, err
+	}
+
+	targetsExpires, err := repository.TufRepo.TargetsExpires()
+	if err != nil {
+		return false, fmt.Errorf("unable to get targets.json expiration time: %w", err)
+	}
+
+	if err := repository.TufRepo.IncrementTargetsVersionWithExpires(targetsExpires); err != nil {
+		return false, fmt.Errorf("unable to update targets.json: %w", err)
+	}
+	if err := repository.TufRepo.Snapshot(); err != nil {
+		return false, fmt.Errorf("tuf repo snapshot failed: %w", err)
+	}
+	if err := repository.TufRepo.Timestamp(); err != nil {
+		return false, fmt.Errorf("tuf repo timestamp failed: %w", err)
+	}
+	if err := repository.TufRepo.Commit(); err != nil {
+		return false, fmt.Errorf("unable to commit consistent snapshot migration into the repo: %w", err)
+	}
+
+	repository.replicate(ctx, objectPaths)
+
+	return true, nil
+}
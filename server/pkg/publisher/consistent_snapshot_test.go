@@ -0,0 +1,154 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+var _ = Describe("Consistent snapshots", func() {
+	var ctx context.Context
+	var fs *testMemoryFilesystem
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fs = &testMemoryFilesystem{files: map[string][]byte{}}
+	})
+
+	newRepository := func(consistentSnapshot bool) *S3Repository {
+		store := NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+
+		repository := &S3Repository{TufStore: store, TufRepo: tufRepo, logger: hclog.NewNullLogger(), initConsistentSnapshot: consistentSnapshot}
+		Expect(repository.Init()).To(Succeed())
+		Expect(repository.GenPrivKeys()).To(Succeed())
+
+		return repository
+	}
+
+	publish := func(repository *S3Repository, targets map[string]string) {
+		for target, data := range targets {
+			Expect(repository.StageTarget(ctx, target, strings.NewReader(data))).To(Succeed())
+		}
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+	}
+
+	// expectClientDownloads checks the repository by the go-tuf client trusting the first root.json
+	expectClientDownloads := func(targets map[string]string) {
+		tufClient := client.NewClient(client.MemoryLocalStore(), &testRemoteStore{fs: fs})
+		Expect(tufClient.Init(fs.files["1.root.json"])).To(Succeed())
+
+		_, err := tufClient.Update()
+		Expect(err).To(Succeed())
+
+		for target, data := range targets {
+			dest := &testDestination{}
+			Expect(tufClient.Download(target, dest)).To(Succeed())
+			Expect(dest.String()).To(Equal(data))
+		}
+	}
+
+	It("should store the targets under the hash-prefixed names and the metadata under the version-prefixed names", func() {
+		repository := newRepository(true)
+		targets := map[string]string{"channels/1/stable": "1.0.0\n"}
+		publish(repository, targets)
+
+		Expect(fs.files).NotTo(HaveKey("targets/channels/1/stable"))
+		Expect(fs.files).To(HaveKey("1.root.json"))
+		Expect(fs.files).To(HaveKey("1.targets.json"))
+		Expect(fs.files).To(HaveKey("1.snapshot.json"))
+
+		expectClientDownloads(targets)
+
+		report, err := repository.Verify(ctx)
+		Expect(err).To(Succeed())
+		Expect(report.Failed()).To(BeFalse())
+		Expect(report.VerifiedTargets).To(Equal(1))
+		Expect(report.UnreferencedObjects).To(BeEmpty())
+	})
+
+	It("should migrate the existing repository", func() {
+		repository := newRepository(false)
+		targets := map[string]string{"channels/1/stable": "1.0.0\n", "releases/1.0.0/linux-amd64/bin/app": "binary"}
+		publish(repository, targets)
+		Expect(fs.files).NotTo(HaveKey("1.targets.json"))
+
+		migrated, err := repository.EnableConsistentSnapshot(ctx)
+		Expect(err).To(Succeed())
+		Expect(migrated).To(BeTrue())
+
+		Expect(fs.files).To(HaveKey("2.root.json"))
+		expectClientDownloads(targets)
+
+		// the objects under the plain names are kept for the clients not updated yet
+		report, err := repository.Verify(ctx)
+		Expect(err).To(Succeed())
+		Expect(report.Failed()).To(BeFalse())
+		Expect(report.VerifiedTargets).To(Equal(2))
+		Expect(report.UnreferencedObjects).To(ConsistOf("targets/channels/1/stable", "targets/releases/1.0.0/linux-amd64/bin/app"))
+
+		// the following publications use the hash-prefixed names
+		targets["channels/1/stable"] = "1.0.1\n"
+		publish(repository, targets)
+		expectClientDownloads(targets)
+
+		migrated, err = repository.EnableConsistentSnapshot(ctx)
+		Expect(err).To(Succeed())
+		Expect(migrated).To(BeFalse())
+	})
+
+	It("should not commit the targets staged before the migration", func() {
+		repository := newRepository(false)
+		publish(repository, map[string]string{"channels/1/stable": "1.0.0\n"})
+
+		Expect(repository.StageTarget(ctx, "channels/1/stable", strings.NewReader("1.0.1\n"))).To(Succeed())
+
+		migrationStore := NewNonAtomicTufStore(repository.GetPrivKeys(), fs, hclog.NewNullLogger())
+		Expect(repository.GetPrivKeys().SetupStoreSigners(migrationStore)).To(Succeed())
+		migrationRepository := &S3Repository{TufStore: migrationStore, TufRepo: repository.TufRepo, logger: hclog.NewNullLogger()}
+		Expect(migrationRepository.EnableConsistentSnapshot(ctx)).To(BeTrue())
+
+		Expect(repository.CommitStaged(ctx)).To(MatchError(ContainSubstring("retry the staging")))
+	})
+})
+
+// testRemoteStore serves the repository to the go-tuf client.
+type testRemoteStore struct {
+	fs *testMemoryFilesystem
+}
+
+func (s *testRemoteStore) GetMeta(name string) (io.ReadCloser, int64, error) {
+	return s.get(name)
+}
+
+func (s *testRemoteStore) GetTarget(p string) (io.ReadCloser, int64, error) {
+	return s.get(path.Join("targets", p))
+}
+
+func (s *testRemoteStore) get(p string) (io.ReadCloser, int64, error) {
+	data, ok := s.fs.files[p]
+	if !ok {
+		return nil, 0, client.ErrNotFound{File: p}
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+type testDestination struct {
+	bytes.Buffer
+}
+
+func (d *testDestination) Delete() error {
+	d.Reset()
+	return nil
+}
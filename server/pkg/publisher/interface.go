@@ -3,6 +3,7 @@ package publisher
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 
@@ -14,10 +15,14 @@ type Interface interface {
 	GetRepository(ctx context.Context, storage logical.Storage, options RepositoryOptions) (RepositoryInterface, error)
 	RotateRepositoryKeys(ctx context.Context, storage logical.Storage, repository RepositoryInterface, systemClock util.Clock) error
 	UpdateTimestamps(ctx context.Context, storage logical.Storage, repository RepositoryInterface, systemClock util.Clock) error
+	RotatePGPSigningKey(ctx context.Context, storage logical.Storage, repository RepositoryInterface, systemClock util.Clock) error
 	StageReleaseTarget(ctx context.Context, repository RepositoryInterface, releaseName, path string, data io.Reader) error
 	StageChannelsConfig(ctx context.Context, repository RepositoryInterface, trdlChannelsConfig *config.TrdlChannels) error
 	StageInMemoryFiles(ctx context.Context, repository RepositoryInterface, files []*InMemoryFile) error
 	GetExistingReleases(ctx context.Context, repository RepositoryInterface) ([]string, error)
+	GetPGPSigningKeyFingerprint(ctx context.Context, storage logical.Storage) (string, error)
+	PrepareRoot(ctx context.Context, storage logical.Storage, repository RepositoryInterface, offlineRoot *OfflineRootOptions) (*PendingRoot, error)
+	PublishRoot(ctx context.Context, storage logical.Storage, repository RepositoryInterface) error
 }
 
 type RepositoryInterface interface {
@@ -25,9 +30,19 @@ type RepositoryInterface interface {
 	SetPrivKeys(privKeys TufRepoPrivKeys) error
 	GetPrivKeys() TufRepoPrivKeys
 	GenPrivKeys() error
+	GenOnlinePrivKeys() error
 	RotatePrivKeys(ctx context.Context) (bool, TufRepoPrivKeys, error)
 	UpdateTimestamps(ctx context.Context, systemClock util.Clock) error
 	StageTarget(ctx context.Context, pathInsideTargets string, data io.Reader) error
 	CommitStaged(ctx context.Context) error
 	GetTargets(ctx context.Context) ([]string, error)
+	GetVersions(ctx context.Context) (map[string]int64, error)
+	GetRolesMetadata(ctx context.Context) (map[string]RoleMetadata, error)
+	Verify(ctx context.Context) (*VerificationReport, error)
+	EnableConsistentSnapshot(ctx context.Context) (bool, error)
+	ResyncMirror(ctx context.Context, name string) error
+	TakeMirrorReplications() []MirrorReplication
+	PrepareRoot(ctx context.Context, offlineRoot *OfflineRootOptions, expires time.Time) (*PendingRoot, error)
+	PublishRoot(ctx context.Context, pendingRoot *PendingRoot) error
+	OfflineRootRenewalDue(ctx context.Context, now time.Time) (bool, error)
 }
@@ -0,0 +1,148 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// LocalFilesystem stores the repository in the local directory, e.g. the directory served by the web server.
+type LocalFilesystem struct {
+	Root string
+
+	logger hclog.Logger
+}
+
+func NewLocalFilesystem(root string, logger hclog.Logger) *LocalFilesystem {
+	return &LocalFilesystem{Root: root, logger: logger}
+}
+
+func (fs *LocalFilesystem) filePath(path string) string {
+	return filepath.Join(fs.Root, filepath.FromSlash(path))
+}
+
+func (fs *LocalFilesystem) IsFileExist(_ context.Context, path string) (bool, error) {
+	if _, err := os.Stat(fs.filePath(path)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking file %q: %w", path, err)
+	}
+
+	return true, nil
+}
+
+func (fs *LocalFilesystem) ReadFile(_ context.Context, path string, writerAt io.WriterAt) error {
+	data, err := os.ReadFile(fs.filePath(path))
+	if err != nil {
+		return fmt.Errorf("unable to read file %q: %w", path, err)
+	}
+
+	if _, err := writerAt.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("unable to write file %q data: %w", path, err)
+	}
+
+	return nil
+}
+
+func (fs *LocalFilesystem) ReadFileStream(_ context.Context, path string, writer io.Writer) error {
+	f, err := os.Open(fs.filePath(path))
+	if err != nil {
+		return fmt.Errorf("unable to open file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	numBytes, err := io.Copy(writer, f)
+	if err != nil {
+		return fmt.Errorf("unable to read file %q: %w", path, err)
+	}
+
+	fs.logger.Debug(fmt.Sprintf("-- LocalFilesystem.ReadFileStream read %q %d bytes", path, numBytes))
+
+	return nil
+}
+
+func (fs *LocalFilesystem) ReadFileBytes(_ context.Context, path string) ([]byte, error) {
+	data, err := os.ReadFile(fs.filePath(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %q: %w", path, err)
+	}
+
+	return data, nil
+}
+
+func (fs *LocalFilesystem) WriteFileBytes(ctx context.Context, path string, data []byte) error {
+	return fs.WriteFileStream(ctx, path, bytes.NewReader(data))
+}
+
+// WriteFileStream replaces the file atomically, so the served file is never partially written.
+func (fs *LocalFilesystem) WriteFileStream(_ context.Context, path string, data io.Reader) error {
+	filePath := fs.filePath(path)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("unable to create directory for %q: %w", path, err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-"+filepath.Base(filePath)+"-")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file for %q: %w", path, err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := io.Copy(tmpFile, data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("error writing %q: %w", path, err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("error writing %q: %w", path, err)
+	}
+
+	if err := os.Chmod(tmpFile.Name(), 0o644); err != nil {
+		return fmt.Errorf("unable to set permissions of %q: %w", path, err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		return fmt.Errorf("unable to replace %q: %w", path, err)
+	}
+
+	fs.logger.Debug(fmt.Sprintf("-- LocalFilesystem.WriteFileStream written %q", path))
+
+	return nil
+}
+
+func (fs *LocalFilesystem) ListFiles(_ context.Context, prefix string) ([]string, error) {
+	var paths []string
+	if err := filepath.WalkDir(fs.Root, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == fs.Root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(fs.Root, filePath)
+		if err != nil {
+			return err
+		}
+
+		if path := filepath.ToSlash(relPath); strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to list files by prefix %q: %w", prefix, err)
+	}
+
+	return paths, nil
+}
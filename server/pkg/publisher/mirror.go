@@ -0,0 +1,329 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	storageKeyPrefixMirror       = "mirror/"
+	storageKeyPrefixMirrorStatus = "mirror_status/"
+)
+
+// MirrorOptions configure the additional bucket or local directory the repository is replicated to.
+type MirrorOptions struct {
+	Name string `json:"-"`
+
+	S3Endpoint        string `json:"s3_endpoint,omitempty"`
+	S3Region          string `json:"s3_region,omitempty"`
+	S3AccessKeyID     string `json:"s3_access_key_id,omitempty"`
+	S3SecretAccessKey string `json:"s3_secret_access_key,omitempty"`
+	S3BucketName      string `json:"s3_bucket_name,omitempty"`
+	// The extended S3 options have the same meaning as the options of the repository bucket.
+	S3ForcePathStyle       bool   `json:"s3_force_path_style,omitempty"`
+	S3CACertificate        string `json:"s3_ca_certificate,omitempty"`
+	S3ServerSideEncryption string `json:"s3_server_side_encryption,omitempty"`
+	S3SSEKMSKeyID          string `json:"s3_sse_kms_key_id,omitempty"`
+	S3StorageClass         string `json:"s3_storage_class,omitempty"`
+
+	LocalPath string `json:"local_path,omitempty"`
+}
+
+func (opts MirrorOptions) Validate() error {
+	switch {
+	case opts.S3BucketName != "" && opts.LocalPath != "":
+		return fmt.Errorf("either s3 bucket or local path must be set, not both")
+	case opts.S3BucketName != "":
+		if opts.S3Endpoint == "" || opts.S3Region == "" {
+			return fmt.Errorf("s3 endpoint and region must be set")
+		}
+
+		if err := opts.repositoryOptions().ValidateS3(); err != nil {
+			return err
+		}
+	case opts.LocalPath != "":
+		if !path.IsAbs(opts.LocalPath) {
+			return fmt.Errorf("local path %q must be absolute", opts.LocalPath)
+		}
+	default:
+		return fmt.Errorf("either s3 bucket or local path must be set")
+	}
+
+	return nil
+}
+
+func (opts MirrorOptions) newFilesystem(logger hclog.Logger) Filesystem {
+	if opts.LocalPath != "" {
+		return NewLocalFilesystem(opts.LocalPath, logger)
+	}
+
+	return opts.repositoryOptions().s3Options().newFilesystem(logger)
+}
+
+// repositoryOptions returns the S3 options of the mirror bucket, so the mirror filesystem is built the same way as the repository one.
+func (opts MirrorOptions) repositoryOptions() RepositoryOptions {
+	return RepositoryOptions{
+		S3Endpoint:        opts.S3Endpoint,
+		S3Region:          opts.S3Region,
+		S3AccessKeyID:     opts.S3AccessKeyID,
+		S3SecretAccessKey: opts.S3SecretAccessKey,
+		S3BucketName:      opts.S3BucketName,
+		S3ForcePathStyle:  opts.S3ForcePathStyle,
+		S3CACertificate:   opts.S3CACertificate,
+		S3UploadOptions: S3UploadOptions{
+			ServerSideEncryption: opts.S3ServerSideEncryption,
+			SSEKMSKeyID:          opts.S3SSEKMSKeyID,
+			StorageClass:         opts.S3StorageClass,
+		},
+	}
+}
+
+func newAwsConfig(endpoint, region, accessKeyID, secretAccessKey string) *aws.Config {
+	return &aws.Config{
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""),
+	}
+}
+
+type repositoryMirror struct {
+	name       string
+	filesystem Filesystem
+}
+
+// MirrorReplication is the result of the replication of the repository changes to the mirror.
+type MirrorReplication struct {
+	Mirror string
+	Time   time.Time
+	// Resync is set if all objects of the repository are replicated.
+	Resync bool
+	Err    error
+}
+
+// MirrorStatus is the persisted state of the mirror replication.
+type MirrorStatus struct {
+	// Synced is set by the successful resync and reset by any failed replication,
+	// because the changes which are not replicated are not retried by the following replications.
+	Synced      bool       `json:"synced" structs:"synced"`
+	LastAttempt time.Time  `json:"last_attempt" structs:"last_attempt"`
+	LastSuccess *time.Time `json:"last_success,omitempty" structs:"last_success"`
+	LastError   string     `json:"last_error,omitempty" structs:"last_error"`
+}
+
+// replicateObjects copies the objects in the order given, so the targets must precede the metadata referencing them.
+func replicateObjects(ctx context.Context, src, dst Filesystem, paths []string) error {
+	for _, p := range paths {
+		if err := copyObject(ctx, src, p, dst, p); err != nil {
+			return fmt.Errorf("unable to replicate %q: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// copyObject streams the object without keeping it in memory.
+func copyObject(ctx context.Context, src Filesystem, srcPath string, dst Filesystem, dstPath string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(src.ReadFileStream(ctx, srcPath, writer))
+	}()
+
+	err := dst.WriteFileStream(ctx, dstPath, reader)
+	reader.Close()
+
+	return err
+}
+
+var versionedMetadataRegexp = regexp.MustCompile(`^(\d+)\.(.+)$`)
+
+// sortMetadataPaths orders the metadata so that the metadata is replicated before the metadata referencing it:
+// root, targets, snapshot and then timestamp, the versioned copies precede the unversioned metadata of the role.
+func sortMetadataPaths(paths []string) {
+	roleRank := func(name string) int {
+		for i, manifest := range topLevelManifests {
+			if name == manifest {
+				return i
+			}
+		}
+		return len(topLevelManifests)
+	}
+
+	type sortKey struct {
+		role    int
+		latest  int
+		version int64
+		name    string
+	}
+
+	key := func(p string) sortKey {
+		if m := versionedMetadataRegexp.FindStringSubmatch(p); m != nil {
+			version, _ := strconv.ParseInt(m[1], 10, 64)
+			return sortKey{role: roleRank(m[2]), version: version, name: p}
+		}
+		return sortKey{role: roleRank(p), latest: 1, name: p}
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		iKey, jKey := key(paths[i]), key(paths[j])
+		switch {
+		case iKey.role != jKey.role:
+			return iKey.role < jKey.role
+		case iKey.latest != jKey.latest:
+			return iKey.latest < jKey.latest
+		case iKey.version != jKey.version:
+			return iKey.version < jKey.version
+		default:
+			return iKey.name < jKey.name
+		}
+	})
+}
+
+func mirrorStorageKey(name string) string {
+	return storageKeyPrefixMirror + name
+}
+
+func mirrorStatusStorageKey(name string) string {
+	return storageKeyPrefixMirrorStatus + name
+}
+
+func PutMirror(ctx context.Context, storage logical.Storage, opts MirrorOptions) error {
+	entry, err := logical.StorageEntryJSON(mirrorStorageKey(opts.Name), opts)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put mirror: %w", err)
+	}
+
+	return nil
+}
+
+// GetMirror returns nil if the mirror does not exist.
+func GetMirror(ctx context.Context, storage logical.Storage, name string) (*MirrorOptions, error) {
+	e, err := storage.Get(ctx, mirrorStorageKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, nil
+	}
+
+	var opts MirrorOptions
+	if err := json.Unmarshal(e.Value, &opts); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal mirror %q: %w", name, err)
+	}
+	opts.Name = name
+
+	return &opts, nil
+}
+
+func GetMirrors(ctx context.Context, storage logical.Storage) ([]MirrorOptions, error) {
+	list, err := storage.List(ctx, storageKeyPrefixMirror)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(list)
+
+	var mirrors []MirrorOptions
+	for _, name := range list {
+		opts, err := GetMirror(ctx, storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if opts == nil {
+			continue
+		}
+
+		mirrors = append(mirrors, *opts)
+	}
+
+	return mirrors, nil
+}
+
+func DeleteMirror(ctx context.Context, storage logical.Storage, name string) error {
+	if err := storage.Delete(ctx, mirrorStorageKey(name)); err != nil {
+		return err
+	}
+
+	return storage.Delete(ctx, mirrorStatusStorageKey(name))
+}
+
+// GetMirrorStatus returns the status of the mirror which is never replicated as not synced.
+func GetMirrorStatus(ctx context.Context, storage logical.Storage, name string) (MirrorStatus, error) {
+	var status MirrorStatus
+
+	e, err := storage.Get(ctx, mirrorStatusStorageKey(name))
+	if err != nil {
+		return status, err
+	}
+	if e == nil {
+		return status, nil
+	}
+
+	if err := json.Unmarshal(e.Value, &status); err != nil {
+		return status, fmt.Errorf("unable to unmarshal mirror %q status: %w", name, err)
+	}
+
+	return status, nil
+}
+
+// SaveMirrorReplications updates the statuses of the mirrors by the results of the replications.
+// The replications of the deleted mirrors are ignored.
+func SaveMirrorReplications(ctx context.Context, storage logical.Storage, replications []MirrorReplication) error {
+	for _, replication := range replications {
+		opts, err := GetMirror(ctx, storage, replication.Mirror)
+		if err != nil {
+			return fmt.Errorf("unable to get mirror %q: %w", replication.Mirror, err)
+		}
+		if opts == nil {
+			continue
+		}
+
+		status, err := GetMirrorStatus(ctx, storage, replication.Mirror)
+		if err != nil {
+			return err
+		}
+
+		status.LastAttempt = replication.Time
+		if replication.Err != nil {
+			status.Synced = false
+			status.LastError = replication.Err.Error()
+		} else {
+			replicationTime := replication.Time
+			status.LastSuccess = &replicationTime
+			status.LastError = ""
+			if replication.Resync {
+				status.Synced = true
+			}
+		}
+
+		entry, err := logical.StorageEntryJSON(mirrorStatusStorageKey(replication.Mirror), status)
+		if err != nil {
+			return fmt.Errorf("error creating storage json entry: %w", err)
+		}
+
+		if err := storage.Put(ctx, entry); err != nil {
+			return fmt.Errorf("unable to put mirror %q status: %w", replication.Mirror, err)
+		}
+	}
+
+	return nil
+}
+
+func isTargetPath(p string) bool {
+	return strings.HasPrefix(p, "targets/")
+}
@@ -0,0 +1,189 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/theupdateframework/go-tuf"
+)
+
+var _ = Describe("Repository mirrors", func() {
+	var ctx context.Context
+	var primary *testMemoryFilesystem
+	var repository *S3Repository
+	var mirrorDir string
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		primary = &testMemoryFilesystem{files: map[string][]byte{}}
+		store := NewNonAtomicTufStore(TufRepoPrivKeys{}, primary, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+		Expect(tufRepo.Init(false)).To(Succeed())
+		for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+			_, err := tufRepo.GenKey(role)
+			Expect(err).To(Succeed())
+		}
+
+		repository = &S3Repository{TufStore: store, TufRepo: tufRepo, logger: hclog.NewNullLogger()}
+
+		mirrorDir = GinkgoT().TempDir()
+		repository.SetMirrors([]MirrorOptions{{Name: "local", LocalPath: mirrorDir}})
+	})
+
+	It("should replicate the committed targets and metadata", func() {
+		Expect(repository.StageTarget(ctx, "channels/1/stable", strings.NewReader("1.0.0\n"))).To(Succeed())
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(mirrorDir, "targets", "channels", "1", "stable"))
+		Expect(err).To(Succeed())
+		Expect(string(data)).To(Equal("1.0.0\n"))
+
+		for _, name := range []string{"1.root.json", "root.json", "targets.json", "snapshot.json", "timestamp.json"} {
+			data, err := os.ReadFile(filepath.Join(mirrorDir, name))
+			Expect(err).To(Succeed())
+			Expect(data).To(Equal(primary.files[name]))
+		}
+
+		replications := repository.TakeMirrorReplications()
+		Expect(replications).To(HaveLen(1))
+		Expect(replications[0].Mirror).To(Equal("local"))
+		Expect(replications[0].Resync).To(BeFalse())
+		Expect(replications[0].Err).To(Succeed())
+		Expect(repository.TakeMirrorReplications()).To(BeEmpty())
+	})
+
+	It("should record the failed replication without failing the commit", func() {
+		repository.mirrors = append(repository.mirrors, repositoryMirror{name: "broken", filesystem: &testFailingFilesystem{testMemoryFilesystem: testMemoryFilesystem{files: map[string][]byte{}}}})
+
+		Expect(repository.StageTarget(ctx, "channels/1/stable", strings.NewReader("1.0.0\n"))).To(Succeed())
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+
+		replications := repository.TakeMirrorReplications()
+		Expect(replications).To(HaveLen(2))
+		Expect(replications[0].Err).To(Succeed())
+		Expect(replications[1].Mirror).To(Equal("broken"))
+		Expect(replications[1].Err).To(HaveOccurred())
+	})
+
+	It("should resync the drifted mirror", func() {
+		Expect(repository.StageTarget(ctx, "channels/1/stable", strings.NewReader("1.0.0\n"))).To(Succeed())
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+		repository.TakeMirrorReplications()
+
+		Expect(os.RemoveAll(filepath.Join(mirrorDir, "targets"))).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(mirrorDir, "timestamp.json"), []byte("stale"), 0o644)).To(Succeed())
+
+		Expect(repository.ResyncMirror(ctx, "local")).To(Succeed())
+
+		localFilesystem := NewLocalFilesystem(mirrorDir, hclog.NewNullLogger())
+		files, err := localFilesystem.ListFiles(ctx, "")
+		Expect(err).To(Succeed())
+		primaryFiles, err := primary.ListFiles(ctx, "")
+		Expect(err).To(Succeed())
+		Expect(files).To(ConsistOf(primaryFiles))
+
+		for _, name := range primaryFiles {
+			data, err := localFilesystem.ReadFileBytes(ctx, name)
+			Expect(err).To(Succeed())
+			Expect(data).To(Equal(primary.files[name]))
+		}
+
+		replications := repository.TakeMirrorReplications()
+		Expect(replications).To(HaveLen(1))
+		Expect(replications[0].Resync).To(BeTrue())
+
+		Expect(repository.ResyncMirror(ctx, "unknown")).To(MatchError(`mirror "unknown" not found`))
+	})
+
+	It("should sort the metadata by the replication order", func() {
+		paths := []string{"timestamp.json", "snapshot.json", "root.json", "10.root.json", "targets.json", "2.root.json"}
+		sortMetadataPaths(paths)
+		Expect(paths).To(Equal([]string{"2.root.json", "10.root.json", "root.json", "targets.json", "snapshot.json", "timestamp.json"}))
+	})
+
+	It("should keep the mirror not synced after the failure until the resync", func() {
+		storage := &logical.InmemStorage{}
+		Expect(PutMirror(ctx, storage, MirrorOptions{Name: "local", LocalPath: mirrorDir})).To(Succeed())
+
+		now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+		status := func(replications ...MirrorReplication) MirrorStatus {
+			Expect(SaveMirrorReplications(ctx, storage, replications)).To(Succeed())
+			status, err := GetMirrorStatus(ctx, storage, "local")
+			Expect(err).To(Succeed())
+			return status
+		}
+
+		Expect(status(MirrorReplication{Mirror: "local", Time: now, Resync: true}).Synced).To(BeTrue())
+
+		failed := status(MirrorReplication{Mirror: "local", Time: now.Add(time.Hour), Err: errors.New("access denied")})
+		Expect(failed.Synced).To(BeFalse())
+		Expect(failed.LastError).To(Equal("access denied"))
+		Expect(*failed.LastSuccess).To(Equal(now))
+
+		succeeded := status(MirrorReplication{Mirror: "local", Time: now.Add(2 * time.Hour)})
+		Expect(succeeded.Synced).To(BeFalse())
+		Expect(succeeded.LastError).To(BeEmpty())
+		Expect(*succeeded.LastSuccess).To(Equal(now.Add(2 * time.Hour)))
+
+		Expect(status(MirrorReplication{Mirror: "local", Time: now.Add(3 * time.Hour), Resync: true}).Synced).To(BeTrue())
+
+		// the replications of the deleted mirror are ignored
+		Expect(SaveMirrorReplications(ctx, storage, []MirrorReplication{{Mirror: "deleted", Time: now}})).To(Succeed())
+		keys, err := storage.List(ctx, storageKeyPrefixMirrorStatus)
+		Expect(err).To(Succeed())
+		Expect(keys).To(Equal([]string{"local"}))
+	})
+
+	It("should validate the mirror options", func() {
+		Expect(MirrorOptions{LocalPath: "/srv/trdl"}.Validate()).To(Succeed())
+		Expect(MirrorOptions{S3Endpoint: "https://s3.eu", S3Region: "eu", S3BucketName: "trdl"}.Validate()).To(Succeed())
+		Expect(MirrorOptions{}.Validate()).To(HaveOccurred())
+		Expect(MirrorOptions{LocalPath: "relative"}.Validate()).To(HaveOccurred())
+		Expect(MirrorOptions{LocalPath: "/srv/trdl", S3BucketName: "trdl"}.Validate()).To(HaveOccurred())
+		Expect(MirrorOptions{S3BucketName: "trdl"}.Validate()).To(HaveOccurred())
+		Expect(MirrorOptions{S3Endpoint: "https://s3.eu", S3Region: "eu", S3BucketName: "trdl", S3ServerSideEncryption: "rot13"}.Validate()).To(MatchError(ContainSubstring("unsupported server side encryption")))
+		Expect(MirrorOptions{S3Endpoint: "https://s3.eu", S3Region: "eu", S3BucketName: "trdl", S3CACertificate: "garbage"}.Validate()).To(MatchError(ContainSubstring("invalid s3 ca certificate")))
+	})
+
+	It("should build the mirror bucket filesystem with the extended S3 options", func() {
+		fs := MirrorOptions{
+			S3Endpoint:             "https://s3.amazonaws.com",
+			S3Region:               "eu-central-1",
+			S3AccessKeyID:          "id",
+			S3SecretAccessKey:      "secret",
+			S3BucketName:           "trdl",
+			S3ForcePathStyle:       true,
+			S3CACertificate:        "CA",
+			S3ServerSideEncryption: "aws:kms",
+			S3SSEKMSKeyID:          "key",
+			S3StorageClass:         "STANDARD_IA",
+		}.newFilesystem(hclog.NewNullLogger())
+
+		Expect(fs).To(BeAssignableToTypeOf(&S3Filesystem{}))
+		s3fs := fs.(*S3Filesystem)
+		Expect(s3fs.BucketName).To(Equal("trdl"))
+		Expect(*s3fs.AwsConfig.S3ForcePathStyle).To(BeTrue())
+		Expect(s3fs.CACertificate).To(Equal("CA"))
+		Expect(s3fs.UploadOptions).To(Equal(S3UploadOptions{ServerSideEncryption: "aws:kms", SSEKMSKeyID: "key", StorageClass: "STANDARD_IA"}))
+	})
+})
+
+type testFailingFilesystem struct {
+	testMemoryFilesystem
+}
+
+func (fs *testFailingFilesystem) WriteFileStream(_ context.Context, _ string, reader io.Reader) error {
+	_, _ = io.Copy(io.Discard, reader)
+	return errors.New("access denied")
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"path"
+	"sort"
+	"sync"
 
 	"github.com/djherbis/buffer"
 	"github.com/djherbis/nio/v3"
@@ -21,9 +24,20 @@ type NonAtomicTufStore struct {
 	Filesystem Filesystem
 	PrivKeys   TufRepoPrivKeys
 
-	stagedMeta  map[string]json.RawMessage
+	stagedMeta map[string]json.RawMessage
+
+	// stagingMu guards the staged files and the consistent snapshot mode, the targets are staged concurrently
+	stagingMu   sync.Mutex
 	stagedFiles []string
-	logger      hclog.Logger
+	// stagedFilesMeta are computed while uploading, so the staged files are not read back to be registered
+	stagedFilesMeta map[string]stagedTargetFile
+	logger          hclog.Logger
+
+	// consistentSnapshot is loaded from root.json on the first staging and updated by the commits
+	consistentSnapshot *bool
+
+	// committedMetadataPaths are written by the commits since the last TakeCommittedMetadataPaths call
+	committedMetadataPaths []string
 
 	signerForKeyID map[string]keys.Signer
 	keyIDsForRole  map[string][]string
@@ -31,15 +45,25 @@ type NonAtomicTufStore struct {
 
 func NewNonAtomicTufStore(privKeys TufRepoPrivKeys, filesystem Filesystem, logger hclog.Logger) *NonAtomicTufStore {
 	return &NonAtomicTufStore{
-		Filesystem:     filesystem,
-		PrivKeys:       privKeys,
-		stagedMeta:     make(map[string]json.RawMessage),
-		logger:         logger,
-		signerForKeyID: make(map[string]keys.Signer),
-		keyIDsForRole:  make(map[string][]string),
+		Filesystem:      filesystem,
+		PrivKeys:        privKeys,
+		stagedMeta:      make(map[string]json.RawMessage),
+		stagedFilesMeta: make(map[string]stagedTargetFile),
+		logger:          logger,
+		signerForKeyID:  make(map[string]keys.Signer),
+		keyIDsForRole:   make(map[string][]string),
 	}
 }
 
+// targetHashAlgorithm is the go-tuf default algorithm of the target hashes.
+const targetHashAlgorithm = "sha512"
+
+type stagedTargetFile struct {
+	meta               data.FileMeta
+	objectPaths        []string
+	consistentSnapshot bool
+}
+
 var topLevelManifests = []string{
 	"root.json",
 	"targets.json",
@@ -114,9 +138,18 @@ func (store *NonAtomicTufStore) WalkStagedTargets(targetPathList []string, targe
 		return reader
 	}
 
+	// the staged files are read by the first object paths, the lock is not held while walking
+	store.stagingMu.Lock()
+	stagedFiles := append([]string(nil), store.stagedFiles...)
+	stagedObjectPaths := make(map[string]string, len(stagedFiles))
+	for _, filePath := range stagedFiles {
+		stagedObjectPaths[filePath] = store.stagedFilesMeta[filePath].objectPaths[0]
+	}
+	store.stagingMu.Unlock()
+
 	if len(targetPathList) == 0 {
-		for _, filePath := range store.stagedFiles {
-			if err := targetsFn(filePath, runPipedFileReader(path.Join("targets", filePath))); err != nil {
+		for _, filePath := range stagedFiles {
+			if err := targetsFn(filePath, runPipedFileReader(stagedObjectPaths[filePath])); err != nil {
 				return err
 			}
 		}
@@ -126,9 +159,9 @@ func (store *NonAtomicTufStore) WalkStagedTargets(targetPathList []string, targe
 
 FilterStagedPaths:
 	for _, targetPath := range targetPathList {
-		for _, stagedPath := range store.stagedFiles {
+		for _, stagedPath := range stagedFiles {
 			if stagedPath == targetPath {
-				if err := targetsFn(targetPath, runPipedFileReader(path.Join("targets", targetPath))); err != nil {
+				if err := targetsFn(targetPath, runPipedFileReader(stagedObjectPaths[targetPath])); err != nil {
 					return err
 				}
 
@@ -145,22 +178,118 @@ FilterStagedPaths:
 func (store *NonAtomicTufStore) StageTargetFile(ctx context.Context, targetPath string, data io.Reader) error {
 	store.logger.Debug(fmt.Sprintf("-- NonAtomicTufStore.StageTargetFile %q", targetPath))
 
-	// NOTE: consistenSnapshot cannot be supported when adding staged files before commit stage
+	store.stagingMu.Lock()
+	consistentSnapshot, err := store.isConsistentSnapshot()
+	store.stagingMu.Unlock()
+	if err != nil {
+		return err
+	}
 
-	if err := store.Filesystem.WriteFileStream(ctx, path.Join("targets", targetPath), data); err != nil {
-		return fmt.Errorf("error writing %q into the store filesystem: %w", targetPath, err)
+	metaWriter := newFileMetaWriter([]string{targetHashAlgorithm})
+
+	var objectPaths []string
+	if consistentSnapshot {
+		// the hash-prefixed names are known only after reading the data, so the data is spooled to the temporary file
+		tmpFile, err := os.CreateTemp("", "trdl-target-*")
+		if err != nil {
+			return fmt.Errorf("unable to create temporary file: %w", err)
+		}
+		defer os.Remove(tmpFile.Name())
+		defer tmpFile.Close()
+
+		if _, err := io.Copy(io.MultiWriter(tmpFile, metaWriter), data); err != nil {
+			return fmt.Errorf("error spooling %q: %w", targetPath, err)
+		}
+
+		objectPaths = targetObjectPaths(true, targetPath, metaWriter.FileMeta().Hashes)
+		for _, objectPath := range objectPaths {
+			if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("unable to rewind temporary file: %w", err)
+			}
+
+			if err := store.Filesystem.WriteFileStream(ctx, objectPath, tmpFile); err != nil {
+				return fmt.Errorf("error writing %q into the store filesystem: %w", objectPath, err)
+			}
+		}
+	} else {
+		objectPaths = targetObjectPaths(false, targetPath, nil)
+		if err := store.Filesystem.WriteFileStream(ctx, objectPaths[0], io.TeeReader(data, metaWriter)); err != nil {
+			return fmt.Errorf("error writing %q into the store filesystem: %w", targetPath, err)
+		}
 	}
 
+	store.stagingMu.Lock()
+	defer store.stagingMu.Unlock()
+
 	store.stagedFiles = append(store.stagedFiles, targetPath)
+	store.stagedFilesMeta[targetPath] = stagedTargetFile{
+		meta:               metaWriter.FileMeta(),
+		objectPaths:        objectPaths,
+		consistentSnapshot: consistentSnapshot,
+	}
 
 	return nil
 }
 
+// StagedTargetFileMeta returns the length and hashes of the staged file computed while uploading.
+func (store *NonAtomicTufStore) StagedTargetFileMeta(targetPath string) (data.FileMeta, bool) {
+	store.stagingMu.Lock()
+	defer store.stagingMu.Unlock()
+
+	stagedFile, ok := store.stagedFilesMeta[targetPath]
+	return stagedFile.meta, ok
+}
+
+// StagedTargetObjectPaths returns the paths of the objects written by the staging of the files.
+func (store *NonAtomicTufStore) StagedTargetObjectPaths() []string {
+	store.stagingMu.Lock()
+	defer store.stagingMu.Unlock()
+
+	var paths []string
+	for _, targetPath := range store.stagedFiles {
+		paths = append(paths, store.stagedFilesMeta[targetPath].objectPaths...)
+	}
+
+	return lo.Uniq[string](paths)
+}
+
+// isConsistentSnapshot returns false for the repository which is not initialized yet.
+func (store *NonAtomicTufStore) isConsistentSnapshot() (bool, error) {
+	if store.consistentSnapshot != nil {
+		return *store.consistentSnapshot, nil
+	}
+
+	meta, err := store.GetMeta()
+	if err != nil {
+		return false, err
+	}
+
+	rootJSON, ok := meta["root.json"]
+	if !ok {
+		return false, nil
+	}
+
+	root, err := decodeRoot(rootJSON)
+	if err != nil {
+		return false, err
+	}
+	store.consistentSnapshot = &root.ConsistentSnapshot
+
+	return root.ConsistentSnapshot, nil
+}
+
 func (store *NonAtomicTufStore) Commit(consistentSnapshot bool, versions map[string]int64, _ map[string]data.Hashes) error {
 	store.logger.Debug("-- NonAtomicTufStore.Commit")
-	if consistentSnapshot {
-		panic("not supported")
+
+	// the targets are uploaded on staging, so they are not available by the names of the other mode
+	store.stagingMu.Lock()
+	for _, targetPath := range store.stagedFiles {
+		if store.stagedFilesMeta[targetPath].consistentSnapshot != consistentSnapshot {
+			store.stagingMu.Unlock()
+			return fmt.Errorf("target %q is staged before the repository consistent snapshot mode is changed, retry the staging", targetPath)
+		}
 	}
+	store.stagingMu.Unlock()
 
 	ctx := context.Background()
 
@@ -173,15 +302,30 @@ func (store *NonAtomicTufStore) Commit(consistentSnapshot bool, versions map[str
 			if err := store.Filesystem.WriteFileBytes(ctx, metadataPath, data); err != nil {
 				return fmt.Errorf("error writing metadata path %q into the filesystem: %w", metadataPath, err)
 			}
+
+			store.committedMetadataPaths = append(store.committedMetadataPaths, metadataPath)
 		}
 	}
 
+	store.stagingMu.Lock()
 	store.stagedFiles = nil
+	store.stagedFilesMeta = make(map[string]stagedTargetFile)
+	store.consistentSnapshot = &consistentSnapshot
+	store.stagingMu.Unlock()
+
 	store.stagedMeta = make(map[string]json.RawMessage)
 
 	return nil
 }
 
+// TakeCommittedMetadataPaths returns the metadata paths written by the commits and resets the list.
+func (store *NonAtomicTufStore) TakeCommittedMetadataPaths() []string {
+	paths := lo.Uniq[string](store.committedMetadataPaths)
+	store.committedMetadataPaths = nil
+
+	return paths
+}
+
 func (store *NonAtomicTufStore) FileIsStaged(filename string) bool {
 	_, ok := store.stagedMeta[filename]
 	return ok
@@ -243,11 +387,9 @@ func (m *NonAtomicTufStore) Clean() error {
 	panic("not supported")
 }
 
+// computeMetadataPaths returns the paths of the metadata: root.json is also stored under the version-prefixed name
+// and so are the other metadata except timestamp.json with consistent snapshots.
 func computeMetadataPaths(consistentSnapshot bool, name string, versions map[string]int64) []string {
-	if consistentSnapshot {
-		panic("not supported")
-	}
-
 	copyVersion := false
 
 	switch name {
@@ -256,7 +398,7 @@ func computeMetadataPaths(consistentSnapshot bool, name string, versions map[str
 	case "timestamp.json":
 		copyVersion = false
 	default:
-		copyVersion = false
+		copyVersion = consistentSnapshot
 	}
 
 	paths := []string{name}
@@ -266,3 +408,30 @@ func computeMetadataPaths(consistentSnapshot bool, name string, versions map[str
 
 	return paths
 }
+
+// targetObjectPaths returns the paths of the target objects: the hash-prefixed names with consistent snapshots.
+func targetObjectPaths(consistentSnapshot bool, targetPath string, hashes data.Hashes) []string {
+	objectPath := path.Join("targets", targetPath)
+	if !consistentSnapshot {
+		return []string{objectPath}
+	}
+
+	paths := util.HashedPaths(objectPath, hashes)
+	sort.Strings(paths)
+
+	return paths
+}
+
+func decodeRoot(rootJSON []byte) (*data.Root, error) {
+	signed := &data.Signed{}
+	if err := json.Unmarshal(rootJSON, signed); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal root.json: %w", err)
+	}
+
+	root := &data.Root{}
+	if err := json.Unmarshal(signed.Signed, root); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal root.json: %w", err)
+	}
+
+	return root, nil
+}
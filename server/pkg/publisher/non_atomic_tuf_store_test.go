@@ -0,0 +1,138 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/util"
+)
+
+var _ = Describe("TUF store staging", func() {
+	It("should register the staged targets by the hashes computed while uploading", func() {
+		ctx := context.Background()
+		fs := &testReadRecordingFilesystem{testMemoryFilesystem: testMemoryFilesystem{files: map[string][]byte{}}}
+		store := NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+		Expect(tufRepo.Init(false)).To(Succeed())
+		for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+			_, err := tufRepo.GenKey(role)
+			Expect(err).To(Succeed())
+		}
+
+		repository := &S3Repository{TufStore: store, TufRepo: tufRepo, logger: hclog.NewNullLogger()}
+
+		targetData := map[string]string{
+			"releases/1.0.0/linux-amd64/bin/app": strings.Repeat("binary", 1024),
+			"channels/1/stable":                  "1.0.0\n",
+		}
+		for target, data := range targetData {
+			Expect(repository.StageTarget(ctx, target, strings.NewReader(data))).To(Succeed())
+		}
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+
+		for _, p := range fs.readPaths {
+			Expect(isTargetPath(p)).To(BeFalse(), "staged target %q is read back", p)
+		}
+
+		targets, err := repository.TufRepo.Targets()
+		Expect(err).To(Succeed())
+		Expect(targets).To(HaveLen(len(targetData)))
+
+		for target, data := range targetData {
+			expected, err := util.GenerateTargetFileMeta(strings.NewReader(data))
+			Expect(err).To(Succeed())
+			Expect(targets[target]).To(Equal(expected))
+		}
+	})
+
+	It("should stage the targets concurrently", func() {
+		ctx := context.Background()
+		fs := &testSyncFilesystem{testMemoryFilesystem: testMemoryFilesystem{files: map[string][]byte{}}}
+		store := NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+
+		repository := &S3Repository{TufStore: store, TufRepo: tufRepo, logger: hclog.NewNullLogger(), initConsistentSnapshot: true}
+		Expect(repository.Init()).To(Succeed())
+		Expect(repository.GenPrivKeys()).To(Succeed())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				target := fmt.Sprintf("releases/1.0.0/linux-amd64/bin/app-%d", i)
+				Expect(repository.StageTarget(ctx, target, strings.NewReader(target))).To(Succeed())
+
+				// the staged files are read while the other targets are staged
+				_, ok := store.StagedTargetFileMeta(target)
+				Expect(ok).To(BeTrue())
+				Expect(store.StagedTargetObjectPaths()).NotTo(BeEmpty())
+			}(i)
+		}
+		wg.Wait()
+		Expect(store.StagedTargetObjectPaths()).To(HaveLen(8))
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+
+		targets, err := repository.GetTargets(ctx)
+		Expect(err).To(Succeed())
+		Expect(targets).To(HaveLen(8))
+	})
+})
+
+// testSyncFilesystem serializes the writes and the metadata reads of the concurrent stagings.
+type testSyncFilesystem struct {
+	testMemoryFilesystem
+	mu sync.Mutex
+}
+
+func (fs *testSyncFilesystem) IsFileExist(ctx context.Context, path string) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.testMemoryFilesystem.IsFileExist(ctx, path)
+}
+
+func (fs *testSyncFilesystem) ReadFileBytes(ctx context.Context, path string) ([]byte, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.testMemoryFilesystem.ReadFileBytes(ctx, path)
+}
+
+func (fs *testSyncFilesystem) WriteFileStream(ctx context.Context, path string, reader io.Reader) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.testMemoryFilesystem.WriteFileStream(ctx, path, reader)
+}
+
+// testReadRecordingFilesystem records the paths of the streamed files.
+type testReadRecordingFilesystem struct {
+	testMemoryFilesystem
+	readPaths []string
+}
+
+func (fs *testReadRecordingFilesystem) ReadFileStream(ctx context.Context, path string, writer io.Writer) error {
+	fs.readPaths = append(fs.readPaths, path)
+	return fs.testMemoryFilesystem.ReadFileStream(ctx, path, writer)
+}
+
+func (fs *testReadRecordingFilesystem) ReadFile(ctx context.Context, path string, writer io.WriterAt) error {
+	fs.readPaths = append(fs.readPaths, path)
+	return fs.testMemoryFilesystem.ReadFile(ctx, path, writer)
+}
+
+func (fs *testReadRecordingFilesystem) ReadFileBytes(ctx context.Context, path string) ([]byte, error) {
+	fs.readPaths = append(fs.readPaths, path)
+	return fs.testMemoryFilesystem.ReadFileBytes(ctx, path)
+}
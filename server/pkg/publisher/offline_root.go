@@ -0,0 +1,452 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/secure-systems-lab/go-securesystemslib/cjson"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/pkg/keys"
+	"github.com/theupdateframework/go-tuf/verify"
+)
+
+const storageKeyPendingRoot = "pending_root"
+
+var (
+	ErrUnsignedRoot        = errors.New("root.json is not signed: it is published once the pending root.json is signed by the offline root keys")
+	ErrStalePendingRoot    = errors.New("root.json is changed since the pending root.json is prepared: prepare it again")
+	ErrPendingRootNotFound = errors.New("pending root.json not found")
+)
+
+// OfflineRootOptions are the root role keys which are kept offline: the plugin has no root key,
+// root.json is signed by the threshold of these keys through the pending root.json.
+type OfflineRootOptions struct {
+	PublicKeys []*data.PublicKey
+	Threshold  int
+}
+
+// ParseOfflineRootPublicKeys parses the public keys in the TUF format, e.g. {"keytype": "ed25519", "scheme": "ed25519", "keyval": {"public": "<hex>"}}.
+func ParseOfflineRootPublicKeys(rawKeys []string) ([]*data.PublicKey, error) {
+	var publicKeys []*data.PublicKey
+	for i, raw := range rawKeys {
+		publicKey := &data.PublicKey{}
+		if err := json.Unmarshal([]byte(raw), publicKey); err != nil {
+			return nil, fmt.Errorf("unable to parse public key #%d: %w", i+1, err)
+		}
+
+		if _, err := keys.GetVerifier(publicKey); err != nil {
+			return nil, fmt.Errorf("invalid public key #%d: %w", i+1, err)
+		}
+
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	return publicKeys, nil
+}
+
+func (opts OfflineRootOptions) Validate() error {
+	ids := map[string]bool{}
+	for _, publicKey := range opts.PublicKeys {
+		id := publicKey.IDs()[0]
+		if ids[id] {
+			return fmt.Errorf("duplicate public key %s", id)
+		}
+		ids[id] = true
+	}
+
+	if opts.Threshold < 1 || opts.Threshold > len(opts.PublicKeys) {
+		return fmt.Errorf("threshold must be between 1 and the number of the public keys %d", len(opts.PublicKeys))
+	}
+
+	return nil
+}
+
+// PendingRoot is the new root.json waiting for the signatures of the offline root keys.
+type PendingRoot struct {
+	// Signed is the canonical JSON of the root.json metadata the signatures are made over.
+	Signed     json.RawMessage  `json:"signed"`
+	Signatures []data.Signature `json:"signatures"`
+	// PreviousKeys and PreviousThreshold are the root role of the published root.json,
+	// the clients trust the new root.json only if it is also signed by the threshold of the previous keys.
+	PreviousKeys      []*data.PublicKey `json:"previous_keys,omitempty"`
+	PreviousThreshold int               `json:"previous_threshold,omitempty"`
+}
+
+func (pendingRoot *PendingRoot) Root() (*data.Root, error) {
+	root := &data.Root{}
+	if err := json.Unmarshal(pendingRoot.Signed, root); err != nil {
+		return nil, fmt.Errorf("unable to decode pending root.json: %w", err)
+	}
+
+	return root, nil
+}
+
+// RootKeys returns the root role keys of the pending root.json.
+func (pendingRoot *PendingRoot) RootKeys() ([]*data.PublicKey, int, error) {
+	root, err := pendingRoot.Root()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	role, ok := root.Roles["root"]
+	if !ok {
+		return nil, 0, fmt.Errorf("no root role in pending root.json")
+	}
+
+	var publicKeys []*data.PublicKey
+	for _, id := range role.KeyIDs {
+		if publicKey, ok := root.Keys[id]; ok {
+			publicKeys = append(publicKeys, publicKey)
+		}
+	}
+
+	return publicKeys, role.Threshold, nil
+}
+
+// AddSignature verifies the signature of the root key of the pending or the published root.json and replaces the previous signature of the key.
+func (pendingRoot *PendingRoot) AddSignature(signature data.Signature) error {
+	rootKeys, _, err := pendingRoot.RootKeys()
+	if err != nil {
+		return err
+	}
+
+	var publicKey *data.PublicKey
+	for _, k := range append(rootKeys, pendingRoot.PreviousKeys...) {
+		if k.ContainsID(signature.KeyID) {
+			publicKey = k
+			break
+		}
+	}
+	if publicKey == nil {
+		return fmt.Errorf("key %s is not a root key of the pending or the published root.json", signature.KeyID)
+	}
+
+	verifier, err := keys.GetVerifier(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid key %s: %w", signature.KeyID, err)
+	}
+
+	if err := verify.VerifySignature(pendingRoot.Signed, signature.Signature, verifier); err != nil {
+		return fmt.Errorf("invalid signature of key %s: %w", signature.KeyID, err)
+	}
+
+	signatures := []data.Signature{signature}
+	for _, s := range pendingRoot.Signatures {
+		if s.KeyID != signature.KeyID {
+			signatures = append(signatures, s)
+		}
+	}
+	pendingRoot.Signatures = signatures
+
+	return nil
+}
+
+// MissingSignatures returns the number of the signatures missing to meet the thresholds of the pending and the published root.json.
+func (pendingRoot *PendingRoot) MissingSignatures() (int, int, error) {
+	rootKeys, threshold, err := pendingRoot.RootKeys()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return pendingRoot.missingSignatures(rootKeys, threshold), pendingRoot.missingSignatures(pendingRoot.PreviousKeys, pendingRoot.PreviousThreshold), nil
+}
+
+func (pendingRoot *PendingRoot) missingSignatures(publicKeys []*data.PublicKey, threshold int) int {
+	signed := 0
+	for _, publicKey := range publicKeys {
+		for _, signature := range pendingRoot.Signatures {
+			if publicKey.ContainsID(signature.KeyID) {
+				signed++
+				break
+			}
+		}
+	}
+
+	if signed >= threshold {
+		return 0
+	}
+	return threshold - signed
+}
+
+// newPendingRoot prepares the root.json based on the current one, the root role is replaced if offlineRoot is set.
+func newPendingRoot(current *data.Root, published *data.Root, offlineRoot *OfflineRootOptions, expires time.Time) (*PendingRoot, error) {
+	root := *current
+	root.Keys = make(map[string]*data.PublicKey, len(current.Keys))
+	for id, publicKey := range current.Keys {
+		root.Keys[id] = publicKey
+	}
+	root.Roles = make(map[string]*data.Role, len(current.Roles))
+	for name, role := range current.Roles {
+		roleCopy := *role
+		root.Roles[name] = &roleCopy
+	}
+
+	pendingRoot := &PendingRoot{}
+
+	root.Version = 1
+	if published != nil {
+		root.Version = published.Version + 1
+
+		if role, ok := published.Roles["root"]; ok {
+			for _, id := range role.KeyIDs {
+				if publicKey, ok := published.Keys[id]; ok {
+					pendingRoot.PreviousKeys = append(pendingRoot.PreviousKeys, publicKey)
+				}
+			}
+			pendingRoot.PreviousThreshold = role.Threshold
+		}
+	}
+
+	if offlineRoot != nil {
+		if role, ok := root.Roles["root"]; ok {
+			for _, id := range role.KeyIDs {
+				if !isKeyUsedByRoles(root.Roles, "root", id) {
+					delete(root.Keys, id)
+				}
+			}
+		}
+
+		role := &data.Role{KeyIDs: []string{}, Threshold: offlineRoot.Threshold}
+		for _, publicKey := range offlineRoot.PublicKeys {
+			root.AddKey(publicKey)
+			role.AddKeyIDs(publicKey.IDs())
+		}
+		root.Roles["root"] = role
+	}
+
+	root.Expires = expires.Round(time.Second)
+
+	signed, err := cjson.EncodeCanonical(&root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode root.json: %w", err)
+	}
+	pendingRoot.Signed = signed
+
+	return pendingRoot, nil
+}
+
+func isKeyUsedByRoles(roles map[string]*data.Role, exceptRole, id string) bool {
+	for name, role := range roles {
+		if name == exceptRole {
+			continue
+		}
+
+		for _, keyID := range role.KeyIDs {
+			if keyID == id {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// GetPendingRoot returns nil if there is no pending root.json.
+func GetPendingRoot(ctx context.Context, storage logical.Storage) (*PendingRoot, error) {
+	e, err := storage.Get(ctx, storageKeyPendingRoot)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, nil
+	}
+
+	var pendingRoot PendingRoot
+	if err := json.Unmarshal(e.Value, &pendingRoot); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal pending root.json: %w", err)
+	}
+
+	return &pendingRoot, nil
+}
+
+func PutPendingRoot(ctx context.Context, storage logical.Storage, pendingRoot *PendingRoot) error {
+	entry, err := logical.StorageEntryJSON(storageKeyPendingRoot, pendingRoot)
+	if err != nil {
+		return fmt.Errorf("unable to create pending root.json storage entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put pending root.json: %w", err)
+	}
+
+	return nil
+}
+
+func DeletePendingRoot(ctx context.Context, storage logical.Storage) error {
+	return storage.Delete(ctx, storageKeyPendingRoot)
+}
+
+// PrepareRoot returns the new root.json based on the staged or the published one, it is signed by the online root keys if any.
+func (repository *S3Repository) PrepareRoot(ctx context.Context, offlineRoot *OfflineRootOptions, expires time.Time) (*PendingRoot, error) {
+	if err := repository.reloadTufRepo(); err != nil {
+		return nil, err
+	}
+
+	currentSigned, err := repository.TufRepo.SignedMeta("root.json")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get root.json: %w", err)
+	}
+
+	current := &data.Root{}
+	if err := json.Unmarshal(currentSigned.Signed, current); err != nil {
+		return nil, fmt.Errorf("unable to decode root.json: %w", err)
+	}
+
+	published, err := repository.publishedRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingRoot, err := newPendingRoot(current, published, offlineRoot, expires)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, err := repository.TufStore.GetSigners("root")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get root signers: %w", err)
+	}
+
+	for _, signer := range signers {
+		signature, err := signer.SignMessage(pendingRoot.Signed)
+		if err != nil {
+			return nil, fmt.Errorf("unable to sign root.json: %w", err)
+		}
+
+		// the online key is neither in the pending nor in the published root.json if it is not published yet
+		_ = pendingRoot.AddSignature(data.Signature{KeyID: signer.PublicData().IDs()[0], Signature: signature})
+	}
+
+	return pendingRoot, nil
+}
+
+// PublishRoot commits the pending root.json signed by the thresholds of the pending and the published root keys.
+func (repository *S3Repository) PublishRoot(ctx context.Context, pendingRoot *PendingRoot) error {
+	missing, previousMissing, err := pendingRoot.MissingSignatures()
+	if err != nil {
+		return err
+	}
+	if missing > 0 || previousMissing > 0 {
+		return fmt.Errorf("pending root.json requires %d more signatures of the new root keys and %d more signatures of the published root keys", missing, previousMissing)
+	}
+
+	root, err := pendingRoot.Root()
+	if err != nil {
+		return err
+	}
+
+	published, err := repository.publishedRoot(ctx)
+	if err != nil {
+		return err
+	}
+
+	var publishedVersion int64
+	if published != nil {
+		publishedVersion = published.Version
+	}
+	if root.Version != publishedVersion+1 {
+		return ErrStalePendingRoot
+	}
+
+	rootJSON, err := json.Marshal(&data.Signed{Signed: pendingRoot.Signed, Signatures: pendingRoot.Signatures})
+	if err != nil {
+		return fmt.Errorf("unable to marshal root.json: %w", err)
+	}
+
+	if err := repository.TufStore.SetMeta("root.json", rootJSON); err != nil {
+		return err
+	}
+
+	if err := repository.reloadTufRepo(); err != nil {
+		return err
+	}
+
+	// the new repository has no targets.json yet
+	if published == nil {
+		if err := repository.TufRepo.IncrementTargetsVersionWithExpires(data.DefaultExpires("targets")); err != nil {
+			return fmt.Errorf("unable to create targets.json: %w", err)
+		}
+	}
+
+	if err := repository.TufRepo.Snapshot(); err != nil {
+		return fmt.Errorf("tuf repo snapshot failed: %w", err)
+	}
+	if err := repository.TufRepo.Timestamp(); err != nil {
+		return fmt.Errorf("tuf repo timestamp failed: %w", err)
+	}
+	if err := repository.TufRepo.Commit(); err != nil {
+		return fmt.Errorf("unable to commit root.json into the repo: %w", err)
+	}
+
+	repository.replicate(ctx, nil)
+
+	return nil
+}
+
+// OfflineRootRenewalDue returns true if root.json is signed by the offline keys only and expires within a month,
+// the month is given to collect the signatures of the offline keys.
+func (repository *S3Repository) OfflineRootRenewalDue(_ context.Context, now time.Time) (bool, error) {
+	if err := repository.reloadTufRepo(); err != nil {
+		return false, err
+	}
+
+	if hasSigners, err := repository.hasRootSigners(); err != nil || hasSigners {
+		return false, err
+	}
+
+	expiresAt, err := repository.TufRepo.RootExpires()
+	if err != nil {
+		return false, fmt.Errorf("unable to get root.json expiration time: %w", err)
+	}
+
+	return !now.Before(expiresAt.AddDate(0, -1, 0)), nil
+}
+
+func (repository *S3Repository) hasRootSigners() (bool, error) {
+	signers, err := repository.TufStore.GetSigners("root")
+	if err != nil {
+		return false, fmt.Errorf("unable to get root signers: %w", err)
+	}
+
+	return len(signers) > 0, nil
+}
+
+// checkRootSigned fails if the staged root.json is not signed, because the root keys are offline.
+func (repository *S3Repository) checkRootSigned() error {
+	if !repository.TufStore.FileIsStaged("root.json") {
+		return nil
+	}
+
+	signed, err := repository.TufRepo.SignedMeta("root.json")
+	if err != nil {
+		return fmt.Errorf("unable to get root.json: %w", err)
+	}
+
+	if len(signed.Signatures) == 0 {
+		return ErrUnsignedRoot
+	}
+
+	return nil
+}
+
+// publishedRoot returns nil if root.json is not published yet.
+func (repository *S3Repository) publishedRoot(ctx context.Context) (*data.Root, error) {
+	exists, err := repository.TufStore.Filesystem.IsFileExist(ctx, "root.json")
+	if err != nil {
+		return nil, fmt.Errorf("error checking existence of root.json: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rootJSON, err := repository.TufStore.Filesystem.ReadFileBytes(ctx, "root.json")
+	if err != nil {
+		return nil, fmt.Errorf("error reading root.json: %w", err)
+	}
+
+	return decodeRoot(rootJSON)
+}
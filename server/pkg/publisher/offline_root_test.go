@@ -0,0 +1,214 @@
+package publisher
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/pkg/keys"
+
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+var _ = Describe("Offline root keys", func() {
+	var ctx context.Context
+	var storage logical.Storage
+	var fs *testMemoryFilesystem
+	var publisher *Publisher
+	var offlineSigners []keys.Signer
+	var offlineRoot *OfflineRootOptions
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		storage = &logical.InmemStorage{}
+		fs = &testMemoryFilesystem{files: map[string][]byte{}}
+		publisher = NewPublisher(hclog.NewNullLogger())
+
+		offlineSigners = nil
+		offlineRoot = &OfflineRootOptions{Threshold: 2}
+		for i := 0; i < 3; i++ {
+			signer, err := keys.GenerateEd25519Key()
+			Expect(err).To(Succeed())
+
+			offlineSigners = append(offlineSigners, signer)
+			offlineRoot.PublicKeys = append(offlineRoot.PublicKeys, signer.PublicData())
+		}
+		Expect(offlineRoot.Validate()).To(Succeed())
+	})
+
+	newRepository := func(opts setRepositoryKeysOptions) *S3Repository {
+		store := NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+
+		repository := &S3Repository{TufStore: store, TufRepo: tufRepo, logger: hclog.NewNullLogger()}
+		Expect(repository.Init()).To(Succeed())
+		Expect(publisher.setRepositoryKeys(ctx, storage, repository, opts)).To(Succeed())
+
+		return repository
+	}
+
+	publish := func(targets map[string]string) {
+		repository := newRepository(setRepositoryKeysOptions{})
+		for target, data := range targets {
+			Expect(repository.StageTarget(ctx, target, strings.NewReader(data))).To(Succeed())
+		}
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+	}
+
+	signPendingRoot := func(signers ...keys.Signer) *PendingRoot {
+		pendingRoot, err := GetPendingRoot(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(pendingRoot).NotTo(BeNil())
+
+		for _, signer := range signers {
+			signature, err := signer.SignMessage(pendingRoot.Signed)
+			Expect(err).To(Succeed())
+			Expect(pendingRoot.AddSignature(data.Signature{KeyID: signer.PublicData().IDs()[0], Signature: signature})).To(Succeed())
+		}
+		Expect(PutPendingRoot(ctx, storage, pendingRoot)).To(Succeed())
+
+		return pendingRoot
+	}
+
+	expectClientDownloads := func(trustedRoot []byte, targets map[string]string) {
+		tufClient := client.NewClient(client.MemoryLocalStore(), &testRemoteStore{fs: fs})
+		Expect(tufClient.Init(trustedRoot)).To(Succeed())
+
+		_, err := tufClient.Update()
+		Expect(err).To(Succeed())
+
+		for target, data := range targets {
+			dest := &testDestination{}
+			Expect(tufClient.Download(target, dest)).To(Succeed())
+			Expect(dest.String()).To(Equal(data))
+		}
+	}
+
+	It("should publish the new repository once root.json is signed by the threshold of the offline keys", func() {
+		repository := newRepository(setRepositoryKeysOptions{InitializeKeys: true, OfflineRoot: offlineRoot})
+		Expect(repository.GetPrivKeys().Root).To(BeNil())
+
+		Expect(repository.StageTarget(ctx, "channels/1/stable", strings.NewReader("1.0.0\n"))).To(Succeed())
+		Expect(repository.CommitStaged(ctx)).To(MatchError(ErrUnsignedRoot))
+		Expect(fs.files).NotTo(HaveKey("root.json"))
+
+		pendingRoot := signPendingRoot(offlineSigners[0])
+		missing, previousMissing, err := pendingRoot.MissingSignatures()
+		Expect(err).To(Succeed())
+		Expect(missing).To(Equal(1))
+		Expect(previousMissing).To(Equal(0))
+
+		Expect(publisher.PublishRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}))).To(MatchError(ContainSubstring("requires 1 more signatures")))
+
+		signPendingRoot(offlineSigners[2])
+		Expect(publisher.PublishRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}))).To(Succeed())
+
+		pendingRoot, err = GetPendingRoot(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(pendingRoot).To(BeNil())
+
+		trustedRoot := fs.files["root.json"]
+		targets := map[string]string{"channels/1/stable": "1.0.0\n"}
+		publish(targets)
+		expectClientDownloads(trustedRoot, targets)
+	})
+
+	It("should switch the existing repository to the offline keys", func() {
+		newRepository(setRepositoryKeysOptions{InitializeKeys: true})
+		targets := map[string]string{"channels/1/stable": "1.0.0\n"}
+		publish(targets)
+		trustedRoot := fs.files["root.json"]
+
+		// the pending root.json is signed by the online root key of the published root.json
+		pendingRoot, err := publisher.PrepareRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}), offlineRoot)
+		Expect(err).To(Succeed())
+		Expect(pendingRoot.Signatures).To(HaveLen(1))
+		Expect(pendingRoot.PreviousKeys).To(HaveLen(1))
+
+		signPendingRoot(offlineSigners[1], offlineSigners[2])
+		Expect(publisher.PublishRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}))).To(Succeed())
+
+		repository := newRepository(setRepositoryKeysOptions{})
+		Expect(repository.GetPrivKeys().Root).To(BeNil())
+
+		hasRootSigners, err := repository.hasRootSigners()
+		Expect(err).To(Succeed())
+		Expect(hasRootSigners).To(BeFalse())
+
+		targets["channels/1/stable"] = "1.0.1\n"
+		publish(targets)
+		expectClientDownloads(trustedRoot, targets)
+
+		Expect(newRepository(setRepositoryKeysOptions{}).UpdateTimestamps(ctx, util.NewFixedClock(time.Now().AddDate(0, 6, 0)))).To(Succeed())
+		expectClientDownloads(trustedRoot, targets)
+	})
+
+	It("should not publish the stale pending root.json", func() {
+		newRepository(setRepositoryKeysOptions{InitializeKeys: true})
+		publish(map[string]string{"channels/1/stable": "1.0.0\n"})
+
+		stalePendingRoot, err := publisher.PrepareRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}), offlineRoot)
+		Expect(err).To(Succeed())
+
+		_, err = publisher.PrepareRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}), offlineRoot)
+		Expect(err).To(Succeed())
+		signPendingRoot(offlineSigners[0], offlineSigners[1])
+		Expect(publisher.PublishRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}))).To(Succeed())
+
+		for _, signer := range offlineSigners[:2] {
+			signature, err := signer.SignMessage(stalePendingRoot.Signed)
+			Expect(err).To(Succeed())
+			Expect(stalePendingRoot.AddSignature(data.Signature{KeyID: signer.PublicData().IDs()[0], Signature: signature})).To(Succeed())
+		}
+		Expect(newRepository(setRepositoryKeysOptions{}).PublishRoot(ctx, stalePendingRoot)).To(MatchError(ErrStalePendingRoot))
+	})
+
+	It("should reject the signature of the unknown key", func() {
+		newRepository(setRepositoryKeysOptions{InitializeKeys: true, OfflineRoot: offlineRoot})
+
+		pendingRoot, err := GetPendingRoot(ctx, storage)
+		Expect(err).To(Succeed())
+
+		signer, err := keys.GenerateEd25519Key()
+		Expect(err).To(Succeed())
+		signature, err := signer.SignMessage(pendingRoot.Signed)
+		Expect(err).To(Succeed())
+		Expect(pendingRoot.AddSignature(data.Signature{KeyID: signer.PublicData().IDs()[0], Signature: signature})).To(MatchError(ContainSubstring("is not a root key")))
+
+		signature, err = offlineSigners[0].SignMessage([]byte("other"))
+		Expect(err).To(Succeed())
+		Expect(pendingRoot.AddSignature(data.Signature{KeyID: offlineSigners[0].PublicData().IDs()[0], Signature: signature})).To(MatchError(ContainSubstring("invalid signature")))
+	})
+
+	It("should prepare the pending root.json before the expiration of root.json", func() {
+		newRepository(setRepositoryKeysOptions{InitializeKeys: true, OfflineRoot: offlineRoot})
+		signPendingRoot(offlineSigners[0], offlineSigners[1])
+		Expect(publisher.PublishRoot(ctx, storage, newRepository(setRepositoryKeysOptions{}))).To(Succeed())
+
+		now := time.Now()
+		Expect(publisher.UpdateTimestamps(ctx, storage, newRepository(setRepositoryKeysOptions{}), util.NewFixedClock(now))).To(Succeed())
+		pendingRoot, err := GetPendingRoot(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(pendingRoot).To(BeNil())
+
+		Expect(publisher.UpdateTimestamps(ctx, storage, newRepository(setRepositoryKeysOptions{}), util.NewFixedClock(now.AddDate(0, 11, 1)))).To(Succeed())
+		pendingRoot, err = GetPendingRoot(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(pendingRoot).NotTo(BeNil())
+		Expect(pendingRoot.PreviousKeys).To(HaveLen(3))
+
+		missing, previousMissing, err := pendingRoot.MissingSignatures()
+		Expect(err).To(Succeed())
+		Expect(missing).To(Equal(2))
+		Expect(previousMissing).To(Equal(2))
+	})
+})
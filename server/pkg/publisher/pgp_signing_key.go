@@ -0,0 +1,380 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/trdl/server/pkg/pgp"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+const (
+	storageKeyPGPSigningKeyOptions = "pgp_signing_key_options"
+	storageKeyPGPSigningKeyRing    = "pgp_signing_key_ring"
+
+	// PGPSigningKeysTarget is the key ring of the next, current and previous PGP public keys the release signatures are verified with.
+	PGPSigningKeysTarget = "trdl/signing-keys.asc"
+)
+
+// PGPSigningKeyOptions configure the generation and the rotation of the PGP signing key.
+// The keys created in Vault Transit are always RSA-4096, the algorithm is applied to the keys generated by the plugin.
+type PGPSigningKeyOptions struct {
+	Algorithm string `json:"algorithm"`
+	// RotationPeriod is the lifetime of the key, the rotation is disabled if it is zero.
+	RotationPeriod time.Duration `json:"rotation_period"`
+	// RotationOverlap is the time the next key is published before it replaces the current key,
+	// so the clients receive the key before the signatures made by it.
+	RotationOverlap time.Duration `json:"rotation_overlap"`
+}
+
+func (opts PGPSigningKeyOptions) Validate() error {
+	if err := pgp.ValidateSigningKeyAlgorithm(opts.Algorithm); err != nil {
+		return err
+	}
+
+	if opts.RotationPeriod < 0 || opts.RotationOverlap < 0 {
+		return fmt.Errorf("rotation period and overlap must not be negative")
+	}
+
+	if opts.RotationPeriod > 0 && opts.RotationOverlap >= opts.RotationPeriod {
+		return fmt.Errorf("rotation overlap must be less than rotation period")
+	}
+
+	return nil
+}
+
+// GetPGPSigningKeyOptions returns the default options if the options are not set.
+func GetPGPSigningKeyOptions(ctx context.Context, storage logical.Storage) (PGPSigningKeyOptions, error) {
+	opts := PGPSigningKeyOptions{Algorithm: pgp.DefaultSigningKeyAlgorithm}
+
+	entry, err := storage.Get(ctx, storageKeyPGPSigningKeyOptions)
+	if err != nil {
+		return opts, fmt.Errorf("error getting pgp signing key options by storage key %q: %w", storageKeyPGPSigningKeyOptions, err)
+	}
+	if entry == nil {
+		return opts, nil
+	}
+
+	if err := entry.DecodeJSON(&opts); err != nil {
+		return opts, fmt.Errorf("unable to decode pgp signing key options by storage key %q: %w", storageKeyPGPSigningKeyOptions, err)
+	}
+
+	return opts, nil
+}
+
+func PutPGPSigningKeyOptions(ctx context.Context, storage logical.Storage, opts PGPSigningKeyOptions) error {
+	entry, err := logical.StorageEntryJSON(storageKeyPGPSigningKeyOptions, opts)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry by key %q: %w", storageKeyPGPSigningKeyOptions, err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("error putting pgp signing key options by storage key %q: %w", storageKeyPGPSigningKeyOptions, err)
+	}
+
+	return nil
+}
+
+// pgpSigningKeyRing is the rotation state of the PGP signing key.
+type pgpSigningKeyRing struct {
+	// CurrentSince is the time the current key is signing since, the creation time of the key is used if it is not set.
+	CurrentSince time.Time `json:"current_since,omitempty"`
+	// Next is the armored private key or NextTransit is the reference to the Transit key version
+	// which replaces the current key at the end of the rotation period.
+	Next        string         `json:"next,omitempty"`
+	NextTransit *transitKeyRef `json:"next_transit,omitempty"`
+	// Previous are the armored public keys of the replaced keys, the latest first.
+	// The keys are kept forever, because the signatures of the published releases are verified by them.
+	Previous []pgpPublicKey `json:"previous,omitempty"`
+	// Published are the fingerprints of the keys in the published PGPSigningKeysTarget.
+	Published []string `json:"published,omitempty"`
+}
+
+type pgpPublicKey struct {
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"public_key"`
+}
+
+func (ring *pgpSigningKeyRing) hasNext() bool {
+	return ring.Next != "" || ring.NextTransit != nil
+}
+
+func getPGPSigningKeyRing(ctx context.Context, storage logical.Storage) (*pgpSigningKeyRing, error) {
+	ring := &pgpSigningKeyRing{}
+
+	entry, err := storage.Get(ctx, storageKeyPGPSigningKeyRing)
+	if err != nil {
+		return nil, fmt.Errorf("error getting pgp signing key ring by storage key %q: %w", storageKeyPGPSigningKeyRing, err)
+	}
+	if entry == nil {
+		return ring, nil
+	}
+
+	if err := entry.DecodeJSON(ring); err != nil {
+		return nil, fmt.Errorf("unable to decode pgp signing key ring by storage key %q: %w", storageKeyPGPSigningKeyRing, err)
+	}
+
+	return ring, nil
+}
+
+func putPGPSigningKeyRing(ctx context.Context, storage logical.Storage, ring *pgpSigningKeyRing) error {
+	entry, err := logical.StorageEntryJSON(storageKeyPGPSigningKeyRing, ring)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry by key %q: %w", storageKeyPGPSigningKeyRing, err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("error putting pgp signing key ring by storage key %q: %w", storageKeyPGPSigningKeyRing, err)
+	}
+
+	return nil
+}
+
+func putPGPSigningKey(ctx context.Context, storage logical.Storage, key *pgp.SigningKey) error {
+	serializedKey := bytes.NewBuffer(nil)
+	if err := key.SerializeFull(serializedKey); err != nil {
+		return fmt.Errorf("unable to serialize pgp signing key: %w", err)
+	}
+
+	if err := storage.Put(ctx, &logical.StorageEntry{Key: storageKeyPGPSigningKey, Value: serializedKey.Bytes()}); err != nil {
+		return fmt.Errorf("error putting pgp signing key by storage key %q: %w", storageKeyPGPSigningKey, err)
+	}
+
+	return nil
+}
+
+func putTransitPGPSigningKeyRef(ctx context.Context, storage logical.Storage, ref transitKeyRef) error {
+	entry, err := logical.StorageEntryJSON(storageKeyPGPSigningKeyTransit, ref)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry by key %q: %w", storageKeyPGPSigningKeyTransit, err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("error putting pgp signing key reference by storage key %q: %w", storageKeyPGPSigningKeyTransit, err)
+	}
+
+	return nil
+}
+
+// RotatePGPSigningKey creates the next key at the start of the rotation overlap, replaces the current key by the next one
+// at the end of the rotation period and publishes the PGPSigningKeysTarget if its keys are changed.
+func (publisher *Publisher) RotatePGPSigningKey(ctx context.Context, storage logical.Storage, repository RepositoryInterface, systemClock util.Clock) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	opts, err := GetPGPSigningKeyOptions(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	ring, err := getPGPSigningKeyRing(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+	if err != nil {
+		return fmt.Errorf("error fetching pgp signing key: %w", err)
+	}
+
+	if opts.RotationPeriod > 0 {
+		currentSince := ring.CurrentSince
+		if currentSince.IsZero() {
+			currentSince = key.CreationTime()
+		}
+		now := systemClock.Now()
+
+		if !ring.hasNext() && !now.Before(currentSince.Add(opts.RotationPeriod-opts.RotationOverlap)) {
+			if err := publisher.createNextPGPSigningKey(ctx, storage, ring, opts); err != nil {
+				return err
+			}
+		}
+
+		if ring.hasNext() && !now.Before(currentSince.Add(opts.RotationPeriod)) {
+			if key, err = publisher.promoteNextPGPSigningKey(ctx, storage, ring, key, now); err != nil {
+				return err
+			}
+			publisher.PGPSigningKey = key
+		}
+	}
+
+	if err := publisher.publishPGPSigningKeys(ctx, storage, repository, ring, key); err != nil {
+		return err
+	}
+
+	return putPGPSigningKeyRing(ctx, storage, ring)
+}
+
+// createNextPGPSigningKey rotates the Transit key if the current key is kept in Transit, otherwise generates the key by the algorithm option.
+func (publisher *Publisher) createNextPGPSigningKey(ctx context.Context, storage logical.Storage, ring *pgpSigningKeyRing, opts PGPSigningKeyOptions) error {
+	entry, err := storage.Get(ctx, storageKeyPGPSigningKey)
+	if err != nil {
+		return fmt.Errorf("error getting storage pgp signing key json entry by storage key %q: %w", storageKeyPGPSigningKey, err)
+	}
+
+	if entry == nil {
+		transitOpts, client, err := getTransitClient(ctx, storage)
+		if err != nil {
+			return err
+		}
+
+		if client != nil {
+			ref, err := client.rotateKey(ctx, transitOpts.Mount, transitOpts.pgpKeyName(), transitKeyTypePGP)
+			if err != nil {
+				return err
+			}
+			ring.NextTransit = &ref
+
+			publisher.logger.Info(fmt.Sprintf("Rotated Transit key %q for the next PGP signing key", ref.Name))
+
+			return putPGPSigningKeyRing(ctx, storage, ring)
+		}
+	}
+
+	key, err := pgp.GenerateSigningKey(opts.Algorithm)
+	if err != nil {
+		return fmt.Errorf("unable to generate new %s pgp signing key: %w", opts.Algorithm, err)
+	}
+
+	serializedKey := bytes.NewBuffer(nil)
+	if err := key.SerializePrivateKey(serializedKey); err != nil {
+		return fmt.Errorf("unable to serialize pgp signing key: %w", err)
+	}
+	ring.Next = serializedKey.String()
+
+	publisher.logger.Info(fmt.Sprintf("Generated next %s PGP signing key %s", opts.Algorithm, key.Fingerprint()))
+
+	return putPGPSigningKeyRing(ctx, storage, ring)
+}
+
+// promoteNextPGPSigningKey replaces the current key by the next one, the public key of the current key is kept in the ring.
+func (publisher *Publisher) promoteNextPGPSigningKey(ctx context.Context, storage logical.Storage, ring *pgpSigningKeyRing, current *pgp.SigningKey, now time.Time) (*pgp.SigningKey, error) {
+	next, err := publisher.getNextPGPSigningKey(ctx, storage, ring)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key could be already replaced by the interrupted rotation
+	if next.Fingerprint() != current.Fingerprint() {
+		// the current key could be already kept by the rotation interrupted before the key is replaced
+		if len(ring.Previous) == 0 || ring.Previous[0].Fingerprint != current.Fingerprint() {
+			publicKey := bytes.NewBuffer(nil)
+			if err := current.SerializePublicKey(publicKey); err != nil {
+				return nil, fmt.Errorf("unable to get public key text: %w", err)
+			}
+			ring.Previous = append([]pgpPublicKey{{Fingerprint: current.Fingerprint(), PublicKey: publicKey.String()}}, ring.Previous...)
+
+			if err := putPGPSigningKeyRing(ctx, storage, ring); err != nil {
+				return nil, err
+			}
+		}
+
+		if ring.NextTransit != nil {
+			if err := storage.Delete(ctx, storageKeyPGPSigningKey); err != nil {
+				return nil, err
+			}
+
+			if err := putTransitPGPSigningKeyRef(ctx, storage, *ring.NextTransit); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := putPGPSigningKey(ctx, storage, next); err != nil {
+				return nil, err
+			}
+
+			if err := storage.Delete(ctx, storageKeyPGPSigningKeyTransit); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ring.Next = ""
+	ring.NextTransit = nil
+	ring.CurrentSince = now
+
+	publisher.logger.Info(fmt.Sprintf("Rotated PGP signing key %s: the new key is %s", current.Fingerprint(), next.Fingerprint()))
+
+	return next, nil
+}
+
+// publishPGPSigningKeys stages and commits the PGPSigningKeysTarget if the published keys are changed.
+// The target is not published until root.json signed by the offline root keys is published.
+func (publisher *Publisher) publishPGPSigningKeys(ctx context.Context, storage logical.Storage, repository RepositoryInterface, ring *pgpSigningKeyRing, current *pgp.SigningKey) error {
+	keys := []*pgp.SigningKey{}
+
+	if ring.hasNext() {
+		next, err := publisher.getNextPGPSigningKey(ctx, storage, ring)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, next)
+	}
+	keys = append(keys, current)
+
+	var publicKeys, fingerprints []string
+	for _, key := range keys {
+		publicKey := bytes.NewBuffer(nil)
+		if err := key.SerializePublicKey(publicKey); err != nil {
+			return fmt.Errorf("unable to get public key text: %w", err)
+		}
+
+		publicKeys = append(publicKeys, publicKey.String())
+		fingerprints = append(fingerprints, key.Fingerprint())
+	}
+	for _, previous := range ring.Previous {
+		publicKeys = append(publicKeys, previous.PublicKey)
+		fingerprints = append(fingerprints, previous.Fingerprint)
+	}
+
+	if strings.Join(fingerprints, ",") == strings.Join(ring.Published, ",") {
+		return nil
+	}
+
+	keyRing, err := pgp.MergeArmoredPublicKeys(publicKeys)
+	if err != nil {
+		return fmt.Errorf("unable to merge pgp public keys: %w", err)
+	}
+
+	if err := repository.StageTarget(ctx, PGPSigningKeysTarget, strings.NewReader(keyRing)); err != nil {
+		return fmt.Errorf("error publishing %q: %w", PGPSigningKeysTarget, err)
+	}
+
+	if err := repository.CommitStaged(ctx); errors.Is(err, ErrUnsignedRoot) {
+		publisher.logger.Info(fmt.Sprintf("Root.json is not published yet: skipping %q publishing", PGPSigningKeysTarget))
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to commit %q: %w", PGPSigningKeysTarget, err)
+	}
+
+	ring.Published = fingerprints
+	publisher.logger.Info(fmt.Sprintf("Published PGP signing keys %q", PGPSigningKeysTarget))
+
+	return nil
+}
+
+func (publisher *Publisher) getNextPGPSigningKey(ctx context.Context, storage logical.Storage, ring *pgpSigningKeyRing) (*pgp.SigningKey, error) {
+	if ring.NextTransit == nil {
+		key, err := pgp.ParseSigningKey(strings.NewReader(ring.Next))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the next pgp signing key: %w", err)
+		}
+
+		return key, nil
+	}
+
+	_, client, err := getTransitClient(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, fmt.Errorf("the next pgp signing key is kept in Vault Transit which is not configured")
+	}
+
+	return newTransitPGPSigningKey(ctx, client, *ring.NextTransit)
+}
@@ -0,0 +1,262 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/theupdateframework/go-tuf"
+
+	"github.com/werf/trdl/server/pkg/pgp"
+	"github.com/werf/trdl/server/pkg/util"
+)
+
+var _ = Describe("PGP signing key rotation", func() {
+	const (
+		rotationPeriod  = 30 * 24 * time.Hour
+		rotationOverlap = 7 * 24 * time.Hour
+	)
+
+	var ctx context.Context
+	var storage logical.Storage
+	var fs *testMemoryFilesystem
+	var publisher *Publisher
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		storage = &logical.InmemStorage{}
+		fs = &testMemoryFilesystem{files: map[string][]byte{}}
+		publisher = NewPublisher(hclog.NewNullLogger())
+	})
+
+	newRepository := func() *S3Repository {
+		store := NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+
+		repository := &S3Repository{TufStore: store, TufRepo: tufRepo, logger: hclog.NewNullLogger()}
+		Expect(repository.Init()).To(Succeed())
+		Expect(publisher.setRepositoryKeys(ctx, storage, repository, setRepositoryKeysOptions{InitializeKeys: true})).To(Succeed())
+
+		return repository
+	}
+
+	rotate := func(now time.Time) {
+		Expect(publisher.RotatePGPSigningKey(ctx, storage, newRepository(), util.NewFixedClock(now))).To(Succeed())
+	}
+
+	publishedFingerprints := func() []string {
+		keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(fs.files["targets/"+PGPSigningKeysTarget]))
+		Expect(err).To(Succeed())
+
+		var fingerprints []string
+		for _, entity := range keyRing {
+			fingerprints = append(fingerprints, (&pgp.SigningKey{Entity: entity}).Fingerprint())
+		}
+		return fingerprints
+	}
+
+	currentFingerprint := func() string {
+		fingerprint, err := publisher.GetPGPSigningKeyFingerprint(ctx, storage)
+		Expect(err).To(Succeed())
+		return fingerprint
+	}
+
+	It("should generate the key by the configured algorithm", func() {
+		Expect(PutPGPSigningKeyOptions(ctx, storage, PGPSigningKeyOptions{Algorithm: pgp.SigningKeyAlgorithmEd25519})).To(Succeed())
+
+		key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+		Expect(key.Entity.PrimaryKey.PubKeyAlgo).To(Equal(packet.PubKeyAlgoEdDSA))
+	})
+
+	It("should publish the next key before the rotation and keep the previous key after it", func() {
+		Expect(PutPGPSigningKeyOptions(ctx, storage, PGPSigningKeyOptions{
+			Algorithm:       pgp.SigningKeyAlgorithmEd25519,
+			RotationPeriod:  rotationPeriod,
+			RotationOverlap: rotationOverlap,
+		})).To(Succeed())
+
+		key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+		start := key.CreationTime()
+		first := key.Fingerprint()
+
+		signature := bytes.NewBuffer(nil)
+		Expect(pgp.SignDataStream(signature, strings.NewReader("binary"), key)).To(Succeed())
+
+		rotate(start)
+		Expect(publishedFingerprints()).To(Equal([]string{first}))
+
+		// the published key ring is not committed again if the keys are not changed
+		targets := fs.files["targets.json"]
+		rotate(start.Add(time.Hour))
+		Expect(fs.files["targets.json"]).To(Equal(targets))
+
+		rotate(start.Add(rotationPeriod - rotationOverlap))
+		Expect(currentFingerprint()).To(Equal(first))
+		published := publishedFingerprints()
+		Expect(published).To(HaveLen(2))
+		Expect(published[1]).To(Equal(first))
+		next := published[0]
+
+		rotate(start.Add(rotationPeriod))
+		Expect(currentFingerprint()).To(Equal(next))
+		Expect(publisher.PGPSigningKey.Fingerprint()).To(Equal(next))
+		Expect(publishedFingerprints()).To(Equal([]string{next, first}))
+
+		// the signatures made by the previous key are verified by the published key ring
+		keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(fs.files["targets/"+PGPSigningKeysTarget]))
+		Expect(err).To(Succeed())
+		_, err = openpgp.CheckDetachedSignature(keyRing, strings.NewReader("binary"), signature, nil)
+		Expect(err).To(Succeed())
+
+		// the rotation period is counted from the replacement of the key
+		rotate(start.Add(2*rotationPeriod - rotationOverlap - time.Hour))
+		Expect(publishedFingerprints()).To(HaveLen(2))
+
+		rotate(start.Add(2*rotationPeriod - rotationOverlap))
+		Expect(publishedFingerprints()).To(HaveLen(3))
+	})
+
+	It("should keep the previous key once after the interrupted rotation", func() {
+		Expect(PutPGPSigningKeyOptions(ctx, storage, PGPSigningKeyOptions{
+			Algorithm:       pgp.SigningKeyAlgorithmEd25519,
+			RotationPeriod:  rotationPeriod,
+			RotationOverlap: rotationOverlap,
+		})).To(Succeed())
+
+		key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+		start := key.CreationTime()
+		first := key.Fingerprint()
+
+		rotate(start.Add(rotationPeriod - rotationOverlap))
+		next := publishedFingerprints()[0]
+
+		// the rotation is interrupted after the current key is kept in the ring, but before it is replaced
+		ring, err := getPGPSigningKeyRing(ctx, storage)
+		Expect(err).To(Succeed())
+		_, err = publisher.promoteNextPGPSigningKey(ctx, &failingPutStorage{Storage: storage, failKey: storageKeyPGPSigningKey}, ring, key, start.Add(rotationPeriod))
+		Expect(err).To(HaveOccurred())
+
+		ring, err = getPGPSigningKeyRing(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(ring.Previous).To(HaveLen(1))
+		Expect(currentFingerprint()).To(Equal(first))
+
+		rotate(start.Add(rotationPeriod))
+		Expect(currentFingerprint()).To(Equal(next))
+		Expect(publishedFingerprints()).To(Equal([]string{next, first}))
+
+		ring, err = getPGPSigningKeyRing(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(ring.Previous).To(HaveLen(1))
+	})
+
+	It("should not rotate the key if the rotation is disabled", func() {
+		key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+
+		rotate(key.CreationTime().AddDate(10, 0, 0))
+		Expect(currentFingerprint()).To(Equal(key.Fingerprint()))
+		Expect(publishedFingerprints()).To(Equal([]string{key.Fingerprint()}))
+	})
+
+	It("should drop the next key with the deleted key", func() {
+		Expect(PutPGPSigningKeyOptions(ctx, storage, PGPSigningKeyOptions{
+			Algorithm:       pgp.SigningKeyAlgorithmEd25519,
+			RotationPeriod:  rotationPeriod,
+			RotationOverlap: rotationOverlap,
+		})).To(Succeed())
+
+		key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+
+		rotate(key.CreationTime().Add(rotationPeriod - rotationOverlap))
+		Expect(publishedFingerprints()).To(HaveLen(2))
+
+		Expect(publisher.deletePGPSigningKey(ctx, storage)).To(Succeed())
+
+		rotate(time.Now())
+		published := publishedFingerprints()
+		Expect(published).To(Equal([]string{currentFingerprint()}))
+		Expect(published).NotTo(ContainElement(key.Fingerprint()))
+	})
+
+	It("should rotate the PGP signing key kept in Transit", func() {
+		transit := newTestTransit()
+		DeferCleanup(transit.server.Close)
+
+		Expect(PutTransitOptions(ctx, storage, TransitOptions{
+			VaultAddress: transit.server.URL,
+			VaultToken:   "s.token",
+			Mount:        DefaultTransitMount,
+			KeyPrefix:    DefaultTransitKeyPrefix,
+		})).To(Succeed())
+		Expect(PutPGPSigningKeyOptions(ctx, storage, PGPSigningKeyOptions{
+			Algorithm:       pgp.SigningKeyAlgorithmRSA4096,
+			RotationPeriod:  rotationPeriod,
+			RotationOverlap: rotationOverlap,
+		})).To(Succeed())
+
+		key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+		first := key.Fingerprint()
+
+		rotate(key.CreationTime().Add(rotationPeriod - rotationOverlap))
+		Expect(publishedFingerprints()).To(HaveLen(2))
+		Expect(currentFingerprint()).To(Equal(first))
+
+		rotate(key.CreationTime().Add(rotationPeriod))
+		Expect(currentFingerprint()).NotTo(Equal(first))
+		Expect(publishedFingerprints()).To(Equal([]string{currentFingerprint(), first}))
+
+		entry, err := storage.Get(ctx, storageKeyPGPSigningKeyTransit)
+		Expect(err).To(Succeed())
+		var ref transitKeyRef
+		Expect(entry.DecodeJSON(&ref)).To(Succeed())
+		Expect(ref.Version).To(Equal(2))
+
+		entry, err = storage.Get(ctx, storageKeyPGPSigningKey)
+		Expect(err).To(Succeed())
+		Expect(entry).To(BeNil())
+	})
+
+	DescribeTable("should validate the options", func(opts PGPSigningKeyOptions, errorSubstring string) {
+		err := opts.Validate()
+		if errorSubstring == "" {
+			Expect(err).To(Succeed())
+		} else {
+			Expect(err).To(MatchError(ContainSubstring(errorSubstring)))
+		}
+	},
+		Entry("default", PGPSigningKeyOptions{Algorithm: pgp.DefaultSigningKeyAlgorithm}, ""),
+		Entry("rotation", PGPSigningKeyOptions{Algorithm: pgp.SigningKeyAlgorithmEd25519, RotationPeriod: rotationPeriod, RotationOverlap: rotationOverlap}, ""),
+		Entry("unsupported algorithm", PGPSigningKeyOptions{Algorithm: "dsa"}, "unsupported algorithm"),
+		Entry("overlap exceeds period", PGPSigningKeyOptions{Algorithm: pgp.SigningKeyAlgorithmEd25519, RotationPeriod: rotationOverlap, RotationOverlap: rotationPeriod}, "must be less than rotation period"),
+	)
+})
+
+// failingPutStorage fails to put the entry by the key.
+type failingPutStorage struct {
+	logical.Storage
+	failKey string
+}
+
+func (s *failingPutStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if entry.Key == s.failKey {
+		return fmt.Errorf("unable to put %q", entry.Key)
+	}
+
+	return s.Storage.Put(ctx, entry)
+}
@@ -11,12 +11,13 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
+	uuid "github.com/satori/go.uuid"
 
 	"github.com/werf/trdl/server/pkg/config"
 	"github.com/werf/trdl/server/pkg/pgp"
@@ -26,6 +27,8 @@ import (
 const (
 	storageKeyTufRepositoryKeys = "tuf_repository_keys"
 	storageKeyPGPSigningKey     = "pgp_signing_key"
+	// storageKeyPGPSigningKeyTransit is the reference to the pgp signing key kept in Vault Transit.
+	storageKeyPGPSigningKeyTransit = "pgp_signing_key_transit"
 )
 
 var (
@@ -39,11 +42,86 @@ type RepositoryOptions struct {
 	S3AccessKeyID     string
 	S3SecretAccessKey string
 	S3BucketName      string
+	// S3ForcePathStyle forces path-style addressing, which is otherwise used only for the endpoints other than AWS.
+	S3ForcePathStyle bool
+	// S3CACertificate is the PEM-encoded CA bundle the endpoint certificate is verified with instead of the system one.
+	S3CACertificate string
+	S3UploadOptions S3UploadOptions
+	// S3CredentialsOptions replace S3AccessKeyID and S3SecretAccessKey by the short-lived credentials.
+	S3CredentialsOptions S3CredentialsOptions
+
+	// ConsistentSnapshot is used to initialize the new repository only.
+	ConsistentSnapshot bool
+	// OfflineRoot keeps the root keys offline: the new repository is initialized without the root key,
+	// the existing one is switched to the offline keys by the pending root.json.
+	OfflineRoot *OfflineRootOptions
 
 	InitializeTUFKeys       bool
 	InitializePGPSigningKey bool
 }
 
+// ValidateS3 checks the extended S3 options, the required ones are checked by the configuration.
+func (options RepositoryOptions) ValidateS3() error {
+	if options.S3CACertificate != "" {
+		if err := validateCACertificate(options.S3CACertificate); err != nil {
+			return fmt.Errorf("invalid s3 ca certificate: %w", err)
+		}
+	}
+
+	if err := options.S3CredentialsOptions.Validate(); err != nil {
+		return err
+	}
+
+	return options.S3UploadOptions.Validate()
+}
+
+// s3ProbePrefix is outside of the TUF repository layout, so the probe objects are never published.
+const s3ProbePrefix = ".trdl-probe/"
+
+// ProbeS3 writes, reads back and deletes the probe object to check the access to the bucket.
+func (options RepositoryOptions) ProbeS3(ctx context.Context, logger hclog.Logger) error {
+	fs := options.s3Options().newFilesystem(logger)
+
+	probePath := s3ProbePrefix + uuid.NewV4().String()
+	probeData := []byte(probePath)
+
+	if err := fs.WriteFileBytes(ctx, probePath, probeData); err != nil {
+		return fmt.Errorf("write probe failed: %w", err)
+	}
+
+	data, err := fs.ReadFileBytes(ctx, probePath)
+	if err != nil {
+		return fmt.Errorf("read probe failed: %w", err)
+	}
+
+	if !bytes.Equal(data, probeData) {
+		return fmt.Errorf("read probe failed: object %q is changed", probePath)
+	}
+
+	if err := fs.DeleteFile(ctx, probePath); err != nil {
+		return fmt.Errorf("delete probe failed: %w", err)
+	}
+
+	return nil
+}
+
+func (options RepositoryOptions) s3Options() S3Options {
+	awsConfig := newAwsConfig(options.S3Endpoint, options.S3Region, options.S3AccessKeyID, options.S3SecretAccessKey)
+	if options.S3ForcePathStyle {
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+	if creds := options.S3CredentialsOptions.newCredentials(); creds != nil {
+		awsConfig.Credentials = creds
+	}
+
+	return S3Options{
+		AwsConfig:     awsConfig,
+		BucketName:    options.S3BucketName,
+		CACertificate: options.S3CACertificate,
+		UploadOptions: options.S3UploadOptions,
+	}
+}
+
 type InMemoryFile struct {
 	Name string
 	Data []byte
@@ -57,7 +135,7 @@ type Publisher struct {
 	mu     sync.Mutex
 	logger hclog.Logger
 
-	PGPSigningKey *pgp.RSASigningKey
+	PGPSigningKey *pgp.SigningKey
 }
 
 func NewPublisher(logger hclog.Logger) *Publisher {
@@ -87,11 +165,103 @@ func (publisher *Publisher) RotateRepositoryKeys(ctx context.Context, storage lo
 }
 
 func (publisher *Publisher) UpdateTimestamps(ctx context.Context, storage logical.Storage, repository RepositoryInterface, systemClock util.Clock) error {
-	return repository.UpdateTimestamps(ctx, systemClock)
+	if err := repository.UpdateTimestamps(ctx, systemClock); err != nil {
+		return err
+	}
+
+	pendingRoot, err := GetPendingRoot(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get pending root.json: %w", err)
+	}
+	if pendingRoot != nil {
+		return nil
+	}
+
+	renewalDue, err := repository.OfflineRootRenewalDue(ctx, systemClock.Now())
+	if err != nil {
+		return err
+	}
+
+	if renewalDue {
+		if _, err := publisher.PrepareRoot(ctx, storage, repository, nil); err != nil {
+			return fmt.Errorf("unable to prepare root.json renewal: %w", err)
+		}
+
+		publisher.logger.Warn("Root.json is to be renewed: the pending root.json must be signed by the offline root keys")
+	}
+
+	return nil
+}
+
+// PrepareRoot saves the pending root.json replacing the previous one, the root role is replaced by the offline keys if offlineRoot is set.
+func (publisher *Publisher) PrepareRoot(ctx context.Context, storage logical.Storage, repository RepositoryInterface, offlineRoot *OfflineRootOptions) (*PendingRoot, error) {
+	pendingRoot, err := repository.PrepareRoot(ctx, offlineRoot, time.Now().AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := PutPendingRoot(ctx, storage, pendingRoot); err != nil {
+		return nil, err
+	}
+
+	return pendingRoot, nil
+}
+
+// PublishRoot publishes the signed pending root.json, then the online root key is deleted unless it is a root key of the published root.json.
+func (publisher *Publisher) PublishRoot(ctx context.Context, storage logical.Storage, repository RepositoryInterface) error {
+	pendingRoot, err := GetPendingRoot(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get pending root.json: %w", err)
+	}
+	if pendingRoot == nil {
+		return ErrPendingRootNotFound
+	}
+
+	if err := repository.PublishRoot(ctx, pendingRoot); err != nil {
+		return err
+	}
+
+	privKeys := repository.GetPrivKeys()
+	rootSigner, err := privKeys.GetSigner("root")
+	if err != nil {
+		return fmt.Errorf("unable to get root key signer: %w", err)
+	}
+
+	if rootSigner != nil {
+		rootKeys, _, err := pendingRoot.RootKeys()
+		if err != nil {
+			return err
+		}
+
+		isRootKey := false
+		for _, rootKey := range rootKeys {
+			if rootKey.ContainsID(rootSigner.PublicData().IDs()[0]) {
+				isRootKey = true
+			}
+		}
+
+		if !isRootKey {
+			privKeys.Root = nil
+
+			entry, err := logical.StorageEntryJSON(storageKeyTufRepositoryKeys, privKeys)
+			if err != nil {
+				return fmt.Errorf("error creating storage json entry by key %q: %w", storageKeyTufRepositoryKeys, err)
+			}
+
+			if err := storage.Put(ctx, entry); err != nil {
+				return fmt.Errorf("error putting private keys json entry by key %q into the storage: %w", storageKeyTufRepositoryKeys, err)
+			}
+
+			publisher.logger.Info("Deleted the online root key: the root keys are kept offline")
+		}
+	}
+
+	return DeletePendingRoot(ctx, storage)
 }
 
 type setRepositoryKeysOptions struct {
 	InitializeKeys bool
+	OfflineRoot    *OfflineRootOptions
 }
 
 func (publisher *Publisher) setRepositoryKeys(ctx context.Context, storage logical.Storage, repository RepositoryInterface, opts setRepositoryKeysOptions) error {
@@ -100,15 +270,38 @@ func (publisher *Publisher) setRepositoryKeys(ctx context.Context, storage logic
 		return fmt.Errorf("error getting storage private keys json entry by the key %q: %w", storageKeyTufRepositoryKeys, err)
 	}
 
+	transitOpts, transit, err := getTransitClient(ctx, storage)
+	if err != nil {
+		return err
+	}
+
 	if entry == nil {
 		if !opts.InitializeKeys {
 			return ErrUninitializedRepositoryKeys
 		}
 
-		publisher.logger.Debug("Will generate new repository private keys")
+		if transitOpts != nil {
+			publisher.logger.Debug("Will create new repository keys in Vault Transit")
+
+			privKeys, err := newTransitTufRepoPrivKeys(ctx, transit, *transitOpts, opts.OfflineRoot == nil)
+			if err != nil {
+				return fmt.Errorf("error creating repository keys in vault transit: %w", err)
+			}
 
-		if err := repository.GenPrivKeys(); err != nil {
-			return fmt.Errorf("error generating repository private keys: %w", err)
+			if err := repository.SetPrivKeys(privKeys); err != nil {
+				return fmt.Errorf("unable to set private keys into repository: %w", err)
+			}
+		} else {
+			publisher.logger.Debug("Will generate new repository private keys")
+
+			genPrivKeys := repository.GenPrivKeys
+			if opts.OfflineRoot != nil {
+				genPrivKeys = repository.GenOnlinePrivKeys
+			}
+
+			if err := genPrivKeys(); err != nil {
+				return fmt.Errorf("error generating repository private keys: %w", err)
+			}
 		}
 
 		privKeys := repository.GetPrivKeys()
@@ -124,6 +317,14 @@ func (publisher *Publisher) setRepositoryKeys(ctx context.Context, storage logic
 
 		publisher.logger.Info("Generated new repository private keys")
 
+		if opts.OfflineRoot != nil {
+			if _, err := publisher.PrepareRoot(ctx, storage, repository, opts.OfflineRoot); err != nil {
+				return fmt.Errorf("unable to prepare root.json: %w", err)
+			}
+
+			publisher.logger.Info("Prepared root.json: it is published once signed by the offline root keys")
+		}
+
 		return nil
 	}
 
@@ -131,6 +332,7 @@ func (publisher *Publisher) setRepositoryKeys(ctx context.Context, storage logic
 	if err := entry.DecodeJSON(&privKeys); err != nil {
 		return fmt.Errorf("unable to decode keys json by the %q storage key:\n%s---\n%w", storageKeyTufRepositoryKeys, entry.Value, err)
 	}
+	privKeys.transit = transit
 
 	if err := repository.SetPrivKeys(privKeys); err != nil {
 		return fmt.Errorf("unable to set private keys into repository: %w", err)
@@ -141,48 +343,78 @@ func (publisher *Publisher) setRepositoryKeys(ctx context.Context, storage logic
 	return nil
 }
 
+// deletePGPSigningKey keeps the Transit key, so the new key is created by its latest version.
+// The pending next key is dropped as well, the public keys of the rotated keys are kept published.
 func (publisher *Publisher) deletePGPSigningKey(ctx context.Context, storage logical.Storage) error {
-	return storage.Delete(ctx, storageKeyPGPSigningKey)
+	if err := storage.Delete(ctx, storageKeyPGPSigningKeyTransit); err != nil {
+		return err
+	}
+
+	if err := storage.Delete(ctx, storageKeyPGPSigningKey); err != nil {
+		return err
+	}
+
+	ring, err := getPGPSigningKeyRing(ctx, storage)
+	if err != nil {
+		return err
+	}
+	ring.Next = ""
+	ring.NextTransit = nil
+	ring.CurrentSince = time.Time{}
+
+	return putPGPSigningKeyRing(ctx, storage, ring)
+}
+
+// GetPGPSigningKeyFingerprint returns the fingerprint of the PGP signing key or empty string if the key is not generated yet.
+func (publisher *Publisher) GetPGPSigningKeyFingerprint(ctx context.Context, storage logical.Storage) (string, error) {
+	key, err := publisher.fetchPGPSigningKey(ctx, storage, false)
+	if err == ErrUninitializedPGPSigningKey {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return key.Fingerprint(), nil
 }
 
-func (publisher *Publisher) fetchPGPSigningKey(ctx context.Context, storage logical.Storage, initializeKey bool) (*pgp.RSASigningKey, error) {
+func (publisher *Publisher) fetchPGPSigningKey(ctx context.Context, storage logical.Storage, initializeKey bool) (*pgp.SigningKey, error) {
 	entry, err := storage.Get(ctx, storageKeyPGPSigningKey)
 	if err != nil {
 		return nil, fmt.Errorf("error getting storage pgp signing key json entry by storage key %q: %w", storageKeyPGPSigningKey, err)
 	}
 
 	if entry == nil {
+		if key, err := fetchTransitPGPSigningKey(ctx, storage, initializeKey); err != nil || key != nil {
+			return key, err
+		}
+
 		if !initializeKey {
 			return nil, ErrUninitializedPGPSigningKey
 		}
 
 		hclog.L().Debug("Will generate a new pgp signing key")
 
-		key, err := pgp.GenerateRSASigningKey()
+		opts, err := GetPGPSigningKeyOptions(ctx, storage)
 		if err != nil {
-			return nil, fmt.Errorf("unable to generate new rsa pgp signing key: %w", err)
+			return nil, err
 		}
 
-		serializedKey := bytes.NewBuffer(nil)
-		if err := key.SerializeFull(serializedKey); err != nil {
-			return nil, fmt.Errorf("unable to serialize pgp signing key: %w", err)
+		key, err := pgp.GenerateSigningKey(opts.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate new %s pgp signing key: %w", opts.Algorithm, err)
 		}
 
-		entry := &logical.StorageEntry{
-			Key:   storageKeyPGPSigningKey,
-			Value: serializedKey.Bytes(),
+		if err := putPGPSigningKey(ctx, storage, key); err != nil {
+			return nil, err
 		}
 
-		if err := storage.Put(ctx, entry); err != nil {
-			return nil, fmt.Errorf("error putting pgp signing key by storage key %q: %w", storageKeyPGPSigningKey, err)
-		}
-
-		hclog.L().Info("Generated new PGP signing key")
+		hclog.L().Info(fmt.Sprintf("Generated new %s PGP signing key", opts.Algorithm))
 
 		return key, nil
 	}
 
-	key, err := pgp.ParseRSASigningKey(bytes.NewReader(entry.Value))
+	key, err := pgp.ParseSigningKey(bytes.NewReader(entry.Value))
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse pgp signing key by the %q storage key:\n%s\n---%w", storageKeyPGPSigningKey, entry.Value, err)
 	}
@@ -193,15 +425,9 @@ func (publisher *Publisher) GetRepository(ctx context.Context, storage logical.S
 	publisher.mu.Lock()
 	defer publisher.mu.Unlock()
 
-	awsConfig := &aws.Config{
-		Endpoint:    aws.String(options.S3Endpoint),
-		Region:      aws.String(options.S3Region),
-		Credentials: credentials.NewStaticCredentials(options.S3AccessKeyID, options.S3SecretAccessKey, ""),
-	}
-
 	repository, err := NewRepositoryWithOptions(
-		S3Options{AwsConfig: awsConfig, BucketName: options.S3BucketName},
-		TufRepoOptions{},
+		options.s3Options(),
+		TufRepoOptions{ConsistentSnapshot: options.ConsistentSnapshot},
 		publisher.logger,
 	)
 	if err != nil {
@@ -212,7 +438,13 @@ func (publisher *Publisher) GetRepository(ctx context.Context, storage logical.S
 		return nil, fmt.Errorf("error initializing repository: %w", err)
 	}
 
-	if err := publisher.setRepositoryKeys(ctx, storage, repository, setRepositoryKeysOptions{InitializeKeys: options.InitializeTUFKeys}); err == ErrUninitializedRepositoryKeys {
+	mirrors, err := GetMirrors(ctx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get mirrors: %w", err)
+	}
+	repository.SetMirrors(mirrors)
+
+	if err := publisher.setRepositoryKeys(ctx, storage, repository, setRepositoryKeysOptions{InitializeKeys: options.InitializeTUFKeys, OfflineRoot: options.OfflineRoot}); err == ErrUninitializedRepositoryKeys {
 		return nil, ErrUninitializedRepositoryKeys
 	} else if err != nil {
 		return nil, fmt.Errorf("error initializing repository keys: %w", err)
@@ -228,8 +460,10 @@ func (publisher *Publisher) GetRepository(ctx context.Context, storage logical.S
 }
 
 func (publisher *Publisher) StageReleaseTarget(ctx context.Context, repository RepositoryInterface, releaseName, releaseFilePath string, data io.Reader) error {
+	// the lock is not held while streaming the target to let the concurrent releases stage their targets
 	publisher.mu.Lock()
-	defer publisher.mu.Unlock()
+	pgpSigningKey := publisher.PGPSigningKey
+	publisher.mu.Unlock()
 
 	pathParts := SplitFilepath(filepath.Clean(releaseFilePath))
 	if len(pathParts) == 0 {
@@ -257,7 +491,7 @@ func (publisher *Publisher) StageReleaseTarget(ctx context.Context, repository R
 	r := util.BufferedPipedWriterProcess(func(w io.WriteCloser) {
 		signDataReader := io.TeeReader(data, w)
 
-		if err := pgp.SignDataStream(gpgSignBuf, signDataReader, publisher.PGPSigningKey); err != nil {
+		if err := pgp.SignDataStream(gpgSignBuf, signDataReader, pgpSigningKey); err != nil {
 			gpgSignErrCh <- fmt.Errorf("unable to sign %q: %w", releaseFilePath, err)
 			return
 		}
@@ -0,0 +1,165 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultReleaseStagingConcurrency is the number of the release targets signed and uploaded at the same time.
+const DefaultReleaseStagingConcurrency = 4
+
+// ReleaseTargetsStager signs and stages the release targets by the bounded pool of workers.
+// The source of the targets is read sequentially (e.g. tar stream), so each target is spooled
+// to the temporary file first and the next target is read while the previous ones are uploaded.
+// The targets are registered in the TUF repository metadata by the serialized commit afterwards.
+type ReleaseTargetsStager struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	publisher   Interface
+	repository  RepositoryInterface
+	releaseName string
+
+	spoolDir string
+	queue    chan spooledReleaseTarget
+	workers  sync.WaitGroup
+
+	errMu sync.Mutex
+	err   error
+}
+
+type spooledReleaseTarget struct {
+	releaseFilePath string
+	spoolFilePath   string
+}
+
+// NewReleaseTargetsStager starts the workers, Wait must be called to stop them and remove the spooled files.
+func NewReleaseTargetsStager(ctx context.Context, publisher Interface, repository RepositoryInterface, releaseName string, concurrency int) (*ReleaseTargetsStager, error) {
+	if concurrency <= 0 {
+		concurrency = DefaultReleaseStagingConcurrency
+	}
+
+	spoolDir, err := os.MkdirTemp("", "trdl-release-targets-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stager := &ReleaseTargetsStager{
+		ctx:         ctx,
+		cancel:      cancel,
+		publisher:   publisher,
+		repository:  repository,
+		releaseName: releaseName,
+		spoolDir:    spoolDir,
+		// the queue bounds the number of the spooled targets waiting for the workers
+		queue: make(chan spooledReleaseTarget, concurrency),
+	}
+
+	for i := 0; i < concurrency; i++ {
+		stager.workers.Add(1)
+		go stager.work()
+	}
+
+	return stager, nil
+}
+
+// Stage spools the target data and queues the target for the workers.
+// The error of the already failed target is returned to stop reading the source early.
+func (stager *ReleaseTargetsStager) Stage(releaseFilePath string, data io.Reader) error {
+	if err := stager.firstErr(); err != nil {
+		return err
+	}
+
+	spoolFile, err := os.CreateTemp(stager.spoolDir, "target-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+
+	_, err = io.Copy(spoolFile, data)
+	if closeErr := spoolFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(spoolFile.Name())
+		return fmt.Errorf("error spooling release target %q: %w", releaseFilePath, err)
+	}
+
+	select {
+	case stager.queue <- spooledReleaseTarget{releaseFilePath: releaseFilePath, spoolFilePath: spoolFile.Name()}:
+		return nil
+	case <-stager.ctx.Done():
+		os.Remove(spoolFile.Name())
+		if err := stager.firstErr(); err != nil {
+			return err
+		}
+		return stager.ctx.Err()
+	}
+}
+
+// Wait waits for the queued targets to be staged and returns the first error.
+// The error is also returned if the context is cancelled, because the remaining targets are not staged then.
+func (stager *ReleaseTargetsStager) Wait() error {
+	close(stager.queue)
+	stager.workers.Wait()
+
+	err := stager.firstErr()
+	if err == nil {
+		err = stager.ctx.Err()
+	}
+	stager.cancel()
+
+	if removeErr := os.RemoveAll(stager.spoolDir); removeErr != nil && err == nil {
+		err = fmt.Errorf("unable to remove temporary directory: %w", removeErr)
+	}
+
+	return err
+}
+
+func (stager *ReleaseTargetsStager) work() {
+	defer stager.workers.Done()
+
+	for target := range stager.queue {
+		// the remaining targets are drained without staging after the failure
+		if stager.ctx.Err() == nil {
+			if err := stager.stage(target); err != nil {
+				stager.fail(err)
+			}
+		}
+
+		os.Remove(target.spoolFilePath)
+	}
+}
+
+func (stager *ReleaseTargetsStager) stage(target spooledReleaseTarget) error {
+	spoolFile, err := os.Open(target.spoolFilePath)
+	if err != nil {
+		return fmt.Errorf("unable to open spooled release target %q: %w", target.releaseFilePath, err)
+	}
+	defer spoolFile.Close()
+
+	if err := stager.publisher.StageReleaseTarget(stager.ctx, stager.repository, stager.releaseName, target.releaseFilePath, spoolFile); err != nil {
+		return fmt.Errorf("unable to publish release target %q: %w", target.releaseFilePath, err)
+	}
+
+	return nil
+}
+
+func (stager *ReleaseTargetsStager) fail(err error) {
+	stager.errMu.Lock()
+	defer stager.errMu.Unlock()
+
+	if stager.err == nil {
+		stager.err = err
+		stager.cancel()
+	}
+}
+
+func (stager *ReleaseTargetsStager) firstErr() error {
+	stager.errMu.Lock()
+	defer stager.errMu.Unlock()
+
+	return stager.err
+}
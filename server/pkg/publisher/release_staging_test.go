@@ -0,0 +1,119 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Release targets staging", func() {
+	var ctx context.Context
+	var publisher *testStagingPublisher
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		publisher = &testStagingPublisher{staged: map[string]string{}, delay: 10 * time.Millisecond}
+	})
+
+	It("should stage the targets by the bounded number of workers", func() {
+		stager, err := NewReleaseTargetsStager(ctx, publisher, nil, "1.0.0", 3)
+		Expect(err).To(Succeed())
+
+		expected := map[string]string{}
+		for i := 0; i < 12; i++ {
+			name := fmt.Sprintf("linux-amd64/bin/app-%d", i)
+			expected[name] = strings.Repeat(name, 1024)
+
+			Expect(stager.Stage(name, strings.NewReader(expected[name]))).To(Succeed())
+		}
+		Expect(stager.Wait()).To(Succeed())
+
+		Expect(publisher.staged).To(Equal(expected))
+		Expect(publisher.maxRunning).To(BeNumerically(">", 1))
+		Expect(publisher.maxRunning).To(BeNumerically("<=", 3))
+
+		_, err = os.Stat(stager.spoolDir)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should stop staging after the failure", func() {
+		publisher.failTarget = "linux-amd64/bin/app-0"
+
+		stager, err := NewReleaseTargetsStager(ctx, publisher, nil, "1.0.0", 1)
+		Expect(err).To(Succeed())
+
+		var stageErr error
+		for i := 0; i < 12 && stageErr == nil; i++ {
+			stageErr = stager.Stage(fmt.Sprintf("linux-amd64/bin/app-%d", i), strings.NewReader("binary"))
+		}
+		Expect(stageErr).To(MatchError(ContainSubstring("access denied")))
+
+		Expect(stager.Wait()).To(MatchError(ContainSubstring(`unable to publish release target "linux-amd64/bin/app-0"`)))
+		Expect(len(publisher.staged)).To(BeNumerically("<", 11))
+	})
+
+	It("should fail if the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(ctx)
+
+		stager, err := NewReleaseTargetsStager(ctx, publisher, nil, "1.0.0", 1)
+		Expect(err).To(Succeed())
+
+		cancel()
+		_ = stager.Stage("linux-amd64/bin/app", strings.NewReader("binary"))
+
+		Expect(stager.Wait()).To(MatchError(context.Canceled))
+		Expect(publisher.staged).To(BeEmpty())
+	})
+})
+
+// testStagingPublisher records the staged release targets and the maximum number of the concurrent stagings.
+type testStagingPublisher struct {
+	Interface
+
+	delay      time.Duration
+	failTarget string
+
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	staged     map[string]string
+}
+
+func (p *testStagingPublisher) StageReleaseTarget(_ context.Context, _ RepositoryInterface, _, releaseFilePath string, data io.Reader) error {
+	p.mu.Lock()
+	p.running++
+	if p.running > p.maxRunning {
+		p.maxRunning = p.running
+	}
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}()
+
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	time.Sleep(p.delay)
+
+	if releaseFilePath == p.failTarget {
+		return errors.New("access denied")
+	}
+
+	p.mu.Lock()
+	p.staged[releaseFilePath] = string(content)
+	p.mu.Unlock()
+
+	return nil
+}
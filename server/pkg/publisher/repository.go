@@ -2,28 +2,46 @@ package publisher
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/hashicorp/go-hclog"
 	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/data"
 
 	"github.com/werf/trdl/server/pkg/util"
 )
 
 type S3Options struct {
-	AwsConfig  *aws.Config
-	BucketName string
+	AwsConfig     *aws.Config
+	BucketName    string
+	CACertificate string
+	UploadOptions S3UploadOptions
 }
 
 type TufRepoOptions struct {
 	PrivKeys TufRepoPrivKeys
+	// ConsistentSnapshot is used to initialize the new repository, the existing one is migrated by EnableConsistentSnapshot.
+	ConsistentSnapshot bool
 }
 
-func NewRepositoryWithOptions(s3Options S3Options, tufRepoOptions TufRepoOptions, logger hclog.Logger) (*S3Repository, error) {
+func (s3Options S3Options) newFilesystem(logger hclog.Logger) *S3Filesystem {
 	s3fs := NewS3Filesystem(s3Options.AwsConfig, s3Options.BucketName, logger)
+	s3fs.CACertificate = s3Options.CACertificate
+	s3fs.UploadOptions = s3Options.UploadOptions
+
+	return s3fs
+}
+
+func NewRepositoryWithOptions(s3Options S3Options, tufRepoOptions TufRepoOptions, logger hclog.Logger) (*S3Repository, error) {
+	s3fs := s3Options.newFilesystem(logger)
 	tufStore := NewNonAtomicTufStore(tufRepoOptions.PrivKeys, s3fs, logger)
 
 	tufRepo, err := tuf.NewRepo(tufStore)
@@ -32,6 +50,7 @@ func NewRepositoryWithOptions(s3Options S3Options, tufRepoOptions TufRepoOptions
 	}
 
 	repository := NewRepository(s3fs, tufStore, tufRepo, logger)
+	repository.initConsistentSnapshot = tufRepoOptions.ConsistentSnapshot
 
 	if err := tufStore.PrivKeys.SetupStoreSigners(tufStore); err != nil {
 		return nil, fmt.Errorf("unable to set private keys into tuf store: %w", err)
@@ -45,7 +64,17 @@ type S3Repository struct {
 	TufStore     *NonAtomicTufStore
 	TufRepo      *tuf.Repo
 
-	logger hclog.Logger
+	// stagedTargets are registered in the TUF repository metadata only on commit,
+	// so the metadata changed by other tasks since the repository was opened is not overwritten
+	stagedTargets   []string
+	stagedTargetsMu sync.Mutex
+	logger          hclog.Logger
+
+	initConsistentSnapshot bool
+
+	// mirrors receive the committed targets and metadata, the results are kept until TakeMirrorReplications
+	mirrors      []repositoryMirror
+	replications []MirrorReplication
 }
 
 func NewRepository(s3Filesystem *S3Filesystem, tufStore *NonAtomicTufStore, tufRepo *tuf.Repo, logger hclog.Logger) *S3Repository {
@@ -86,6 +115,11 @@ func (repository *S3Repository) GenPrivKeys() error {
 		return fmt.Errorf("error generating tuf repository root key: %w", err)
 	}
 
+	return repository.GenOnlinePrivKeys()
+}
+
+// GenOnlinePrivKeys generates the keys of all roles except root, which keys are kept offline.
+func (repository *S3Repository) GenOnlinePrivKeys() error {
 	if _, err := repository.TufRepo.GenKey("targets"); err != nil {
 		return fmt.Errorf("error generating tuf repository targets key: %w", err)
 	}
@@ -108,7 +142,18 @@ func (repository *S3Repository) RotatePrivKeys(ctx context.Context) (bool, TufRe
 }
 
 func (repository *S3Repository) Init() error {
-	err := repository.TufRepo.Init(false)
+	// go-tuf allows the initialization until the targets are published,
+	// but the published root.json is kept, because it may be signed by the offline root keys
+	meta, err := repository.TufStore.GetMeta()
+	if err != nil {
+		return fmt.Errorf("unable to get tuf repository meta: %w", err)
+	}
+	if _, ok := meta["root.json"]; ok {
+		repository.logger.Info("Tuf repository already initialized: skip initialization")
+		return nil
+	}
+
+	err = repository.TufRepo.Init(repository.initConsistentSnapshot)
 
 	if err == tuf.ErrInitNotAllowed {
 		repository.logger.Info("Tuf repository already initialized: skip initialization")
@@ -124,31 +169,225 @@ func (repository *S3Repository) StageTarget(ctx context.Context, pathInsideTarge
 		return fmt.Errorf("unable to add staged file %q: %w", pathInsideTargets, err)
 	}
 
-	if err := repository.TufRepo.AddTarget(pathInsideTargets, json.RawMessage("")); err != nil {
-		return fmt.Errorf("unable to register target file %q in the tuf repo: %w", pathInsideTargets, err)
+	repository.stagedTargetsMu.Lock()
+	repository.stagedTargets = append(repository.stagedTargets, pathInsideTargets)
+	repository.stagedTargetsMu.Unlock()
+
+	return nil
+}
+
+// SetMirrors sets the mirrors the committed changes are replicated to.
+func (repository *S3Repository) SetMirrors(mirrors []MirrorOptions) {
+	repository.mirrors = nil
+	for _, opts := range mirrors {
+		repository.mirrors = append(repository.mirrors, repositoryMirror{name: opts.Name, filesystem: opts.newFilesystem(repository.logger)})
+	}
+}
+
+// replicate copies the committed target objects and then the committed metadata to the mirrors.
+// The failure does not fail the commit, the repository is already changed, the mirror is resynced later.
+func (repository *S3Repository) replicate(ctx context.Context, targetObjectPaths []string) {
+	metadataPaths := repository.TufStore.TakeCommittedMetadataPaths()
+	if len(repository.mirrors) == 0 {
+		return
+	}
+
+	sortMetadataPaths(metadataPaths)
+	objectPaths := append(append([]string{}, targetObjectPaths...), metadataPaths...)
+
+	if len(objectPaths) == 0 {
+		return
+	}
+
+	for _, mirror := range repository.mirrors {
+		err := replicateObjects(ctx, repository.TufStore.Filesystem, mirror.filesystem, objectPaths)
+		if err != nil {
+			repository.logger.Warn(fmt.Sprintf("Unable to replicate the repository changes to the mirror %q: %s", mirror.name, err))
+		} else {
+			repository.logger.Debug(fmt.Sprintf("Replicated %d objects to the mirror %q", len(objectPaths), mirror.name))
+		}
+
+		repository.replications = append(repository.replications, MirrorReplication{Mirror: mirror.name, Time: time.Now(), Err: err})
+	}
+}
+
+// ResyncMirror replicates all objects of the repository to the mirror: the targets and then the metadata.
+func (repository *S3Repository) ResyncMirror(ctx context.Context, name string) error {
+	var mirror *repositoryMirror
+	for i := range repository.mirrors {
+		if repository.mirrors[i].name == name {
+			mirror = &repository.mirrors[i]
+		}
+	}
+
+	if mirror == nil {
+		return fmt.Errorf("mirror %q not found", name)
+	}
+
+	objectPaths, err := repository.TufStore.Filesystem.ListFiles(ctx, "")
+	if err != nil {
+		return fmt.Errorf("unable to list repository objects: %w", err)
+	}
+
+	var targetPaths, metadataPaths []string
+	for _, p := range objectPaths {
+		if isTargetPath(p) {
+			targetPaths = append(targetPaths, p)
+		} else {
+			metadataPaths = append(metadataPaths, p)
+		}
+	}
+	sort.Strings(targetPaths)
+	sortMetadataPaths(metadataPaths)
+
+	err = replicateObjects(ctx, repository.TufStore.Filesystem, mirror.filesystem, append(targetPaths, metadataPaths...))
+	repository.replications = append(repository.replications, MirrorReplication{Mirror: name, Time: time.Now(), Resync: true, Err: err})
+	if err != nil {
+		return fmt.Errorf("unable to resync mirror %q: %w", name, err)
 	}
 
 	return nil
 }
 
-func (repository *S3Repository) UpdateTimestamps(_ context.Context, systemClock util.Clock) error {
-	return NewTufRepoRotator(repository.TufRepo).Rotate(repository.logger, systemClock.Now())
+// TakeMirrorReplications returns the results of the replications to the mirrors and resets the list.
+func (repository *S3Repository) TakeMirrorReplications() []MirrorReplication {
+	replications := repository.replications
+	repository.replications = nil
+
+	return replications
+}
+
+// reloadTufRepo loads the actual TUF repository metadata, the changes not committed yet are kept by the store.
+func (repository *S3Repository) reloadTufRepo() error {
+	tufRepo, err := tuf.NewRepo(repository.TufStore)
+	if err != nil {
+		return fmt.Errorf("error loading tuf repo: %w", err)
+	}
+
+	repository.TufRepo = tufRepo
+
+	return nil
 }
 
-func (repository *S3Repository) CommitStaged(_ context.Context) error {
+// RoleMetadata is the version and the expiration time of the TUF repository role metadata.
+// Published is false if the role metadata is not published yet, the version and the expiration time are not set in that case.
+type RoleMetadata struct {
+	Published bool      `json:"-"`
+	Version   int64     `json:"version"`
+	Expires   time.Time `json:"expires"`
+}
+
+// GetRolesMetadata returns the versions and the expiration times of the top-level TUF repository metadata by role.
+// The roles of the configured but not yet published repository are returned as not published.
+func (repository *S3Repository) GetRolesMetadata(_ context.Context) (map[string]RoleMetadata, error) {
+	meta, err := repository.TufStore.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get TUF-repo metadata: %w", err)
+	}
+
+	rolesMetadata := map[string]RoleMetadata{}
+	for _, name := range topLevelManifests {
+		role := strings.TrimSuffix(name, ".json")
+
+		raw, ok := meta[name]
+		if !ok {
+			rolesMetadata[role] = RoleMetadata{}
+			continue
+		}
+
+		signed := &data.Signed{}
+		if err := json.Unmarshal(raw, signed); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal TUF-repo metadata %q: %w", name, err)
+		}
+
+		roleMetadata := RoleMetadata{Published: true}
+		if err := json.Unmarshal(signed.Signed, &roleMetadata); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal TUF-repo metadata %q: %w", name, err)
+		}
+
+		rolesMetadata[role] = roleMetadata
+	}
+
+	return rolesMetadata, nil
+}
+
+// Verify checks the repository objects against the committed TUF repository metadata.
+func (repository *S3Repository) Verify(ctx context.Context) (*VerificationReport, error) {
+	return VerifyTufStore(ctx, repository.TufStore)
+}
+
+func (repository *S3Repository) UpdateTimestamps(ctx context.Context, systemClock util.Clock) error {
+	if err := repository.reloadTufRepo(); err != nil {
+		return err
+	}
+
+	if err := repository.checkRootSigned(); err == ErrUnsignedRoot {
+		repository.logger.Info("Root.json is not published yet: skipping timestamps update")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	hasRootSigners, err := repository.hasRootSigners()
+	if err != nil {
+		return err
+	}
+
+	rotator := NewTufRepoRotator(repository.TufRepo)
+	rotator.OfflineRoot = !hasRootSigners
+	if err := rotator.Rotate(repository.logger, systemClock.Now()); err != nil {
+		return err
+	}
+
+	repository.replicate(ctx, nil)
+
+	return nil
+}
+
+func (repository *S3Repository) CommitStaged(ctx context.Context) error {
+	if err := repository.reloadTufRepo(); err != nil {
+		return err
+	}
+
+	if err := repository.checkRootSigned(); err != nil {
+		return err
+	}
+
+	// the hashes are computed while uploading, so the staged targets are not downloaded back to be registered
+	for _, target := range repository.stagedTargets {
+		fileMeta, ok := repository.TufStore.StagedTargetFileMeta(target)
+		if !ok {
+			return fmt.Errorf("unable to register staged target file %q in the tuf repo: file is not staged", target)
+		}
+
+		if err := repository.TufRepo.AddTargetsWithDigest(hex.EncodeToString(fileMeta.Hashes[targetHashAlgorithm]), targetHashAlgorithm, fileMeta.Length, target, nil); err != nil {
+			return fmt.Errorf("unable to register staged target file %q in the tuf repo: %w", target, err)
+		}
+	}
+
 	if err := repository.TufRepo.Snapshot(); err != nil {
 		return fmt.Errorf("tuf repo snapshot failed: %w", err)
 	}
 	if err := repository.TufRepo.Timestamp(); err != nil {
 		return fmt.Errorf("tuf repo timestamp failed: %w", err)
 	}
+
+	targetObjectPaths := repository.TufStore.StagedTargetObjectPaths()
 	if err := repository.TufRepo.Commit(); err != nil {
 		return fmt.Errorf("unable to commit staged changes into the repo: %w", err)
 	}
+
+	repository.replicate(ctx, targetObjectPaths)
+	repository.stagedTargets = nil
+
 	return nil
 }
 
 func (repository *S3Repository) GetTargets(ctx context.Context) ([]string, error) {
+	if err := repository.reloadTufRepo(); err != nil {
+		return nil, err
+	}
+
 	targetsMeta, err := repository.TufRepo.Targets()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get TUF-repo targets metadata: %w", err)
@@ -160,3 +399,27 @@ func (repository *S3Repository) GetTargets(ctx context.Context) ([]string, error
 	}
 	return res, nil
 }
+
+// GetVersions returns the versions of the TUF repository metadata by role.
+func (repository *S3Repository) GetVersions(_ context.Context) (map[string]int64, error) {
+	if err := repository.reloadTufRepo(); err != nil {
+		return nil, err
+	}
+
+	versions := map[string]int64{}
+	for role, getVersion := range map[string]func() (int64, error){
+		"root":      repository.TufRepo.RootVersion,
+		"targets":   repository.TufRepo.TargetsVersion,
+		"snapshot":  repository.TufRepo.SnapshotVersion,
+		"timestamp": repository.TufRepo.TimestampVersion,
+	} {
+		version, err := getVersion()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get TUF-repo %s version: %w", role, err)
+		}
+
+		versions[role] = version
+	}
+
+	return versions, nil
+}
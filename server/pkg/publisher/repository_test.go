@@ -0,0 +1,41 @@
+package publisher
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GetRolesMetadata", func() {
+	var fs *testMemoryFilesystem
+	var repository *S3Repository
+
+	BeforeEach(func() {
+		fs = &testMemoryFilesystem{files: map[string][]byte{}}
+		repository = &S3Repository{TufStore: NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger()), logger: hclog.NewNullLogger()}
+	})
+
+	It("should return the roles of the empty repository as not published", func() {
+		rolesMetadata, err := repository.GetRolesMetadata(context.Background())
+		Expect(err).To(Succeed())
+		Expect(rolesMetadata).To(Equal(map[string]RoleMetadata{
+			"root":      {},
+			"targets":   {},
+			"snapshot":  {},
+			"timestamp": {},
+		}))
+	})
+
+	It("should return the versions and the expiration times of the published roles", func() {
+		expires := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+		fs.files["timestamp.json"] = []byte(`{"signed":{"_type":"timestamp","version":42,"expires":"2026-01-01T00:00:00Z"},"signatures":[]}`)
+
+		rolesMetadata, err := repository.GetRolesMetadata(context.Background())
+		Expect(err).To(Succeed())
+		Expect(rolesMetadata["timestamp"]).To(Equal(RoleMetadata{Published: true, Version: 42, Expires: expires}))
+		Expect(rolesMetadata["root"]).To(Equal(RoleMetadata{}))
+	})
+})
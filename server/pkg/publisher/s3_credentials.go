@@ -0,0 +1,120 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	// S3CredentialsSourceStatic is the access key id and secret access key stored in the configuration.
+	S3CredentialsSourceStatic = "static"
+	// S3CredentialsSourceVault is the AWS secrets engine of Vault which issues the short-lived credentials.
+	S3CredentialsSourceVault = "vault"
+	// S3CredentialsSourceProcess is the command printing the credentials in the AWS credential_process format.
+	S3CredentialsSourceProcess = "process"
+)
+
+var S3CredentialsSources = []string{S3CredentialsSourceStatic, S3CredentialsSourceVault, S3CredentialsSourceProcess}
+
+// s3CredentialsExpiryWindow is the time before the expiration the credentials are refreshed at.
+const s3CredentialsExpiryWindow = time.Minute
+
+// S3CredentialsOptions select the source of the S3 credentials, the static keys are used by default.
+type S3CredentialsOptions struct {
+	Source string
+	// VaultAddress, VaultToken and VaultPath are used to read the credentials from the AWS secrets engine,
+	// e.g. aws/creds/<role> or aws/sts/<role>. The token must be renewed by its owner, e.g. the periodic token.
+	VaultAddress string
+	VaultToken   string
+	VaultPath    string
+	// Process is the command line printing the credentials in the AWS credential_process JSON format.
+	Process string
+}
+
+func (opts S3CredentialsOptions) Validate() error {
+	switch opts.Source {
+	case "", S3CredentialsSourceStatic:
+	case S3CredentialsSourceVault:
+		if opts.VaultAddress == "" || opts.VaultToken == "" || opts.VaultPath == "" {
+			return fmt.Errorf("vault address, token and path are required for the %q credentials source", S3CredentialsSourceVault)
+		}
+	case S3CredentialsSourceProcess:
+		if opts.Process == "" {
+			return fmt.Errorf("process command is required for the %q credentials source", S3CredentialsSourceProcess)
+		}
+	default:
+		return fmt.Errorf("unsupported credentials source %q: expected one of %s", opts.Source, strings.Join(S3CredentialsSources, ", "))
+	}
+
+	return nil
+}
+
+// newCredentials returns nil for the static credentials.
+// The returned credentials are cached until the expiration, so the source is requested once per repository usually.
+func (opts S3CredentialsOptions) newCredentials() *credentials.Credentials {
+	switch opts.Source {
+	case S3CredentialsSourceVault:
+		return credentials.NewCredentials(&vaultAWSCredentialsProvider{address: opts.VaultAddress, token: opts.VaultToken, path: opts.VaultPath})
+	case S3CredentialsSourceProcess:
+		return processcreds.NewCredentials(opts.Process, func(p *processcreds.ProcessProvider) {
+			p.ExpiryWindow = s3CredentialsExpiryWindow
+		})
+	default:
+		return nil
+	}
+}
+
+// vaultAWSCredentialsProvider reads the credentials issued by the AWS secrets engine of Vault.
+type vaultAWSCredentialsProvider struct {
+	credentials.Expiry
+
+	address string
+	token   string
+	path    string
+}
+
+func (p *vaultAWSCredentialsProvider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+func (p *vaultAWSCredentialsProvider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	client, err := api.NewClient(&api.Config{Address: p.address})
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("unable to create vault client: %w", err)
+	}
+	client.SetToken(p.token)
+
+	secret, err := client.Logical().ReadWithContext(ctx, p.path)
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("unable to read vault path %q: %w", p.path, err)
+	}
+
+	if secret == nil || secret.Data == nil {
+		return credentials.Value{}, fmt.Errorf("no credentials found by vault path %q", p.path)
+	}
+
+	accessKeyID, _ := secret.Data["access_key"].(string)
+	secretAccessKey, _ := secret.Data["secret_key"].(string)
+	sessionToken, _ := secret.Data["security_token"].(string)
+	if accessKeyID == "" || secretAccessKey == "" {
+		return credentials.Value{}, fmt.Errorf("no access_key or secret_key found by vault path %q", p.path)
+	}
+
+	// the credentials without the lease are not refreshed
+	if secret.LeaseDuration > 0 {
+		p.SetExpiration(time.Now().Add(time.Duration(secret.LeaseDuration)*time.Second), s3CredentialsExpiryWindow)
+	}
+
+	return credentials.Value{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+		ProviderName:    "VaultAWSCredentialsProvider",
+	}, nil
+}
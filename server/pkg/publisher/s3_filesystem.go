@@ -3,8 +3,11 @@ package publisher
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -15,9 +18,53 @@ import (
 	"github.com/hashicorp/go-hclog"
 )
 
+// S3UploadOptions are applied to every object uploaded to the bucket.
+type S3UploadOptions struct {
+	// ServerSideEncryption is either empty, AES256 (SSE-S3) or aws:kms (SSE-KMS).
+	ServerSideEncryption string
+	// SSEKMSKeyID is the KMS key used with aws:kms, the bucket default key is used if empty.
+	SSEKMSKeyID  string
+	StorageClass string
+}
+
+func (opts S3UploadOptions) Validate() error {
+	if opts.ServerSideEncryption != "" && !isOneOf(opts.ServerSideEncryption, s3.ServerSideEncryption_Values()) {
+		return fmt.Errorf("unsupported server side encryption %q: expected one of %s", opts.ServerSideEncryption, strings.Join(s3.ServerSideEncryption_Values(), ", "))
+	}
+
+	if opts.SSEKMSKeyID != "" && opts.ServerSideEncryption != s3.ServerSideEncryptionAwsKms {
+		return fmt.Errorf("kms key id requires the %q server side encryption", s3.ServerSideEncryptionAwsKms)
+	}
+
+	if opts.StorageClass != "" && !isOneOf(opts.StorageClass, s3.StorageClass_Values()) {
+		return fmt.Errorf("unsupported storage class %q: expected one of %s", opts.StorageClass, strings.Join(s3.StorageClass_Values(), ", "))
+	}
+
+	return nil
+}
+
+func validateCACertificate(caCertificate string) error {
+	if !x509.NewCertPool().AppendCertsFromPEM([]byte(caCertificate)) {
+		return fmt.Errorf("no PEM-encoded certificates found")
+	}
+	return nil
+}
+
+func isOneOf(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 type S3Filesystem struct {
 	AwsConfig  *aws.Config
 	BucketName string
+	// CACertificate is the PEM-encoded CA bundle the endpoint certificate is verified with instead of the system one.
+	CACertificate string
+	UploadOptions S3UploadOptions
 
 	logger hclog.Logger
 
@@ -25,7 +72,8 @@ type S3Filesystem struct {
 }
 
 func NewS3Filesystem(awsConfig *aws.Config, bucketName string, logger hclog.Logger) *S3Filesystem {
-	if !strings.Contains(*awsConfig.Endpoint, "s3.amazonaws.com") {
+	// path-style addressing is used for the endpoints other than AWS unless it is forced by the configuration
+	if awsConfig.S3ForcePathStyle == nil && !strings.Contains(*awsConfig.Endpoint, "s3.amazonaws.com") {
 		awsConfig.S3ForcePathStyle = new(bool)
 		*awsConfig.S3ForcePathStyle = true
 	}
@@ -33,8 +81,21 @@ func NewS3Filesystem(awsConfig *aws.Config, bucketName string, logger hclog.Logg
 	return &S3Filesystem{AwsConfig: awsConfig, BucketName: bucketName, logger: logger}
 }
 
+func (fs *S3Filesystem) newSession() (*session.Session, error) {
+	opts := session.Options{}
+	opts.Config.MergeIn(fs.AwsConfig)
+
+	// the session option takes precedence over the AWS_CA_BUNDLE of the plugin host
+	if fs.CACertificate != "" {
+		opts.Config.HTTPClient = &http.Client{}
+		opts.CustomCABundle = strings.NewReader(fs.CACertificate)
+	}
+
+	return session.NewSessionWithOptions(opts)
+}
+
 func (fs *S3Filesystem) IsFileExist(ctx context.Context, path string) (bool, error) {
-	sess, err := session.NewSession(fs.AwsConfig)
+	sess, err := fs.newSession()
 	if err != nil {
 		return false, fmt.Errorf("error opening s3 session: %w", err)
 	}
@@ -59,7 +120,7 @@ func (fs *S3Filesystem) IsFileExist(ctx context.Context, path string) (bool, err
 }
 
 func (fs *S3Filesystem) ReadFile(ctx context.Context, path string, writerAt io.WriterAt) error {
-	sess, err := session.NewSession(fs.AwsConfig)
+	sess, err := fs.newSession()
 	if err != nil {
 		return fmt.Errorf("error opening s3 session: %w", err)
 	}
@@ -97,7 +158,7 @@ func (fw sequentialWriterAt) WriteAt(p []byte, offset int64) (int, error) {
 }
 
 func (fs *S3Filesystem) ReadFileStream(ctx context.Context, path string, writer io.Writer) error {
-	sess, err := session.NewSession(fs.AwsConfig)
+	sess, err := fs.newSession()
 	if err != nil {
 		return fmt.Errorf("error opening s3 session: %w", err)
 	}
@@ -122,7 +183,7 @@ func (fs *S3Filesystem) ReadFileStream(ctx context.Context, path string, writer
 }
 
 func (fs *S3Filesystem) ReadFileBytes(ctx context.Context, path string) ([]byte, error) {
-	sess, err := session.NewSession(fs.AwsConfig)
+	sess, err := fs.newSession()
 	if err != nil {
 		return nil, fmt.Errorf("error opening s3 session: %w", err)
 	}
@@ -145,15 +206,83 @@ func (fs *S3Filesystem) ReadFileBytes(ctx context.Context, path string) ([]byte,
 	return buf.Bytes(), nil
 }
 
+// ListFiles returns the paths of all objects with the prefix.
+func (fs *S3Filesystem) ListFiles(ctx context.Context, prefix string) ([]string, error) {
+	sess, err := fs.newSession()
+	if err != nil {
+		return nil, fmt.Errorf("error opening s3 session: %w", err)
+	}
+
+	svc := s3.New(sess)
+
+	var paths []string
+	if err := svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: &fs.BucketName,
+		Prefix: &prefix,
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			paths = append(paths, aws.StringValue(object.Key))
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("error listing s3 objects by prefix %q: %w", prefix, err)
+	}
+
+	fs.logger.Debug(fmt.Sprintf("-- S3Filesystem.ListFiles %q -> %d objects", prefix, len(paths)))
+
+	return paths, nil
+}
+
+func (fs *S3Filesystem) DeleteFile(ctx context.Context, path string) error {
+	sess, err := fs.newSession()
+	if err != nil {
+		return fmt.Errorf("error opening s3 session: %w", err)
+	}
+
+	svc := s3.New(sess)
+
+	if _, err := svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: &fs.BucketName,
+		Key:    &path,
+	}); err != nil {
+		return fmt.Errorf("error deleting s3 object by key %q: %w", path, err)
+	}
+
+	return nil
+}
+
 func (fs *S3Filesystem) WriteFileBytes(ctx context.Context, path string, data []byte) error {
 	return fs.WriteFileStream(ctx, path, bytes.NewReader(data))
 }
 
+const (
+	// immutableCacheControl is set for the consistent snapshot objects, which are never overwritten by the following commits.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// mutableCacheControl is set for the other objects: timestamp.json, the unversioned metadata and targets.
+	mutableCacheControl = "no-store"
+)
+
+var (
+	// versionedTopLevelMetadataRegexp matches the metadata copies with the version prefix, e.g. 3.targets.json.
+	versionedTopLevelMetadataRegexp = regexp.MustCompile(`^\d+\.[^/]+\.json$`)
+	// hashedTargetRegexp matches the targets with the hash prefix, e.g. targets/channels/1/<sha512>.stable.
+	hashedTargetRegexp = regexp.MustCompile(`^targets/(.+/)?[0-9a-f]{64,128}\.[^/]+$`)
+)
+
+// objectCacheControl allows the clients and CDNs to cache the consistent snapshot objects forever.
+func objectCacheControl(path string) string {
+	if versionedTopLevelMetadataRegexp.MatchString(path) || hashedTargetRegexp.MatchString(path) {
+		return immutableCacheControl
+	}
+
+	return mutableCacheControl
+}
+
 func (fs *S3Filesystem) WriteFileStream(ctx context.Context, path string, data io.Reader) error {
 	// TODO: cache opened session
-	cacheControl := "no-store"
+	cacheControl := objectCacheControl(path)
 
-	sess, err := session.NewSession(fs.AwsConfig)
+	sess, err := fs.newSession()
 	if err != nil {
 		return fmt.Errorf("error opening s3 session: %w", err)
 	}
@@ -166,6 +295,15 @@ func (fs *S3Filesystem) WriteFileStream(ctx context.Context, path string, data i
 		Body:         data,
 		CacheControl: &cacheControl,
 	}
+	if fs.UploadOptions.ServerSideEncryption != "" {
+		upParams.ServerSideEncryption = aws.String(fs.UploadOptions.ServerSideEncryption)
+	}
+	if fs.UploadOptions.SSEKMSKeyID != "" {
+		upParams.SSEKMSKeyId = aws.String(fs.UploadOptions.SSEKMSKeyID)
+	}
+	if fs.UploadOptions.StorageClass != "" {
+		upParams.StorageClass = aws.String(fs.UploadOptions.StorageClass)
+	}
 
 	result, err := uploader.UploadWithContext(ctx, upParams, func(u *s3manager.Uploader) {
 		u.LeavePartsOnError = false
@@ -0,0 +1,177 @@
+package publisher
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/hashicorp/go-hclog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("S3 filesystem", func() {
+	var ctx context.Context
+	var server *httptest.Server
+	var bucket *testS3Bucket
+	var caCertificate string
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		bucket = &testS3Bucket{objects: map[string]testS3Object{}}
+		server = httptest.NewTLSServer(bucket)
+		caCertificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newS3Filesystem := func(options RepositoryOptions) *S3Filesystem {
+		return options.s3Options().newFilesystem(hclog.NewNullLogger())
+	}
+
+	It("should upload with the encryption and storage class and read through the custom CA", func() {
+		fs := newS3Filesystem(RepositoryOptions{
+			S3Endpoint:        server.URL,
+			S3Region:          "us-east-1",
+			S3AccessKeyID:     "minio",
+			S3SecretAccessKey: "minio123",
+			S3BucketName:      "trdl",
+			S3ForcePathStyle:  true,
+			S3CACertificate:   caCertificate,
+			S3UploadOptions: S3UploadOptions{
+				ServerSideEncryption: "aws:kms",
+				SSEKMSKeyID:          "trdl-key",
+				StorageClass:         "STANDARD_IA",
+			},
+		})
+
+		Expect(fs.WriteFileBytes(ctx, "targets/channels/1/stable", []byte("1.0.0\n"))).To(Succeed())
+
+		object, ok := bucket.get("/trdl/targets/channels/1/stable")
+		Expect(ok).To(BeTrue())
+		Expect(object.header.Get("X-Amz-Server-Side-Encryption")).To(Equal("aws:kms"))
+		Expect(object.header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")).To(Equal("trdl-key"))
+		Expect(object.header.Get("X-Amz-Storage-Class")).To(Equal("STANDARD_IA"))
+
+		data, err := fs.ReadFileBytes(ctx, "targets/channels/1/stable")
+		Expect(err).To(Succeed())
+		Expect(string(data)).To(Equal("1.0.0\n"))
+
+		exist, err := fs.IsFileExist(ctx, "targets/channels/1/stable")
+		Expect(err).To(Succeed())
+		Expect(exist).To(BeTrue())
+	})
+
+	It("should cache only the consistent snapshot objects", func() {
+		fs := newS3Filesystem(RepositoryOptions{S3Endpoint: server.URL, S3Region: "us-east-1", S3AccessKeyID: "minio", S3SecretAccessKey: "minio123", S3BucketName: "trdl", S3ForcePathStyle: true, S3CACertificate: caCertificate})
+
+		hash := strings.Repeat("0a", 64)
+		for path, expectedCacheControl := range map[string]string{
+			"timestamp.json":                         mutableCacheControl,
+			"snapshot.json":                          mutableCacheControl,
+			"root.json":                              mutableCacheControl,
+			"3.snapshot.json":                        immutableCacheControl,
+			"1.root.json":                            immutableCacheControl,
+			"targets/channels/1/stable":              mutableCacheControl,
+			"targets/channels/1/" + hash + ".stable": immutableCacheControl,
+			"targets/releases/1.0.0/linux-amd64/app": mutableCacheControl,
+			"targets/" + hash + ".trdl-pgp-keys.asc": immutableCacheControl,
+		} {
+			Expect(fs.WriteFileBytes(ctx, path, []byte("data"))).To(Succeed())
+
+			object, ok := bucket.get("/trdl/" + path)
+			Expect(ok).To(BeTrue())
+			Expect(object.header.Get("Cache-Control")).To(Equal(expectedCacheControl), path)
+		}
+	})
+
+	It("should not trust the endpoint without the custom CA", func() {
+		fs := newS3Filesystem(RepositoryOptions{S3Endpoint: server.URL, S3Region: "us-east-1", S3AccessKeyID: "minio", S3SecretAccessKey: "minio123", S3BucketName: "trdl"})
+		fs.AwsConfig.MaxRetries = aws.Int(0)
+
+		Expect(fs.WriteFileBytes(ctx, "root.json", []byte("{}"))).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("should probe the bucket access", func() {
+		options := RepositoryOptions{S3Endpoint: server.URL, S3Region: "us-east-1", S3AccessKeyID: "minio", S3SecretAccessKey: "minio123", S3BucketName: "trdl", S3CACertificate: caCertificate}
+		Expect(options.ProbeS3(ctx, hclog.NewNullLogger())).To(Succeed())
+		Expect(bucket.objects).To(BeEmpty())
+
+		options.S3CACertificate = ""
+		Expect(options.ProbeS3(ctx, hclog.NewNullLogger())).To(MatchError(ContainSubstring("write probe failed")))
+	})
+
+	It("should validate the extended options", func() {
+		Expect(RepositoryOptions{S3CACertificate: caCertificate, S3UploadOptions: S3UploadOptions{ServerSideEncryption: "AES256", StorageClass: "GLACIER"}}.ValidateS3()).To(Succeed())
+		Expect(RepositoryOptions{S3CACertificate: "certificate"}.ValidateS3()).To(HaveOccurred())
+		Expect(RepositoryOptions{S3UploadOptions: S3UploadOptions{ServerSideEncryption: "aws:unknown"}}.ValidateS3()).To(HaveOccurred())
+		Expect(RepositoryOptions{S3UploadOptions: S3UploadOptions{SSEKMSKeyID: "trdl-key"}}.ValidateS3()).To(HaveOccurred())
+		Expect(RepositoryOptions{S3UploadOptions: S3UploadOptions{StorageClass: "COLD"}}.ValidateS3()).To(HaveOccurred())
+	})
+})
+
+type testS3Object struct {
+	data   []byte
+	header http.Header
+}
+
+// testS3Bucket is the minimal path-style S3 API stand-in which serves the objects uploaded with a single request.
+type testS3Bucket struct {
+	mu      sync.Mutex
+	objects map[string]testS3Object
+}
+
+func (b *testS3Bucket) get(path string) (testS3Object, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	object, ok := b.objects[path]
+	return object, ok
+}
+
+func (b *testS3Bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		b.mu.Lock()
+		b.objects[r.URL.Path] = testS3Object{data: data, header: r.Header.Clone()}
+		b.mu.Unlock()
+	case http.MethodGet, http.MethodHead:
+		object, ok := b.get(r.URL.Path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// the whole object is always returned, which is enough for the objects smaller than the download part
+		w.Header().Set("Content-Length", fmt.Sprint(len(object.data)))
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(object.data)-1, len(object.data)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(object.data)
+		}
+	case http.MethodDelete:
+		b.mu.Lock()
+		delete(b.objects, r.URL.Path)
+		b.mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,520 @@
+package publisher
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/theupdateframework/go-tuf/data"
+
+	"github.com/werf/trdl/server/pkg/pgp"
+)
+
+const (
+	storageKeyTransit = "transit"
+
+	DefaultTransitMount     = "transit"
+	DefaultTransitKeyPrefix = "trdl-"
+
+	// transitKeyType marks the TUF key stored as the reference to the Transit key instead of the private key.
+	transitKeyType data.KeyType = "vault-transit"
+
+	transitKeyTypeTUF = "ed25519"
+	transitKeyTypePGP = "rsa-4096"
+
+	// transitRequestTimeout limits the signing calls which have no context.
+	transitRequestTimeout = time.Minute
+)
+
+// TransitOptions configure the Vault Transit mount the new signing keys are created and kept in.
+// The token must be allowed to create and read <mount>/keys/<prefix>* and to update <mount>/sign/<prefix>*,
+// the rotation of the PGP signing key also updates <mount>/keys/<prefix>pgp/rotate.
+type TransitOptions struct {
+	VaultAddress string `json:"vault_address"`
+	VaultToken   string `json:"vault_token"`
+	Mount        string `json:"mount"`
+	KeyPrefix    string `json:"key_prefix"`
+}
+
+func (opts TransitOptions) Validate() error {
+	if opts.VaultAddress == "" || opts.VaultToken == "" {
+		return fmt.Errorf("vault address and token must be set")
+	}
+
+	if opts.Mount == "" {
+		return fmt.Errorf("mount must be set")
+	}
+
+	return nil
+}
+
+func (opts TransitOptions) tufKeyName(role string) string {
+	return opts.KeyPrefix + "tuf-" + role
+}
+
+func (opts TransitOptions) pgpKeyName() string {
+	return opts.KeyPrefix + "pgp"
+}
+
+// GetTransitOptions returns nil if Transit is not configured.
+func GetTransitOptions(ctx context.Context, storage logical.Storage) (*TransitOptions, error) {
+	e, err := storage.Get(ctx, storageKeyTransit)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, nil
+	}
+
+	var opts TransitOptions
+	if err := json.Unmarshal(e.Value, &opts); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal transit options: %w", err)
+	}
+
+	return &opts, nil
+}
+
+func PutTransitOptions(ctx context.Context, storage logical.Storage, opts TransitOptions) error {
+	entry, err := logical.StorageEntryJSON(storageKeyTransit, opts)
+	if err != nil {
+		return fmt.Errorf("unable to create transit options storage entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put transit options: %w", err)
+	}
+
+	return nil
+}
+
+func DeleteTransitOptions(ctx context.Context, storage logical.Storage) error {
+	return storage.Delete(ctx, storageKeyTransit)
+}
+
+// transitKeysInUse returns true if the TUF repository keys or the PGP signing key are kept in Transit.
+func transitKeysInUse(ctx context.Context, storage logical.Storage) (bool, error) {
+	entry, err := storage.Get(ctx, storageKeyPGPSigningKeyTransit)
+	if err != nil {
+		return false, err
+	}
+	if entry != nil {
+		return true, nil
+	}
+
+	entry, err = storage.Get(ctx, storageKeyTufRepositoryKeys)
+	if err != nil || entry == nil {
+		return false, err
+	}
+
+	var privKeys TufRepoPrivKeys
+	if err := entry.DecodeJSON(&privKeys); err != nil {
+		return false, fmt.Errorf("unable to decode keys json by the %q storage key: %w", storageKeyTufRepositoryKeys, err)
+	}
+
+	return privKeys.IsTransit(), nil
+}
+
+// getTransitClient returns nils if Transit is not configured.
+func getTransitClient(ctx context.Context, storage logical.Storage) (*TransitOptions, *transitClient, error) {
+	opts, err := GetTransitOptions(ctx, storage)
+	if err != nil || opts == nil {
+		return nil, nil, err
+	}
+
+	client, err := newTransitClient(*opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return opts, client, nil
+}
+
+// newTransitTufRepoPrivKeys creates the missing keys, the existing keys are used by their latest versions.
+// The root key is not created if the root keys are kept offline.
+func newTransitTufRepoPrivKeys(ctx context.Context, client *transitClient, opts TransitOptions, withRoot bool) (TufRepoPrivKeys, error) {
+	privKeys := TufRepoPrivKeys{transit: client}
+
+	roles := []string{"targets", "snapshot", "timestamp"}
+	if withRoot {
+		roles = append([]string{"root"}, roles...)
+	}
+
+	for _, role := range roles {
+		ref, err := client.ensureKey(ctx, opts.Mount, opts.tufKeyName(role), transitKeyTypeTUF)
+		if err != nil {
+			return privKeys, err
+		}
+
+		signer, err := newTransitTufSigner(ctx, client, ref)
+		if err != nil {
+			return privKeys, err
+		}
+
+		if err := privKeys.SetKeyFromSigner(role, signer); err != nil {
+			return privKeys, fmt.Errorf("unable to set key for role %q: %w", role, err)
+		}
+	}
+
+	return privKeys, nil
+}
+
+// fetchTransitPGPSigningKey returns nil if the key is not kept in Transit and is not to be created there.
+func fetchTransitPGPSigningKey(ctx context.Context, storage logical.Storage, initializeKey bool) (*pgp.SigningKey, error) {
+	opts, client, err := getTransitClient(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := storage.Get(ctx, storageKeyPGPSigningKeyTransit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage pgp signing key reference by storage key %q: %w", storageKeyPGPSigningKeyTransit, err)
+	}
+
+	var ref transitKeyRef
+	switch {
+	case entry != nil:
+		if client == nil {
+			return nil, fmt.Errorf("the pgp signing key is kept in Vault Transit which is not configured")
+		}
+
+		if err := entry.DecodeJSON(&ref); err != nil {
+			return nil, fmt.Errorf("unable to decode pgp signing key reference by storage key %q: %w", storageKeyPGPSigningKeyTransit, err)
+		}
+	case opts != nil && initializeKey:
+		if ref, err = client.ensureKey(ctx, opts.Mount, opts.pgpKeyName(), transitKeyTypePGP); err != nil {
+			return nil, err
+		}
+
+		if err := putTransitPGPSigningKeyRef(ctx, storage, ref); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	return newTransitPGPSigningKey(ctx, client, ref)
+}
+
+// transitKeyRef pins the version of the Transit key, so the rotation of the key in Transit does not change the signing key
+// until the new version is taken explicitly.
+type transitKeyRef struct {
+	Mount   string `json:"mount"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+type transitKeyVersion struct {
+	Version      int
+	PublicKey    string
+	CreationTime time.Time
+}
+
+type transitClient struct {
+	client *api.Client
+}
+
+func newTransitClient(opts TransitOptions) (*transitClient, error) {
+	client, err := api.NewClient(&api.Config{Address: opts.VaultAddress, Timeout: transitRequestTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create vault client: %w", err)
+	}
+	client.SetToken(opts.VaultToken)
+
+	return &transitClient{client: client}, nil
+}
+
+// ensureKey creates the key if it does not exist and returns the reference to its latest version.
+func (c *transitClient) ensureKey(ctx context.Context, mount, name, keyType string) (transitKeyRef, error) {
+	ref := transitKeyRef{Mount: mount, Name: name}
+
+	latest, err := c.readLatestKeyVersion(ctx, mount, name)
+	if err != nil {
+		return ref, err
+	}
+
+	if latest == nil {
+		if _, err := c.client.Logical().WriteWithContext(ctx, path.Join(mount, "keys", name), map[string]interface{}{"type": keyType}); err != nil {
+			return ref, fmt.Errorf("unable to create transit key %q: %w", name, err)
+		}
+
+		if latest, err = c.readLatestKeyVersion(ctx, mount, name); err != nil {
+			return ref, err
+		} else if latest == nil {
+			return ref, fmt.Errorf("transit key %q not found after creation", name)
+		}
+	}
+
+	ref.Version = latest.Version
+
+	return ref, nil
+}
+
+// rotateKey creates the new version of the key, the key is created if it does not exist.
+// The reference to the new latest version is returned.
+func (c *transitClient) rotateKey(ctx context.Context, mount, name, keyType string) (transitKeyRef, error) {
+	ref, err := c.ensureKey(ctx, mount, name, keyType)
+	if err != nil {
+		return ref, err
+	}
+
+	if _, err := c.client.Logical().WriteWithContext(ctx, path.Join(mount, "keys", name, "rotate"), nil); err != nil {
+		return ref, fmt.Errorf("unable to rotate transit key %q: %w", name, err)
+	}
+
+	latest, err := c.readLatestKeyVersion(ctx, mount, name)
+	if err != nil {
+		return ref, err
+	} else if latest == nil {
+		return ref, fmt.Errorf("transit key %q not found after rotation", name)
+	}
+
+	ref.Version = latest.Version
+
+	return ref, nil
+}
+
+// readLatestKeyVersion returns nil if the key does not exist.
+func (c *transitClient) readLatestKeyVersion(ctx context.Context, mount, name string) (*transitKeyVersion, error) {
+	key, err := c.readKey(ctx, mount, name)
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	return key.version(name, key.LatestVersion)
+}
+
+func (c *transitClient) readKeyVersion(ctx context.Context, ref transitKeyRef) (*transitKeyVersion, error) {
+	key, err := c.readKey(ctx, ref.Mount, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("transit key %q not found", ref.Name)
+	}
+
+	return key.version(ref.Name, ref.Version)
+}
+
+type transitKey struct {
+	LatestVersion int `json:"latest_version"`
+	Keys          map[string]struct {
+		PublicKey    string    `json:"public_key"`
+		CreationTime time.Time `json:"creation_time"`
+	} `json:"keys"`
+}
+
+func (key transitKey) version(name string, version int) (*transitKeyVersion, error) {
+	v, ok := key.Keys[strconv.Itoa(version)]
+	if !ok {
+		return nil, fmt.Errorf("transit key %q version %d not found", name, version)
+	}
+
+	if v.PublicKey == "" {
+		return nil, fmt.Errorf("transit key %q version %d has no public key: an asymmetric key type expected", name, version)
+	}
+
+	return &transitKeyVersion{Version: version, PublicKey: v.PublicKey, CreationTime: v.CreationTime}, nil
+}
+
+func (c *transitClient) readKey(ctx context.Context, mount, name string) (*transitKey, error) {
+	secret, err := c.client.Logical().ReadWithContext(ctx, path.Join(mount, "keys", name))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read transit key %q: %w", name, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(secret.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var key transitKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("unable to decode transit key %q: %w", name, err)
+	}
+
+	return &key, nil
+}
+
+func (c *transitClient) sign(ctx context.Context, ref transitKeyRef, input []byte, params map[string]interface{}) ([]byte, error) {
+	body := map[string]interface{}{
+		"input":       base64.StdEncoding.EncodeToString(input),
+		"key_version": ref.Version,
+	}
+	for k, v := range params {
+		body[k] = v
+	}
+
+	secret, err := c.client.Logical().WriteWithContext(ctx, path.Join(ref.Mount, "sign", ref.Name), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign by transit key %q: %w", ref.Name, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("no signature returned by transit key %q", ref.Name)
+	}
+
+	// the signature is in the vault:v<version>:<base64> format
+	signature, _ := secret.Data["signature"].(string)
+	parts := strings.Split(signature, ":")
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected signature %q returned by transit key %q", signature, ref.Name)
+	}
+
+	return base64.StdEncoding.DecodeString(parts[2])
+}
+
+// transitTufKeyValue is the value of the TUF key stored as the reference to the Transit key.
+type transitTufKeyValue struct {
+	transitKeyRef
+	Public data.HexBytes `json:"public"`
+}
+
+// transitTufSigner signs the TUF metadata by the Transit ed25519 key.
+type transitTufSigner struct {
+	client *transitClient
+	ref    transitKeyRef
+	public *data.PublicKey
+	value  transitTufKeyValue
+}
+
+func newTransitTufSigner(ctx context.Context, client *transitClient, ref transitKeyRef) (*transitTufSigner, error) {
+	version, err := client.readKeyVersion(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := base64.StdEncoding.DecodeString(version.PublicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("unexpected public key of transit key %q: ed25519 key expected", ref.Name)
+	}
+
+	return newTransitTufSignerFromValue(client, transitTufKeyValue{transitKeyRef: ref, Public: public})
+}
+
+func newTransitTufSignerFromValue(client *transitClient, value transitTufKeyValue) (*transitTufSigner, error) {
+	publicValue, err := json.Marshal(struct {
+		Public data.HexBytes `json:"public"`
+	}{Public: value.Public})
+	if err != nil {
+		return nil, err
+	}
+
+	return &transitTufSigner{
+		client: client,
+		ref:    value.transitKeyRef,
+		value:  value,
+		public: &data.PublicKey{
+			Type:       data.KeyTypeEd25519,
+			Scheme:     data.KeySchemeEd25519,
+			Algorithms: data.HashAlgorithms,
+			Value:      publicValue,
+		},
+	}, nil
+}
+
+// MarshalPrivateKey returns the reference to the Transit key, the private key never leaves Transit.
+func (s *transitTufSigner) MarshalPrivateKey() (*data.PrivateKey, error) {
+	value, err := json.Marshal(s.value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.PrivateKey{
+		Type:       transitKeyType,
+		Scheme:     data.KeySchemeEd25519,
+		Algorithms: data.HashAlgorithms,
+		Value:      value,
+	}, nil
+}
+
+func (s *transitTufSigner) UnmarshalPrivateKey(key *data.PrivateKey) error {
+	return fmt.Errorf("transit key reference requires the transit client")
+}
+
+func (s *transitTufSigner) PublicData() *data.PublicKey {
+	return s.public
+}
+
+func (s *transitTufSigner) SignMessage(message []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), transitRequestTimeout)
+	defer cancel()
+
+	return s.client.sign(ctx, s.ref, message, nil)
+}
+
+// transitPGPSigner signs the digests by the Transit RSA key to be used by the PGP signing key.
+type transitPGPSigner struct {
+	client *transitClient
+	ref    transitKeyRef
+	public *rsa.PublicKey
+}
+
+func (s *transitPGPSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *transitPGPSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var hashAlgorithm string
+	switch opts.HashFunc() {
+	case crypto.SHA224:
+		hashAlgorithm = "sha2-224"
+	case crypto.SHA256:
+		hashAlgorithm = "sha2-256"
+	case crypto.SHA384:
+		hashAlgorithm = "sha2-384"
+	case crypto.SHA512:
+		hashAlgorithm = "sha2-512"
+	default:
+		return nil, fmt.Errorf("unsupported hash function %s", opts.HashFunc())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transitRequestTimeout)
+	defer cancel()
+
+	return s.client.sign(ctx, s.ref, digest, map[string]interface{}{
+		"prehashed":           true,
+		"hash_algorithm":      hashAlgorithm,
+		"signature_algorithm": "pkcs1v15",
+	})
+}
+
+// newTransitPGPSigningKey builds the PGP signing key by the Transit key version,
+// the creation time of the version keeps the fingerprint stable.
+func newTransitPGPSigningKey(ctx context.Context, client *transitClient, ref transitKeyRef) (*pgp.SigningKey, error) {
+	version, err := client.readKeyVersion(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(version.PublicKey))
+	if block == nil {
+		return nil, fmt.Errorf("unexpected public key of transit key %q: pem expected", ref.Name)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key of transit key %q: %w", ref.Name, err)
+	}
+
+	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected public key of transit key %q: rsa key expected", ref.Name)
+	}
+
+	return pgp.NewRSASigningKeyFromSigner(&transitPGPSigner{client: client, ref: ref, public: rsaPublicKey}, version.CreationTime)
+}
@@ -0,0 +1,290 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/theupdateframework/go-tuf"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/werf/trdl/server/pkg/pgp"
+)
+
+var _ = Describe("Vault Transit signing keys", func() {
+	var ctx context.Context
+	var storage logical.Storage
+	var transit *testTransit
+	var fs *testMemoryFilesystem
+	var publisher *Publisher
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		storage = &logical.InmemStorage{}
+		fs = &testMemoryFilesystem{files: map[string][]byte{}}
+		publisher = NewPublisher(hclog.NewNullLogger())
+
+		transit = newTestTransit()
+		DeferCleanup(transit.server.Close)
+
+		Expect(PutTransitOptions(ctx, storage, TransitOptions{
+			VaultAddress: transit.server.URL,
+			VaultToken:   "s.token",
+			Mount:        DefaultTransitMount,
+			KeyPrefix:    DefaultTransitKeyPrefix,
+		})).To(Succeed())
+	})
+
+	newRepository := func() *S3Repository {
+		store := NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+
+		repository := &S3Repository{TufStore: store, TufRepo: tufRepo, logger: hclog.NewNullLogger()}
+		Expect(repository.Init()).To(Succeed())
+
+		return repository
+	}
+
+	It("should create the TUF repository keys in Transit and store the references only", func() {
+		repository := newRepository()
+		Expect(publisher.setRepositoryKeys(ctx, storage, repository, setRepositoryKeysOptions{InitializeKeys: true})).To(Succeed())
+
+		Expect(transit.keyNames()).To(ConsistOf("trdl-tuf-root", "trdl-tuf-targets", "trdl-tuf-snapshot", "trdl-tuf-timestamp"))
+
+		entry, err := storage.Get(ctx, storageKeyTufRepositoryKeys)
+		Expect(err).To(Succeed())
+		Expect(string(entry.Value)).NotTo(ContainSubstring("private"))
+
+		var privKeys TufRepoPrivKeys
+		Expect(entry.DecodeJSON(&privKeys)).To(Succeed())
+		Expect(privKeys.IsTransit()).To(BeTrue())
+
+		Expect(repository.StageTarget(ctx, "channels/1/stable", strings.NewReader("1.0.0\n"))).To(Succeed())
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+
+		// the rotation in Transit does not change the pinned keys
+		transit.rotate("trdl-tuf-timestamp")
+
+		repository = newRepository()
+		Expect(publisher.setRepositoryKeys(ctx, storage, repository, setRepositoryKeysOptions{})).To(Succeed())
+
+		Expect(repository.StageTarget(ctx, "channels/1/stable", strings.NewReader("1.0.1\n"))).To(Succeed())
+		Expect(repository.CommitStaged(ctx)).To(Succeed())
+
+		report, err := repository.Verify(ctx)
+		Expect(err).To(Succeed())
+		Expect(report.Failed()).To(BeFalse())
+		Expect(report.VerifiedTargets).To(Equal(1))
+
+		inUse, err := transitKeysInUse(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(inUse).To(BeTrue())
+	})
+
+	It("should fail to load the keys kept in Transit if Transit is not configured", func() {
+		Expect(publisher.setRepositoryKeys(ctx, storage, newRepository(), setRepositoryKeysOptions{InitializeKeys: true})).To(Succeed())
+		Expect(DeleteTransitOptions(ctx, storage)).To(Succeed())
+
+		err := publisher.setRepositoryKeys(ctx, storage, newRepository(), setRepositoryKeysOptions{})
+		Expect(err).To(MatchError(ContainSubstring("Vault Transit which is not configured")))
+	})
+
+	It("should sign release artifacts by the PGP signing key kept in Transit", func() {
+		key, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+		Expect(transit.keyNames()).To(ConsistOf("trdl-pgp"))
+
+		entry, err := storage.Get(ctx, storageKeyPGPSigningKey)
+		Expect(err).To(Succeed())
+		Expect(entry).To(BeNil())
+
+		publicKey := bytes.NewBuffer(nil)
+		Expect(key.SerializePublicKey(publicKey)).To(Succeed())
+		keyRing, err := openpgp.ReadArmoredKeyRing(publicKey)
+		Expect(err).To(Succeed())
+
+		signature := bytes.NewBuffer(nil)
+		Expect(pgp.SignDataStream(signature, strings.NewReader("binary"), key)).To(Succeed())
+		_, err = openpgp.CheckDetachedSignature(keyRing, strings.NewReader("binary"), signature)
+		Expect(err).To(Succeed())
+
+		fingerprint, err := publisher.GetPGPSigningKeyFingerprint(ctx, storage)
+		Expect(err).To(Succeed())
+
+		// the rotated version is taken after the current key is deleted only
+		transit.rotate("trdl-pgp")
+
+		sameFingerprint, err := publisher.GetPGPSigningKeyFingerprint(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(sameFingerprint).To(Equal(fingerprint))
+
+		Expect(publisher.deletePGPSigningKey(ctx, storage)).To(Succeed())
+		_, err = publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+
+		newFingerprint, err := publisher.GetPGPSigningKeyFingerprint(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(newFingerprint).NotTo(Equal(fingerprint))
+	})
+
+	It("should keep the PGP signing key stored before Transit is configured", func() {
+		Expect(DeleteTransitOptions(ctx, storage)).To(Succeed())
+		_, err := publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+
+		Expect(PutTransitOptions(ctx, storage, TransitOptions{VaultAddress: transit.server.URL, VaultToken: "s.token", Mount: DefaultTransitMount})).To(Succeed())
+		_, err = publisher.fetchPGPSigningKey(ctx, storage, true)
+		Expect(err).To(Succeed())
+		Expect(transit.keyNames()).To(BeEmpty())
+
+		inUse, err := transitKeysInUse(ctx, storage)
+		Expect(err).To(Succeed())
+		Expect(inUse).To(BeFalse())
+	})
+})
+
+// testTransit serves the keys, rotate and sign endpoints of the Transit secrets engine mounted at transit/.
+type testTransit struct {
+	server *httptest.Server
+
+	mu   sync.Mutex
+	keys map[string][]crypto.Signer
+}
+
+func newTestTransit() *testTransit {
+	transit := &testTransit{keys: map[string][]crypto.Signer{}}
+	transit.server = httptest.NewServer(http.HandlerFunc(transit.serveHTTP))
+	return transit
+}
+
+func (t *testTransit) keyNames() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var names []string
+	for name := range t.keys {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (t *testTransit) rotate(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, isRSA := t.keys[name][0].(*rsa.PrivateKey)
+	t.keys[name] = append(t.keys[name], newTestTransitKey(isRSA))
+}
+
+// newTestTransitKey generates the smaller RSA key than rsa-4096 to speed up the tests.
+func newTestTransitKey(isRSA bool) crypto.Signer {
+	if isRSA {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).To(Succeed())
+		return key
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).To(Succeed())
+	return key
+}
+
+func (t *testTransit) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "s.token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var body map[string]interface{}
+	if r.Method != http.MethodGet && r.ContentLength != 0 {
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/transit/"), "/")
+	switch {
+	case parts[0] == "keys" && r.Method == http.MethodGet:
+		versions, ok := t.keys[parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+			return
+		}
+
+		keys := map[string]interface{}{}
+		for i, key := range versions {
+			var publicKey string
+			switch public := key.Public().(type) {
+			case ed25519.PublicKey:
+				publicKey = base64.StdEncoding.EncodeToString(public)
+			default:
+				der, err := x509.MarshalPKIXPublicKey(public)
+				Expect(err).To(Succeed())
+				publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			}
+
+			keys[strconv.Itoa(i+1)] = map[string]interface{}{
+				"public_key":    publicKey,
+				"creation_time": time.Date(2023, 3, 15, 8, i, 0, 0, time.UTC).Format(time.RFC3339Nano),
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"latest_version": len(versions), "keys": keys}})
+
+	case parts[0] == "keys" && len(parts) == 3 && parts[2] == "rotate":
+		_, isRSA := t.keys[parts[1]][0].(*rsa.PrivateKey)
+		t.keys[parts[1]] = append(t.keys[parts[1]], newTestTransitKey(isRSA))
+		w.WriteHeader(http.StatusNoContent)
+
+	case parts[0] == "keys":
+		if _, ok := t.keys[parts[1]]; !ok {
+			t.keys[parts[1]] = []crypto.Signer{newTestTransitKey(body["type"] != transitKeyTypeTUF)}
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case parts[0] == "sign":
+		version, _ := strconv.Atoi(fmt.Sprint(body["key_version"]))
+		input, err := base64.StdEncoding.DecodeString(body["input"].(string))
+		Expect(err).To(Succeed())
+
+		var signature []byte
+		switch key := t.keys[parts[1]][version-1].(type) {
+		case ed25519.PrivateKey:
+			signature = ed25519.Sign(key, input)
+		case *rsa.PrivateKey:
+			Expect(body["prehashed"]).To(BeTrue())
+			Expect(body["hash_algorithm"]).To(Equal("sha2-256"))
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, input)
+			Expect(err).To(Succeed())
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+			"signature": fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(signature)),
+		}})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -1,6 +1,7 @@
 package publisher
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/theupdateframework/go-tuf"
@@ -13,6 +14,9 @@ type TufRepoPrivKeys struct {
 	Snapshot  *data.PrivateKey `json:"snapshot"`
 	Targets   *data.PrivateKey `json:"targets"`
 	Timestamp *data.PrivateKey `json:"timestamp"`
+
+	// transit signs by the keys stored as the references to the Transit keys.
+	transit *transitClient
 }
 
 func (keys *TufRepoPrivKeys) SetKeyFromSigner(role string, signer keys.Signer) error {
@@ -67,10 +71,14 @@ func (privKeys TufRepoPrivKeys) SetupTufRepoSigners(tufRepo *tuf.Repo) error {
 		{"snapshot", privKeys.Snapshot},
 		{"timestamp", privKeys.Timestamp},
 	} {
-		signer, err := keys.GetSigner(desc.key)
+		signer, err := privKeys.toSigner(desc.key)
 		if err != nil {
 			return fmt.Errorf("unable to get key signer for role %s: %w", desc.role, err)
 		}
+		// the root key is absent if it is kept offline
+		if signer == nil {
+			continue
+		}
 
 		if err := tufRepo.AddPrivateKeyWithExpires(desc.role, signer, data.DefaultExpires("root")); err != nil {
 			return fmt.Errorf("unable to add tuf repository private key for role %s: %w", desc.role, err)
@@ -83,25 +91,50 @@ func (privKeys TufRepoPrivKeys) SetupTufRepoSigners(tufRepo *tuf.Repo) error {
 func (privKeys TufRepoPrivKeys) GetSigner(role string) (keys.Signer, error) {
 	switch role {
 	case "root":
-		return toSigner(privKeys.Root)
+		return privKeys.toSigner(privKeys.Root)
 
 	case "targets":
-		return toSigner(privKeys.Targets)
+		return privKeys.toSigner(privKeys.Targets)
 
 	case "snapshot":
-		return toSigner(privKeys.Snapshot)
+		return privKeys.toSigner(privKeys.Snapshot)
 
 	case "timestamp":
-		return toSigner(privKeys.Timestamp)
+		return privKeys.toSigner(privKeys.Timestamp)
 
 	default:
 		panic(fmt.Sprintf("unknown role %q", role))
 	}
 }
 
-func toSigner(key *data.PrivateKey) (keys.Signer, error) {
+func (privKeys TufRepoPrivKeys) toSigner(key *data.PrivateKey) (keys.Signer, error) {
 	if key == nil {
 		return nil, nil
 	}
+
+	if key.Type == transitKeyType {
+		if privKeys.transit == nil {
+			return nil, fmt.Errorf("the key is kept in Vault Transit which is not configured")
+		}
+
+		var value transitTufKeyValue
+		if err := json.Unmarshal(key.Value, &value); err != nil {
+			return nil, fmt.Errorf("unable to decode transit key reference: %w", err)
+		}
+
+		return newTransitTufSignerFromValue(privKeys.transit, value)
+	}
+
 	return keys.GetSigner(key)
 }
+
+// IsTransit returns true if any key is kept in Vault Transit.
+func (privKeys TufRepoPrivKeys) IsTransit() bool {
+	for _, key := range []*data.PrivateKey{privKeys.Root, privKeys.Targets, privKeys.Snapshot, privKeys.Timestamp} {
+		if key != nil && key.Type == transitKeyType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -9,6 +9,8 @@ import (
 
 type TufRepoRotator struct {
 	TufRepo TufRepoRotatorAccessor
+	// OfflineRoot skips the rotation of root.json, which is renewed by the pending root.json signed by the offline root keys.
+	OfflineRoot bool
 }
 
 func NewTufRepoRotator(tufRepo TufRepoRotatorAccessor) *TufRepoRotator {
@@ -20,7 +22,7 @@ func (rotator *TufRepoRotator) Rotate(logger hclog.Logger, now time.Time) error
 
 	logger.Debug("start rotating expiration timestamps and versions of TUF repository roles")
 
-	{
+	if !rotator.OfflineRoot {
 		rotateAt, err := rotator.GetRootRotateAt()
 		if err != nil {
 			return fmt.Errorf("unable to get root.json rotation time: %w", err)
@@ -232,6 +232,26 @@ var _ = Describe("TUF repo rotator", func() {
 		_ = prevSnapshotExpires
 		_ = prevTimestampExpires
 	})
+
+	It("should not rotate root.json if the root keys are kept offline", func() {
+		now := time.Now()
+
+		testRepo := &testTufRepoRotatorAccessor{
+			rootExpires:      now,
+			targetsExpires:   now,
+			snapshotExpires:  now,
+			timestampExpires: now,
+		}
+
+		rotator := NewTufRepoRotator(testRepo)
+		rotator.OfflineRoot = true
+
+		Expect(rotator.Rotate(hclog.Default(), now)).To(Succeed())
+		Expect(testRepo.rootExpires).To(Equal(now))
+		Expect(testRepo.targetsExpires).To(Equal(now.AddDate(0, 3, 0)))
+		Expect(testRepo.snapshotExpires).To(Equal(now.AddDate(0, 0, 7)))
+		Expect(testRepo.timestampExpires).To(Equal(now.AddDate(0, 0, 1)))
+	})
 })
 
 type testTufRepoRotatorAccessor struct {
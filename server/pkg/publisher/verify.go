@@ -0,0 +1,225 @@
+package publisher
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+
+	"github.com/theupdateframework/go-tuf/data"
+	"github.com/theupdateframework/go-tuf/util"
+	"github.com/theupdateframework/go-tuf/verify"
+)
+
+// VerificationReport lists the differences between the repository objects and the TUF repository metadata.
+type VerificationReport struct {
+	// InvalidMetadata is the reason by the top-level metadata name which is missing or not signed by the threshold of the root keys.
+	InvalidMetadata map[string]string `json:"invalid_metadata,omitempty"`
+	// MissingTargets are registered in targets.json but not found in the repository.
+	MissingTargets []string `json:"missing_targets,omitempty"`
+	// ModifiedTargets is the reason by the target which length or hashes do not match targets.json.
+	ModifiedTargets map[string]string `json:"modified_targets,omitempty"`
+	// UnreferencedObjects are found in the targets directory but not registered in targets.json.
+	UnreferencedObjects []string `json:"unreferenced_objects,omitempty"`
+	VerifiedTargets     int      `json:"verified_targets"`
+}
+
+// Failed reports the missing and changed data, the unreferenced objects are not the failure,
+// because the targets of the running release are uploaded before they are registered in the metadata.
+func (report *VerificationReport) Failed() bool {
+	return len(report.InvalidMetadata) > 0 || len(report.MissingTargets) > 0 || len(report.ModifiedTargets) > 0
+}
+
+func (report *VerificationReport) String() string {
+	return fmt.Sprintf("%d targets verified, %d invalid metadata, %d missing targets, %d modified targets, %d unreferenced objects", report.VerifiedTargets, len(report.InvalidMetadata), len(report.MissingTargets), len(report.ModifiedTargets), len(report.UnreferencedObjects))
+}
+
+// VerifyTufStore checks the signatures of the top-level metadata by the keys of root.json
+// and streams every target registered in targets.json through the store filesystem to check its length and hashes.
+func VerifyTufStore(ctx context.Context, store *NonAtomicTufStore) (*VerificationReport, error) {
+	report := &VerificationReport{
+		InvalidMetadata: map[string]string{},
+		ModifiedTargets: map[string]string{},
+	}
+
+	meta, err := store.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get TUF-repo metadata: %w", err)
+	}
+
+	signedMeta := map[string]*data.Signed{}
+	for _, name := range topLevelManifests {
+		raw, ok := meta[name]
+		if !ok {
+			report.InvalidMetadata[name] = "not found"
+			continue
+		}
+
+		signed := &data.Signed{}
+		if err := json.Unmarshal(raw, signed); err != nil {
+			report.InvalidMetadata[name] = fmt.Sprintf("unable to unmarshal: %s", err)
+			continue
+		}
+
+		signedMeta[name] = signed
+	}
+
+	if rootSigned, ok := signedMeta["root.json"]; ok {
+		db, err := newRootVerificationDB(rootSigned)
+		if err != nil {
+			report.InvalidMetadata["root.json"] = err.Error()
+		} else {
+			for name, signed := range signedMeta {
+				if err := db.VerifySignatures(signed, strings.TrimSuffix(name, ".json")); err != nil {
+					report.InvalidMetadata[name] = fmt.Sprintf("signature verification failed: %s", err)
+				}
+			}
+		}
+	}
+
+	targetsSigned, ok := signedMeta["targets.json"]
+	if !ok {
+		return report, nil
+	}
+
+	targets := &data.Targets{}
+	if err := json.Unmarshal(targetsSigned.Signed, targets); err != nil {
+		report.InvalidMetadata["targets.json"] = fmt.Sprintf("unable to unmarshal: %s", err)
+		return report, nil
+	}
+
+	consistentSnapshot := false
+	if root, err := decodeRoot(meta["root.json"]); err == nil {
+		consistentSnapshot = root.ConsistentSnapshot
+	}
+
+	referencedObjects := map[string]bool{}
+VerifyTargets:
+	for _, targetPath := range sortedTargetPaths(targets.Targets) {
+		expected := targets.Targets[targetPath]
+
+		for _, objectPath := range targetObjectPaths(consistentSnapshot, targetPath, expected.Hashes) {
+			referencedObjects[objectPath] = true
+
+			exists, err := store.Filesystem.IsFileExist(ctx, objectPath)
+			if err != nil {
+				return nil, fmt.Errorf("error checking existence of %q: %w", objectPath, err)
+			}
+
+			if !exists {
+				report.MissingTargets = append(report.MissingTargets, targetPath)
+				continue VerifyTargets
+			}
+
+			actual, err := readFileMeta(ctx, store.Filesystem, objectPath, expected.Hashes.HashAlgorithms())
+			if err != nil {
+				return nil, err
+			}
+
+			if err := util.FileMetaEqual(actual, expected.FileMeta); err != nil {
+				report.ModifiedTargets[targetPath] = err.Error()
+				continue VerifyTargets
+			}
+		}
+
+		report.VerifiedTargets++
+	}
+
+	objectPaths, err := store.Filesystem.ListFiles(ctx, "targets/")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list targets: %w", err)
+	}
+
+	for _, objectPath := range objectPaths {
+		if !referencedObjects[objectPath] {
+			report.UnreferencedObjects = append(report.UnreferencedObjects, objectPath)
+		}
+	}
+	sort.Strings(report.UnreferencedObjects)
+
+	return report, nil
+}
+
+func newRootVerificationDB(rootSigned *data.Signed) (*verify.DB, error) {
+	root := &data.Root{}
+	if err := json.Unmarshal(rootSigned.Signed, root); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal: %w", err)
+	}
+
+	db := verify.NewDB()
+	for id, key := range root.Keys {
+		if err := db.AddKey(id, key); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+	}
+
+	for name, role := range root.Roles {
+		if err := db.AddRole(name, role); err != nil {
+			return nil, fmt.Errorf("invalid role %q: %w", name, err)
+		}
+	}
+
+	return db, nil
+}
+
+// readFileMeta streams the file to compute its length and hashes without keeping the file in memory.
+func readFileMeta(ctx context.Context, filesystem Filesystem, filePath string, hashAlgorithms []string) (data.FileMeta, error) {
+	metaWriter := newFileMetaWriter(hashAlgorithms)
+	if err := filesystem.ReadFileStream(ctx, filePath, metaWriter); err != nil {
+		return data.FileMeta{}, fmt.Errorf("error reading file %q stream: %w", filePath, err)
+	}
+
+	return metaWriter.FileMeta(), nil
+}
+
+// fileMetaWriter computes the length and hashes of the data written, the unknown hash algorithms are skipped.
+type fileMetaWriter struct {
+	hashes map[string]hash.Hash
+	length int64
+}
+
+func newFileMetaWriter(hashAlgorithms []string) *fileMetaWriter {
+	w := &fileMetaWriter{hashes: map[string]hash.Hash{}}
+	for _, hashAlgorithm := range hashAlgorithms {
+		switch hashAlgorithm {
+		case "sha256":
+			w.hashes[hashAlgorithm] = sha256.New()
+		case "sha512":
+			w.hashes[hashAlgorithm] = sha512.New()
+		}
+	}
+
+	return w
+}
+
+func (w *fileMetaWriter) Write(p []byte) (int, error) {
+	for _, h := range w.hashes {
+		h.Write(p)
+	}
+	w.length += int64(len(p))
+
+	return len(p), nil
+}
+
+func (w *fileMetaWriter) FileMeta() data.FileMeta {
+	fileMeta := data.FileMeta{Length: w.length, Hashes: data.Hashes{}}
+	for hashAlgorithm, h := range w.hashes {
+		fileMeta.Hashes[hashAlgorithm] = h.Sum(nil)
+	}
+
+	return fileMeta
+}
+
+func sortedTargetPaths(targets data.TargetFiles) []string {
+	var paths []string
+	for targetPath := range targets {
+		paths = append(paths, targetPath)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
@@ -0,0 +1,147 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/theupdateframework/go-tuf"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+var _ = Describe("TUF store verification", func() {
+	var ctx context.Context
+	var fs *testMemoryFilesystem
+	var store *NonAtomicTufStore
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fs = &testMemoryFilesystem{files: map[string][]byte{}}
+		store = NewNonAtomicTufStore(TufRepoPrivKeys{}, fs, hclog.NewNullLogger())
+
+		tufRepo, err := tuf.NewRepo(store)
+		Expect(err).To(Succeed())
+		Expect(tufRepo.Init(false)).To(Succeed())
+		for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+			_, err := tufRepo.GenKey(role)
+			Expect(err).To(Succeed())
+		}
+
+		for _, target := range []string{"releases/1.0.0/linux-amd64/bin/app", "channels/1/stable"} {
+			Expect(store.StageTargetFile(ctx, target, strings.NewReader("data of "+target))).To(Succeed())
+			Expect(tufRepo.AddTarget(target, json.RawMessage(""))).To(Succeed())
+		}
+
+		Expect(tufRepo.Snapshot()).To(Succeed())
+		Expect(tufRepo.Timestamp()).To(Succeed())
+		Expect(tufRepo.Commit()).To(Succeed())
+	})
+
+	It("should verify the consistent repository", func() {
+		report, err := VerifyTufStore(ctx, store)
+		Expect(err).To(Succeed())
+		Expect(report.Failed()).To(BeFalse())
+		Expect(report.VerifiedTargets).To(Equal(2))
+		Expect(report.UnreferencedObjects).To(BeEmpty())
+	})
+
+	It("should report missing, modified and unreferenced objects", func() {
+		delete(fs.files, "targets/channels/1/stable")
+		fs.files["targets/releases/1.0.0/linux-amd64/bin/app"] = []byte("tampered")
+		fs.files["targets/releases/1.0.1/linux-amd64/bin/app"] = []byte("not committed")
+
+		report, err := VerifyTufStore(ctx, store)
+		Expect(err).To(Succeed())
+		Expect(report.Failed()).To(BeTrue())
+		Expect(report.VerifiedTargets).To(Equal(0))
+		Expect(report.MissingTargets).To(Equal([]string{"channels/1/stable"}))
+		Expect(report.ModifiedTargets).To(HaveKey("releases/1.0.0/linux-amd64/bin/app"))
+		Expect(report.UnreferencedObjects).To(Equal([]string{"targets/releases/1.0.1/linux-amd64/bin/app"}))
+		Expect(report.InvalidMetadata).To(BeEmpty())
+	})
+
+	It("should report the metadata with invalid signatures", func() {
+		signed := &data.Signed{}
+		Expect(json.Unmarshal(fs.files["timestamp.json"], signed)).To(Succeed())
+		signed.Signed = json.RawMessage(strings.Replace(string(signed.Signed), `"version":1`, `"version":2`, 1))
+		fs.files["timestamp.json"], _ = json.Marshal(signed)
+
+		report, err := VerifyTufStore(ctx, store)
+		Expect(err).To(Succeed())
+		Expect(report.Failed()).To(BeTrue())
+		Expect(report.InvalidMetadata).To(HaveLen(1))
+		Expect(report.InvalidMetadata).To(HaveKey("timestamp.json"))
+	})
+})
+
+type testMemoryFilesystem struct {
+	files map[string][]byte
+}
+
+func (fs *testMemoryFilesystem) IsFileExist(_ context.Context, path string) (bool, error) {
+	_, ok := fs.files[path]
+	return ok, nil
+}
+
+func (fs *testMemoryFilesystem) ReadFile(_ context.Context, path string, writer io.WriterAt) error {
+	data, ok := fs.files[path]
+	if !ok {
+		return fmt.Errorf("file %q not found", path)
+	}
+
+	_, err := writer.WriteAt(data, 0)
+	return err
+}
+
+func (fs *testMemoryFilesystem) ReadFileStream(_ context.Context, path string, writer io.Writer) error {
+	data, ok := fs.files[path]
+	if !ok {
+		return fmt.Errorf("file %q not found", path)
+	}
+
+	_, err := writer.Write(data)
+	return err
+}
+
+func (fs *testMemoryFilesystem) ReadFileBytes(_ context.Context, path string) ([]byte, error) {
+	data, ok := fs.files[path]
+	if !ok {
+		return nil, fmt.Errorf("file %q not found", path)
+	}
+
+	return data, nil
+}
+
+func (fs *testMemoryFilesystem) WriteFileBytes(_ context.Context, path string, data []byte) error {
+	fs.files[path] = data
+	return nil
+}
+
+func (fs *testMemoryFilesystem) WriteFileStream(_ context.Context, path string, reader io.Reader) error {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, reader); err != nil {
+		return err
+	}
+
+	fs.files[path] = buf.Bytes()
+	return nil
+}
+
+func (fs *testMemoryFilesystem) ListFiles(_ context.Context, prefix string) ([]string, error) {
+	var paths []string
+	for path := range fs.files {
+		if strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
@@ -2,6 +2,7 @@ package secrets
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/hashicorp/vault/sdk/framework"
@@ -11,22 +12,32 @@ import (
 )
 
 const (
-	fieldNameSecretId   = "id"
-	fieldNameSecretData = "data"
+	fieldNameSecretId       = "id"
+	fieldNameSecretData     = "data"
+	fieldNameSecretSteps    = "steps"
+	fieldNameSecretDelivery = "delivery"
 )
 
-type Secret struct {
-	Id   string
-	Data []byte
-}
-
 func Paths() []*framework.Path {
+	optionsFields := map[string]*framework.FieldSchema{
+		fieldNameSecretSteps: {
+			Type:        framework.TypeCommaIntSlice,
+			Description: "Numbers of the trdl.yaml commands starting from 1 the secret is delivered to (all commands by default). The consecutive commands with the same scoped secrets are run in a separate shell, which is the only one the scoped secret is delivered to",
+		},
+		fieldNameSecretDelivery: {
+			Type:          framework.TypeCommaStringSlice,
+			Description:   "How the secret is delivered to the build commands: file — the /run/secrets/<id> file, env — the <id> environment variable",
+			Default:       DefaultDelivery,
+			AllowedValues: []interface{}{DeliveryFile, DeliveryEnv},
+		},
+	}
+
 	return []*framework.Path{
 		{
 			Pattern:         "configure/build/secrets/?",
-			HelpSynopsis:    "Add a build secret",
-			HelpDescription: "Add a build secret",
-			Fields: map[string]*framework.FieldSchema{
+			HelpSynopsis:    "Add a build secret or list build secrets",
+			HelpDescription: "Add a build secret or list build secrets",
+			Fields: mergeFields(map[string]*framework.FieldSchema{
 				fieldNameSecretId: {
 					Type:        framework.TypeNameString,
 					Description: "Secret Id",
@@ -37,7 +48,7 @@ func Paths() []*framework.Path {
 					Description: "Secret data",
 					Required:    true,
 				},
-			},
+			}, optionsFields),
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
 					Description: "Add a build secret",
@@ -47,20 +58,40 @@ func Paths() []*framework.Path {
 					Description: "Add a build secret",
 					Callback:    pathSecretCreate,
 				},
+				logical.ListOperation: &framework.PathOperation{
+					Description: "Get the list of build secrets",
+					Callback:    pathSecretList,
+				},
 			},
 		},
 		{
 			Pattern:         "configure/build/secrets/" + framework.GenericNameRegex(fieldNameSecretId) + "$",
-			HelpSynopsis:    "Delete a build secret",
-			HelpDescription: "Delete a build secret",
-			Fields: map[string]*framework.FieldSchema{
+			HelpSynopsis:    "Read, update or delete a build secret",
+			HelpDescription: "Read, update or delete a build secret. The secret data is never returned",
+			Fields: mergeFields(map[string]*framework.FieldSchema{
 				fieldNameSecretId: {
 					Type:        framework.TypeNameString,
 					Description: "Secret Id",
 					Required:    true,
 				},
-			},
+				fieldNameSecretData: {
+					Type:        framework.TypeString,
+					Description: "Secret data (required if the secret does not exist, the current data is kept otherwise)",
+				},
+			}, optionsFields),
 			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Description: "Add or update a build secret",
+					Callback:    pathSecretCreateOrUpdate,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Description: "Add or update a build secret",
+					Callback:    pathSecretCreateOrUpdate,
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Description: "Get the build secret options",
+					Callback:    pathSecretRead,
+				},
 				logical.DeleteOperation: &framework.PathOperation{
 					Description: "Delete a build secret",
 					Callback:    pathSecretDelete,
@@ -70,25 +101,138 @@ func Paths() []*framework.Path {
 	}
 }
 
+func mergeFields(fields, extraFields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	for name, schema := range extraFields {
+		fields[name] = schema
+	}
+	return fields
+}
+
 func pathSecretCreate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
 	if errResp := util.CheckRequiredFields(req, fields); errResp != nil {
 		return errResp, nil
 	}
-	return CreateSecret(ctx, req, secretStorage{
-		Id:   fields.Get(fieldNameSecretId).(string),
-		Data: fields.Get(fieldNameSecretData).(string),
-	})
+
+	s := Secret{
+		Id:            fields.Get(fieldNameSecretId).(string),
+		Data:          []byte(fields.Get(fieldNameSecretData).(string)),
+		SecretOptions: secretOptionsFromFields(fields),
+	}
+	if err := s.Validate(s.Id); err != nil {
+		return logical.ErrorResponse("secret validation failed: %s", err), nil
+	}
+
+	if err := CreateSecret(ctx, req.Storage, s); err != nil {
+		if errors.Is(err, ErrSecretAlreadyExists) {
+			return logical.ErrorResponse("secret with id %s already exists", s.Id), nil
+		}
+		return nil, err
+	}
+
+	return nil, nil
 }
 
-func pathSecretDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
-	if errResp := util.CheckRequiredFields(req, fields); errResp != nil {
-		return errResp, nil
+func pathSecretCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	id := fields.Get(fieldNameSecretId).(string)
+
+	existing, err := GetSecret(ctx, req.Storage, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get secret: %w", err)
+	}
+
+	data, hasData := fields.GetOk(fieldNameSecretData)
+	if !hasData && existing == nil {
+		return logical.ErrorResponse("Required field %q must be set", fieldNameSecretData), nil
+	}
+
+	s := Secret{Id: id, SecretOptions: secretOptionsFromFields(fields)}
+	if hasData {
+		s.Data = []byte(data.(string))
+	} else {
+		s.Data = existing.Data
 	}
-	err := DeleteSecret(ctx, req, secretStorage{
-		Id: fields.Get(fieldNameSecretId).(string),
-	})
+
+	// options which are not passed are kept as is
+	if existing != nil {
+		if _, ok := fields.GetOk(fieldNameSecretSteps); !ok {
+			s.Steps = existing.Steps
+		}
+		if _, ok := fields.GetOk(fieldNameSecretDelivery); !ok {
+			s.Delivery = existing.Delivery
+		}
+	}
+
+	if err := s.Validate(s.Id); err != nil {
+		return logical.ErrorResponse("secret validation failed: %s", err), nil
+	}
+
+	if err := PutSecret(ctx, req.Storage, s); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func pathSecretList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	list, err := GetSecrets(ctx, req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list secrets: %w", err)
+	}
+
+	keys := make([]string, 0, len(list))
+	keysInfo := make(map[string]interface{}, len(list))
+	for _, s := range list {
+		keys = append(keys, s.Id)
+		keysInfo[s.Id] = secretOptionsData(s.SecretOptions)
+	}
+
+	return logical.ListResponseWithInfo(keys, keysInfo), nil
+}
+
+func pathSecretRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	id := fields.Get(fieldNameSecretId).(string)
+
+	s, err := GetSecret(ctx, req.Storage, id)
 	if err != nil {
+		return nil, fmt.Errorf("unable to get secret: %w", err)
+	}
+	if s == nil {
+		return logical.ErrorResponse("secret %q not found", id), nil
+	}
+
+	data := secretOptionsData(s.SecretOptions)
+	data[fieldNameSecretId] = s.Id
+
+	return &logical.Response{Data: data}, nil
+}
+
+func pathSecretDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	if err := DeleteSecret(ctx, req.Storage, fields.Get(fieldNameSecretId).(string)); err != nil {
 		return nil, fmt.Errorf("error delete secret: %w", err)
 	}
 	return nil, nil
 }
+
+func secretOptionsFromFields(fields *framework.FieldData) SecretOptions {
+	return SecretOptions{
+		Steps:    fields.Get(fieldNameSecretSteps).([]int),
+		Delivery: fields.Get(fieldNameSecretDelivery).([]string),
+	}
+}
+
+func secretOptionsData(opts SecretOptions) map[string]interface{} {
+	steps := opts.Steps
+	if steps == nil {
+		steps = []int{}
+	}
+
+	delivery := opts.Delivery
+	if len(delivery) == 0 {
+		delivery = DefaultDelivery
+	}
+
+	return map[string]interface{}{
+		fieldNameSecretSteps:    steps,
+		fieldNameSecretDelivery: delivery,
+	}
+}
@@ -0,0 +1,178 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type pathSecretsCallbacksSuite struct {
+	suite.Suite
+	ctx     context.Context
+	backend logical.Backend
+	req     *logical.Request
+	storage logical.Storage
+}
+
+func (suite *pathSecretsCallbacksSuite) SetupTest() {
+	ctx := context.Background()
+	b := &framework.Backend{}
+	b.Paths = Paths()
+	storage := &logical.InmemStorage{}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	err := b.Setup(ctx, config)
+	assert.Nil(suite.T(), err)
+
+	suite.ctx = ctx
+	suite.backend = b
+	suite.req = &logical.Request{Storage: storage}
+	suite.storage = storage
+}
+
+func (suite *pathSecretsCallbacksSuite) TestCreate() {
+	suite.req.Path = "configure/build/secrets"
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Data = map[string]interface{}{
+		fieldNameSecretId:   "aws",
+		fieldNameSecretData: "credentials",
+	}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("secret with id aws already exists"), resp)
+
+	secrets, err := GetSecrets(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []Secret{{
+		Id:            "aws",
+		Data:          []byte("credentials"),
+		SecretOptions: SecretOptions{Delivery: []string{DeliveryFile}},
+	}}, secrets)
+}
+
+func (suite *pathSecretsCallbacksSuite) TestCreate_InvalidOptions() {
+	suite.req.Path = "configure/build/secrets"
+	suite.req.Operation = logical.CreateOperation
+
+	for name, data := range map[string]map[string]interface{}{
+		"step":     {fieldNameSecretId: "aws", fieldNameSecretData: "data", fieldNameSecretSteps: "0"},
+		"delivery": {fieldNameSecretId: "aws", fieldNameSecretData: "data", fieldNameSecretDelivery: "stdin"},
+		"env name": {fieldNameSecretId: "aws-key", fieldNameSecretData: "data", fieldNameSecretDelivery: "env"},
+		"id":       {fieldNameSecretId: "aws,target=/etc/passwd", fieldNameSecretData: "data"},
+	} {
+		suite.Run(name, func() {
+			suite.req.Data = data
+
+			resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+			assert.Nil(suite.T(), err)
+			assert.True(suite.T(), resp.IsError())
+		})
+	}
+}
+
+func (suite *pathSecretsCallbacksSuite) TestUpdateReadListDelete() {
+	suite.req.Path = "configure/build/secrets/token"
+	suite.req.Operation = logical.UpdateOperation
+	suite.req.Data = map[string]interface{}{
+		fieldNameSecretSteps: "1",
+	}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logical.ErrorResponse("Required field %q must be set", fieldNameSecretData), resp)
+
+	suite.req.Data = map[string]interface{}{
+		fieldNameSecretData:  "old",
+		fieldNameSecretSteps: "1,3",
+	}
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	// the data and the steps are kept
+	suite.req.Data = map[string]interface{}{
+		fieldNameSecretDelivery: "env,file",
+	}
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	s, err := GetSecret(suite.ctx, suite.storage, "token")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), &Secret{
+		Id:            "token",
+		Data:          []byte("old"),
+		SecretOptions: SecretOptions{Steps: []int{1, 3}, Delivery: []string{DeliveryEnv, DeliveryFile}},
+	}, s)
+
+	suite.req.Operation = logical.ReadOperation
+	suite.req.Data = nil
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), map[string]interface{}{
+		fieldNameSecretId:       "token",
+		fieldNameSecretSteps:    []int{1, 3},
+		fieldNameSecretDelivery: []string{DeliveryEnv, DeliveryFile},
+	}, resp.Data)
+
+	suite.req.Path = "configure/build/secrets/"
+	suite.req.Operation = logical.ListOperation
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"token"}, resp.Data["keys"])
+
+	suite.req.Path = "configure/build/secrets/token"
+	suite.req.Operation = logical.DeleteOperation
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), resp)
+
+	secrets, err := GetSecrets(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), secrets)
+
+	options, err := suite.storage.List(suite.ctx, storageKeyPrefixSecretOptions)
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), options)
+}
+
+func (suite *pathSecretsCallbacksSuite) TestGetSecrets_WithoutOptions() {
+	// secrets stored before the options were introduced
+	assert.Nil(suite.T(), suite.storage.Put(suite.ctx, &logical.StorageEntry{
+		Key:   secretIdStorageKey("legacy"),
+		Value: []byte(`{"data": "not options"}`),
+	}))
+
+	secrets, err := GetSecrets(suite.ctx, suite.storage)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []Secret{{Id: "legacy", Data: []byte(`{"data": "not options"}`)}}, secrets)
+	assert.True(suite.T(), secrets[0].AsFile())
+	assert.False(suite.T(), secrets[0].AsEnv())
+	assert.True(suite.T(), secrets[0].InStep(2))
+}
+
+func (suite *pathSecretsCallbacksSuite) TestGetSecrets_InvalidId() {
+	// the id is passed into the build command options, so the stored secret with the unsafe id is rejected
+	assert.Nil(suite.T(), suite.storage.Put(suite.ctx, &logical.StorageEntry{
+		Key:   secretIdStorageKey("aws,required=true"),
+		Value: []byte("data"),
+	}))
+
+	_, err := GetSecrets(suite.ctx, suite.storage)
+	if assert.NotNil(suite.T(), err) {
+		assert.Contains(suite.T(), err.Error(), "invalid secret id")
+	}
+}
+
+func TestPathSecretsCallbacks(t *testing.T) {
+	suite.Run(t, new(pathSecretsCallbacksSuite))
+}
@@ -2,73 +2,264 @@ package secrets
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"regexp"
+	"sort"
 
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
 const (
-	storageKeyPrefixSecret = "build_secret/"
+	storageKeyPrefixSecret        = "build_secret/"
+	storageKeyPrefixSecretOptions = "build_secret_options/"
 )
 
-type secretStorage struct {
+const (
+	// DeliveryFile mounts the secret as the /run/secrets/<id> file.
+	DeliveryFile = "file"
+	// DeliveryEnv exports the secret as the <id> environment variable.
+	DeliveryEnv = "env"
+)
+
+var (
+	Deliveries      = []string{DeliveryFile, DeliveryEnv}
+	DefaultDelivery = []string{DeliveryFile}
+
+	ErrSecretAlreadyExists = errors.New("secret already exists")
+
+	envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// idRegexp keeps the id safe to be used in the --secret and --mount=type=secret options and as the file name.
+	idRegexp = regexp.MustCompile(`^\w([\w.-]*\w)?$`)
+)
+
+type Secret struct {
 	Id   string
-	Data string
+	Data []byte
+	SecretOptions
+}
+
+// SecretOptions are stored separately from the secret data, so the data of the secrets added before
+// the options were introduced is read as is.
+type SecretOptions struct {
+	// Steps are the numbers of the build commands starting from 1, the secret is available for all commands if empty.
+	Steps    []int    `json:"steps,omitempty"`
+	Delivery []string `json:"delivery,omitempty"`
+}
+
+func (o SecretOptions) Validate(id string) error {
+	if !idRegexp.MatchString(id) {
+		return fmt.Errorf("invalid secret id %q: only letters, digits, underscores, dots and hyphens are allowed", id)
+	}
+
+	for _, step := range o.Steps {
+		if step < 1 {
+			return fmt.Errorf("invalid step %d: steps are numbered starting from 1", step)
+		}
+	}
+
+	for _, d := range o.Delivery {
+		switch d {
+		case DeliveryFile:
+		case DeliveryEnv:
+			if !envNameRegexp.MatchString(id) {
+				return fmt.Errorf("secret id %q cannot be used as environment variable name", id)
+			}
+		default:
+			return fmt.Errorf("unknown delivery %q: expected one of %v", d, Deliveries)
+		}
+	}
+
+	return nil
+}
+
+// IsScoped returns true if the secret is available only for the selected build steps.
+func (o SecretOptions) IsScoped() bool {
+	return len(o.Steps) > 0
+}
+
+// InStep returns true if the secret is available for the build step with the number starting from 1.
+func (o SecretOptions) InStep(step int) bool {
+	if !o.IsScoped() {
+		return true
+	}
+
+	for _, s := range o.Steps {
+		if s == step {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o SecretOptions) AsFile() bool {
+	return len(o.Delivery) == 0 || o.hasDelivery(DeliveryFile)
+}
+
+func (o SecretOptions) AsEnv() bool {
+	return o.hasDelivery(DeliveryEnv)
+}
+
+func (o SecretOptions) hasDelivery(delivery string) bool {
+	for _, d := range o.Delivery {
+		if d == delivery {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AnyScoped returns true if at least one of the secrets is scoped to the selected build steps.
+func AnyScoped(secrets []Secret) bool {
+	for _, s := range secrets {
+		if s.IsScoped() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ForStep returns the secrets available for the build step with the number starting from 1.
+func ForStep(secrets []Secret, step int) []Secret {
+	var res []Secret
+	for _, s := range secrets {
+		if s.InStep(step) {
+			res = append(res, s)
+		}
+	}
+
+	return res
 }
 
 func secretIdStorageKey(name string) string {
 	return storageKeyPrefixSecret + name
 }
 
-func CreateSecret(ctx context.Context, req *logical.Request, s secretStorage) (*logical.Response, error) {
-	secretIdStorageKey := secretIdStorageKey(s.Id)
-	entry, err := req.Storage.Get(ctx, secretIdStorageKey)
+func secretOptionsStorageKey(name string) string {
+	return storageKeyPrefixSecretOptions + name
+}
+
+func CreateSecret(ctx context.Context, storage logical.Storage, s Secret) error {
+	entry, err := storage.Get(ctx, secretIdStorageKey(s.Id))
 	if err != nil {
-		return nil, fmt.Errorf("can't check if secret exists: %w", err)
+		return fmt.Errorf("can't check if secret exists: %w", err)
 	}
 	if entry != nil {
-		return nil, fmt.Errorf("secret with id %s already exists", s.Id)
+		return ErrSecretAlreadyExists
+	}
+
+	return PutSecret(ctx, storage, s)
+}
+
+func PutSecret(ctx context.Context, storage logical.Storage, s Secret) error {
+	if err := putSecretOptions(ctx, storage, s.Id, s.SecretOptions); err != nil {
+		return err
 	}
 
-	if err := req.Storage.Put(ctx, &logical.StorageEntry{
-		Key:   secretIdStorageKey,
-		Value: []byte(s.Data),
+	if err := storage.Put(ctx, &logical.StorageEntry{
+		Key:   secretIdStorageKey(s.Id),
+		Value: s.Data,
 	}); err != nil {
-		return nil, fmt.Errorf("unable to put secret: %w", err)
+		return fmt.Errorf("unable to put secret: %w", err)
 	}
 
-	return nil, nil
+	return nil
 }
 
-func GetSecrets(ctx context.Context, storage logical.Storage) ([]Secret, error) {
+func putSecretOptions(ctx context.Context, storage logical.Storage, id string, opts SecretOptions) error {
+	entry, err := logical.StorageEntryJSON(secretOptionsStorageKey(id), opts)
+	if err != nil {
+		return fmt.Errorf("error creating storage json entry: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put secret options: %w", err)
+	}
+
+	return nil
+}
+
+// GetSecret returns nil if the secret does not exist.
+func GetSecret(ctx context.Context, storage logical.Storage, id string) (*Secret, error) {
+	e, err := storage.Get(ctx, secretIdStorageKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, nil
+	}
+
+	opts, err := getSecretOptions(ctx, storage, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Secret{Id: id, Data: e.Value, SecretOptions: opts}, nil
+}
+
+func getSecretOptions(ctx context.Context, storage logical.Storage, id string) (SecretOptions, error) {
+	var opts SecretOptions
+
+	e, err := storage.Get(ctx, secretOptionsStorageKey(id))
+	if err != nil {
+		return opts, err
+	}
+	if e == nil {
+		return opts, nil
+	}
+
+	if err := json.Unmarshal(e.Value, &opts); err != nil {
+		return opts, fmt.Errorf("unable to unmarshal secret %q options: %w", id, err)
+	}
+
+	return opts, nil
+}
+
+func ListSecretIds(ctx context.Context, storage logical.Storage) ([]string, error) {
 	list, err := storage.List(ctx, storageKeyPrefixSecret)
 	if err != nil {
 		return nil, err
 	}
 
+	sort.Strings(list)
+	return list, nil
+}
+
+func GetSecrets(ctx context.Context, storage logical.Storage) ([]Secret, error) {
+	list, err := ListSecretIds(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
 	var secrets []Secret
-	for _, name := range list {
-		storageEntryKey := secretIdStorageKey(name)
-		e, err := storage.Get(ctx, storageEntryKey)
+	for _, id := range list {
+		s, err := GetSecret(ctx, storage, id)
 		if err != nil {
 			return nil, err
 		}
-		if e == nil {
+		if s == nil {
 			continue
 		}
 
-		secrets = append(secrets,
-			Secret{
-				Id:   strings.TrimPrefix(e.Key, storageKeyPrefixSecret),
-				Data: e.Value,
-			},
-		)
+		// the id is checked before it is passed to the build command options
+		if err := s.Validate(s.Id); err != nil {
+			return nil, fmt.Errorf("invalid build secret: %w", err)
+		}
+
+		secrets = append(secrets, *s)
 	}
 
 	return secrets, nil
 }
 
-func DeleteSecret(ctx context.Context, req *logical.Request, s secretStorage) error {
-	return req.Storage.Delete(ctx, secretIdStorageKey(s.Id))
+func DeleteSecret(ctx context.Context, storage logical.Storage, id string) error {
+	if err := storage.Delete(ctx, secretIdStorageKey(id)); err != nil {
+		return err
+	}
+
+	return storage.Delete(ctx, secretOptionsStorageKey(id))
 }
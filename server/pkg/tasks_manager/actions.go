@@ -16,12 +16,38 @@ var (
 	ErrContextCanceled = errors.New("context canceled")
 )
 
-const taskReasonInvalidatedTask = "the task canceled due to restart of the plugin"
+const (
+	taskReasonInvalidatedTask  = "the task canceled due to restart of the plugin"
+	taskReasonUnrestorableTask = "the task canceled due to restart of the plugin: unable to restore the queued task"
+	taskReasonRestartedTask    = "the task interrupted by restart of the plugin and queued again"
+)
+
+// TaskOptions are the options of the task common for all actions.
+type TaskOptions struct {
+	// Locks are held for the whole task, the task waits for them after the start (within the task timeout).
+	// The steps of the task can use WithResourceLock to hold the locks only when necessary.
+	Locks []string
+
+	// Type identifies the task kind. The queued task is restored after restart of the plugin
+	// if the factory is registered for its type by RegisterTaskFactory, otherwise the task is canceled.
+	Type string
+
+	// Params are stored with the task and passed to the task factory on restore, so they must not contain secrets.
+	// AddTask returns the queued task with the same type and params instead of adding a duplicate.
+	Params map[string]string
 
-func (m *Manager) RunTask(ctx context.Context, reqStorage logical.Storage, taskFunc func(context.Context, logical.Storage) error) (string, error) {
+	// CollapseWithRunning makes AddTask also return the running task with the same type and params.
+	CollapseWithRunning bool
+
+	// Restartable marks the idempotent task which is queued again if interrupted by restart of the plugin.
+	// The task is restored by the factory of its type, the number of attempts is limited by the task_max_attempts option.
+	Restartable bool
+}
+
+func (m *Manager) RunTask(ctx context.Context, reqStorage logical.Storage, opts TaskOptions, taskFunc func(context.Context, logical.Storage) error) (string, error) {
 	var taskUUID string
-	err := m.doTaskWrap(ctx, reqStorage, taskFunc, func(newTaskFunc func(ctx context.Context) error) error {
-		busy, err := m.isBusy(ctx, reqStorage)
+	err := m.doTaskWrap(ctx, reqStorage, opts, taskFunc, func(newTaskFunc func(ctx context.Context) error, workers int) error {
+		busy, err := m.isBusy(ctx, reqStorage, workers, opts.Locks)
 		if err != nil {
 			return err
 		}
@@ -30,15 +56,15 @@ func (m *Manager) RunTask(ctx context.Context, reqStorage logical.Storage, taskF
 			return ErrBusy
 		}
 
-		taskUUID, err = m.queueTask(ctx, newTaskFunc)
+		taskUUID, err = m.queueTask(ctx, opts, newTaskFunc)
 		return err
 	})
 
 	return taskUUID, err
 }
 
-func (m *Manager) AddOptionalTask(ctx context.Context, reqStorage logical.Storage, taskFunc func(context.Context, logical.Storage) error) (string, bool, error) {
-	taskUUID, err := m.RunTask(ctx, reqStorage, taskFunc)
+func (m *Manager) AddOptionalTask(ctx context.Context, reqStorage logical.Storage, opts TaskOptions, taskFunc func(context.Context, logical.Storage) error) (string, bool, error) {
+	taskUUID, err := m.RunTask(ctx, reqStorage, opts, taskFunc)
 	if err != nil {
 		if err == ErrBusy {
 			return taskUUID, false, nil
@@ -50,28 +76,45 @@ func (m *Manager) AddOptionalTask(ctx context.Context, reqStorage logical.Storag
 	return taskUUID, true, nil
 }
 
-func (m *Manager) AddTask(ctx context.Context, reqStorage logical.Storage, taskFunc func(context.Context, logical.Storage) error) (string, error) {
+func (m *Manager) AddTask(ctx context.Context, reqStorage logical.Storage, opts TaskOptions, taskFunc func(context.Context, logical.Storage) error) (string, error) {
 	var taskUUID string
-	err := m.doTaskWrap(ctx, reqStorage, taskFunc, func(newTaskFunc func(ctx context.Context) error) error {
-		var err error
-		taskUUID, err = m.queueTask(ctx, newTaskFunc)
+	err := m.doTaskWrap(ctx, reqStorage, opts, taskFunc, func(newTaskFunc func(ctx context.Context) error, _ int) error {
+		duplicateTaskUUID, err := m.findDuplicateTask(ctx, reqStorage, opts)
+		if err != nil {
+			return err
+		}
 
+		if duplicateTaskUUID != "" {
+			taskUUID = duplicateTaskUUID
+			return nil
+		}
+
+		taskUUID, err = m.queueTask(ctx, opts, newTaskFunc)
 		return err
 	})
 
 	return taskUUID, err
 }
 
-func (m *Manager) doTaskWrap(ctx context.Context, reqStorage logical.Storage, taskFunc func(context.Context, logical.Storage) error, f func(func(ctx context.Context) error) error) error {
+// RunExclusive holds the manager for f, so that f can replace the storage without the concurrent task actions.
+// The tasks queued before are not canceled, f must check the queued and running tasks in the storage itself.
+func (m *Manager) RunExclusive(ctx context.Context, reqStorage logical.Storage, f func() error) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// initialize on first task
-	if m.Storage == nil {
-		m.Storage = reqStorage
-		if err := m.invalidateStorage(ctx, reqStorage); err != nil {
-			return fmt.Errorf("unable to invalidate storage: %w", err)
-		}
+	if err := m.initStorage(ctx, reqStorage); err != nil {
+		return err
+	}
+
+	return f()
+}
+
+func (m *Manager) doTaskWrap(ctx context.Context, reqStorage logical.Storage, opts TaskOptions, taskFunc func(context.Context, logical.Storage) error, f func(newTaskFunc func(ctx context.Context) error, workers int) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.initStorage(ctx, reqStorage); err != nil {
+		return err
 	}
 
 	config, err := getConfiguration(ctx, reqStorage)
@@ -79,16 +122,49 @@ func (m *Manager) doTaskWrap(ctx context.Context, reqStorage logical.Storage, ta
 		return fmt.Errorf("unable to get tasks manager configuration: %w", err)
 	}
 
-	var taskTimeoutDuration time.Duration
-	if config != nil {
-		taskTimeoutDuration = config.TaskTimeout
-	} else {
-		taskTimeoutDuration = defaultTaskTimeoutDuration
+	workers := configWorkers(config)
+	m.setWorkers(workers)
+
+	workerTaskFunc := m.WrapTaskFunc(m.wrapTaskFuncWithLocks(taskFunc, opts.Locks), configTaskTimeout(config))
+
+	return f(workerTaskFunc, workers)
+}
+
+// initStorage is called on the first access to the storage to restore the tasks of the previous plugin run.
+func (m *Manager) initStorage(ctx context.Context, reqStorage logical.Storage) error {
+	if m.Storage != nil {
+		return nil
 	}
 
-	workerTaskFunc := m.WrapTaskFunc(taskFunc, taskTimeoutDuration)
+	m.Storage = reqStorage
+	if err := m.restoreStorage(ctx, reqStorage); err != nil {
+		return fmt.Errorf("unable to restore storage: %w", err)
+	}
 
-	return f(workerTaskFunc)
+	return nil
+}
+
+// wrapTaskFuncWithLocks holds the declared task locks and allows the task steps to hold additional locks.
+func (m *Manager) wrapTaskFuncWithLocks(taskFunc func(context.Context, logical.Storage) error, locks []string) func(context.Context, logical.Storage) error {
+	locks = uniqLocks(locks)
+
+	return func(ctx context.Context, storage logical.Storage) error {
+		t := &taskLocks{locks: m.locks, held: map[string]bool{}}
+
+		if len(locks) != 0 {
+			release, err := m.locks.acquire(ctx, locks)
+			if err != nil {
+				return fmt.Errorf("unable to acquire task locks %v: %w", locks, err)
+			}
+			defer release()
+
+			for _, name := range locks {
+				t.held[name] = true
+			}
+		}
+
+		return taskFunc(context.WithValue(ctx, taskLocksCtxKey{}, t), storage)
+	}
 }
 
 // WrapTaskFunc separates processing of the context and the taskFunc execution in the background
@@ -128,50 +204,218 @@ func (m *Manager) WrapTaskFunc(taskFunc func(context.Context, logical.Storage) e
 	}
 }
 
-func (m *Manager) invalidateStorage(ctx context.Context, reqStorage logical.Storage) error {
-	var list []string
-	for _, state := range []taskState{taskStateRunning, taskStateQueued} {
-		prefix := taskStorageKeyPrefix(state)
-		l, err := reqStorage.List(ctx, prefix)
-		if err != nil {
-			return fmt.Errorf("unable to list %q in storage: %w", prefix, err)
+// restoreStorage cancels the tasks interrupted by restart of the plugin and queues again the restorable queued tasks.
+func (m *Manager) restoreStorage(ctx context.Context, reqStorage logical.Storage) error {
+	config, err := getConfiguration(ctx, reqStorage)
+	if err != nil {
+		return fmt.Errorf("unable to get tasks manager configuration: %w", err)
+	}
+
+	runningTasks, err := getTasksFromStorage(ctx, reqStorage, taskStateRunning)
+	if err != nil {
+		return err
+	}
+
+	maxAttempts := configTaskMaxAttempts(config)
+	for _, task := range runningTasks {
+		reason := taskReasonInvalidatedTask
+		if _, ok := m.taskFactories[task.Type]; ok && task.Type != "" && task.Restartable {
+			if task.Attempts < maxAttempts {
+				m.logger.Debug(fmt.Sprintf("interrupted %s task %q queued again (attempt %d of %d)", task.Type, task.UUID, task.Attempts+1, maxAttempts))
+
+				if err := switchTaskToQueuedInStorage(ctx, reqStorage, task.UUID, taskReasonRestartedTask); err != nil {
+					return fmt.Errorf("unable to queue interrupted task %q: %w", task.UUID, err)
+				}
+
+				continue
+			}
+
+			reason = fmt.Sprintf("%s: %d attempts exhausted", taskReasonInvalidatedTask, task.Attempts)
 		}
 
-		list = append(list, l...)
+		if err := switchTaskToCompletedInStorage(ctx, reqStorage, taskStatusCanceled, task.UUID, switchTaskToCompletedInStorageOptions{
+			reason: reason,
+		}); err != nil {
+			return fmt.Errorf("unable to invalidate task %q: %w", task.UUID, err)
+		}
 	}
 
-	for _, uuid := range list {
-		if err := switchTaskToCompletedInStorage(ctx, reqStorage, taskStatusCanceled, uuid, switchTaskToCompletedInStorageOptions{
-			reason: taskReasonInvalidatedTask,
+	queuedTasks, err := getTasksFromStorage(ctx, reqStorage, taskStateQueued)
+	if err != nil {
+		return err
+	}
+
+	if len(queuedTasks) == 0 {
+		return nil
+	}
+
+	m.setWorkers(configWorkers(config))
+
+	for _, task := range queuedTasks {
+		reason := taskReasonInvalidatedTask
+		if factory, ok := m.taskFactories[task.Type]; ok && task.Type != "" {
+			taskFunc, err := factory(ctx, reqStorage, task.Params)
+			if err == nil {
+				m.logger.Debug(fmt.Sprintf("restored queued %s task %q", task.Type, task.UUID))
+				m.requeueTask(task, m.WrapTaskFunc(m.wrapTaskFuncWithLocks(taskFunc, task.Locks), configTaskTimeout(config)))
+				continue
+			}
+
+			reason = fmt.Sprintf("%s: %s", taskReasonUnrestorableTask, err)
+		}
+
+		if err := switchTaskToCompletedInStorage(ctx, reqStorage, taskStatusCanceled, task.UUID, switchTaskToCompletedInStorageOptions{
+			reason: reason,
 		}); err != nil {
-			return fmt.Errorf("unable to invalidate task %q: %w", uuid, err)
+			return fmt.Errorf("unable to invalidate task %q: %w", task.UUID, err)
 		}
 	}
 
 	return nil
 }
 
-func (m *Manager) queueTask(ctx context.Context, workerTaskFunc func(context.Context) error) (string, error) {
-	queuedTaskUUID, err := addNewTaskToStorage(ctx, m.Storage)
+// findDuplicateTask returns the UUID of the queued task (or the running task if CollapseWithRunning is set)
+// with the same type and params, or an empty string if there is no such task.
+func (m *Manager) findDuplicateTask(ctx context.Context, reqStorage logical.Storage, opts TaskOptions) (string, error) {
+	if opts.Type == "" {
+		return "", nil
+	}
+
+	states := []taskState{taskStateQueued}
+	if opts.CollapseWithRunning {
+		states = append(states, taskStateRunning)
+	}
+
+	for _, state := range states {
+		tasks, err := getTasksFromStorage(ctx, reqStorage, state)
+		if err != nil {
+			return "", err
+		}
+
+		for _, task := range tasks {
+			if task.Type == opts.Type && equalTaskParams(task.Params, opts.Params) {
+				return task.UUID, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+func equalTaskParams(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *Manager) queueTask(ctx context.Context, opts TaskOptions, workerTaskFunc func(context.Context) error) (string, error) {
+	queuedTaskUUID, err := addNewTaskToStorage(ctx, m.Storage, opts)
 	if err != nil {
 		return "", err
 	}
 
-	m.taskChan <- &worker.Task{Context: ctx, UUID: queuedTaskUUID, Action: workerTaskFunc}
+	m.pushTask(ctx, queuedTaskUUID, opts.Locks, workerTaskFunc)
 
 	return queuedTaskUUID, nil
 }
 
-func (m *Manager) isBusy(ctx context.Context, reqStorage logical.Storage) (bool, error) {
-	// busy if there are running or queued tasks
+// requeueTask pushes the task restored from the storage to the queue, the task is run in the background context.
+func (m *Manager) requeueTask(task *Task, workerTaskFunc func(context.Context) error) {
+	m.pushTask(context.Background(), task.UUID, task.Locks, workerTaskFunc)
+}
+
+func (m *Manager) pushTask(ctx context.Context, uuid string, locks []string, workerTaskFunc func(context.Context) error) {
+	if len(locks) != 0 {
+		if m.taskLocks == nil {
+			m.taskLocks = map[string][]string{}
+		}
+		m.taskLocks[uuid] = locks
+	}
+
+	result := &taskResult{}
+	if m.taskResults == nil {
+		m.taskResults = map[string]*taskResult{}
+	}
+	m.taskResults[uuid] = result
+
+	m.enqueueTask(&worker.Task{Context: ctx, UUID: uuid, Action: func(ctx context.Context) error {
+		return workerTaskFunc(context.WithValue(ctx, taskResultCtxKey{}, result))
+	}})
+}
+
+// enqueueTask never blocks: pushTask is called under mu, which is also required by the task callbacks of the workers.
+// If the task channel is full, the task is kept in the pending queue and forwarded in the original order.
+func (m *Manager) enqueueTask(task *worker.Task) {
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+
+	if len(m.pendingTasks) == 0 {
+		select {
+		case m.taskChan <- task:
+			return
+		default:
+		}
+	}
+
+	m.pendingTasks = append(m.pendingTasks, task)
+	if !m.pendingForwarding {
+		m.pendingForwarding = true
+		go m.forwardPendingTasks()
+	}
+}
+
+func (m *Manager) forwardPendingTasks() {
+	for {
+		m.pendingMu.Lock()
+		if len(m.pendingTasks) == 0 {
+			m.pendingForwarding = false
+			m.pendingMu.Unlock()
+			return
+		}
+		task := m.pendingTasks[0]
+		m.pendingMu.Unlock()
+
+		m.taskChan <- task
+
+		// the task is dropped from the pending queue only after sending to keep the order with the new tasks
+		m.pendingMu.Lock()
+		m.pendingTasks = m.pendingTasks[1:]
+		m.pendingMu.Unlock()
+	}
+}
+
+func (m *Manager) isBusy(ctx context.Context, reqStorage logical.Storage, workers int, locks []string) (bool, error) {
+	// busy if all workers are occupied by running and queued tasks
+	var tasksNumber int
 	for _, prefix := range []string{storageKeyPrefixRunningTask, storageKeyPrefixQueuedTask} {
 		list, err := reqStorage.List(ctx, prefix)
 		if err != nil {
 			return false, fmt.Errorf("unable to list %q in storage: %w", prefix, err)
 		}
 
-		if len(list) != 0 {
-			return true, nil
+		tasksNumber += len(list)
+	}
+
+	if tasksNumber >= workers {
+		return true, nil
+	}
+
+	// busy if the task would wait for the locks declared by running or queued tasks
+	for _, taskLocks := range m.taskLocks {
+		for _, taskLock := range taskLocks {
+			for _, lock := range locks {
+				if lock == taskLock {
+					return true, nil
+				}
+			}
 		}
 	}
 
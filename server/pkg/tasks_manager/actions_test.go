@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -22,7 +23,7 @@ func TestManager_RunTask(t *testing.T) {
 	var uuids []string
 	// check the first task
 	{
-		uuid, err := m.RunTask(ctx, storage, noneTask)
+		uuid, err := m.RunTask(ctx, storage, TaskOptions{}, noneTask)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, uuid)
 		assert.NotNil(t, m.Storage, "must be initialized on the first action call")
@@ -34,7 +35,7 @@ func TestManager_RunTask(t *testing.T) {
 
 	// check the second task
 	{
-		uuid, err := m.RunTask(ctx, storage, noneTask)
+		uuid, err := m.RunTask(ctx, storage, TaskOptions{}, noneTask)
 		if assert.Error(t, err) {
 			assert.Equal(t, err, ErrBusy)
 		}
@@ -58,7 +59,7 @@ func TestManager_RunTaskWithCurrentRunningTask(t *testing.T) {
 	runningTaskUUID := assertAndAddRunningTaskToStorage(t, ctx, storage)
 
 	{
-		uuid, err := m.RunTask(ctx, storage, noneTask)
+		uuid, err := m.RunTask(ctx, storage, TaskOptions{}, noneTask)
 		if assert.Error(t, err) {
 			assert.Equal(t, err, ErrBusy)
 		}
@@ -70,7 +71,7 @@ func TestManager_RunTaskWithCurrentRunningTask(t *testing.T) {
 	assert.Nil(t, err)
 
 	{
-		uuid, err := m.RunTask(ctx, storage, noneTask)
+		uuid, err := m.RunTask(ctx, storage, TaskOptions{}, noneTask)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, uuid)
 
@@ -92,7 +93,7 @@ func TestManager_RunTaskInvalidateStorage(t *testing.T) {
 	runningTaskUUID := assertAndAddRunningTaskToStorage(t, ctx, storage)
 	assert.Nil(t, m.Storage, "must be initialized on the first action call")
 
-	uuid, err := m.RunTask(ctx, storage, noneTask)
+	uuid, err := m.RunTask(ctx, storage, TaskOptions{}, noneTask)
 	assert.Nil(t, err)
 	assert.NotEmpty(t, uuid)
 
@@ -134,7 +135,7 @@ func TestManager_AddTask(t *testing.T) {
 	assert.Nil(t, m.Storage, "must be initialized on the first action call")
 	var uuids []string
 	for i := 0; i < 2; i++ {
-		uuid, err := m.AddTask(ctx, storage, noneTask)
+		uuid, err := m.AddTask(ctx, storage, TaskOptions{}, noneTask)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, uuid)
 		if i == 0 {
@@ -153,6 +154,42 @@ func TestManager_AddTask(t *testing.T) {
 	}
 }
 
+// check that Manager.RunExclusive blocks adding tasks until f returns
+func TestManager_RunExclusive(t *testing.T) {
+	ctx := context.Background()
+	m := initManagerWithoutWorker()
+	storage := &logical.InmemStorage{}
+
+	exclusiveStartedCh := make(chan bool)
+	exclusiveDoneCh := make(chan bool)
+	go func() {
+		err := m.RunExclusive(ctx, storage, func() error {
+			exclusiveStartedCh <- true
+			<-exclusiveDoneCh
+			return nil
+		})
+		assert.Nil(t, err)
+	}()
+	<-exclusiveStartedCh
+	assert.NotNil(t, m.Storage, "must be initialized on the first action call")
+
+	addedCh := make(chan string)
+	go func() {
+		uuid, err := m.AddTask(ctx, storage, TaskOptions{}, noneTask)
+		assert.Nil(t, err)
+		addedCh <- uuid
+	}()
+
+	select {
+	case <-addedCh:
+		t.Fatal("task must not be added while f is running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(exclusiveDoneCh)
+	assertQueuedTaskInStorage(t, ctx, storage, <-addedCh)
+}
+
 // check that Manager.AddOptionalTask queues task when manager not busy
 func TestManager_AddOptionalTask(t *testing.T) {
 	ctx := context.Background()
@@ -163,7 +200,7 @@ func TestManager_AddOptionalTask(t *testing.T) {
 	var uuids []string
 	// check the first task
 	{
-		uuid, added, err := m.AddOptionalTask(ctx, storage, noneTask)
+		uuid, added, err := m.AddOptionalTask(ctx, storage, TaskOptions{}, noneTask)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, uuid)
 		assert.True(t, added)
@@ -176,7 +213,7 @@ func TestManager_AddOptionalTask(t *testing.T) {
 
 	// check the second task
 	{
-		uuid, added, err := m.AddOptionalTask(ctx, storage, noneTask)
+		uuid, added, err := m.AddOptionalTask(ctx, storage, TaskOptions{}, noneTask)
 		assert.Nil(t, err)
 		assert.Empty(t, uuid)
 		assert.False(t, added)
@@ -222,7 +259,7 @@ func TestManager_WrapTaskFunc(t *testing.T) {
 
 func initManagerWithoutWorker() *Manager {
 	taskChan := make(chan *worker.Task, taskChanSize)
-	m := &Manager{taskChan: taskChan, logger: hclog.L()}
+	m := &Manager{taskChan: taskChan, logger: hclog.L(), locks: newResourceLocks(), taskLocks: map[string][]string{}, taskFactories: map[string]TaskFactory{}}
 	return m
 }
 
@@ -234,3 +271,247 @@ func assertQueuedTaskInStorage(t *testing.T, ctx context.Context, storage logica
 	assert.NotNil(t, task)
 	assert.Equal(t, task.Status, string(taskStatusQueued))
 }
+
+// check that Manager.RunTask takes into account the number of workers and the task locks
+func TestManager_RunTaskWithWorkersAndLocks(t *testing.T) {
+	ctx := context.Background()
+	m := initManagerWithoutWorker()
+	storage := &logical.InmemStorage{}
+
+	assert.Nil(t, putConfiguration(ctx, storage, &configuration{TaskTimeout: defaultTaskTimeoutDuration, Workers: 3}))
+
+	uuid, err := m.RunTask(ctx, storage, TaskOptions{Locks: []string{GitBuildLock("v1.0.0")}}, noneTask)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, uuid)
+
+	// the same lock
+	uuid, err = m.RunTask(ctx, storage, TaskOptions{Locks: []string{GitBuildLock("v1.0.0")}}, noneTask)
+	assert.Equal(t, ErrBusy, err)
+	assert.Empty(t, uuid)
+
+	// another lock
+	uuid, err = m.RunTask(ctx, storage, TaskOptions{Locks: []string{GitBuildLock("v1.0.1")}}, noneTask)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, uuid)
+
+	// no locks
+	uuid, err = m.RunTask(ctx, storage, TaskOptions{}, noneTask)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, uuid)
+
+	// all workers are busy
+	uuid, err = m.RunTask(ctx, storage, TaskOptions{}, noneTask)
+	assert.Equal(t, ErrBusy, err)
+	assert.Empty(t, uuid)
+}
+
+func TestWithResourceLock(t *testing.T) {
+	m := initManagerWithoutWorker()
+	storage := &logical.InmemStorage{}
+	m.Storage = storage
+
+	lockedCh := make(chan bool)
+	releaseCh := make(chan bool)
+	firstTaskFunc := m.wrapTaskFuncWithLocks(func(ctx context.Context, _ logical.Storage) error {
+		return WithResourceLock(ctx, LockTufRepoCommit, func() error {
+			lockedCh <- true
+			<-releaseCh
+			return nil
+		})
+	}, nil)
+
+	var order []string
+	secondTaskFunc := m.wrapTaskFuncWithLocks(func(ctx context.Context, _ logical.Storage) error {
+		// the declared lock is not acquired again
+		return WithResourceLock(ctx, LockTufRepoCommit, func() error {
+			order = append(order, "second")
+			return nil
+		})
+	}, []string{LockTufRepoCommit})
+
+	firstErrCh := make(chan error)
+	go func() { firstErrCh <- firstTaskFunc(context.Background(), storage) }()
+	<-lockedCh
+
+	// the second task waits for the lock
+	waitCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, secondTaskFunc(waitCtx, storage), context.DeadlineExceeded)
+	assert.Empty(t, order)
+
+	secondErrCh := make(chan error)
+	go func() { secondErrCh <- secondTaskFunc(context.Background(), storage) }()
+
+	order = append(order, "first")
+	releaseCh <- true
+	assert.Nil(t, <-firstErrCh)
+	assert.Nil(t, <-secondErrCh)
+	assert.Equal(t, []string{"first", "second"}, order)
+}
+
+// check that Manager.AddTask returns the queued or running task with the same type and params instead of adding a duplicate
+func TestManager_AddTaskCollapseDuplicates(t *testing.T) {
+	ctx := context.Background()
+	m := initManagerWithoutWorker()
+	storage := &logical.InmemStorage{}
+
+	opts := TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.0"}}
+
+	uuid, err := m.AddTask(ctx, storage, opts, noneTask)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, uuid)
+
+	duplicateUUID, err := m.AddTask(ctx, storage, opts, noneTask)
+	assert.Nil(t, err)
+	assert.Equal(t, uuid, duplicateUUID)
+
+	otherUUID, err := m.AddTask(ctx, storage, TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.1"}}, noneTask)
+	assert.Nil(t, err)
+	assert.NotEqual(t, uuid, otherUUID)
+
+	// the running task is collapsed only with CollapseWithRunning
+	assert.Nil(t, switchTaskToRunningInStorage(ctx, storage, uuid))
+
+	newUUID, err := m.AddTask(ctx, storage, opts, noneTask)
+	assert.Nil(t, err)
+	assert.NotEqual(t, uuid, newUUID)
+
+	assert.Nil(t, switchTaskToRunningInStorage(ctx, storage, otherUUID))
+
+	opts = TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.1"}, CollapseWithRunning: true}
+	runningUUID, err := m.AddTask(ctx, storage, opts, noneTask)
+	assert.Nil(t, err)
+	assert.Equal(t, otherUUID, runningUUID)
+
+	assert.Len(t, m.taskChan, 3)
+}
+
+// check that the tasks overflowing the task channel are queued without blocking and keep the order
+func TestManager_AddTaskOverflowTaskChan(t *testing.T) {
+	ctx := context.Background()
+	m := initManagerWithoutWorker()
+	storage := &logical.InmemStorage{}
+
+	var uuids []string
+	for i := 0; i < taskChanSize+10; i++ {
+		uuid, err := m.AddTask(ctx, storage, TaskOptions{}, noneTask)
+		assert.Nil(t, err)
+		uuids = append(uuids, uuid)
+	}
+
+	// the worker callbacks are not blocked by the overflowed queue
+	m.TaskStartedCallback(ctx, uuids[0])
+
+	for _, uuid := range uuids {
+		task := <-m.taskChan
+		assert.Equal(t, uuid, task.UUID)
+	}
+}
+
+// check that the queued tasks of the registered types are restored after restart of the plugin
+func TestManager_RestoreQueuedTasks(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	// imitate the queue of the previous plugin run
+	restorableUUID, err := addNewTaskToStorage(ctx, storage, TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.0"}, Locks: []string{GitBuildLock("v1.0.0")}})
+	assert.Nil(t, err)
+	failedUUID, err := addNewTaskToStorage(ctx, storage, TaskOptions{Type: "release", Params: map[string]string{"git_tag": "bad"}})
+	assert.Nil(t, err)
+	untypedUUID := assertAndAddNewTaskToStorage(t, ctx, storage)
+
+	m := initManagerWithoutWorker()
+	var restoredParams []map[string]string
+	m.RegisterTaskFactory("release", func(_ context.Context, _ logical.Storage, params map[string]string) (func(context.Context, logical.Storage) error, error) {
+		if params["git_tag"] == "bad" {
+			return nil, fmt.Errorf("bad tag")
+		}
+
+		restoredParams = append(restoredParams, params)
+		return noneTask, nil
+	})
+
+	assert.Nil(t, m.initStorage(ctx, storage))
+	assert.Equal(t, []map[string]string{{"git_tag": "v1.0.0"}}, restoredParams)
+
+	assertQueuedTaskInStorage(t, ctx, storage, restorableUUID)
+	if assert.Len(t, m.taskChan, 1) {
+		task := <-m.taskChan
+		assert.Equal(t, restorableUUID, task.UUID)
+	}
+	assert.Equal(t, []string{GitBuildLock("v1.0.0")}, m.taskLocks[restorableUUID])
+
+	task, err := getTaskFromStorage(ctx, storage, taskStateCompleted, failedUUID)
+	assert.Nil(t, err)
+	if assert.NotNil(t, task) {
+		assert.Equal(t, string(taskStatusCanceled), task.Status)
+		assert.Equal(t, taskReasonUnrestorableTask+": bad tag", task.Reason)
+	}
+
+	task, err = getTaskFromStorage(ctx, storage, taskStateCompleted, untypedUUID)
+	assert.Nil(t, err)
+	if assert.NotNil(t, task) {
+		assert.Equal(t, string(taskStatusCanceled), task.Status)
+		assert.Equal(t, taskReasonInvalidatedTask, task.Reason)
+	}
+}
+
+// check that the interrupted restartable tasks are queued again until the attempts are exhausted
+func TestManager_RestartInterruptedTasks(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	assert.Nil(t, putConfiguration(ctx, storage, &configuration{TaskMaxAttempts: 2}))
+
+	// imitate the tasks interrupted by restart of the plugin
+	addRunningTask := func(opts TaskOptions, attempts int) string {
+		uuid, err := addNewTaskToStorage(ctx, storage, opts)
+		assert.Nil(t, err)
+
+		for i := 0; i < attempts; i++ {
+			if i > 0 {
+				assert.Nil(t, switchTaskToQueuedInStorage(ctx, storage, uuid, taskReasonRestartedTask))
+			}
+			assert.Nil(t, switchTaskToRunningInStorage(ctx, storage, uuid))
+		}
+
+		return uuid
+	}
+
+	restartableOpts := TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.0"}, Restartable: true}
+	restartedUUID := addRunningTask(restartableOpts, 1)
+	exhaustedUUID := addRunningTask(restartableOpts, 2)
+	notRestartableUUID := addRunningTask(TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.0"}}, 1)
+
+	m := initManagerWithoutWorker()
+	m.RegisterTaskFactory("release", func(_ context.Context, _ logical.Storage, _ map[string]string) (func(context.Context, logical.Storage) error, error) {
+		return noneTask, nil
+	})
+
+	assert.Nil(t, m.initStorage(ctx, storage))
+
+	task, err := getTaskFromStorage(ctx, storage, taskStateQueued, restartedUUID)
+	assert.Nil(t, err)
+	if assert.NotNil(t, task) {
+		assert.Equal(t, string(taskStatusQueued), task.Status)
+		assert.Equal(t, taskReasonRestartedTask, task.Reason)
+		assert.Equal(t, 1, task.Attempts)
+	}
+	if assert.Len(t, m.taskChan, 1) {
+		task := <-m.taskChan
+		assert.Equal(t, restartedUUID, task.UUID)
+	}
+
+	task, err = getTaskFromStorage(ctx, storage, taskStateCompleted, exhaustedUUID)
+	assert.Nil(t, err)
+	if assert.NotNil(t, task) {
+		assert.Equal(t, string(taskStatusCanceled), task.Status)
+		assert.Equal(t, taskReasonInvalidatedTask+": 2 attempts exhausted", task.Reason)
+	}
+
+	task, err = getTaskFromStorage(ctx, storage, taskStateCompleted, notRestartableUUID)
+	assert.Nil(t, err)
+	if assert.NotNil(t, task) {
+		assert.Equal(t, string(taskStatusCanceled), task.Status)
+		assert.Equal(t, taskReasonInvalidatedTask, task.Reason)
+	}
+}
@@ -3,6 +3,8 @@ package tasks_manager
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/fatih/structs"
@@ -15,28 +17,51 @@ import (
 const (
 	fieldNameTaskTimeout      = "task_timeout"
 	fieldNameTaskHistoryLimit = "task_history_limit"
+	fieldNameWorkers          = "workers"
+	fieldNameTaskMaxAttempts  = "task_max_attempts"
+	fieldNameTaskLogMaxSize   = "task_log_max_size"
+	fieldNameTaskHistoryAge   = "task_history_max_age"
 	fieldNameUUID             = "uuid"
 	fieldNameLimit            = "limit"
 	fieldNameOffset           = "offset"
+	fieldNameType             = "type"
+	fieldNameStatus           = "status"
+	fieldNameSince            = "since"
+	fieldNameUntil            = "until"
 
 	fieldDefaultTaskTimeout      = "30m"
 	fieldDefaultTaskHistoryLimit = 10
+	fieldDefaultWorkers          = 1
+	fieldDefaultTaskMaxAttempts  = 3
+	fieldDefaultTaskLogMaxSize   = 5 * 1024 * 1024
 	fieldDefaultLimit            = 500
 
 	defaultTaskTimeoutDuration = 30 * time.Minute
 )
 
 var (
-	pathPatternConfigure  = "task/configure/?"
-	pathPatternTaskList   = "task/?"
-	pathPatternTaskStatus = "task/" + uuidPattern(fieldNameUUID) + "$"
-	pathPatternTaskCancel = "task/" + uuidPattern(fieldNameUUID) + "/cancel$"
-	pathPatternTaskLog    = "task/" + uuidPattern(fieldNameUUID) + "/log$"
+	pathPatternConfigure    = "task/configure/?"
+	pathPatternTaskList     = "task/?"
+	pathPatternTaskStatus   = "task/" + uuidPattern(fieldNameUUID) + "$"
+	pathPatternTaskCancel   = "task/" + uuidPattern(fieldNameUUID) + "/cancel$"
+	pathPatternTaskLog      = "task/" + uuidPattern(fieldNameUUID) + "/log$"
+	pathPatternTaskProgress = "task/" + uuidPattern(fieldNameUUID) + "/progress$"
 
 	errorResponseConfigurationNotFound = logical.ErrorResponse("Configuration not found")
 )
 
 func (m *Manager) Paths() []*framework.Path {
+	m.mu.Lock()
+	taskTypes := make([]interface{}, 0, len(m.taskTypes))
+	for _, taskType := range m.taskTypes {
+		taskTypes = append(taskTypes, taskType)
+	}
+	typeDescription := "Task type"
+	if len(m.taskTypes) > 0 {
+		typeDescription = fmt.Sprintf("Task type (%s)", strings.Join(m.taskTypes, ", "))
+	}
+	m.mu.Unlock()
+
 	return []*framework.Path{
 		{
 			Pattern:      pathPatternConfigure,
@@ -52,6 +77,25 @@ func (m *Manager) Paths() []*framework.Path {
 					Description: "Task history limit",
 					Default:     fieldDefaultTaskHistoryLimit,
 				},
+				fieldNameWorkers: {
+					Type:        framework.TypeInt,
+					Description: "Number of tasks running concurrently. The steps changing the TUF repository are serialized regardless of this setting",
+					Default:     fieldDefaultWorkers,
+				},
+				fieldNameTaskMaxAttempts: {
+					Type:        framework.TypeInt,
+					Description: "Maximum number of attempts to run the release or publish task interrupted by restart of the plugin",
+					Default:     fieldDefaultTaskMaxAttempts,
+				},
+				fieldNameTaskLogMaxSize: {
+					Type:        framework.TypeInt,
+					Description: "Maximum size of the stored task log in bytes. The head and the tail of the larger log are kept",
+					Default:     fieldDefaultTaskLogMaxSize,
+				},
+				fieldNameTaskHistoryAge: {
+					Type:        framework.TypeDurationSecond,
+					Description: "Maximum age of the completed tasks and their logs in the task history (not limited by default). The task history limit is applied too",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
@@ -71,11 +115,45 @@ func (m *Manager) Paths() []*framework.Path {
 		{
 			Pattern:         pathPatternTaskList,
 			HelpSynopsis:    "Get tasks",
-			HelpDescription: "Get tasks",
-			Fields:          map[string]*framework.FieldSchema{},
+			HelpDescription: "Get tasks sorted by creation time starting from the newest one",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameType: {
+					Type:          framework.TypeString,
+					Description:   typeDescription,
+					AllowedValues: taskTypes,
+					Query:         true,
+				},
+				fieldNameStatus: {
+					Type:          framework.TypeString,
+					Description:   "Task status",
+					AllowedValues: []interface{}{string(taskStatusQueued), string(taskStatusRunning), string(taskStatusSucceeded), string(taskStatusFailed), string(taskStatusCanceled)},
+					Query:         true,
+				},
+				fieldNameSince: {
+					Type:        framework.TypeTime,
+					Description: "Get the tasks created at or after the time (RFC3339 or Unix time)",
+					Query:       true,
+				},
+				fieldNameUntil: {
+					Type:        framework.TypeTime,
+					Description: "Get the tasks created before the time (RFC3339 or Unix time)",
+					Query:       true,
+				},
+				fieldNameLimit: {
+					Type:        framework.TypeInt,
+					Description: "Limit of tasks (no limit by default)",
+					Query:       true,
+				},
+				fieldNameOffset: {
+					Type:        framework.TypeInt,
+					Description: "Offset",
+					Default:     0,
+					Query:       true,
+				},
+			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
-					Description: "Get a list of task UUIDs",
+					Description: "Get a list of task UUIDs with the task info",
 					Callback:    m.pathTaskList,
 				},
 			},
@@ -118,6 +196,24 @@ func (m *Manager) Paths() []*framework.Path {
 				},
 			},
 		},
+		{
+			Pattern:         pathPatternTaskProgress,
+			HelpSynopsis:    "Get the task progress",
+			HelpDescription: "Get the stages of the task with their statuses and timings",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameUUID: {
+					Type:        framework.TypeNameString,
+					Description: "Task UUID",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Description: "Get the task progress",
+					Callback:    m.pathTaskProgress,
+				},
+			},
+		},
 		{
 			Pattern:      pathPatternTaskLog,
 			HelpSynopsis: "Get the task log",
@@ -155,16 +251,42 @@ func (m *Manager) Paths() []*framework.Path {
 func (m *Manager) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
 	taskTimeout := time.Duration(fields.Get(fieldNameTaskTimeout).(int)) * time.Second
 	taskHistoryLimit := fields.Get(fieldNameTaskHistoryLimit).(int)
+	workers := fields.Get(fieldNameWorkers).(int)
+	taskMaxAttempts := fields.Get(fieldNameTaskMaxAttempts).(int)
+	taskLogMaxSize := fields.Get(fieldNameTaskLogMaxSize).(int)
+	taskHistoryAge := time.Duration(fields.Get(fieldNameTaskHistoryAge).(int)) * time.Second
+
+	if workers < 1 {
+		return logical.ErrorResponse("Field %q must be positive", fieldNameWorkers), nil
+	}
+
+	if taskMaxAttempts < 1 {
+		return logical.ErrorResponse("Field %q must be positive", fieldNameTaskMaxAttempts), nil
+	}
+
+	if taskLogMaxSize < 1 {
+		return logical.ErrorResponse("Field %q must be positive", fieldNameTaskLogMaxSize), nil
+	}
+
+	if taskHistoryAge < 0 {
+		return logical.ErrorResponse("Field %q cannot be negative", fieldNameTaskHistoryAge), nil
+	}
 
 	cfg := &configuration{
 		TaskTimeout:      taskTimeout,
 		TaskHistoryLimit: taskHistoryLimit,
+		Workers:          workers,
+		TaskMaxAttempts:  taskMaxAttempts,
+		TaskLogMaxSize:   taskLogMaxSize,
+		TaskHistoryAge:   taskHistoryAge,
 	}
 
 	if err := putConfiguration(ctx, req.Storage, cfg); err != nil {
 		return nil, fmt.Errorf("unable to save configuration: %w", err)
 	}
 
+	m.setWorkers(workers)
+
 	return nil, nil
 }
 
@@ -180,22 +302,78 @@ func (m *Manager) pathConfigureRead(ctx context.Context, req *logical.Request, _
 
 	data := structs.Map(c)
 	data[fieldNameTaskTimeout] = c.TaskTimeout / time.Second
+	data[fieldNameWorkers] = configWorkers(c)
+	data[fieldNameTaskMaxAttempts] = configTaskMaxAttempts(c)
+	data[fieldNameTaskLogMaxSize] = configTaskLogMaxSize(c)
+	data[fieldNameTaskHistoryAge] = c.TaskHistoryAge / time.Second
 	return &logical.Response{Data: data}, nil
 }
 
-func (m *Manager) pathTaskList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
-	var list []string
-	for _, state := range []taskState{taskStateCompleted, taskStateRunning, taskStateQueued} {
-		prefix := taskStorageKeyPrefix(state)
-		l, err := req.Storage.List(ctx, prefix)
+func (m *Manager) pathTaskList(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	offset := fields.Get(fieldNameOffset).(int)
+	limit := fields.Get(fieldNameLimit).(int)
+	taskType := fields.Get(fieldNameType).(string)
+	status := fields.Get(fieldNameStatus).(string)
+	since := fields.Get(fieldNameSince).(time.Time)
+	until := fields.Get(fieldNameUntil).(time.Time)
+
+	if offset < 0 {
+		return logical.ErrorResponse("Field %q cannot be negative", fieldNameOffset), nil
+	}
+
+	if limit < 0 {
+		return logical.ErrorResponse("Field %q cannot be negative", fieldNameLimit), nil
+	}
+
+	states := []taskState{taskStateCompleted, taskStateRunning, taskStateQueued}
+	if status != "" {
+		states = []taskState{taskStatusState(taskStatus(status))}
+	}
+
+	var tasks []*Task
+	for _, state := range states {
+		l, err := getTasksFromStorage(ctx, req.Storage, state)
 		if err != nil {
-			return nil, fmt.Errorf("unable to list %q in storage: %w", prefix, err)
+			return nil, err
 		}
 
-		list = append(list, l...)
+		for _, task := range l {
+			switch {
+			case status != "" && task.Status != status:
+			case taskType != "" && task.Type != taskType:
+			case !since.IsZero() && task.Created.Before(since):
+			case !until.IsZero() && !task.Created.Before(until):
+			default:
+				tasks = append(tasks, task)
+			}
+		}
 	}
 
-	return logical.ListResponse(list), nil
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Created.After(tasks[j].Created)
+	})
+
+	total := len(tasks)
+	switch {
+	case total <= offset:
+		tasks = nil
+	case limit == 0 || len(tasks[offset:]) < limit:
+		tasks = tasks[offset:]
+	default:
+		tasks = tasks[offset : offset+limit]
+	}
+
+	keys := make([]string, 0, len(tasks))
+	keysInfo := make(map[string]interface{}, len(tasks))
+	for _, task := range tasks {
+		keys = append(keys, task.UUID)
+		keysInfo[task.UUID] = structs.Map(task)
+	}
+
+	resp := logical.ListResponseWithInfo(keys, keysInfo)
+	resp.Data["total"] = total
+
+	return resp, nil
 }
 
 func (m *Manager) pathTaskStatus(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
@@ -235,6 +413,68 @@ func (m *Manager) pathTaskCancel(_ context.Context, _ *logical.Request, fields *
 	return nil, nil
 }
 
+func (m *Manager) pathTaskProgress(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	uuid := fields.Get(fieldNameUUID).(string)
+
+	var task *Task
+	var state taskState
+	for _, s := range []taskState{taskStateQueued, taskStateRunning, taskStateCompleted} {
+		t, err := getTaskFromStorage(ctx, req.Storage, s, uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		if t != nil {
+			task = t
+			state = s
+			break
+		}
+	}
+
+	if task == nil {
+		return logical.ErrorResponse("Task %q not found", uuid), nil
+	}
+
+	// the stages of the running task are saved on completion
+	stages := task.Stages
+	if state == taskStateRunning {
+		m.mu.Lock()
+		result := m.taskResults[uuid]
+		m.mu.Unlock()
+
+		if result != nil {
+			stages = result.snapshotStages()
+		}
+	}
+
+	now := time.Now()
+	stagesData := make([]map[string]interface{}, 0, len(stages))
+	for _, stage := range stages {
+		stageData := map[string]interface{}{
+			"name":    stage.Name,
+			"status":  stage.Status,
+			"started": stage.Started,
+		}
+
+		finished := now
+		if stage.Finished != nil {
+			finished = *stage.Finished
+			stageData["finished"] = finished
+		}
+		stageData["duration"] = finished.Sub(stage.Started).Seconds()
+
+		stagesData = append(stagesData, stageData)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"uuid":   task.UUID,
+			"status": task.Status,
+			"stages": stagesData,
+		},
+	}, nil
+}
+
 func (m *Manager) pathTaskLogRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
 	offset := fields.Get(fieldNameOffset).(int)
 	limit := fields.Get(fieldNameLimit).(int)
@@ -38,14 +38,21 @@ func TestManager_pathConfigureCreateOrUpdate(t *testing.T) {
 				assert.Equal(t, &configuration{
 					TaskTimeout:      defaultTaskTimeoutDuration,
 					TaskHistoryLimit: fieldDefaultTaskHistoryLimit,
+					Workers:          fieldDefaultWorkers,
+					TaskMaxAttempts:  fieldDefaultTaskMaxAttempts,
+					TaskLogMaxSize:   fieldDefaultTaskLogMaxSize,
 				}, c)
 			})
 
 			t.Run("custom", func(t *testing.T) {
-				ctx, b, _, storage := pathTestSetup(t)
+				ctx, b, m, storage := pathTestSetup(t)
 
 				expectedTaskTimeout := 5 * time.Minute
 				expectedTaskHistoryLimit := 25
+				expectedWorkers := 3
+				expectedTaskMaxAttempts := 5
+				expectedTaskLogMaxSize := 1024
+				expectedTaskHistoryAge := 24 * time.Hour
 				fieldValueTaskTimeout := expectedTaskTimeout.String()
 				fieldValueTaskHistoryLimit := expectedTaskHistoryLimit
 
@@ -55,6 +62,10 @@ func TestManager_pathConfigureCreateOrUpdate(t *testing.T) {
 					Data: map[string]interface{}{
 						fieldNameTaskTimeout:      fieldValueTaskTimeout,
 						fieldNameTaskHistoryLimit: fieldValueTaskHistoryLimit,
+						fieldNameWorkers:          expectedWorkers,
+						fieldNameTaskMaxAttempts:  expectedTaskMaxAttempts,
+						fieldNameTaskLogMaxSize:   expectedTaskLogMaxSize,
+						fieldNameTaskHistoryAge:   expectedTaskHistoryAge.String(),
 					},
 					Storage: storage,
 				}
@@ -68,7 +79,42 @@ func TestManager_pathConfigureCreateOrUpdate(t *testing.T) {
 				assert.Equal(t, &configuration{
 					TaskTimeout:      expectedTaskTimeout,
 					TaskHistoryLimit: expectedTaskHistoryLimit,
+					Workers:          expectedWorkers,
+					TaskMaxAttempts:  expectedTaskMaxAttempts,
+					TaskLogMaxSize:   expectedTaskLogMaxSize,
+					TaskHistoryAge:   expectedTaskHistoryAge,
 				}, c)
+				assert.Equal(t, expectedWorkers, m.pool.Size())
+			})
+
+			t.Run("invalid workers", func(t *testing.T) {
+				ctx, b, _, storage := pathTestSetup(t)
+
+				req := &logical.Request{
+					Operation: op,
+					Path:      "task/configure",
+					Data:      map[string]interface{}{fieldNameWorkers: 0},
+					Storage:   storage,
+				}
+
+				resp, err := b.HandleRequest(ctx, req)
+				assert.Nil(t, err)
+				assert.Equal(t, logical.ErrorResponse("Field %q must be positive", fieldNameWorkers), resp)
+			})
+
+			t.Run("invalid task max attempts", func(t *testing.T) {
+				ctx, b, _, storage := pathTestSetup(t)
+
+				req := &logical.Request{
+					Operation: op,
+					Path:      "task/configure",
+					Data:      map[string]interface{}{fieldNameTaskMaxAttempts: 0},
+					Storage:   storage,
+				}
+
+				resp, err := b.HandleRequest(ctx, req)
+				assert.Nil(t, err)
+				assert.Equal(t, logical.ErrorResponse("Field %q must be positive", fieldNameTaskMaxAttempts), resp)
 			})
 		})
 	}
@@ -100,6 +146,10 @@ func TestManager_pathConfigureRead(t *testing.T) {
 		expectedResponseData := map[string]interface{}{
 			fieldNameTaskTimeout:      expectedTimeout / time.Second,
 			fieldNameTaskHistoryLimit: expectedHistoryLimit,
+			fieldNameWorkers:          fieldDefaultWorkers,
+			fieldNameTaskMaxAttempts:  fieldDefaultTaskMaxAttempts,
+			fieldNameTaskLogMaxSize:   fieldDefaultTaskLogMaxSize,
+			fieldNameTaskHistoryAge:   time.Duration(0),
 		}
 
 		err := putConfiguration(ctx, storage, expectedConfig)
@@ -133,7 +183,7 @@ func TestManager_pathTaskList(t *testing.T) {
 		resp, err := b.HandleRequest(ctx, req)
 		assert.Nil(t, err)
 		if assert.NotNil(t, resp) {
-			assert.Equal(t, map[string]interface{}{}, resp.Data)
+			assert.Equal(t, map[string]interface{}{"total": 0}, resp.Data)
 		}
 	})
 
@@ -151,11 +201,117 @@ func TestManager_pathTaskList(t *testing.T) {
 		resp, err := b.HandleRequest(ctx, req)
 		assert.Nil(t, err)
 		if assert.NotNil(t, resp) {
-			assert.Equal(t, map[string]interface{}{"keys": []string{runningTaskUUID, queuedTaskUUID}}, resp.Data)
+			assert.Equal(t, []string{runningTaskUUID, queuedTaskUUID}, resp.Data["keys"])
+			assert.Equal(t, 2, resp.Data["total"])
+			assert.Contains(t, resp.Data["key_info"], runningTaskUUID)
+			assert.Contains(t, resp.Data["key_info"], queuedTaskUUID)
 		}
 	})
 }
 
+func TestManager_pathTaskListFilter(t *testing.T) {
+	ctx, b, _, storage := pathTestSetup(t)
+
+	releaseTaskUUID, err := addNewTaskToStorage(ctx, storage, TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.0"}})
+	assert.Nil(t, err)
+	publishTaskUUID, err := addNewTaskToStorage(ctx, storage, TaskOptions{Type: "publish"})
+	assert.Nil(t, err)
+	assert.Nil(t, switchTaskToRunningInStorage(ctx, storage, publishTaskUUID))
+	otherReleaseTaskUUID, err := addNewTaskToStorage(ctx, storage, TaskOptions{Type: "release", Params: map[string]string{"git_tag": "v1.0.1"}})
+	assert.Nil(t, err)
+
+	otherReleaseTask, err := getTaskFromStorage(ctx, storage, taskStateQueued, otherReleaseTaskUUID)
+	assert.Nil(t, err)
+
+	for _, test := range []struct {
+		name          string
+		data          map[string]interface{}
+		expectedKeys  []string
+		expectedTotal int
+	}{
+		{
+			name:          "type",
+			data:          map[string]interface{}{fieldNameType: "release"},
+			expectedKeys:  []string{otherReleaseTaskUUID, releaseTaskUUID},
+			expectedTotal: 2,
+		},
+		{
+			name:          "status",
+			data:          map[string]interface{}{fieldNameStatus: string(taskStatusRunning)},
+			expectedKeys:  []string{publishTaskUUID},
+			expectedTotal: 1,
+		},
+		{
+			name:          "since",
+			data:          map[string]interface{}{fieldNameSince: otherReleaseTask.Created.Format(time.RFC3339Nano)},
+			expectedKeys:  []string{otherReleaseTaskUUID},
+			expectedTotal: 1,
+		},
+		{
+			name:          "until",
+			data:          map[string]interface{}{fieldNameUntil: otherReleaseTask.Created.Format(time.RFC3339Nano)},
+			expectedKeys:  []string{publishTaskUUID, releaseTaskUUID},
+			expectedTotal: 2,
+		},
+		{
+			name:          "pagination",
+			data:          map[string]interface{}{fieldNameLimit: 1, fieldNameOffset: 1},
+			expectedKeys:  []string{publishTaskUUID},
+			expectedTotal: 3,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req := &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "task",
+				Data:      test.data,
+				Storage:   storage,
+			}
+
+			resp, err := b.HandleRequest(ctx, req)
+			assert.Nil(t, err)
+			if assert.NotNil(t, resp) {
+				assert.Equal(t, test.expectedKeys, resp.Data["keys"])
+				assert.Equal(t, test.expectedTotal, resp.Data["total"])
+			}
+		})
+	}
+
+	t.Run("key info", func(t *testing.T) {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "task",
+			Data:      map[string]interface{}{fieldNameStatus: string(taskStatusQueued), fieldNameLimit: 1},
+			Storage:   storage,
+		}
+
+		resp, err := b.HandleRequest(ctx, req)
+		assert.Nil(t, err)
+		if assert.NotNil(t, resp) {
+			info := resp.Data["key_info"].(map[string]interface{})[otherReleaseTaskUUID].(map[string]interface{})
+			assert.Equal(t, "release", info["type"])
+			assert.Equal(t, map[string]string{"git_tag": "v1.0.1"}, info["params"])
+		}
+	})
+}
+
+func TestManager_pathTaskListTypeField(t *testing.T) {
+	m := initManagerWithoutWorker()
+	m.RegisterTaskTypes("release", "publish")
+
+	var typeField *framework.FieldSchema
+	for _, p := range m.Paths() {
+		if p.Pattern == pathPatternTaskList {
+			typeField = p.Fields[fieldNameType]
+		}
+	}
+
+	if assert.NotNil(t, typeField) {
+		assert.Equal(t, "Task type (release, publish)", typeField.Description)
+		assert.Equal(t, []interface{}{"release", "publish"}, typeField.AllowedValues)
+	}
+}
+
 func TestManager_pathTaskStatus(t *testing.T) {
 	ctx, b, _, storage := pathTestSetup(t)
 
@@ -211,6 +367,9 @@ func TestManager_pathTaskStatus(t *testing.T) {
 					"created":  testTask.Created,
 					"modified": testTask.Modified,
 				}
+				if testTask.Attempts != 0 {
+					expectedResponseData["attempts"] = testTask.Attempts
+				}
 
 				assert.Equal(t, expectedResponseData, resp.Data)
 			}
@@ -240,7 +399,7 @@ func TestManager_pathTaskCancel(t *testing.T) {
 		startedCh := make(chan bool)
 		taskFunc := testTaskAction(startedCh)
 
-		uuid, err := m.AddTask(ctx, storage, taskFunc)
+		uuid, err := m.AddTask(ctx, storage, TaskOptions{}, taskFunc)
 		assert.Nil(t, err)
 		assert.NotEmpty(t, uuid)
 
@@ -260,6 +419,69 @@ func TestManager_pathTaskCancel(t *testing.T) {
 	})
 }
 
+func TestManager_pathTaskProgress(t *testing.T) {
+	ctx, b, m, storage := pathTestSetup(t)
+
+	getProgress := func(t *testing.T, uuid string) *logical.Response {
+		req := &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "task/" + uuid + "/progress",
+			Data:      make(map[string]interface{}),
+			Storage:   storage,
+		}
+
+		resp, err := b.HandleRequest(ctx, req)
+		assert.Nil(t, err)
+		assert.NotNil(t, resp)
+
+		return resp
+	}
+
+	stageStatuses := func(resp *logical.Response) map[string]interface{} {
+		res := map[string]interface{}{}
+		for _, stage := range resp.Data["stages"].([]map[string]interface{}) {
+			res[stage["name"].(string)] = stage["status"]
+		}
+		return res
+	}
+
+	t.Run("nonexistent", func(t *testing.T) {
+		resp := getProgress(t, randomUUID)
+		assert.Equal(t, logical.ErrorResponse("Task %q not found", randomUUID), resp)
+	})
+
+	t.Run("running and completed", func(t *testing.T) {
+		startedCh := make(chan bool)
+		finishCh := make(chan bool)
+		uuid, err := m.RunTask(ctx, storage, TaskOptions{}, func(ctx context.Context, _ logical.Storage) error {
+			StartTaskStage(ctx, "clone")()
+			StartTaskStage(ctx, "build")
+			startedCh <- true
+			<-finishCh
+			return nil
+		})
+		assert.Nil(t, err)
+
+		<-startedCh
+		resp := getProgress(t, uuid)
+		assert.Equal(t, string(taskStatusRunning), resp.Data["status"])
+		assert.Equal(t, map[string]interface{}{"clone": string(taskStatusSucceeded), "build": string(taskStatusRunning)}, stageStatuses(resp))
+
+		finishCh <- true
+		assert.Eventually(t, func() bool {
+			task, err := getTaskFromStorage(ctx, storage, taskStateCompleted, uuid)
+			return err == nil && task != nil
+		}, time.Second*5, time.Millisecond*10)
+
+		resp = getProgress(t, uuid)
+		assert.Equal(t, string(taskStatusSucceeded), resp.Data["status"])
+		assert.Equal(t, map[string]interface{}{"clone": string(taskStatusSucceeded), "build": string(taskStatusSucceeded)}, stageStatuses(resp))
+		for _, stage := range resp.Data["stages"].([]map[string]interface{}) {
+			assert.Contains(t, stage, "finished")
+		}
+	})
+}
+
 func TestManager_pathTaskLog(t *testing.T) {
 	ctx, b, m, storage := pathTestSetup(t)
 
@@ -314,7 +536,7 @@ func TestManager_pathTaskLog(t *testing.T) {
 	t.Run(string(taskStateRunning), func(t *testing.T) {
 		msgCh := make(chan string)
 		msgSentCh := make(chan bool)
-		uuid, err := m.RunTask(ctx, storage, taskActionWithLogCh(msgCh, msgSentCh))
+		uuid, err := m.RunTask(ctx, storage, TaskOptions{}, taskActionWithLogCh(msgCh, msgSentCh))
 		assert.Nil(t, err)
 
 		var expectedLog string
@@ -439,7 +661,7 @@ func getIntPointer(val int) *int {
 }
 
 func assertAndAddNewTaskToStorage(t *testing.T, ctx context.Context, storage logical.Storage) string {
-	taskUUID, err := addNewTaskToStorage(ctx, storage)
+	taskUUID, err := addNewTaskToStorage(ctx, storage, TaskOptions{})
 	assert.Nil(t, err)
 	assert.NotEmpty(t, taskUUID)
 
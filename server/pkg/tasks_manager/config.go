@@ -7,4 +7,43 @@ const storageKeyConfiguration = "tasks_manager_configuration"
 type configuration struct {
 	TaskTimeout      time.Duration `structs:"task_timeout" json:"task_timeout"`
 	TaskHistoryLimit int           `structs:"task_history_limit" json:"task_history_limit"`
+	Workers          int           `structs:"workers" json:"workers"`
+	TaskMaxAttempts  int           `structs:"task_max_attempts" json:"task_max_attempts"`
+	TaskLogMaxSize   int           `structs:"task_log_max_size" json:"task_log_max_size"`
+	TaskHistoryAge   time.Duration `structs:"task_history_max_age" json:"task_history_max_age"`
+}
+
+// configWorkers returns the default for the configuration saved before the workers option was introduced.
+func configWorkers(config *configuration) int {
+	if config == nil || config.Workers <= 0 {
+		return fieldDefaultWorkers
+	}
+
+	return config.Workers
+}
+
+// configTaskMaxAttempts returns the default for the configuration saved before the task_max_attempts option was introduced.
+func configTaskMaxAttempts(config *configuration) int {
+	if config == nil || config.TaskMaxAttempts <= 0 {
+		return fieldDefaultTaskMaxAttempts
+	}
+
+	return config.TaskMaxAttempts
+}
+
+// configTaskLogMaxSize returns the default for the configuration saved before the task_log_max_size option was introduced.
+func configTaskLogMaxSize(config *configuration) int {
+	if config == nil || config.TaskLogMaxSize <= 0 {
+		return fieldDefaultTaskLogMaxSize
+	}
+
+	return config.TaskLogMaxSize
+}
+
+func configTaskTimeout(config *configuration) time.Duration {
+	if config == nil {
+		return defaultTaskTimeoutDuration
+	}
+
+	return config.TaskTimeout
 }
@@ -7,12 +7,15 @@ import (
 )
 
 type ActionsInterface interface {
-	// RunTask runs task or returns busy error
-	RunTask(ctx context.Context, reqStorage logical.Storage, taskFunc func(ctx context.Context, storage logical.Storage) error) (string, error)
+	// RunTask runs task or returns busy error if there is no free worker or the task locks are held by other tasks
+	RunTask(ctx context.Context, reqStorage logical.Storage, opts TaskOptions, taskFunc func(ctx context.Context, storage logical.Storage) error) (string, error)
 
-	// AddTask adds task to queue
-	AddTask(ctx context.Context, reqStorage logical.Storage, taskFunc func(ctx context.Context, storage logical.Storage) error) (string, error)
+	// AddTask adds task to queue or returns the UUID of the queued task with the same type and params
+	AddTask(ctx context.Context, reqStorage logical.Storage, opts TaskOptions, taskFunc func(ctx context.Context, storage logical.Storage) error) (string, error)
 
-	// AddOptionalTask adds task to queue if empty
-	AddOptionalTask(ctx context.Context, reqStorage logical.Storage, taskFunc func(ctx context.Context, storage logical.Storage) error) (string, bool, error)
+	// AddOptionalTask adds task to queue if it can be run immediately
+	AddOptionalTask(ctx context.Context, reqStorage logical.Storage, opts TaskOptions, taskFunc func(ctx context.Context, storage logical.Storage) error) (string, bool, error)
+
+	// RunExclusive runs f while the tasks cannot be added, started or completed
+	RunExclusive(ctx context.Context, reqStorage logical.Storage, f func() error) error
 }
@@ -0,0 +1,137 @@
+package tasks_manager
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+const (
+	// LockTufRepoCommit serializes the steps loading, changing and committing the TUF repository metadata.
+	LockTufRepoCommit = "tuf-repo-commit"
+	// LockChannelsPublish serializes the publications of the trdl channels git branch.
+	LockChannelsPublish = "channels-publish"
+	// LockRepositoryVerify serializes the verifications of the repository.
+	LockRepositoryVerify = "repository-verify"
+
+	lockPrefixGitBuild = "git-build/"
+)
+
+// GitBuildLock serializes the builds of the same git tag.
+func GitBuildLock(gitTag string) string {
+	return lockPrefixGitBuild + gitTag
+}
+
+// resourceLocks are the named locks shared by all workers.
+type resourceLocks struct {
+	held map[string]chan struct{}
+	mu   sync.Mutex
+}
+
+func newResourceLocks() *resourceLocks {
+	return &resourceLocks{held: map[string]chan struct{}{}}
+}
+
+// acquire takes all the locks at once to avoid deadlocks between the tasks waiting for the same locks in a different order.
+func (l *resourceLocks) acquire(ctx context.Context, names []string) (func(), error) {
+	for {
+		l.mu.Lock()
+		var busyCh chan struct{}
+		for _, name := range names {
+			if ch, ok := l.held[name]; ok {
+				busyCh = ch
+				break
+			}
+		}
+
+		if busyCh == nil {
+			for _, name := range names {
+				l.held[name] = make(chan struct{})
+			}
+			l.mu.Unlock()
+
+			return func() { l.release(names) }, nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-busyCh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *resourceLocks) release(names []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, name := range names {
+		if ch, ok := l.held[name]; ok {
+			close(ch)
+			delete(l.held, name)
+		}
+	}
+}
+
+type taskLocksCtxKey struct{}
+
+// taskLocks are the locks held by the running task.
+type taskLocks struct {
+	locks *resourceLocks
+	held  map[string]bool
+	mu    sync.Mutex
+}
+
+func (t *taskLocks) isHeld(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.held[name]
+}
+
+func (t *taskLocks) setHeld(name string, held bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if held {
+		t.held[name] = true
+	} else {
+		delete(t.held, name)
+	}
+}
+
+// WithResourceLock runs f holding the named lock, so the task steps working with the same resource
+// are serialized while the rest of the tasks run concurrently.
+// The locks declared by the task with TaskOptions are already held and not acquired again.
+func WithResourceLock(ctx context.Context, name string, f func() error) error {
+	t, ok := ctx.Value(taskLocksCtxKey{}).(*taskLocks)
+	if !ok || t.isHeld(name) {
+		return f()
+	}
+
+	release, err := t.locks.acquire(ctx, []string{name})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	t.setHeld(name, true)
+	defer t.setHeld(name, false)
+
+	return f()
+}
+
+func uniqLocks(names []string) []string {
+	set := map[string]bool{}
+	var res []string
+	for _, name := range names {
+		if !set[name] {
+			set[name] = true
+			res = append(res, name)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,199 @@
+package tasks_manager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// taskLogChunkSize keeps the storage entries of the compressed task log below the entry size limits of the storage backends.
+const taskLogChunkSize = 256 * 1024
+
+// truncateTaskLog keeps the head and the tail of the log which is larger than maxSize.
+func truncateTaskLog(log []byte, maxSize int) []byte {
+	if maxSize <= 0 || len(log) <= maxSize {
+		return log
+	}
+
+	headSize := maxSize / 2
+	tailSize := maxSize - headSize
+
+	var buf bytes.Buffer
+	buf.Write(log[:headSize])
+	buf.WriteString(fmt.Sprintf("\n\n... %d bytes of the log truncated ...\n\n", len(log)-headSize-tailSize))
+	buf.Write(log[len(log)-tailSize:])
+
+	return buf.Bytes()
+}
+
+// putTaskLogToStorage stores the gzip-compressed log split into chunks task_log/<uuid>/<index>.
+func putTaskLogToStorage(ctx context.Context, storage logical.Storage, uuid string, log []byte) error {
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	if _, err := gzipWriter.Write(log); err != nil {
+		return fmt.Errorf("unable to compress task log: %w", err)
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("unable to compress task log: %w", err)
+	}
+
+	data := compressed.Bytes()
+	for i := 0; len(data) > 0; i++ {
+		size := taskLogChunkSize
+		if len(data) < size {
+			size = len(data)
+		}
+
+		storageKey := taskLogChunkStorageKey(uuid, i)
+		if err := storage.Put(ctx, &logical.StorageEntry{
+			Key:   storageKey,
+			Value: data[:size],
+		}); err != nil {
+			return fmt.Errorf("unable to put %q into the storage: %w", storageKey, err)
+		}
+
+		data = data[size:]
+	}
+
+	return nil
+}
+
+// getTaskLogFromStorage returns the decompressed log or the uncompressed log stored by the previous versions of the plugin.
+func getTaskLogFromStorage(ctx context.Context, storage logical.Storage, uuid string) ([]byte, error) {
+	storageKey := taskLogStorageKey(uuid)
+	entry, err := storage.Get(ctx, storageKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %q from storage: %w", storageKey, err)
+	}
+
+	if entry != nil {
+		return entry.Value, nil
+	}
+
+	chunkIndexes, err := listTaskLogChunks(ctx, storage, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chunkIndexes) == 0 {
+		return nil, nil
+	}
+
+	var compressed bytes.Buffer
+	for _, i := range chunkIndexes {
+		chunkStorageKey := taskLogChunkStorageKey(uuid, i)
+		entry, err := storage.Get(ctx, chunkStorageKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get %q from storage: %w", chunkStorageKey, err)
+		}
+
+		if entry == nil {
+			return nil, fmt.Errorf("task log chunk %q not found in storage", chunkStorageKey)
+		}
+
+		compressed.Write(entry.Value)
+	}
+
+	gzipReader, err := gzip.NewReader(&compressed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress task log %q: %w", uuid, err)
+	}
+	defer gzipReader.Close()
+
+	log, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress task log %q: %w", uuid, err)
+	}
+
+	return log, nil
+}
+
+func deleteTaskLogFromStorage(ctx context.Context, storage logical.Storage, uuid string) error {
+	chunkIndexes, err := listTaskLogChunks(ctx, storage, uuid)
+	if err != nil {
+		return err
+	}
+
+	storageKeys := []string{taskLogStorageKey(uuid)}
+	for _, i := range chunkIndexes {
+		storageKeys = append(storageKeys, taskLogChunkStorageKey(uuid, i))
+	}
+
+	for _, storageKey := range storageKeys {
+		if err := storage.Delete(ctx, storageKey); err != nil {
+			return fmt.Errorf("unable to delete %q from storage: %w", storageKey, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrateUncompressedTaskLogs compresses and chunks the task logs stored by the previous versions of the plugin.
+func MigrateUncompressedTaskLogs(ctx context.Context, storage logical.Storage) error {
+	list, err := storage.List(ctx, storageKeyPrefixTaskLog)
+	if err != nil {
+		return fmt.Errorf("unable to list %q in storage: %w", storageKeyPrefixTaskLog, err)
+	}
+
+	for _, key := range list {
+		// the chunks of the compressed log are listed as "<uuid>/"
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		storageKey := taskLogStorageKey(key)
+		entry, err := storage.Get(ctx, storageKey)
+		if err != nil {
+			return fmt.Errorf("unable to get %q from storage: %w", storageKey, err)
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		if err := putTaskLogToStorage(ctx, storage, key, entry.Value); err != nil {
+			return err
+		}
+
+		if err := storage.Delete(ctx, storageKey); err != nil {
+			return fmt.Errorf("unable to delete %q from storage: %w", storageKey, err)
+		}
+	}
+
+	return nil
+}
+
+// listTaskLogChunks returns the sorted indexes of the task log chunks.
+func listTaskLogChunks(ctx context.Context, storage logical.Storage, uuid string) ([]int, error) {
+	prefix := taskLogStorageKey(uuid) + "/"
+	list, err := storage.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list %q in storage: %w", prefix, err)
+	}
+
+	var indexes []int
+	for _, key := range list {
+		i, err := strconv.Atoi(strings.TrimSuffix(key, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected task log chunk key %q", prefix+key)
+		}
+
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	return indexes, nil
+}
+
+func taskLogChunkStorageKey(uuid string, index int) string {
+	return fmt.Sprintf("%s/%d", taskLogStorageKey(uuid), index)
+}
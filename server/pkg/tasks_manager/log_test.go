@@ -0,0 +1,105 @@
+package tasks_manager
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateTaskLog(t *testing.T) {
+	log := []byte("0123456789")
+
+	assert.Equal(t, log, truncateTaskLog(log, 10))
+	assert.Equal(t, []byte("012\n\n... 4 bytes of the log truncated ...\n\n789"), truncateTaskLog(log, 6))
+}
+
+func TestTaskLogStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("chunks", func(t *testing.T) {
+		storage := &logical.InmemStorage{}
+		uuid := "00000000-0000-0000-0000-000000000000"
+
+		// the random data is not compressed, so it is split into several chunks
+		log := make([]byte, 3*taskLogChunkSize)
+		rand.Read(log)
+
+		assert.Nil(t, putTaskLogToStorage(ctx, storage, uuid, log))
+
+		chunks, err := listTaskLogChunks(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{0, 1, 2, 3}, chunks)
+
+		storedLog, err := getTaskLogFromStorage(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.True(t, bytes.Equal(log, storedLog))
+
+		assert.Nil(t, deleteTaskLogFromStorage(ctx, storage, uuid))
+
+		keys, err := storage.List(ctx, storageKeyPrefixTaskLog)
+		assert.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("uncompressed", func(t *testing.T) {
+		storage := &logical.InmemStorage{}
+		uuid := "00000000-0000-0000-0000-000000000000"
+		log := []byte("Hello!")
+
+		// the log stored by the previous versions of the plugin
+		assert.Nil(t, storage.Put(ctx, &logical.StorageEntry{Key: taskLogStorageKey(uuid), Value: log}))
+
+		storedLog, err := getTaskLogFromStorage(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.Equal(t, log, storedLog)
+
+		assert.Nil(t, deleteTaskLogFromStorage(ctx, storage, uuid))
+
+		storedLog, err = getTaskLogFromStorage(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.Nil(t, storedLog)
+	})
+
+	t.Run("migrate uncompressed", func(t *testing.T) {
+		storage := &logical.InmemStorage{}
+		uuid := "00000000-0000-0000-0000-000000000000"
+		log := []byte("Hello!")
+
+		assert.Nil(t, storage.Put(ctx, &logical.StorageEntry{Key: taskLogStorageKey(uuid), Value: log}))
+		assert.Nil(t, MigrateUncompressedTaskLogs(ctx, storage))
+
+		entry, err := storage.Get(ctx, taskLogStorageKey(uuid))
+		assert.Nil(t, err)
+		assert.Nil(t, entry)
+
+		chunks, err := listTaskLogChunks(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{0}, chunks)
+
+		storedLog, err := getTaskLogFromStorage(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.Equal(t, log, storedLog)
+
+		// the migrated logs are kept as is
+		assert.Nil(t, MigrateUncompressedTaskLogs(ctx, storage))
+
+		storedLog, err = getTaskLogFromStorage(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.Equal(t, log, storedLog)
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		storage := &logical.InmemStorage{}
+		assert.Nil(t, putConfiguration(ctx, storage, &configuration{TaskLogMaxSize: 6}))
+
+		uuid := assertAndAddCompletedTaskToStorage(t, ctx, storage, taskStatusSucceeded, switchTaskToCompletedInStorageOptions{log: []byte("0123456789")})
+
+		storedLog, err := getTaskLogFromStorage(ctx, storage, uuid)
+		assert.Nil(t, err)
+		assert.Equal(t, truncateTaskLog([]byte("0123456789"), 6), storedLog)
+	})
+}
@@ -2,6 +2,7 @@ package tasks_manager
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/hashicorp/go-hclog"
@@ -18,17 +19,82 @@ type Manager struct {
 
 	logger   hclog.Logger
 	taskChan chan *worker.Task
-	mu       sync.Mutex
+	pool     *worker.Pool
+	locks    *resourceLocks
+
+	// taskLocks are the locks declared by the queued and running tasks
+	taskLocks map[string][]string
+	// taskResults are filled by the running tasks
+	taskResults map[string]*taskResult
+	// taskFactories restore the queued tasks by their types after restart of the plugin
+	taskFactories map[string]TaskFactory
+	// taskTypes are the types of the tasks to filter the task list by
+	taskTypes []string
+	// taskCompletedHooks are called after the task is succeeded or failed
+	taskCompletedHooks []TaskCompletedHook
+	mu                 sync.Mutex
+
+	// pendingTasks overflow the task channel and are forwarded to it in the background without holding mu
+	pendingTasks      []*worker.Task
+	pendingForwarding bool
+	pendingMu         sync.Mutex
 }
 
+// TaskCompletedHook is called with the completed task and its log, the hook must not block.
+type TaskCompletedHook func(ctx context.Context, storage logical.Storage, task *Task, log []byte)
+
+// TaskFactory creates the function of the task restored from the storage by the stored task params.
+type TaskFactory func(ctx context.Context, storage logical.Storage, params map[string]string) (func(context.Context, logical.Storage) error, error)
+
 func NewManager(logger hclog.Logger) *Manager {
-	m := &Manager{taskChan: make(chan *worker.Task, taskChanSize), logger: logger}
-	m.Worker = worker.NewWorker(context.Background(), m.taskChan, m)
+	m := &Manager{
+		taskChan:      make(chan *worker.Task, taskChanSize),
+		logger:        logger,
+		locks:         newResourceLocks(),
+		taskLocks:     map[string][]string{},
+		taskResults:   map[string]*taskResult{},
+		taskFactories: map[string]TaskFactory{},
+	}
+	m.pool = worker.NewPool(context.Background(), fieldDefaultWorkers, m.taskChan, m)
+	m.Worker = m.pool
 	go m.Worker.Start()
 
 	return m
 }
 
+// RegisterTaskFactory allows to restore the queued tasks of the type after restart of the plugin.
+// Must be called before the first task action.
+func (m *Manager) RegisterTaskFactory(taskType string, factory TaskFactory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.taskFactories[taskType] = factory
+}
+
+// RegisterTaskTypes declares the types of the tasks added to the manager to filter the task list by.
+// Must be called before Paths.
+func (m *Manager) RegisterTaskTypes(taskTypes ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.taskTypes = append(m.taskTypes, taskTypes...)
+}
+
+// AddTaskCompletedHook adds the hook called after the task is succeeded or failed.
+func (m *Manager) AddTaskCompletedHook(hook TaskCompletedHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.taskCompletedHooks = append(m.taskCompletedHooks, hook)
+}
+
+// setWorkers resizes the worker pool according to the configuration.
+func (m *Manager) setWorkers(workers int) {
+	if m.pool != nil && m.pool.Size() != workers {
+		m.pool.SetSize(workers)
+	}
+}
+
 func (m *Manager) TaskStartedCallback(ctx context.Context, uuid string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -42,21 +108,56 @@ func (m *Manager) TaskSucceededCallback(ctx context.Context, uuid string, log []
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	delete(m.taskLocks, uuid)
+	result := m.popTaskResult(uuid)
+
 	if err := switchTaskToCompletedInStorage(ctx, m.Storage, taskStatusSucceeded, uuid, switchTaskToCompletedInStorageOptions{
-		log: log,
+		log:    log,
+		result: result,
 	}); err != nil {
 		panic("runtime error: " + err.Error())
 	}
+
+	m.runTaskCompletedHooks(ctx, uuid, log)
 }
 
 func (m *Manager) TaskFailedCallback(ctx context.Context, uuid string, log []byte, taskErr error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	delete(m.taskLocks, uuid)
+	result := m.popTaskResult(uuid)
+
 	if err := switchTaskToCompletedInStorage(ctx, m.Storage, taskStatusFailed, uuid, switchTaskToCompletedInStorageOptions{
 		reason: taskErr.Error(),
 		log:    log,
+		result: result,
 	}); err != nil {
 		panic("runtime error: " + err.Error())
 	}
+
+	m.runTaskCompletedHooks(ctx, uuid, log)
+}
+
+func (m *Manager) popTaskResult(uuid string) *taskResult {
+	result := m.taskResults[uuid]
+	delete(m.taskResults, uuid)
+
+	return result
+}
+
+func (m *Manager) runTaskCompletedHooks(ctx context.Context, uuid string, log []byte) {
+	if len(m.taskCompletedHooks) == 0 {
+		return
+	}
+
+	task, err := getTaskFromStorage(ctx, m.Storage, taskStateCompleted, uuid)
+	if err != nil || task == nil {
+		m.logger.Error(fmt.Sprintf("Unable to get completed task %q: %v", uuid, err))
+		return
+	}
+
+	for _, hook := range m.taskCompletedHooks {
+		hook(ctx, m.Storage, task, log)
+	}
 }
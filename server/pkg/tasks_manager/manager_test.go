@@ -81,6 +81,28 @@ func TestManager_SucceededCallback(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, taskActionLog, log)
 	})
+
+	t.Run("result", func(t *testing.T) {
+		uuid, err := m.RunTask(ctx, storage, TaskOptions{}, func(ctx context.Context, _ logical.Storage) error {
+			SetTaskCommit(ctx, "0123456789abcdef")
+			SetTaskTufVersions(ctx, map[string]int64{"root": 1, "targets": 2})
+			return nil
+		})
+		assert.Nil(t, err)
+
+		task := <-m.taskChan
+		assert.Nil(t, switchTaskToRunningInStorage(ctx, storage, uuid))
+		assert.Nil(t, task.Action(ctx))
+		m.TaskSucceededCallback(ctx, uuid, nil)
+
+		completedTask, err := getTaskFromStorage(ctx, storage, taskStateCompleted, uuid)
+		assert.Nil(t, err)
+		if assert.NotNil(t, completedTask) {
+			assert.Equal(t, "0123456789abcdef", completedTask.Commit)
+			assert.Equal(t, map[string]int64{"root": 1, "targets": 2}, completedTask.TufVersions)
+		}
+		assert.NotContains(t, m.taskResults, uuid)
+	})
 }
 
 func TestManager_FailedCallback(t *testing.T) {
@@ -123,6 +145,26 @@ func TestManager_FailedCallback(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, taskActionLog, log)
 	})
+
+	t.Run("hook", func(t *testing.T) {
+		var hookTask *Task
+		var hookLog []byte
+		m.AddTaskCompletedHook(func(_ context.Context, _ logical.Storage, task *Task, log []byte) {
+			hookTask = task
+			hookLog = log
+		})
+
+		runningTaskUUID := assertAndAddRunningTaskToStorage(t, ctx, storage)
+		m.TaskFailedCallback(ctx, runningTaskUUID, []byte("Hello!"), taskActionErr)
+
+		if assert.NotNil(t, hookTask) {
+			assert.Equal(t, runningTaskUUID, hookTask.UUID)
+			assert.Equal(t, string(taskStatusFailed), hookTask.Status)
+			assert.Equal(t, taskActionErr.Error(), hookTask.Reason)
+			assert.False(t, hookTask.Succeeded())
+		}
+		assert.Equal(t, []byte("Hello!"), hookLog)
+	})
 }
 
 func assertPanic(t *testing.T, f func(), expectedMsg string) {
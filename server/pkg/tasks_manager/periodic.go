@@ -19,6 +19,11 @@ func (m *Manager) PeriodicFunc(ctx context.Context, req *logical.Request) error
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// restore the queued tasks after restart of the plugin without waiting for the first task action
+	if err := m.initStorage(ctx, req.Storage); err != nil {
+		return err
+	}
+
 	// skip if the time since the last successfully passed periodic task less than period of the periodic task (1 hour)
 	{
 		entry, err := req.Storage.Get(ctx, storageKeyLastPeriodicRunTimestamp)
@@ -47,9 +52,11 @@ func (m *Manager) PeriodicFunc(ctx context.Context, req *logical.Request) error
 	return nil
 }
 
+// cleanupTaskHistory deletes the completed tasks and their logs exceeding the task history limit or older than the task history max age.
 func (m *Manager) cleanupTaskHistory(ctx context.Context, req *logical.Request) error {
-	// define taskHistoryLimit
+	// define taskHistoryLimit and taskHistoryAge
 	taskHistoryLimit := fieldDefaultTaskHistoryLimit
+	var taskHistoryAge time.Duration
 	{
 		config, err := getConfiguration(ctx, req.Storage)
 		if err != nil {
@@ -58,6 +65,7 @@ func (m *Manager) cleanupTaskHistory(ctx context.Context, req *logical.Request)
 
 		if config != nil {
 			taskHistoryLimit = config.TaskHistoryLimit
+			taskHistoryAge = config.TaskHistoryAge
 		}
 	}
 
@@ -80,16 +88,19 @@ func (m *Manager) cleanupTaskHistory(ctx context.Context, req *logical.Request)
 		return completedTasks[i].Modified.After(completedTasks[j].Modified)
 	})
 
-	if len(completedTasks) > taskHistoryLimit {
-		completedTasks = append([]*Task(nil), completedTasks[taskHistoryLimit:]...)
+	var expiredTasks []*Task
+	for i, task := range completedTasks {
+		if i >= taskHistoryLimit || (taskHistoryAge > 0 && time.Since(task.Modified) > taskHistoryAge) {
+			expiredTasks = append(expiredTasks, task)
+		}
 	}
 
-	for _, task := range completedTasks {
+	for _, task := range expiredTasks {
 		if err := req.Storage.Delete(ctx, taskStorageKey(taskStateCompleted, task.UUID)); err != nil {
 			return err
 		}
 
-		if err := req.Storage.Delete(ctx, taskLogStorageKey(task.UUID)); err != nil {
+		if err := deleteTaskLogFromStorage(ctx, req.Storage, task.UUID); err != nil {
 			return err
 		}
 	}
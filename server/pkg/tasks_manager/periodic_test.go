@@ -24,6 +24,9 @@ func (suite *PeriodicTaskSuite) SetupTest() {
 	suite.ctx = context.Background()
 	suite.manager = initManagerWithoutWorker()
 	suite.storage = &logical.InmemStorage{}
+
+	// the storage is already restored by the previous task actions
+	suite.manager.Storage = suite.storage
 }
 
 func (suite *PeriodicTaskSuite) TestCleanupTaskHistoryDefaultTaskHistoryLimit() {
@@ -100,7 +103,10 @@ func (suite *PeriodicTaskSuite) testCleanupTaskHistory(testedTaskHistoryLimit in
 			expectedCompletedTaskUUIDs = append(expectedCompletedTaskUUIDs, task.UUID)
 		}
 
-		expectedTaskLogUUIDs = expectedCompletedTaskUUIDs
+		// the compressed log chunks are stored under the task UUID
+		for _, uuid := range expectedCompletedTaskUUIDs {
+			expectedTaskLogUUIDs = append(expectedTaskLogUUIDs, uuid+"/")
+		}
 	}
 
 	req := &logical.Request{Storage: suite.storage}
@@ -131,6 +137,39 @@ func (suite *PeriodicTaskSuite) testCleanupTaskHistory(testedTaskHistoryLimit in
 	}
 }
 
+func (suite *PeriodicTaskSuite) TestCleanupTaskHistoryMaxAge() {
+	err := putConfiguration(suite.ctx, suite.storage, &configuration{
+		TaskHistoryLimit: fieldDefaultTaskHistoryLimit,
+		TaskHistoryAge:   time.Hour,
+	})
+	assert.Nil(suite.T(), err)
+
+	expiredTaskUUID := assertAndAddCompletedTaskToStorage(suite.T(), suite.ctx, suite.storage, taskStatusSucceeded, switchTaskToCompletedInStorageOptions{log: []byte("expired")})
+	actualTaskUUID := assertAndAddCompletedTaskToStorage(suite.T(), suite.ctx, suite.storage, taskStatusSucceeded, switchTaskToCompletedInStorageOptions{log: []byte("actual")})
+
+	// make the task older than the task history max age
+	{
+		task, err := getTaskFromStorage(suite.ctx, suite.storage, taskStateCompleted, expiredTaskUUID)
+		assert.Nil(suite.T(), err)
+
+		task.Modified = time.Now().Add(-2 * time.Hour)
+		entry, err := logical.StorageEntryJSON(taskStorageKey(taskStateCompleted, expiredTaskUUID), task)
+		assert.Nil(suite.T(), err)
+		assert.Nil(suite.T(), suite.storage.Put(suite.ctx, entry))
+	}
+
+	err = suite.manager.PeriodicFunc(suite.ctx, &logical.Request{Storage: suite.storage})
+	assert.Nil(suite.T(), err)
+
+	completedTaskUUIDs, err := suite.storage.List(suite.ctx, taskStorageKeyPrefix(taskStateCompleted))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{actualTaskUUID}, completedTaskUUIDs)
+
+	taskLogUUIDs, err := suite.storage.List(suite.ctx, storageKeyPrefixTaskLog)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{actualTaskUUID + "/"}, taskLogUUIDs)
+}
+
 func (suite *PeriodicTaskSuite) TestLastPeriodicRunTimestamp() {
 	for _, test := range []struct {
 		name                            string
@@ -0,0 +1,110 @@
+package tasks_manager
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type taskResultCtxKey struct{}
+
+// taskResult is filled by the task steps and saved with the completed task.
+type taskResult struct {
+	commit      string
+	tufVersions map[string]int64
+	stages      []*TaskStage
+	mu          sync.Mutex
+}
+
+// TaskStage is the named step of the task, the stages can overlap.
+type TaskStage struct {
+	Name     string     `json:"name"`
+	Status   string     `json:"status"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// StartTaskStage starts the named stage of the task, the returned function completes the stage successfully.
+// The stages which are not completed by the end of the task get the status of the task.
+func StartTaskStage(ctx context.Context, name string) func() {
+	r, ok := ctx.Value(taskResultCtxKey{}).(*taskResult)
+	if !ok {
+		return func() {}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stage := &TaskStage{Name: name, Status: string(taskStatusRunning), Started: time.Now()}
+	r.stages = append(r.stages, stage)
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		finishTaskStage(stage, taskStatusSucceeded, time.Now())
+	}
+}
+
+func finishTaskStage(stage *TaskStage, status taskStatus, finished time.Time) {
+	if stage.Finished != nil {
+		return
+	}
+
+	stage.Status = string(status)
+	stage.Finished = &finished
+}
+
+// SetTaskCommit records the git commit the task is working with.
+func SetTaskCommit(ctx context.Context, commit string) {
+	if r, ok := ctx.Value(taskResultCtxKey{}).(*taskResult); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.commit = commit
+	}
+}
+
+// SetTaskTufVersions records the TUF repository metadata versions (by role) resulting from the task.
+func SetTaskTufVersions(ctx context.Context, versions map[string]int64) {
+	if r, ok := ctx.Value(taskResultCtxKey{}).(*taskResult); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.tufVersions = versions
+	}
+}
+
+func (r *taskResult) apply(task *Task) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task.Commit = r.commit
+	task.TufVersions = r.tufVersions
+
+	for _, stage := range r.stages {
+		finishTaskStage(stage, taskStatus(task.Status), task.Modified)
+	}
+	task.Stages = r.getStages()
+}
+
+// getStages returns the copy of the task stages, must be called with the lock held.
+func (r *taskResult) getStages() []TaskStage {
+	var stages []TaskStage
+	for _, stage := range r.stages {
+		stages = append(stages, *stage)
+	}
+
+	return stages
+}
+
+func (r *taskResult) snapshotStages() []TaskStage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.getStages()
+}
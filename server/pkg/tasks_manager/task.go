@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
@@ -34,18 +35,67 @@ const (
 
 var taskStateStatusesCompleted = []taskStatus{taskStatusSucceeded, taskStatusFailed, taskStatusCanceled}
 
+var (
+	// ActiveTaskStorageKeyPrefixes are the storage prefixes of the queued and running tasks.
+	ActiveTaskStorageKeyPrefixes = []string{storageKeyPrefixQueuedTask, storageKeyPrefixRunningTask}
+	// TaskHistoryStorageKeyPrefixes are the storage prefixes of the completed tasks and the task logs.
+	TaskHistoryStorageKeyPrefixes = []string{storageKeyPrefixCompletedTask, storageKeyPrefixTaskLog}
+)
+
 type Task struct {
-	UUID     string    `structs:"uuid" json:"uuid"`
-	Status   string    `structs:"status" json:"status"`
-	Reason   string    `structs:"reason" json:"reason"`
-	Created  time.Time `structs:"created" json:"created"`
-	Modified time.Time `structs:"modified" json:"modified"`
+	UUID     string            `structs:"uuid" json:"uuid"`
+	Status   string            `structs:"status" json:"status"`
+	Reason   string            `structs:"reason" json:"reason"`
+	Created  time.Time         `structs:"created" json:"created"`
+	Modified time.Time         `structs:"modified" json:"modified"`
+	Type     string            `structs:"type,omitempty" json:"type,omitempty"`
+	Params   map[string]string `structs:"params,omitempty" json:"params,omitempty"`
+	Locks    []string          `structs:"-" json:"locks,omitempty"`
+
+	// Attempts is the number of times the task was started, the restartable task is started again
+	// if interrupted by restart of the plugin
+	Attempts    int  `structs:"attempts,omitempty" json:"attempts,omitempty"`
+	Restartable bool `structs:"-" json:"restartable,omitempty"`
+
+	// Commit and TufVersions are set by the task steps and saved on completion
+	Commit      string           `structs:"commit,omitempty" json:"commit,omitempty"`
+	TufVersions map[string]int64 `structs:"tuf_versions,omitempty" json:"tuf_versions,omitempty"`
+	Stages      []TaskStage      `structs:"-" json:"stages,omitempty"`
+}
+
+func (t *Task) Succeeded() bool {
+	return t.Status == string(taskStatusSucceeded)
+}
+
+func (t *Task) Completed() bool {
+	return isCompletedTaskStatus(taskStatus(t.Status))
 }
 
-func newTask() *Task {
+// GetTask returns the queued, running or completed task or nil if the task is not found.
+// The states are checked in the order of the task lifecycle, so the task switched in the meantime is not missed.
+func GetTask(ctx context.Context, storage logical.Storage, uuid string) (*Task, error) {
+	for _, state := range []taskState{taskStateQueued, taskStateRunning, taskStateCompleted} {
+		task, err := getTaskFromStorage(ctx, storage, state, uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		if task != nil {
+			return task, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func newTask(opts TaskOptions) *Task {
 	task := &Task{}
 	task.UUID = uuid.NewV4().String()
 	task.Status = string(taskStatusQueued)
+	task.Type = opts.Type
+	task.Params = opts.Params
+	task.Locks = opts.Locks
+	task.Restartable = opts.Restartable
 
 	tNow := time.Now()
 	task.Created = tNow
@@ -54,8 +104,8 @@ func newTask() *Task {
 	return task
 }
 
-func addNewTaskToStorage(ctx context.Context, storage logical.Storage) (string, error) {
-	queuedTask := newTask()
+func addNewTaskToStorage(ctx context.Context, storage logical.Storage, opts TaskOptions) (string, error) {
+	queuedTask := newTask(opts)
 	storageKey := taskStorageKey(taskStateQueued, queuedTask.UUID)
 	entry, err := logical.StorageEntryJSON(storageKey, queuedTask)
 	if err != nil {
@@ -90,6 +140,7 @@ func switchTaskToRunningInStorage(ctx context.Context, storage logical.Storage,
 		runningTask := prevTask
 		runningTask.Status = string(taskStatusRunning)
 		runningTask.Modified = time.Now()
+		runningTask.Attempts++
 		runningTaskState := taskStateRunning
 
 		storageKey := taskStorageKey(runningTaskState, uuid)
@@ -112,9 +163,44 @@ func switchTaskToRunningInStorage(ctx context.Context, storage logical.Storage,
 	return nil
 }
 
+// switchTaskToQueuedInStorage returns the interrupted running task to the queue to be started again.
+func switchTaskToQueuedInStorage(ctx context.Context, storage logical.Storage, uuid, reason string) error {
+	prevTask, err := getTaskFromStorage(ctx, storage, taskStateRunning, uuid)
+	if err != nil {
+		return err
+	}
+
+	if prevTask == nil {
+		return fmt.Errorf("running task %q must be in storage", uuid)
+	}
+
+	queuedTask := prevTask
+	queuedTask.Status = string(taskStatusQueued)
+	queuedTask.Modified = time.Now()
+	queuedTask.Reason = reason
+
+	storageKey := taskStorageKey(taskStateQueued, uuid)
+	entry, err := logical.StorageEntryJSON(storageKey, queuedTask)
+	if err != nil {
+		return fmt.Errorf("unable to prepare storage entry JSON: %w", err)
+	}
+
+	if err := storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to put %q into storage: %w", storageKey, err)
+	}
+
+	prevStorageKey := taskStorageKey(taskStateRunning, uuid)
+	if err := storage.Delete(ctx, prevStorageKey); err != nil {
+		return fmt.Errorf("unable to delete %q from storage: %w", prevStorageKey, err)
+	}
+
+	return nil
+}
+
 type switchTaskToCompletedInStorageOptions struct {
 	reason string
 	log    []byte
+	result *taskResult
 }
 
 func switchTaskToCompletedInStorage(ctx context.Context, storage logical.Storage, status taskStatus, uuid string, opts switchTaskToCompletedInStorageOptions) error {
@@ -152,6 +238,7 @@ func switchTaskToCompletedInStorage(ctx context.Context, storage logical.Storage
 		completedTask.Status = string(status)
 		completedTask.Modified = time.Now()
 		completedTask.Reason = opts.reason
+		opts.result.apply(completedTask)
 		completedTaskState := taskStatusState(status)
 
 		storageKey := taskStorageKey(completedTaskState, uuid)
@@ -165,12 +252,13 @@ func switchTaskToCompletedInStorage(ctx context.Context, storage logical.Storage
 		}
 
 		if len(opts.log) != 0 {
-			logStorageKey := taskLogStorageKey(uuid)
-			if err := storage.Put(ctx, &logical.StorageEntry{
-				Key:   logStorageKey,
-				Value: opts.log,
-			}); err != nil {
-				return fmt.Errorf("unable to put %q into the storage: %w", logStorageKey, err)
+			config, err := getConfiguration(ctx, storage)
+			if err != nil {
+				return fmt.Errorf("unable to get tasks manager configuration: %w", err)
+			}
+
+			if err := putTaskLogToStorage(ctx, storage, uuid, truncateTaskLog(opts.log, configTaskLogMaxSize(config))); err != nil {
+				return err
 			}
 		}
 	}
@@ -198,18 +286,31 @@ func getTaskFromStorage(ctx context.Context, storage logical.Storage, state task
 	return storageEntryToTask(entry)
 }
 
-func getTaskLogFromStorage(ctx context.Context, storage logical.Storage, uuid string) ([]byte, error) {
-	storageKey := taskLogStorageKey(uuid)
-	entry, err := storage.Get(ctx, taskLogStorageKey(uuid))
+// getTasksFromStorage returns the tasks in the state sorted by creation time.
+func getTasksFromStorage(ctx context.Context, storage logical.Storage, state taskState) ([]*Task, error) {
+	prefix := taskStorageKeyPrefix(state)
+	list, err := storage.List(ctx, prefix)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get %q from storage: %w", storageKey, err)
+		return nil, fmt.Errorf("unable to list %q in storage: %w", prefix, err)
 	}
 
-	if entry == nil {
-		return nil, nil
+	var tasks []*Task
+	for _, uuid := range list {
+		t, err := getTaskFromStorage(ctx, storage, state, uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		if t != nil {
+			tasks = append(tasks, t)
+		}
 	}
 
-	return entry.Value, nil
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Created.Before(tasks[j].Created)
+	})
+
+	return tasks, nil
 }
 
 func taskStorageKey(state taskState, uuid string) string {
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Pool runs tasks from the shared task channel by several workers.
+// The pool can be resized at any time, the excess workers stop after completing their current jobs.
+type Pool struct {
+	ctx       context.Context
+	taskChan  chan *Task
+	callbacks TaskCallbacksInterface
+
+	workers []*poolWorker
+	mu      sync.Mutex
+}
+
+type poolWorker struct {
+	*Worker
+	stop context.CancelFunc
+}
+
+func NewPool(ctx context.Context, size int, taskChan chan *Task, callbacks TaskCallbacksInterface) *Pool {
+	p := &Pool{ctx: ctx, taskChan: taskChan, callbacks: callbacks}
+	p.SetSize(size)
+	return p
+}
+
+// Start blocks until the pool context is done, the workers are started by SetSize.
+func (p *Pool) Start() {
+	<-p.ctx.Done()
+}
+
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	size := 0
+	for _, w := range p.workers {
+		if w.stop != nil {
+			size++
+		}
+	}
+
+	return size
+}
+
+func (p *Pool) SetSize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var active []*poolWorker
+	for _, w := range p.workers {
+		if w.stop != nil {
+			active = append(active, w)
+		}
+	}
+
+	for i := len(active); i < size; i++ {
+		stopCtx, stop := context.WithCancel(p.ctx)
+		w := &poolWorker{Worker: newStoppableWorker(p.ctx, stopCtx, p.taskChan, p.callbacks), stop: stop}
+		p.workers = append(p.workers, w)
+
+		go func() {
+			w.Start()
+			p.removeWorker(w)
+		}()
+	}
+
+	for i := size; i < len(active); i++ {
+		active[i].stop()
+		active[i].stop = nil
+	}
+}
+
+func (p *Pool) removeWorker(w *poolWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for ind, pw := range p.workers {
+		if pw == w {
+			p.workers = append(p.workers[:ind], p.workers[ind+1:]...)
+			return
+		}
+	}
+}
+
+func (p *Pool) HoldRunningJobByTaskUUID(uuid string, do func(job *Job)) bool {
+	for _, w := range p.snapshot() {
+		if w.HoldRunningJobByTaskUUID(uuid, do) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Pool) CancelRunningJobByTaskUUID(uuid string) bool {
+	for _, w := range p.snapshot() {
+		if w.CancelRunningJobByTaskUUID(uuid) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Pool) snapshot() []*poolWorker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*poolWorker(nil), p.workers...)
+}
@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPool_RunsTasksConcurrently(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	taskChan := make(chan *Task)
+	mockedTasksCallbacks := &MockedTasksCallbacks{}
+	mockedTasksCallbacks.On(TaskStartedCallback, mock.Anything).Return()
+	mockedTasksCallbacks.On(TaskSucceededCallback, mock.Anything, mock.Anything).Return()
+	mockedTasksCallbacks.On(TaskFailedCallback, "0", mock.Anything, errTestTaskContextCanceled).Return()
+
+	p := NewPool(ctx, 2, taskChan, mockedTasksCallbacks)
+	go p.Start()
+	assert.Equal(t, 2, p.Size())
+
+	var channels []testTaskChannels
+	for i := 0; i < 2; i++ {
+		c, task := testTask(fmt.Sprintf("%d", i))
+		channels = append(channels, c)
+		taskChan <- task
+	}
+
+	// both tasks are running at the same time
+	for _, c := range channels {
+		<-c.startedCh
+	}
+
+	assert.True(t, p.HoldRunningJobByTaskUUID("1", func(job *Job) {}))
+	assert.True(t, p.CancelRunningJobByTaskUUID("0"))
+	<-channels[0].completedCh
+
+	channels[1].doneCh <- true
+	<-channels[1].completedCh
+}
+
+func TestPool_SetSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	taskChan := make(chan *Task)
+	mockedTasksCallbacks := &MockedTasksCallbacks{}
+	mockedTasksCallbacks.On(TaskStartedCallback, mock.Anything).Return()
+	mockedTasksCallbacks.On(TaskSucceededCallback, mock.Anything, mock.Anything).Return()
+
+	p := NewPool(ctx, 3, taskChan, mockedTasksCallbacks)
+	go p.Start()
+
+	// the running job stays available until its worker stops
+	c, task := testTask("1")
+	taskChan <- task
+	<-c.startedCh
+
+	p.SetSize(1)
+	assert.Equal(t, 1, p.Size())
+	assert.True(t, p.HoldRunningJobByTaskUUID("1", func(job *Job) {}))
+
+	c.doneCh <- true
+	<-c.completedCh
+
+	p.SetSize(2)
+	assert.Equal(t, 2, p.Size())
+}
+
+type ctxRecordingTasksCallbacks struct {
+	succeededCtxCh chan context.Context
+}
+
+func (c *ctxRecordingTasksCallbacks) TaskStartedCallback(ctx context.Context, _ string) {
+	if err := ctx.Err(); err != nil {
+		panic(err)
+	}
+}
+
+func (c *ctxRecordingTasksCallbacks) TaskFailedCallback(_ context.Context, _ string, _ []byte, err error) {
+	panic(err)
+}
+
+func (c *ctxRecordingTasksCallbacks) TaskSucceededCallback(ctx context.Context, _ string, _ []byte) {
+	c.succeededCtxCh <- ctx
+}
+
+func TestPool_ShrinkWhileTaskRunning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	taskChan := make(chan *Task)
+	callbacks := &ctxRecordingTasksCallbacks{succeededCtxCh: make(chan context.Context)}
+
+	p := NewPool(ctx, 1, taskChan, callbacks)
+	go p.Start()
+
+	c, task := testTask("1")
+	taskChan <- task
+	<-c.startedCh
+
+	// the stopped worker completes the running job and reports it with the live context
+	p.SetSize(0)
+	assert.Equal(t, 0, p.Size())
+
+	c.doneCh <- true
+	<-c.completedCh
+
+	succeededCtx := <-callbacks.succeededCtxCh
+	assert.NoError(t, succeededCtx.Err())
+
+	// the stopped worker does not take the next task
+	select {
+	case taskChan <- task:
+		t.Fatal("the stopped worker has taken the next task")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 type Worker struct {
 	ctx        context.Context
+	stopCtx    context.Context
 	currentJob *Job
 	taskChan   chan *Task
 	callbacks  TaskCallbacksInterface
@@ -15,11 +16,22 @@ type Worker struct {
 }
 
 func NewWorker(ctx context.Context, taskChan chan *Task, callbacks TaskCallbacksInterface) Interface {
-	return &Worker{ctx: ctx, taskChan: taskChan, callbacks: callbacks}
+	return newStoppableWorker(ctx, ctx, taskChan, callbacks)
+}
+
+// newStoppableWorker returns the worker that stops taking the next task when stopCtx is done.
+// The task callbacks are run with ctx, so the job that is already running is completed normally.
+func newStoppableWorker(ctx, stopCtx context.Context, taskChan chan *Task, callbacks TaskCallbacksInterface) *Worker {
+	return &Worker{ctx: ctx, stopCtx: stopCtx, taskChan: taskChan, callbacks: callbacks}
 }
 
 func (w *Worker) Start() {
 	for {
+		// the stopped worker must not take the next task even if it is ready
+		if w.stopCtx.Err() != nil {
+			return
+		}
+
 		select {
 		case task := <-w.taskChan:
 			func() {
@@ -34,7 +46,7 @@ func (w *Worker) Start() {
 					w.callbacks.TaskSucceededCallback(w.ctx, job.taskUUID, job.Log())
 				}
 			}()
-		case <-w.ctx.Done():
+		case <-w.stopCtx.Done():
 			return
 		}
 	}
@@ -14,3 +14,14 @@ func CheckRequiredFields(req *logical.Request, fields *framework.FieldData) *log
 
 	return nil
 }
+
+// CheckRequiredFieldData checks the raw field data instead of the request data, e.g. the request data merged with the stored one.
+func CheckRequiredFieldData(fields *framework.FieldData) *logical.Response {
+	for fieldName, schema := range fields.Schema {
+		if schema.Required && fields.Raw[fieldName] == nil {
+			return logical.ErrorResponse("Required field %q must be set", fieldName)
+		}
+	}
+
+	return nil
+}
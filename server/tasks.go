@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/werf/logboek"
+	trdlGit "github.com/werf/trdl/server/pkg/git"
+	"github.com/werf/trdl/server/pkg/notifications"
+	"github.com/werf/trdl/server/pkg/publisher"
+	"github.com/werf/trdl/server/pkg/tasks_manager"
+)
+
+const (
+	taskTypeRelease  = "release"
+	taskTypePublish  = "publish"
+	taskTypePeriodic = "periodic"
+	taskTypeVerify   = "verify"
+	taskTypeResync   = "resync-mirror"
+
+	taskTypeEnableConsistentSnapshot = "enable-consistent-snapshot"
+	taskTypePublishRoot              = "publish-root"
+
+	taskParamGitTag = "git_tag"
+
+	taskStageClone            = "clone"
+	taskStageVerifySignatures = "verify-signatures"
+	taskStageBuild            = "build"
+	taskStageStageTargets     = "stage-targets"
+	taskStageCommit           = "commit"
+	taskStageRotateKeys       = "rotate-keys"
+	taskStageUpdateTimestamps = "update-timestamps"
+	taskStageRotatePGPKey     = "rotate-pgp-key"
+	taskStageVerifyRepository = "verify-repository"
+	taskStageResyncMirror     = "resync-mirror"
+
+	taskStageEnableConsistentSnapshot = "enable-consistent-snapshot"
+	taskStagePublishRoot              = "publish-root"
+)
+
+// RegisterTaskFactories allows the tasks manager to restore the queued and interrupted release and publish tasks after restart of the plugin.
+// The restored tasks use the git credential from the storage, because the credential passed with the request is not stored.
+func (b *Backend) RegisterTaskFactories(m *tasks_manager.Manager) {
+	m.RegisterTaskTypes(taskTypeRelease, taskTypePublish, taskTypePeriodic, taskTypeVerify, taskTypeResync, taskTypeEnableConsistentSnapshot, taskTypePublishRoot)
+
+	m.RegisterTaskFactory(taskTypeRelease, func(ctx context.Context, storage logical.Storage, params map[string]string) (func(context.Context, logical.Storage) error, error) {
+		cfg, err := getRestoredTaskConfiguration(ctx, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		gitTag := params[taskParamGitTag]
+		if err := ValidateReleaseVersion(gitTag); err != nil {
+			return nil, err
+		}
+
+		return b.newReleaseTaskFunc(ctx, storage, cfg, gitTag, "", "")
+	})
+
+	m.RegisterTaskFactory(taskTypePublish, func(ctx context.Context, storage logical.Storage, _ map[string]string) (func(context.Context, logical.Storage) error, error) {
+		cfg, err := getRestoredTaskConfiguration(ctx, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.newPublishTaskFunc(ctx, storage, cfg, "", "")
+	})
+
+	m.RegisterTaskFactory(taskTypeVerify, func(ctx context.Context, storage logical.Storage, _ map[string]string) (func(context.Context, logical.Storage) error, error) {
+		cfg, err := getRestoredTaskConfiguration(ctx, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.newVerifyTaskFunc(ctx, storage, cfg)
+	})
+
+	m.RegisterTaskFactory(taskTypeEnableConsistentSnapshot, func(ctx context.Context, storage logical.Storage, _ map[string]string) (func(context.Context, logical.Storage) error, error) {
+		cfg, err := getRestoredTaskConfiguration(ctx, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.newConsistentSnapshotTaskFunc(ctx, storage, cfg)
+	})
+
+	m.RegisterTaskFactory(taskTypePublishRoot, func(ctx context.Context, storage logical.Storage, _ map[string]string) (func(context.Context, logical.Storage) error, error) {
+		cfg, err := getRestoredTaskConfiguration(ctx, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.newPublishRootTaskFunc(ctx, storage, cfg)
+	})
+
+	m.RegisterTaskFactory(taskTypeResync, func(ctx context.Context, storage logical.Storage, params map[string]string) (func(context.Context, logical.Storage) error, error) {
+		cfg, err := getRestoredTaskConfiguration(ctx, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.newMirrorResyncTaskFunc(ctx, storage, cfg, params[taskParamMirror])
+	})
+}
+
+func getRestoredTaskConfiguration(ctx context.Context, storage logical.Storage) (*configuration, error) {
+	cfg, err := getConfiguration(ctx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get configuration from storage: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration not found")
+	}
+
+	return cfg, nil
+}
+
+// releaseTaskOptions collapses the queued and running releases of the same git tag.
+// The release is restartable, because building and staging the same tag again gives the same targets.
+func releaseTaskOptions(gitTag string) tasks_manager.TaskOptions {
+	return tasks_manager.TaskOptions{
+		Locks:               []string{tasks_manager.GitBuildLock(gitTag)},
+		Type:                taskTypeRelease,
+		Params:              map[string]string{taskParamGitTag: gitTag},
+		CollapseWithRunning: true,
+		Restartable:         true,
+	}
+}
+
+// publishTaskOptions collapses only the queued publications, because the running one may not see the latest commit.
+// The publication is restartable, because the already published commit is skipped.
+func publishTaskOptions() tasks_manager.TaskOptions {
+	return tasks_manager.TaskOptions{
+		Locks:       []string{tasks_manager.LockChannelsPublish},
+		Type:        taskTypePublish,
+		Restartable: true,
+	}
+}
+
+// verifyTaskOptions collapses the queued and running verifications, the verification only reads the repository.
+func verifyTaskOptions() tasks_manager.TaskOptions {
+	return tasks_manager.TaskOptions{
+		Locks:               []string{tasks_manager.LockRepositoryVerify},
+		Type:                taskTypeVerify,
+		CollapseWithRunning: true,
+		Restartable:         true,
+	}
+}
+
+func consistentSnapshotTaskOptions() tasks_manager.TaskOptions {
+	return tasks_manager.TaskOptions{
+		Type:                taskTypeEnableConsistentSnapshot,
+		CollapseWithRunning: true,
+		Restartable:         true,
+	}
+}
+
+// publishRootTaskOptions collapses the queued and running publications of the pending root.json.
+// The interrupted publication fails after restart if root.json is already published, the pending root.json is stale then.
+func publishRootTaskOptions() tasks_manager.TaskOptions {
+	return tasks_manager.TaskOptions{
+		Type:                taskTypePublishRoot,
+		CollapseWithRunning: true,
+		Restartable:         true,
+	}
+}
+
+// mirrorResyncTaskOptions collapses the queued and running resyncs of the same mirror.
+func mirrorResyncTaskOptions(name string) tasks_manager.TaskOptions {
+	return tasks_manager.TaskOptions{
+		Type:                taskTypeResync,
+		Params:              map[string]string{taskParamMirror: name},
+		CollapseWithRunning: true,
+		Restartable:         true,
+	}
+}
+
+func (b *Backend) runOrQueueTask(storage logical.Storage, queue bool, opts tasks_manager.TaskOptions, taskFunc func(context.Context, logical.Storage) error) (string, error) {
+	if queue {
+		return b.TasksManager.AddTask(context.Background(), storage, opts, taskFunc)
+	}
+
+	return b.TasksManager.RunTask(context.Background(), storage, opts, taskFunc)
+}
+
+// getGitCredential returns the git credential from the storage if the username and password are not passed.
+func getGitCredential(ctx context.Context, storage logical.Storage, username, password string) (string, string, error) {
+	if username != "" || password != "" {
+		return username, password, nil
+	}
+
+	gitCredentialFromStorage, err := trdlGit.GetGitCredential(ctx, storage)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get git credential from storage: %w", err)
+	}
+
+	if gitCredentialFromStorage == nil {
+		return "", "", nil
+	}
+
+	return gitCredentialFromStorage.Username, gitCredentialFromStorage.Password, nil
+}
+
+// setTaskTufVersions records the TUF repository metadata versions in the task, the failure does not fail the task.
+func (b *Backend) setTaskTufVersions(ctx context.Context, publisherRepository publisher.RepositoryInterface) {
+	versions, err := publisherRepository.GetVersions(ctx)
+	if err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to get TUF repository versions: %s", err))
+		return
+	}
+
+	tasks_manager.SetTaskTufVersions(ctx, versions)
+}
+
+// saveMirrorReplications saves the results of the replications to the mirrors, the failed replication does not fail the task.
+func (b *Backend) saveMirrorReplications(ctx context.Context, storage logical.Storage, publisherRepository publisher.RepositoryInterface) {
+	replications := publisherRepository.TakeMirrorReplications()
+	for _, replication := range replications {
+		if replication.Err != nil {
+			logboek.Context(ctx).Warn().LogF("Unable to replicate the repository to the mirror %q: %s\n", replication.Mirror, replication.Err)
+		}
+	}
+
+	if err := publisher.SaveMirrorReplications(ctx, storage, replications); err != nil {
+		b.Logger().Error(fmt.Sprintf("Unable to save mirror replications: %s", err))
+	}
+}
+
+// notifyTaskCompletedHook sends the events of the completed tasks to the notification webhooks.
+func (b *Backend) notifyTaskCompletedHook(notifier *notifications.Notifier) tasks_manager.TaskCompletedHook {
+	return func(ctx context.Context, storage logical.Storage, task *tasks_manager.Task, log []byte) {
+		event := notifications.EventTaskFailed
+		if task.Succeeded() {
+			event = notifications.EventTaskSucceeded
+		}
+
+		if err := notifier.NotifyTaskEvent(ctx, storage, notifications.TaskEvent{
+			Event:       event,
+			UUID:        task.UUID,
+			Type:        task.Type,
+			Params:      task.Params,
+			Status:      task.Status,
+			Reason:      task.Reason,
+			Commit:      task.Commit,
+			TufVersions: task.TufVersions,
+			Created:     task.Created,
+			Modified:    task.Modified,
+			LogTail:     notifications.LogTail(log),
+		}); err != nil {
+			b.Logger().Error(fmt.Sprintf("Unable to send notifications about the task %q: %s", task.UUID, err))
+		}
+	}
+}